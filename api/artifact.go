@@ -0,0 +1,102 @@
+// Copyright 2019 Grendel Authors. All rights reserved.
+//
+// This file is part of Grendel.
+//
+// Grendel is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Grendel is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with Grendel. If not, see <https://www.gnu.org/licenses/>.
+
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+	"github.com/ubccr/grendel/artifact"
+	"github.com/ubccr/grendel/model"
+)
+
+// BootImageArtifactAdd uploads a kernel, initrd, live image or rootfs to the
+// artifact store and sets it on the boot image, creating the image if it
+// doesn't exist. The request body is the raw file content.
+func (h *Handler) BootImageArtifactAdd(c echo.Context) error {
+	if h.Artifacts == nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "artifact store not configured")
+	}
+
+	name := c.Param("name")
+	kind := c.QueryParam("kind")
+	if !model.IsArtifactKind(kind) {
+		return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("invalid artifact kind %q", kind))
+	}
+
+	index := 0
+	if kind == model.ArtifactInitrd && c.QueryParam("index") != "" {
+		var err error
+		index, err = strconv.Atoi(c.QueryParam("index"))
+		if err != nil || index < 0 {
+			return echo.NewHTTPError(http.StatusBadRequest, "invalid initrd index").SetInternal(err)
+		}
+	}
+
+	log.Infof("Uploading %s artifact for image %s", kind, name)
+
+	a, err := h.Artifacts.Add(c.Request().Body, strings.ToLower(c.QueryParam("sha256")), func(a *model.Artifact, path string) error {
+		image, err := h.DB.LoadBootImage(name)
+		if errors.Is(err, model.ErrNotFound) {
+			image = &model.BootImage{Name: name}
+		} else if err != nil {
+			return err
+		}
+
+		a.Kind = kind
+		a.Name = c.QueryParam("filename")
+
+		if err := h.Artifacts.Attach(image, a, path, index); err != nil {
+			return err
+		}
+
+		return h.store(c).StoreBootImage(image)
+	})
+	if err != nil {
+		if errors.Is(err, artifact.ErrChecksumMismatch) || errors.Is(err, artifact.ErrInvalidDigest) || errors.Is(err, artifact.ErrInvalidIndex) {
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error()).SetInternal(err)
+		}
+
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to store artifact").SetInternal(err)
+	}
+
+	h.gcArtifacts()
+
+	return c.JSON(http.StatusCreated, a)
+}
+
+// gcArtifacts removes artifacts no longer referenced by any boot image
+func (h *Handler) gcArtifacts() {
+	if h.Artifacts == nil {
+		return
+	}
+
+	removed, err := h.Artifacts.GC(h.DB)
+	if err != nil {
+		log.Errorf("Failed to garbage collect artifacts: %v", err)
+		return
+	}
+
+	if len(removed) > 0 {
+		log.Infof("Removed %d unreferenced artifacts", len(removed))
+	}
+}
@@ -0,0 +1,97 @@
+// Copyright 2019 Grendel Authors. All rights reserved.
+//
+// This file is part of Grendel.
+//
+// Grendel is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Grendel is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with Grendel. If not, see <https://www.gnu.org/licenses/>.
+
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/tidwall/gjson"
+	"github.com/ubccr/grendel/artifact"
+)
+
+func TestBootImageArtifactAdd(t *testing.T) {
+	assert := assert.New(t)
+
+	store, err := artifact.NewStore(t.TempDir())
+	if !assert.NoError(err) {
+		return
+	}
+
+	h := &Handler{DB: newTestDB(t), Artifacts: store}
+
+	upload := func(query, body string) (*httptest.ResponseRecorder, error) {
+		e := newEcho()
+		req := httptest.NewRequest(http.MethodPost, "/bootimage/compute/artifact?"+query, strings.NewReader(body))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEOctetStream)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+		c.SetPath("/bootimage/:name/artifact")
+		c.SetParamNames("name")
+		c.SetParamValues("compute")
+
+		return rec, h.BootImageArtifactAdd(c)
+	}
+
+	rec, err := upload("kind=kernel&filename=vmlinuz", "kernel")
+	if assert.NoError(err) {
+		assert.Equal(http.StatusCreated, rec.Code)
+		assert.Equal(int64(6), gjson.Get(rec.Body.String(), "size").Int())
+	}
+
+	for _, initrd := range []string{"initrd0", "initrd1"} {
+		_, err = upload("kind=initrd&index="+initrd[6:], initrd)
+		assert.NoError(err)
+	}
+
+	image, err := h.DB.LoadBootImage("compute")
+	if assert.NoError(err) {
+		assert.Equal(2, len(image.InitrdPaths))
+		assert.Equal(3, len(image.Artifacts))
+		assert.Equal("vmlinuz", image.Artifacts[0].Name)
+		assert.Equal(store.Path(image.Artifacts[0].SHA256), image.KernelPath)
+	}
+	oldKernel := image.KernelPath
+
+	for _, query := range []string{"kind=bogus", "kind=initrd&index=5", "kind=kernel&sha256=" + strings.Repeat("0", 64)} {
+		_, err = upload(query, "new kernel")
+		if assert.Errorf(err, "no error for %s", query) {
+			he, ok := err.(*echo.HTTPError)
+			if assert.True(ok) {
+				assert.Equalf(http.StatusBadRequest, he.Code, "bad code for %s", query)
+			}
+		}
+	}
+
+	// Replaced artifacts are garbage collected
+	_, err = upload("kind=kernel", "new kernel")
+	assert.NoError(err)
+
+	image, err = h.DB.LoadBootImage("compute")
+	if assert.NoError(err) {
+		assert.Equal(3, len(image.Artifacts))
+		assert.NotEqual(oldKernel, image.KernelPath)
+	}
+	_, err = os.Stat(oldKernel)
+	assert.True(os.IsNotExist(err))
+}
@@ -0,0 +1,91 @@
+// Copyright 2019 Grendel Authors. All rights reserved.
+//
+// This file is part of Grendel.
+//
+// Grendel is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Grendel is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with Grendel. If not, see <https://www.gnu.org/licenses/>.
+
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/ubccr/grendel/model"
+	"github.com/ubccr/grendel/nodeset"
+)
+
+func (h *Handler) AuditList(c echo.Context) error {
+	filter := &model.AuditFilter{
+		Kind:      c.QueryParam("kind"),
+		Operation: c.QueryParam("operation"),
+		Caller:    c.QueryParam("caller"),
+	}
+
+	if nodesetString := c.QueryParam("nodeset"); nodesetString != "" {
+		ns, err := nodeset.NewNodeSet(nodesetString)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, "invalid nodeset").SetInternal(err)
+		}
+		filter.NodeSet = ns
+	}
+
+	for param, t := range map[string]*time.Time{"since": &filter.Since, "until": &filter.Until} {
+		val := c.QueryParam(param)
+		if val == "" {
+			continue
+		}
+
+		ts, err := time.Parse(time.RFC3339, val)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, "invalid "+param+" timestamp, must be RFC3339").SetInternal(err)
+		}
+		*t = ts
+	}
+
+	if limit := c.QueryParam("limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil || n < 0 {
+			return echo.NewHTTPError(http.StatusBadRequest, "invalid limit").SetInternal(err)
+		}
+		filter.Limit = n
+	}
+
+	entries, err := h.DB.AuditEntries(filter)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to fetch audit log").SetInternal(err)
+	}
+
+	return c.JSON(http.StatusOK, entries)
+}
+
+func (h *Handler) AuditFind(c echo.Context) error {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid audit entry id").SetInternal(err)
+	}
+
+	entry, err := h.DB.LoadAuditEntry(id)
+	if err != nil {
+		if errors.Is(err, model.ErrNotFound) {
+			return echo.NewHTTPError(http.StatusNotFound, "audit entry not found").SetInternal(err)
+		}
+
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to fetch audit entry").SetInternal(err)
+	}
+
+	return c.JSON(http.StatusOK, entry)
+}
@@ -0,0 +1,90 @@
+// Copyright 2019 Grendel Authors. All rights reserved.
+//
+// This file is part of Grendel.
+//
+// Grendel is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Grendel is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with Grendel. If not, see <https://www.gnu.org/licenses/>.
+
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/tidwall/gjson"
+	"github.com/ubccr/grendel/internal/tests"
+)
+
+func TestAuditList(t *testing.T) {
+	assert := assert.New(t)
+
+	h := &Handler{DB: newTestDB(t)}
+	e := newEcho()
+	h.SetupRoutes(e, SocketAuth)
+
+	req := httptest.NewRequest(http.MethodPost, "/v1/host", strings.NewReader("["+string(tests.TestHostJSON)+"]"))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	assert.Equal(http.StatusCreated, rec.Code)
+
+	req = httptest.NewRequest(http.MethodPut, "/v1/host/tag/tux01?tags=k16", nil)
+	rec = httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	assert.Equal(http.StatusOK, rec.Code)
+
+	req = httptest.NewRequest(http.MethodGet, "/v1/audit?nodeset=tux01&operation=host.tag", nil)
+	rec = httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	if assert.Equal(http.StatusOK, rec.Code) {
+		res := gjson.Parse(rec.Body.String())
+		assert.Equal(int64(1), res.Get("#").Int())
+		assert.Equal("unix-socket", res.Get("0.caller").String())
+		assert.Equal("tags.0", res.Get("0.changes.0.diff.0.path").String())
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/v1/audit/1", nil)
+	rec = httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	if assert.Equal(http.StatusOK, rec.Code) {
+		assert.Equal("host.store", gjson.Get(rec.Body.String(), "operation").String())
+	}
+
+	req = httptest.NewRequest(http.MethodPut, "/v1/host/revert/tux01?revision=1", nil)
+	rec = httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	if assert.Equal(http.StatusOK, rec.Code) {
+		assert.Equal(int64(0), gjson.Get(rec.Body.String(), "tags.#").Int())
+	}
+
+	for _, path := range []string{"/v1/audit?since=yesterday", "/v1/audit?limit=-1", "/v1/audit/abc", "/v1/host/revert/tux01?revision=abc"} {
+		method := http.MethodGet
+		if strings.Contains(path, "revert") {
+			method = http.MethodPut
+		}
+
+		req = httptest.NewRequest(method, path, nil)
+		rec = httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		assert.Equal(http.StatusBadRequest, rec.Code, path)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/v1/audit/100", nil)
+	rec = httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	assert.Equal(http.StatusNotFound, rec.Code)
+}
@@ -0,0 +1,192 @@
+// Copyright 2019 Grendel Authors. All rights reserved.
+//
+// This file is part of Grendel.
+//
+// Grendel is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Grendel is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with Grendel. If not, see <https://www.gnu.org/licenses/>.
+
+package api
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/hako/branca"
+	"github.com/labstack/echo/v4"
+	"github.com/segmentio/ksuid"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+	"github.com/ubccr/grendel/logger"
+)
+
+// Role defines the level of access granted by an API token
+type Role string
+
+const (
+	// RoleRead allows read-only access to the API
+	RoleRead Role = "read"
+
+	// RoleAdmin allows full access to the API
+	RoleAdmin Role = "admin"
+)
+
+var (
+	// ErrTokenExpired is returned when parsing an API token past its expiry
+	ErrTokenExpired = errors.New("token expired")
+
+	// socketClaims are used for requests over the unix domain socket, which
+	// is protected by file system permissions
+	socketClaims = &APIClaims{Name: "unix-socket", Role: RoleAdmin}
+)
+
+// APIClaims are the claims encoded in a signed API token
+type APIClaims struct {
+	ID      string `json:"id"`
+	Name    string `json:"name"`
+	Role    Role   `json:"role"`
+	Expires int64  `json:"exp,omitempty"`
+}
+
+// ParseRole returns the Role with the given name
+func ParseRole(name string) (Role, error) {
+	switch Role(strings.ToLower(name)) {
+	case RoleRead:
+		return RoleRead, nil
+	case RoleAdmin:
+		return RoleAdmin, nil
+	}
+
+	return "", fmt.Errorf("invalid role: %s", name)
+}
+
+// CanWrite returns true if the role is allowed to modify data
+func (r Role) CanWrite() bool {
+	return r == RoleAdmin
+}
+
+// newBranca returns a branca instance keyed with a 32 byte key derived from
+// the api.secret config option
+func newBranca() *branca.Branca {
+	key := sha256.Sum256([]byte(viper.GetString("api.secret")))
+	return branca.NewBranca(string(key[:]))
+}
+
+// NewAPIToken returns a signed API token for the given name and role. If ttl
+// is 0 the token never expires.
+func NewAPIToken(name string, role Role, ttl time.Duration) (string, error) {
+	if _, err := ParseRole(string(role)); err != nil {
+		return "", err
+	}
+
+	id, err := ksuid.NewRandom()
+	if err != nil {
+		return "", err
+	}
+
+	claims := &APIClaims{
+		ID:   id.String(),
+		Name: name,
+		Role: role,
+	}
+
+	if ttl > 0 {
+		claims.Expires = time.Now().Add(ttl).Unix()
+	}
+
+	jsonBytes, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+
+	return newBranca().EncodeToString(string(jsonBytes))
+}
+
+// ParseAPIToken verifies the signature of the given API token and returns
+// its claims
+func ParseAPIToken(token string) (*APIClaims, error) {
+	message, err := newBranca().DecodeToString(token)
+	if err != nil {
+		return nil, err
+	}
+
+	var claims APIClaims
+	err = json.Unmarshal([]byte(message), &claims)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := ParseRole(string(claims.Role)); err != nil {
+		return nil, err
+	}
+
+	if claims.Expires != 0 && time.Now().Unix() > claims.Expires {
+		return nil, ErrTokenExpired
+	}
+
+	return &claims, nil
+}
+
+// AuthRequired verifies the bearer token sent in the Authorization header and
+// ensures read-only tokens are only used with safe HTTP methods
+func AuthRequired(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		auth := c.Request().Header.Get(echo.HeaderAuthorization)
+		if !strings.HasPrefix(auth, "Bearer ") {
+			return echo.NewHTTPError(http.StatusUnauthorized, "missing api token")
+		}
+
+		claims, err := ParseAPIToken(strings.TrimSpace(strings.TrimPrefix(auth, "Bearer ")))
+		if err != nil {
+			return echo.NewHTTPError(http.StatusUnauthorized, "invalid api token").SetInternal(err)
+		}
+
+		method := c.Request().Method
+		if method != http.MethodGet && method != http.MethodHead && !claims.Role.CanWrite() {
+			log.WithFields(logrus.Fields{
+				logger.FieldTokenID:   claims.ID,
+				logger.FieldTokenName: claims.Name,
+				"role":                claims.Role,
+				"path":                c.Request().URL,
+			}).Warn("API token not authorized to modify data")
+			return echo.NewHTTPError(http.StatusForbidden, "insufficient privileges")
+		}
+
+		c.Set(ContextKeyJWT, claims)
+
+		return next(c)
+	}
+}
+
+// SocketAuth grants admin access to requests received over the unix domain
+// socket
+func SocketAuth(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		c.Set(ContextKeyJWT, socketClaims)
+		return next(c)
+	}
+}
+
+// Claims returns the API claims for the current request, if any
+func Claims(c echo.Context) *APIClaims {
+	claims, ok := c.Get(ContextKeyJWT).(*APIClaims)
+	if !ok {
+		return nil
+	}
+
+	return claims
+}
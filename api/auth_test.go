@@ -0,0 +1,144 @@
+// Copyright 2019 Grendel Authors. All rights reserved.
+//
+// This file is part of Grendel.
+//
+// Grendel is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Grendel is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with Grendel. If not, see <https://www.gnu.org/licenses/>.
+
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/ubccr/grendel/internal/tests"
+	"github.com/ubccr/grendel/model"
+)
+
+func TestAPIToken(t *testing.T) {
+	assert := assert.New(t)
+
+	token, err := NewAPIToken("tux", RoleAdmin, 0)
+	assert.NoError(err)
+
+	claims, err := ParseAPIToken(token)
+	if assert.NoError(err) {
+		assert.Equal("tux", claims.Name)
+		assert.Equal(RoleAdmin, claims.Role)
+		assert.NotEmpty(claims.ID)
+	}
+
+	_, err = NewAPIToken("tux", Role("root"), 0)
+	assert.Error(err)
+
+	token, err = NewAPIToken("tux", RoleRead, -1*time.Second)
+	assert.NoError(err)
+	_, err = ParseAPIToken(token)
+	assert.NoError(err)
+
+	token, err = NewAPIToken("tux", RoleRead, time.Second)
+	assert.NoError(err)
+	badToken := []byte(token)
+	badToken[2] = 'a'
+	_, err = ParseAPIToken(string(badToken))
+	assert.Error(err)
+}
+
+func TestAPITokenExpired(t *testing.T) {
+	assert := assert.New(t)
+
+	claims := &APIClaims{Name: "tux", Role: RoleRead, Expires: time.Now().Add(-1 * time.Minute).Unix()}
+	token, err := newTestToken(claims)
+	assert.NoError(err)
+
+	_, err = ParseAPIToken(token)
+	if assert.Error(err) {
+		assert.True(errors.Is(err, ErrTokenExpired))
+	}
+}
+
+func TestAuthRequired(t *testing.T) {
+	assert := assert.New(t)
+
+	h := &Handler{DB: newTestDB(t)}
+	e := newEcho()
+	h.SetupRoutes(e, AuthRequired)
+
+	readToken, err := NewAPIToken("reader", RoleRead, time.Hour)
+	assert.NoError(err)
+	adminToken, err := NewAPIToken("admin", RoleAdmin, time.Hour)
+	assert.NoError(err)
+
+	addHostJSON := "[" + string(tests.TestHostJSON) + "]"
+
+	for _, test := range []struct {
+		method string
+		path   string
+		body   string
+		token  string
+		code   int
+	}{
+		{http.MethodGet, "/", "", "", http.StatusOK},
+		{http.MethodGet, "/v1/host/list", "", "", http.StatusUnauthorized},
+		{http.MethodGet, "/v1/host/list", "", "bad token", http.StatusUnauthorized},
+		{http.MethodGet, "/v1/host/list", "", readToken, http.StatusOK},
+		{http.MethodPost, "/v1/host", addHostJSON, readToken, http.StatusForbidden},
+		{http.MethodDelete, "/v1/host/find/tux01", "", readToken, http.StatusForbidden},
+		{http.MethodPost, "/v1/host", addHostJSON, adminToken, http.StatusCreated},
+		{http.MethodDelete, "/v1/host/find/tux01", "", adminToken, http.StatusOK},
+	} {
+		req := httptest.NewRequest(test.method, test.path, strings.NewReader(test.body))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		if test.token != "" {
+			req.Header.Set(echo.HeaderAuthorization, "Bearer "+test.token)
+		}
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+
+		assert.Equal(test.code, rec.Code, "%s %s", test.method, test.path)
+	}
+}
+
+func TestSocketAuth(t *testing.T) {
+	assert := assert.New(t)
+
+	h := &Handler{DB: newTestDB(t)}
+	e := newEcho()
+	h.SetupRoutes(e, SocketAuth)
+
+	host := tests.HostFactory.MustCreate().(*model.Host)
+	host.Name = "tux01"
+	assert.NoError(h.DB.StoreHost(host))
+
+	req := httptest.NewRequest(http.MethodDelete, "/v1/host/find/tux01", nil)
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+
+	assert.Equal(http.StatusOK, rec.Code)
+}
+
+func newTestToken(claims *APIClaims) (string, error) {
+	jsonBytes, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+
+	return newBranca().EncodeToString(string(jsonBytes))
+}
@@ -19,6 +19,7 @@ package api
 
 import (
 	"errors"
+	"fmt"
 	"net/http"
 	"strings"
 
@@ -44,14 +45,27 @@ func (h *Handler) BootImageAdd(c echo.Context) error {
 		if err != nil {
 			return echo.NewHTTPError(http.StatusBadRequest, "invalid data").SetInternal(err)
 		}
+
+		if !image.Firmware.IsNil() && !image.Firmware.Available() {
+			return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("unknown firmware build %s for image %s", image.Firmware, image.Name))
+		}
+
+		for _, src := range image.Sources {
+			if err := src.Validate(); err != nil {
+				return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("invalid source for image %s: %s", image.Name, err)).SetInternal(err)
+			}
+		}
+
+		image.ResetStatus()
 	}
 
-	err := h.DB.StoreBootImages(images)
+	err := h.store(c).StoreBootImages(images)
 	if err != nil {
 		return echo.NewHTTPError(http.StatusInternalServerError, "failed to save boot images").SetInternal(err)
 	}
 
 	log.Infof("Stored %d images successfully", len(images))
+	h.gcArtifacts()
 
 	res := map[string]interface{}{
 		"images": len(images),
@@ -88,11 +102,13 @@ func (h *Handler) BootImageDelete(c echo.Context) error {
 	name := c.Param("name")
 
 	// TODO add support for deleting more than one image
-	err := h.DB.DeleteBootImages([]string{name})
+	err := h.store(c).DeleteBootImages([]string{name})
 	if err != nil {
 		return echo.NewHTTPError(http.StatusInternalServerError, "failed to delete image").SetInternal(err)
 	}
 
+	h.gcArtifacts()
+
 	res := map[string]interface{}{
 		"images": 1,
 	}
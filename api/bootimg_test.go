@@ -35,7 +35,7 @@ import (
 func TestBootImageAdd(t *testing.T) {
 	assert := assert.New(t)
 
-	h := &Handler{newTestDB(t)}
+	h := &Handler{DB: newTestDB(t)}
 
 	e := newEcho()
 
@@ -56,7 +56,7 @@ func TestBootImageAdd(t *testing.T) {
 func TestBootImageAddWrongContentType(t *testing.T) {
 	assert := assert.New(t)
 
-	h := &Handler{newTestDB(t)}
+	h := &Handler{DB: newTestDB(t)}
 
 	e := newEcho()
 
@@ -94,7 +94,7 @@ func TestBootImageAddInvalid(t *testing.T) {
 	// Test single boot image (needs to be a list)
 	badData[3] = string(tests.TestBootImageJSON)
 
-	h := &Handler{newTestDB(t)}
+	h := &Handler{DB: newTestDB(t)}
 
 	e := newEcho()
 
@@ -120,7 +120,7 @@ func TestBootImageAddInvalid(t *testing.T) {
 func TestBootImageList(t *testing.T) {
 	assert := assert.New(t)
 
-	h := &Handler{newTestDB(t)}
+	h := &Handler{DB: newTestDB(t)}
 
 	size := 10
 	for i := 0; i < size; i++ {
@@ -145,7 +145,7 @@ func TestBootImageList(t *testing.T) {
 func TestBootImageFind(t *testing.T) {
 	assert := assert.New(t)
 
-	h := &Handler{newTestDB(t)}
+	h := &Handler{DB: newTestDB(t)}
 
 	size := 20
 	for i := 0; i < size; i++ {
@@ -174,7 +174,7 @@ func TestBootImageFind(t *testing.T) {
 func TestBootImageFindNone(t *testing.T) {
 	assert := assert.New(t)
 
-	h := &Handler{newTestDB(t)}
+	h := &Handler{DB: newTestDB(t)}
 
 	size := 20
 	for i := 0; i < size; i++ {
@@ -208,7 +208,7 @@ func TestBootImageFindNone(t *testing.T) {
 func TestBootImageDelete(t *testing.T) {
 	assert := assert.New(t)
 
-	h := &Handler{newTestDB(t)}
+	h := &Handler{DB: newTestDB(t)}
 
 	size := 20
 	for i := 0; i < size; i++ {
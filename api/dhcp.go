@@ -0,0 +1,55 @@
+// Copyright 2019 Grendel Authors. All rights reserved.
+//
+// This file is part of Grendel.
+//
+// Grendel is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Grendel is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with Grendel. If not, see <https://www.gnu.org/licenses/>.
+
+package api
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/ubccr/grendel/model"
+)
+
+func (h *Handler) DHCPLeases(c echo.Context) error {
+	onlyActive := false
+	if active := c.QueryParam("active"); active != "" {
+		var err error
+		onlyActive, err = strconv.ParseBool(active)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, "invalid active value").SetInternal(err)
+		}
+	}
+
+	leases, err := h.DB.Leases()
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to fetch dhcp leases").SetInternal(err)
+	}
+
+	now := time.Now()
+	res := make(model.LeaseList, 0, len(leases))
+	for _, lease := range leases {
+		if onlyActive && lease.Expired(now) {
+			continue
+		}
+
+		res = append(res, lease)
+	}
+
+	return c.JSON(http.StatusOK, res)
+}
@@ -0,0 +1,77 @@
+// Copyright 2019 Grendel Authors. All rights reserved.
+//
+// This file is part of Grendel.
+//
+// Grendel is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Grendel is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with Grendel. If not, see <https://www.gnu.org/licenses/>.
+
+package api
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tidwall/gjson"
+	"github.com/ubccr/grendel/model"
+)
+
+func TestDHCPLeases(t *testing.T) {
+	assert := assert.New(t)
+
+	db := newTestDB(t)
+	h := &Handler{DB: db}
+	e := newEcho()
+	h.SetupRoutes(e, SocketAuth)
+
+	now := time.Now()
+	ips := []string{"10.17.40.100", "10.17.40.101"}
+	for i, mac := range []string{"02:00:00:00:00:01", "02:00:00:00:00:02"} {
+		hwaddr, _ := net.ParseMAC(mac)
+		lease := &model.Lease{
+			MAC:     hwaddr,
+			IP:      netip.MustParseAddr(ips[i]),
+			Started: now.Add(-2 * time.Hour),
+			Expires: now.Add(time.Duration(1-2*i) * time.Hour),
+		}
+		assert.NoError(db.StoreLease(lease))
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/v1/dhcp/leases", nil)
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	if assert.Equal(http.StatusOK, rec.Code) {
+		res := gjson.Parse(rec.Body.String())
+		assert.Equal(int64(2), res.Get("#").Int())
+		assert.Equal("02:00:00:00:00:01", res.Get("0.mac").String())
+		assert.Equal("10.17.40.100", res.Get("0.ip").String())
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/v1/dhcp/leases?active=true", nil)
+	rec = httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	if assert.Equal(http.StatusOK, rec.Code) {
+		res := gjson.Parse(rec.Body.String())
+		assert.Equal(int64(1), res.Get("#").Int())
+		assert.Equal("10.17.40.100", res.Get("0.ip").String())
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/v1/dhcp/leases?active=maybe", nil)
+	rec = httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	assert.Equal(http.StatusBadRequest, rec.Code)
+}
@@ -0,0 +1,104 @@
+// Copyright 2019 Grendel Authors. All rights reserved.
+//
+// This file is part of Grendel.
+//
+// Grendel is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Grendel is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with Grendel. If not, see <https://www.gnu.org/licenses/>.
+
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/ubccr/grendel/events"
+	"github.com/ubccr/grendel/nodeset"
+)
+
+// keepAliveInterval is how often a comment is sent on idle event streams so
+// proxies and clients don't time out the connection
+var keepAliveInterval = 30 * time.Second
+
+// Events streams provisioning events to the client as server-sent events
+func (h *Handler) Events(c echo.Context) error {
+	filter := &events.Filter{}
+
+	if nodesetString := c.QueryParam("nodeset"); nodesetString != "" {
+		ns, err := nodeset.NewNodeSet(nodesetString)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, "invalid nodeset").SetInternal(err)
+		}
+		filter.NodeSet = ns
+	}
+
+	if tags := c.QueryParam("tags"); tags != "" {
+		filter.Tags = strings.Split(tags, ",")
+	}
+
+	if types := c.QueryParam("type"); types != "" {
+		filter.Types = strings.Split(types, ",")
+	}
+
+	sub := events.Subscribe(filter, 0)
+	defer sub.Close()
+
+	// Event streams are long lived so disable the server write timeout for
+	// this request
+	rc := http.NewResponseController(c.Response().Writer)
+	if err := rc.SetWriteDeadline(time.Time{}); err != nil {
+		log.Debugf("Failed to clear write deadline for event stream: %v", err)
+	}
+
+	res := c.Response()
+	res.Header().Set(echo.HeaderContentType, "text/event-stream")
+	res.Header().Set("Cache-Control", "no-cache")
+	res.Header().Set("Connection", "keep-alive")
+	res.Header().Set("X-Accel-Buffering", "no")
+	res.WriteHeader(http.StatusOK)
+	res.Flush()
+
+	ticker := time.NewTicker(keepAliveInterval)
+	defer ticker.Stop()
+
+	ctx := c.Request().Context()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			if _, err := fmt.Fprint(res, ": keepalive\n\n"); err != nil {
+				return nil
+			}
+			res.Flush()
+		case e, ok := <-sub.C:
+			if !ok {
+				return nil
+			}
+
+			data, err := json.Marshal(e)
+			if err != nil {
+				log.Errorf("Failed to encode event: %v", err)
+				continue
+			}
+
+			if _, err := fmt.Fprintf(res, "id: %d\nevent: %s\ndata: %s\n\n", e.ID, e.Type, data); err != nil {
+				return nil
+			}
+			res.Flush()
+		}
+	}
+}
@@ -0,0 +1,85 @@
+// Copyright 2019 Grendel Authors. All rights reserved.
+//
+// This file is part of Grendel.
+//
+// Grendel is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Grendel is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with Grendel. If not, see <https://www.gnu.org/licenses/>.
+
+package api
+
+import (
+	"bufio"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/tidwall/gjson"
+	"github.com/ubccr/grendel/events"
+	"github.com/ubccr/grendel/internal/tests"
+)
+
+func TestEvents(t *testing.T) {
+	assert := assert.New(t)
+
+	h := &Handler{DB: newTestDB(t)}
+	e := newEcho()
+	h.SetupRoutes(e, SocketAuth)
+	srv := httptest.NewServer(e)
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL+"/v1/events?nodeset=tux01&type=host", nil)
+	if !assert.NoError(err) {
+		return
+	}
+	res, err := http.DefaultClient.Do(req)
+	if !assert.NoError(err) {
+		return
+	}
+	defer res.Body.Close()
+	assert.Equal("text/event-stream", res.Header.Get(echo.HeaderContentType))
+
+	events.Publish(&events.Event{Service: "dhcp", Type: events.TypeDHCPOffer, Host: "tux01"})
+	events.Publish(&events.Event{Service: "db", Type: events.TypeHostChanged, Host: "tux02"})
+
+	rec := httptest.NewRecorder()
+	hreq := httptest.NewRequest(http.MethodPost, "/v1/host", strings.NewReader("["+string(tests.TestHostJSON)+"]"))
+	hreq.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	e.ServeHTTP(rec, hreq)
+	assert.Equal(http.StatusCreated, rec.Code)
+
+	scanner := bufio.NewScanner(res.Body)
+	var data string
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.HasPrefix(line, "data: ") {
+			data = strings.TrimPrefix(line, "data: ")
+			break
+		}
+	}
+
+	assert.Equal("tux01", gjson.Get(data, "host").String())
+	assert.Equal(events.TypeHostChanged, gjson.Get(data, "type").String())
+	assert.Equal("host.store by unix-socket", gjson.Get(data, "message").String())
+
+	rec = httptest.NewRecorder()
+	e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/v1/events?nodeset=tux[", nil))
+	assert.Equal(http.StatusBadRequest, rec.Code)
+}
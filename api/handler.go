@@ -21,11 +21,16 @@ import (
 	"net/http"
 
 	"github.com/labstack/echo/v4"
+	"github.com/ubccr/grendel/artifact"
 	"github.com/ubccr/grendel/model"
 )
 
 type Handler struct {
 	DB model.DataStore
+
+	// Artifacts is the store for uploaded boot image files. Uploads are
+	// disabled if nil.
+	Artifacts *artifact.Store
 }
 
 func NewHandler(db model.DataStore) (*Handler, error) {
@@ -36,10 +41,24 @@ func NewHandler(db model.DataStore) (*Handler, error) {
 	return h, nil
 }
 
-func (h *Handler) SetupRoutes(e *echo.Echo) {
+// store returns a DataStore which records mutations in the audit log on
+// behalf of the API caller
+func (h *Handler) store(c echo.Context) *model.AuditedStore {
+	caller, tokenID := "anonymous", ""
+	if claims := Claims(c); claims != nil {
+		caller = claims.Name
+		tokenID = claims.ID
+	}
+
+	return model.NewAuditedStore(h.DB, caller, tokenID)
+}
+
+// SetupRoutes registers the API routes. The given middleware is applied to
+// all versioned API routes.
+func (h *Handler) SetupRoutes(e *echo.Echo, m ...echo.MiddlewareFunc) {
 	e.GET("/", h.Index).Name = "index"
 
-	v1 := e.Group("/v1/")
+	v1 := e.Group("/v1/", m...)
 	v1.POST("host", h.HostAdd)
 	v1.GET("host/list", h.HostList)
 	v1.GET("host/find/*", h.HostFind)
@@ -49,11 +68,24 @@ func (h *Handler) SetupRoutes(e *echo.Echo) {
 	v1.PUT("host/untag/*", h.HostUntag)
 	v1.PUT("host/provision/*", h.HostProvision)
 	v1.PUT("host/unprovision/*", h.HostUnprovision)
+	v1.PUT("host/revert/:name", h.HostRevert)
 
 	v1.POST("bootimage", h.BootImageAdd)
+	v1.POST("bootimage/:name/artifact", h.BootImageArtifactAdd)
 	v1.GET("bootimage/find/:name", h.BootImageFind)
 	v1.DELETE("bootimage/find/:name", h.BootImageDelete)
 	v1.GET("bootimage/list", h.BootImageList)
+
+	v1.GET("audit", h.AuditList)
+	v1.GET("audit/:id", h.AuditFind)
+
+	v1.GET("provision/status", h.ProvisionStatus)
+	v1.GET("events", h.Events)
+
+	v1.GET("dhcp/leases", h.DHCPLeases)
+	v1.GET("unregistered", h.UnregisteredList)
+	v1.DELETE("unregistered/:mac", h.UnregisteredDelete)
+	v1.POST("unregistered/promote", h.UnregisteredPromote)
 }
 
 func (h *Handler) Index(c echo.Context) error {
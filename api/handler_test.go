@@ -39,7 +39,7 @@ func newTestDB(t *testing.T) model.DataStore {
 func TestStatus(t *testing.T) {
 	assert := assert.New(t)
 
-	h := &Handler{newTestDB(t)}
+	h := &Handler{DB: newTestDB(t)}
 
 	e := newEcho()
 	req := httptest.NewRequest(http.MethodGet, "/", nil)
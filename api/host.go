@@ -19,6 +19,7 @@ package api
 
 import (
 	"errors"
+	"fmt"
 	"net/http"
 	"path"
 	"strconv"
@@ -47,10 +48,18 @@ func (h *Handler) HostAdd(c echo.Context) error {
 		if err != nil {
 			return echo.NewHTTPError(http.StatusBadRequest, "invalid data").SetInternal(err)
 		}
+
+		if !host.Firmware.IsNil() && !host.Firmware.Available() {
+			return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("unknown firmware build %s for host %s", host.Firmware, host.Name))
+		}
 	}
 
-	err := h.DB.StoreHosts(hosts)
+	err := h.store(c).StoreHosts(hosts)
 	if err != nil {
+		if errors.Is(err, model.ErrInvalidData) || errors.Is(err, model.ErrDuplicateEntry) {
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error()).SetInternal(err)
+		}
+
 		return echo.NewHTTPError(http.StatusInternalServerError, "failed to save hosts").SetInternal(err)
 	}
 
@@ -97,7 +106,7 @@ func (h *Handler) HostDelete(c echo.Context) error {
 
 	log.Infof("Got nodeset to delete: %s", nodeset.String())
 
-	err = h.DB.DeleteHosts(nodeset)
+	err = h.store(c).DeleteHosts(nodeset)
 	if err != nil {
 		return echo.NewHTTPError(http.StatusInternalServerError, "failed to delete hosts").SetInternal(err)
 	}
@@ -147,7 +156,7 @@ func (h *Handler) hostSetProvision(c echo.Context, provision bool) error {
 
 	log.Infof("Got nodeset: %s", nodeset.String())
 
-	err = h.DB.ProvisionHosts(nodeset, provision)
+	err = h.store(c).ProvisionHosts(nodeset, provision)
 	if err != nil {
 		if errors.Is(err, model.ErrNotFound) {
 			return echo.NewHTTPError(http.StatusBadRequest, "No hosts found in nodeset").SetInternal(err)
@@ -195,9 +204,9 @@ func (h *Handler) hostSetTags(c echo.Context, remove bool) error {
 	log.Infof("Got nodeset: %s", nodeset.String())
 
 	if remove {
-		err = h.DB.UntagHosts(nodeset, tags)
+		err = h.store(c).UntagHosts(nodeset, tags)
 	} else {
-		err = h.DB.TagHosts(nodeset, tags)
+		err = h.store(c).TagHosts(nodeset, tags)
 	}
 
 	if err != nil {
@@ -231,3 +240,30 @@ func (h *Handler) HostTag(c echo.Context) error {
 func (h *Handler) HostUntag(c echo.Context) error {
 	return h.hostSetTags(c, true)
 }
+
+func (h *Handler) HostRevert(c echo.Context) error {
+	name := c.Param("name")
+
+	revision, err := strconv.ParseInt(c.QueryParam("revision"), 10, 64)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid revision").SetInternal(err)
+	}
+
+	before, _ := strconv.ParseBool(c.QueryParam("before"))
+
+	host, err := h.store(c).RevertHost(name, revision, before)
+	if err != nil {
+		if errors.Is(err, model.ErrNotFound) {
+			return echo.NewHTTPError(http.StatusNotFound, "host not found in revision").SetInternal(err)
+		}
+		if errors.Is(err, model.ErrInvalidData) {
+			return echo.NewHTTPError(http.StatusBadRequest, "host did not exist at revision").SetInternal(err)
+		}
+
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to revert host").SetInternal(err)
+	}
+
+	log.Infof("Reverted host %s to revision %d", host.Name, revision)
+
+	return c.JSON(http.StatusOK, host)
+}
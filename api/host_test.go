@@ -35,7 +35,7 @@ import (
 func TestHostAdd(t *testing.T) {
 	assert := assert.New(t)
 
-	h := &Handler{newTestDB(t)}
+	h := &Handler{DB: newTestDB(t)}
 
 	e := newEcho()
 
@@ -56,7 +56,7 @@ func TestHostAdd(t *testing.T) {
 func TestHostAddWrongContentType(t *testing.T) {
 	assert := assert.New(t)
 
-	h := &Handler{newTestDB(t)}
+	h := &Handler{DB: newTestDB(t)}
 
 	e := newEcho()
 
@@ -94,7 +94,7 @@ func TestHostAddInvalid(t *testing.T) {
 	// Test single host (needs to be a list)
 	badData[3] = string(tests.TestHostJSON)
 
-	h := &Handler{newTestDB(t)}
+	h := &Handler{DB: newTestDB(t)}
 
 	e := newEcho()
 
@@ -120,7 +120,7 @@ func TestHostAddInvalid(t *testing.T) {
 func TestHostList(t *testing.T) {
 	assert := assert.New(t)
 
-	h := &Handler{newTestDB(t)}
+	h := &Handler{DB: newTestDB(t)}
 
 	size := 10
 	for i := 0; i < size; i++ {
@@ -145,7 +145,7 @@ func TestHostList(t *testing.T) {
 func TestHostFind(t *testing.T) {
 	assert := assert.New(t)
 
-	h := &Handler{newTestDB(t)}
+	h := &Handler{DB: newTestDB(t)}
 
 	size := 20
 	for i := 0; i < size; i++ {
@@ -171,7 +171,7 @@ func TestHostFind(t *testing.T) {
 func TestHostFindByTags(t *testing.T) {
 	assert := assert.New(t)
 
-	h := &Handler{newTestDB(t)}
+	h := &Handler{DB: newTestDB(t)}
 
 	size := 10
 	for i := 0; i < size; i++ {
@@ -226,7 +226,7 @@ func TestHostFindByTags(t *testing.T) {
 func TestHostFindNone(t *testing.T) {
 	assert := assert.New(t)
 
-	h := &Handler{newTestDB(t)}
+	h := &Handler{DB: newTestDB(t)}
 
 	size := 20
 	for i := 0; i < size; i++ {
@@ -252,7 +252,7 @@ func TestHostFindNone(t *testing.T) {
 func TestHostFindInvalidNodeSet(t *testing.T) {
 	assert := assert.New(t)
 
-	h := &Handler{newTestDB(t)}
+	h := &Handler{DB: newTestDB(t)}
 
 	e := newEcho()
 
@@ -276,7 +276,7 @@ func TestHostFindInvalidNodeSet(t *testing.T) {
 func TestHostProvision(t *testing.T) {
 	assert := assert.New(t)
 
-	h := &Handler{newTestDB(t)}
+	h := &Handler{DB: newTestDB(t)}
 
 	size := 20
 	for i := 0; i < size; i++ {
@@ -324,7 +324,7 @@ func TestHostProvision(t *testing.T) {
 func TestHostTag(t *testing.T) {
 	assert := assert.New(t)
 
-	h := &Handler{newTestDB(t)}
+	h := &Handler{DB: newTestDB(t)}
 
 	size := 20
 	for i := 0; i < size; i++ {
@@ -370,7 +370,7 @@ func TestHostTag(t *testing.T) {
 func TestHostDelete(t *testing.T) {
 	assert := assert.New(t)
 
-	h := &Handler{newTestDB(t)}
+	h := &Handler{DB: newTestDB(t)}
 
 	size := 20
 	for i := 0; i < size; i++ {
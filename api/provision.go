@@ -0,0 +1,76 @@
+// Copyright 2019 Grendel Authors. All rights reserved.
+//
+// This file is part of Grendel.
+//
+// Grendel is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Grendel is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with Grendel. If not, see <https://www.gnu.org/licenses/>.
+
+package api
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/spf13/viper"
+	"github.com/ubccr/grendel/model"
+	"github.com/ubccr/grendel/nodeset"
+)
+
+func (h *Handler) ProvisionStatus(c echo.Context) error {
+	var ns *nodeset.NodeSet
+	if nodesetString := c.QueryParam("nodeset"); nodesetString != "" {
+		var err error
+		ns, err = nodeset.NewNodeSet(nodesetString)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, "invalid nodeset").SetInternal(err)
+		}
+	}
+
+	onlyStuck := false
+	if stuck := c.QueryParam("stuck"); stuck != "" {
+		var err error
+		onlyStuck, err = strconv.ParseBool(stuck)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, "invalid stuck value").SetInternal(err)
+		}
+	}
+
+	state := model.ProvisionState(c.QueryParam("state"))
+
+	records, err := h.DB.ProvisionRecords(ns)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to fetch provision records").SetInternal(err)
+	}
+
+	timeout := viper.GetDuration("provision.stuck_timeout")
+	now := time.Now()
+
+	res := make(model.ProvisionRecordList, 0, len(records))
+	for _, record := range records {
+		record.Stuck = record.Stuck || record.IsStuck(timeout, now)
+
+		if state != "" && record.State != state {
+			continue
+		}
+
+		if onlyStuck && !record.Stuck {
+			continue
+		}
+
+		res = append(res, record)
+	}
+
+	return c.JSON(http.StatusOK, res)
+}
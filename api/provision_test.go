@@ -0,0 +1,79 @@
+// Copyright 2019 Grendel Authors. All rights reserved.
+//
+// This file is part of Grendel.
+//
+// Grendel is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Grendel is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with Grendel. If not, see <https://www.gnu.org/licenses/>.
+
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tidwall/gjson"
+	"github.com/ubccr/grendel/internal/tests"
+	"github.com/ubccr/grendel/model"
+)
+
+func TestProvisionStatus(t *testing.T) {
+	assert := assert.New(t)
+
+	db := newTestDB(t)
+	h := &Handler{DB: db}
+	e := newEcho()
+	h.SetupRoutes(e, SocketAuth)
+
+	now := time.Now()
+	for i, name := range []string{"tux01", "tux02"} {
+		host := tests.HostFactory.MustCreate().(*model.Host)
+		host.Name = name
+		assert.NoError(db.StoreHost(host))
+
+		record := model.NewProvisionRecord(name, now.Add(-time.Duration(i)*time.Hour))
+		record.Transition(model.ProvisionStateKernel, "10.0.0.1", "", now.Add(-time.Duration(i)*time.Hour))
+		assert.NoError(db.StoreProvisionRecord(record))
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/v1/provision/status?nodeset=tux[01-02]", nil)
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	if assert.Equal(http.StatusOK, rec.Code) {
+		assert.Equal(int64(2), gjson.Get(rec.Body.String(), "#").Int())
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/v1/provision/status?stuck=true", nil)
+	rec = httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	if assert.Equal(http.StatusOK, rec.Code) {
+		res := gjson.Parse(rec.Body.String())
+		assert.Equal(int64(1), res.Get("#").Int())
+		assert.Equal("tux02", res.Get("0.name").String())
+		assert.Equal("kernel", res.Get("0.state").String())
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/v1/provision/status?state=installing", nil)
+	rec = httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	if assert.Equal(http.StatusOK, rec.Code) {
+		assert.Equal(int64(0), gjson.Get(rec.Body.String(), "#").Int())
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/v1/provision/status?stuck=maybe", nil)
+	rec = httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	assert.Equal(http.StatusBadRequest, rec.Code)
+}
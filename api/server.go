@@ -31,6 +31,7 @@ import (
 	"github.com/labstack/echo/v4"
 	"github.com/labstack/echo/v4/middleware"
 	"github.com/sirupsen/logrus"
+	"github.com/ubccr/grendel/artifact"
 	"github.com/ubccr/grendel/logger"
 	"github.com/ubccr/grendel/model"
 	"github.com/ubccr/grendel/util"
@@ -47,6 +48,7 @@ type Server struct {
 	KeyFile       string
 	CertFile      string
 	Hostname      string
+	Artifacts     *artifact.Store
 	DB            model.DataStore
 	httpServer    *http.Server
 }
@@ -116,22 +118,22 @@ func HTTPErrorHandler(err error, c echo.Context) {
 	if he, ok := err.(*echo.HTTPError); ok {
 		if he.Code == http.StatusNotFound {
 			log.WithFields(logrus.Fields{
-				"path": c.Request().URL,
-				"ip":   c.RealIP(),
+				"path":         c.Request().URL,
+				logger.FieldIP: c.RealIP(),
 			}).Warn("Requested path not found")
 		} else {
 			log.WithFields(logrus.Fields{
-				"code": he.Code,
-				"err":  he.Internal,
-				"path": c.Request().URL,
-				"ip":   c.RealIP(),
+				"code":         he.Code,
+				"err":          he.Internal,
+				"path":         c.Request().URL,
+				logger.FieldIP: c.RealIP(),
 			}).Error(he.Message)
 		}
 	} else {
 		log.WithFields(logrus.Fields{
-			"err":  err,
-			"path": c.Request().URL,
-			"ip":   c.RealIP(),
+			"err":          err,
+			"path":         c.Request().URL,
+			logger.FieldIP: c.RealIP(),
 		}).Error("HTTP Error")
 	}
 
@@ -146,7 +148,16 @@ func (s *Server) Serve() error {
 		return err
 	}
 
-	h.SetupRoutes(e)
+	h.Artifacts = s.Artifacts
+
+	// Connections over the unix domain socket are protected by file system
+	// permissions. All TCP connections require a signed API token.
+	auth := AuthRequired
+	if s.SocketPath != "" {
+		auth = SocketAuth
+	}
+
+	h.SetupRoutes(e, auth)
 
 	httpServer := &http.Server{
 		ReadTimeout:  5 * time.Minute,
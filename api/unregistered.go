@@ -0,0 +1,292 @@
+// Copyright 2019 Grendel Authors. All rights reserved.
+//
+// This file is part of Grendel.
+//
+// Grendel is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Grendel is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with Grendel. If not, see <https://www.gnu.org/licenses/>.
+
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/ubccr/grendel/firmware"
+	"github.com/ubccr/grendel/model"
+	"github.com/ubccr/grendel/nodeset"
+	"go4.org/netipx"
+)
+
+var nodeNumberRegexp = regexp.MustCompile(`(\d+)$`)
+
+func (h *Handler) UnregisteredList(c echo.Context) error {
+	hosts, err := h.DB.UnregisteredHosts()
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to fetch unregistered hosts").SetInternal(err)
+	}
+
+	return c.JSON(http.StatusOK, hosts)
+}
+
+func (h *Handler) UnregisteredDelete(c echo.Context) error {
+	mac := c.Param("mac")
+
+	if _, err := h.DB.LoadUnregistered(mac); err != nil {
+		if errors.Is(err, model.ErrNotFound) {
+			return echo.NewHTTPError(http.StatusNotFound, "unregistered host not found").SetInternal(err)
+		}
+
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to find unregistered host").SetInternal(err)
+	}
+
+	if err := h.DB.DeleteUnregistered(mac); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to delete unregistered host").SetInternal(err)
+	}
+
+	res := map[string]interface{}{
+		"hosts": 1,
+	}
+	return c.JSON(http.StatusOK, res)
+}
+
+// nodeAddr returns the address of the node in the subnet, which is the network
+// address plus the number the node name ends in. The network, broadcast and
+// gateway addresses of the subnet are never assigned.
+func nodeAddr(subnet netip.Prefix, name string) (netip.Prefix, error) {
+	matches := nodeNumberRegexp.FindStringSubmatch(name)
+	if len(matches) != 2 {
+		return netip.Prefix{}, fmt.Errorf("host name %s doesn't end in a number", name)
+	}
+
+	num, err := strconv.ParseUint(matches[1], 10, 32)
+	if err != nil {
+		return netip.Prefix{}, fmt.Errorf("invalid host number %s: %w", name, err)
+	}
+
+	network := subnet.Masked().Addr().As4()
+	base := uint32(network[0])<<24 | uint32(network[1])<<16 | uint32(network[2])<<8 | uint32(network[3])
+	n := base + uint32(num)
+
+	addr := netip.AddrFrom4([4]byte{byte(n >> 24), byte(n >> 16), byte(n >> 8), byte(n)})
+	if num == 0 || !subnet.Contains(addr) {
+		return netip.Prefix{}, fmt.Errorf("address of host %s is outside of subnet %s", name, subnet.Masked())
+	}
+
+	if subnet.Bits() < 31 && addr == netipx.PrefixLastIP(subnet.Masked()) {
+		return netip.Prefix{}, fmt.Errorf("address of host %s is the broadcast address of subnet %s", name, subnet.Masked())
+	}
+
+	ip := netip.PrefixFrom(addr, subnet.Bits())
+	nic := &model.NetInterface{IP: ip}
+	if addr == subnet.Addr() || addr == nic.Gateway() {
+		return netip.Prefix{}, fmt.Errorf("address of host %s is the gateway of subnet %s", name, subnet.Masked())
+	}
+
+	return ip, nil
+}
+
+// dynamicAddr returns an error if the address is in a dynamic DHCP pool or
+// leased to a client other than mac
+func dynamicAddr(addr netip.Addr, mac net.HardwareAddr, leases model.LeaseList) error {
+	for _, subnet := range model.Subnets {
+		if subnet.Pool.IsValid() && subnet.Pool.Contains(addr) {
+			return fmt.Errorf("address %s is in the dynamic range %s", addr, subnet.Pool)
+		}
+	}
+
+	now := time.Now()
+	for _, lease := range leases {
+		if lease.IP == addr && !lease.Expired(now) && lease.MAC.String() != mac.String() {
+			return fmt.Errorf("address %s is leased to %s", addr, lease.MAC)
+		}
+	}
+
+	return nil
+}
+
+func (h *Handler) UnregisteredPromote(c echo.Context) error {
+	var req model.PromoteRequest
+
+	if !strings.HasPrefix(c.Request().Header.Get(echo.HeaderContentType), echo.MIMEApplicationJSON) {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid content type")
+	}
+
+	if err := c.Bind(&req); err != nil {
+		return err
+	}
+
+	ns, err := nodeset.NewNodeSet(req.NodeSet)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid nodeset").SetInternal(err)
+	}
+
+	subnet, err := model.ParseIPPrefix(req.Subnet)
+	if err != nil || !subnet.Addr().Is4() {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid IPv4 subnet").SetInternal(err)
+	}
+
+	fw := firmware.NewFromString(req.Firmware)
+	if req.Firmware != "" && !fw.Available() {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid firmware build")
+	}
+
+	var unregistered model.UnregisteredHostList
+	if len(req.MACs) == 0 {
+		unregistered, err = h.DB.UnregisteredHosts()
+		if err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "failed to fetch unregistered hosts").SetInternal(err)
+		}
+	}
+	for _, mac := range req.MACs {
+		u, err := h.DB.LoadUnregistered(mac)
+		if errors.Is(err, model.ErrNotFound) {
+			return echo.NewHTTPError(http.StatusNotFound, "unregistered host not found: "+mac).SetInternal(err)
+		}
+		if err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "failed to find unregistered host").SetInternal(err)
+		}
+		unregistered = append(unregistered, u)
+	}
+
+	if len(unregistered) == 0 {
+		return echo.NewHTTPError(http.StatusBadRequest, "no unregistered hosts to promote")
+	}
+
+	if ns.Len() < len(unregistered) {
+		return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("nodeset has %d names for %d unregistered hosts", ns.Len(), len(unregistered)))
+	}
+
+	existing, err := h.DB.Hosts()
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to fetch hosts").SetInternal(err)
+	}
+
+	leases, err := h.DB.Leases()
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to fetch leases").SetInternal(err)
+	}
+
+	names := make(map[string]bool, len(existing))
+	addrs := make(map[netip.Addr]string)
+	uuids := make(map[string]string)
+	for _, host := range existing {
+		names[host.Name] = true
+		if host.UUID != "" {
+			uuids[strings.ToLower(host.UUID)] = host.Name
+		}
+		for _, nic := range host.Interfaces {
+			if nic.IP.IsValid() {
+				addrs[nic.IP.Addr()] = host.Name
+			}
+		}
+	}
+
+	hosts := make(model.HostList, 0, len(unregistered))
+	it := ns.Iterator()
+	for _, u := range unregistered {
+		it.Next()
+		name := it.Value()
+
+		if names[name] {
+			return echo.NewHTTPError(http.StatusConflict, "host already exists: "+name)
+		}
+
+		ip, err := nodeAddr(subnet, name)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, "failed to assign address").SetInternal(err)
+		}
+
+		if other, ok := addrs[ip.Addr()]; ok {
+			return echo.NewHTTPError(http.StatusConflict, fmt.Sprintf("address %s is assigned to host %s", ip.Addr(), other))
+		}
+
+		if err := dynamicAddr(ip.Addr(), u.MAC, leases); err != nil {
+			return echo.NewHTTPError(http.StatusConflict, err.Error()).SetInternal(err)
+		}
+
+		uuid := u.UUID
+		if model.PlaceholderUUID(uuid) {
+			uuid = ""
+		}
+
+		if other, ok := uuids[strings.ToLower(uuid)]; ok && uuid != "" {
+			return echo.NewHTTPError(http.StatusConflict, fmt.Sprintf("uuid %s is assigned to host %s", uuid, other))
+		}
+
+		if _, err := h.DB.LoadHostFromMAC(u.MAC.String()); err == nil {
+			return echo.NewHTTPError(http.StatusConflict, "mac address already registered: "+u.MAC.String())
+		}
+
+		fqdn := ""
+		if req.Domain != "" {
+			fqdn = name + "." + strings.TrimPrefix(req.Domain, ".")
+		}
+
+		host := &model.Host{
+			Name:      name,
+			Provision: req.Provision,
+			Firmware:  fw,
+			BootImage: req.BootImage,
+			Tags:      req.Tags,
+			UUID:      uuid,
+			Interfaces: []*model.NetInterface{
+				{
+					MAC:  u.MAC,
+					IP:   ip,
+					FQDN: fqdn,
+				},
+			},
+		}
+
+		if req.Relay && u.CircuitID != "" {
+			if u.RemoteID == "" {
+				return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("unregistered host %s has a circuit id but no remote id", u.MAC))
+			}
+
+			host.Interfaces[0].CircuitID = u.CircuitID
+			host.Interfaces[0].RemoteID = u.RemoteID
+		}
+
+		if err := c.Validate(host); err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, "invalid data").SetInternal(err)
+		}
+
+		addrs[ip.Addr()] = name
+		if u.UUID != "" {
+			uuids[u.UUID] = name
+		}
+		hosts = append(hosts, host)
+	}
+
+	if err := h.store(c).StoreHosts(hosts); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to save hosts").SetInternal(err)
+	}
+
+	for _, host := range hosts {
+		if err := h.DB.DeleteUnregistered(host.Interfaces[0].MAC.String()); err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "failed to delete unregistered host").SetInternal(err)
+		}
+	}
+
+	log.Infof("Promoted %d unregistered hosts", len(hosts))
+
+	return c.JSON(http.StatusCreated, hosts)
+}
@@ -0,0 +1,236 @@
+// Copyright 2019 Grendel Authors. All rights reserved.
+//
+// This file is part of Grendel.
+//
+// Grendel is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Grendel is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with Grendel. If not, see <https://www.gnu.org/licenses/>.
+
+package api
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/tidwall/gjson"
+	"github.com/ubccr/grendel/internal/tests"
+	"github.com/ubccr/grendel/model"
+	"go4.org/netipx"
+)
+
+func TestUnregisteredPromote(t *testing.T) {
+	assert := assert.New(t)
+
+	db := newTestDB(t)
+	h := &Handler{DB: db}
+	e := newEcho()
+	h.SetupRoutes(e, SocketAuth)
+
+	host := tests.HostFactory.MustCreate().(*model.Host)
+	host.Name = "cpn-01"
+	host.Interfaces[0].IP = netip.MustParsePrefix("10.17.40.1/24")
+	assert.NoError(db.StoreHost(host))
+
+	now := time.Now()
+	for i, mac := range []string{"02:00:00:00:00:01", "02:00:00:00:00:02", "02:00:00:00:00:03"} {
+		hwaddr, _ := net.ParseMAC(mac)
+		assert.NoError(db.StoreUnregistered(&model.UnregisteredHost{
+			MAC:       hwaddr,
+			UUID:      fmt.Sprintf("4c4c4544-0000-1000-8000-00000000000%d", i+1),
+			CircuitID: fmt.Sprintf("Ethernet1/%d", i+1),
+			RemoteID:  "leaf-01",
+			FirstSeen: now.Add(time.Duration(i) * time.Minute),
+			LastSeen:  now,
+			Count:     1,
+		}))
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/v1/unregistered", nil)
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	if assert.Equal(http.StatusOK, rec.Code) {
+		res := gjson.Parse(rec.Body.String())
+		assert.Equal(int64(3), res.Get("#").Int())
+		assert.Equal("02:00:00:00:00:01", res.Get("0.mac").String())
+	}
+
+	promote := func(body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/v1/unregistered/promote", strings.NewReader(body))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		return rec
+	}
+
+	// Names in use are rejected
+	rec = promote(`{"nodeset": "cpn-[01-02]", "subnet": "10.17.40.0/24", "macs": ["02:00:00:00:00:02"]}`)
+	assert.Equal(http.StatusConflict, rec.Code)
+
+	// Not enough names
+	rec = promote(`{"nodeset": "cpn-02", "subnet": "10.17.40.0/24"}`)
+	assert.Equal(http.StatusBadRequest, rec.Code)
+
+	rec = promote(`{"nodeset": "cpn-[12-13]", "subnet": "10.17.40.0/24", "domain": "compute.local", "provision": true, "macs": ["02:00:00:00:00:03", "02:00:00:00:00:01"]}`)
+	if assert.Equal(http.StatusCreated, rec.Code) {
+		res := gjson.Parse(rec.Body.String())
+		assert.Equal(int64(2), res.Get("#").Int())
+		assert.Equal("cpn-12", res.Get("0.name").String())
+		assert.Equal("02:00:00:00:00:03", res.Get("0.interfaces.0.mac").String())
+		assert.Equal("10.17.40.12/24", res.Get("0.interfaces.0.ip").String())
+		assert.Equal("cpn-12.compute.local", res.Get("0.interfaces.0.fqdn").String())
+		assert.True(res.Get("0.provision").Bool())
+		assert.Equal("cpn-13", res.Get("1.name").String())
+	}
+
+	// Relay agent ids are only kept when asked for
+	promoted, err := db.LoadHostFromMAC("02:00:00:00:00:01")
+	if assert.NoError(err) {
+		assert.Equal("cpn-13", promoted.Name)
+		assert.Equal("4c4c4544-0000-1000-8000-000000000001", promoted.UUID)
+		assert.Equal("", promoted.Interfaces[0].CircuitID)
+		assert.Equal("", promoted.Interfaces[0].RemoteID)
+	}
+
+	promoted, err = db.LoadHostFromUUID("4c4c4544-0000-1000-8000-000000000003")
+	if assert.NoError(err) {
+		assert.Equal("cpn-12", promoted.Name)
+	}
+
+	// UUIDs already assigned to a host are rejected
+	hwaddr, _ := net.ParseMAC("02:00:00:00:00:04")
+	assert.NoError(db.StoreUnregistered(&model.UnregisteredHost{MAC: hwaddr, UUID: "4c4c4544-0000-1000-8000-000000000003", FirstSeen: now, LastSeen: now}))
+	rec = promote(`{"nodeset": "cpn-14", "subnet": "10.17.40.0/24", "macs": ["02:00:00:00:00:04"]}`)
+	assert.Equal(http.StatusConflict, rec.Code)
+
+	_, err = db.LoadUnregistered("02:00:00:00:00:03")
+	assert.ErrorIs(err, model.ErrNotFound)
+
+	// A circuit id without a remote id matches the port on every switch
+	hwaddr, _ = net.ParseMAC("02:00:00:00:00:05")
+	assert.NoError(db.StoreUnregistered(&model.UnregisteredHost{MAC: hwaddr, CircuitID: "Ethernet1/5", FirstSeen: now, LastSeen: now}))
+	rec = promote(`{"nodeset": "cpn-15", "subnet": "10.17.40.0/24", "relay": true, "macs": ["02:00:00:00:00:05"]}`)
+	assert.Equal(http.StatusBadRequest, rec.Code)
+
+	rec = promote(`{"nodeset": "cpn-16", "subnet": "10.17.40.0/24", "relay": true, "macs": ["02:00:00:00:00:02"]}`)
+	if assert.Equal(http.StatusCreated, rec.Code) {
+		res := gjson.Parse(rec.Body.String())
+		assert.Equal("Ethernet1/2", res.Get("0.interfaces.0.circuit_id").String())
+		assert.Equal("leaf-01", res.Get("0.interfaces.0.remote_id").String())
+	}
+
+	req = httptest.NewRequest(http.MethodDelete, "/v1/unregistered/02:00:00:00:00:05", nil)
+	rec = httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	assert.Equal(http.StatusOK, rec.Code)
+
+	req = httptest.NewRequest(http.MethodDelete, "/v1/unregistered/02:00:00:00:00:05", nil)
+	rec = httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	assert.Equal(http.StatusNotFound, rec.Code)
+}
+
+func TestNodeAddr(t *testing.T) {
+	assert := assert.New(t)
+
+	subnet := netip.MustParsePrefix("10.17.40.0/23")
+
+	ip, err := nodeAddr(subnet, "cpn-300")
+	if assert.NoError(err) {
+		assert.Equal("10.17.41.44/23", ip.String())
+	}
+
+	_, err = nodeAddr(subnet, "cpn-512")
+	assert.Error(err)
+	_, err = nodeAddr(subnet, "cpn")
+	assert.Error(err)
+
+	// Broadcast address
+	_, err = nodeAddr(subnet, "cpn-511")
+	assert.Error(err)
+
+	saved := model.Subnets
+	t.Cleanup(func() { model.Subnets = saved })
+	model.Subnets = []model.Subnet{
+		{Gateway: netip.MustParsePrefix("10.17.40.254/23")},
+	}
+
+	// Gateway addresses
+	_, err = nodeAddr(subnet, "cpn-254")
+	assert.Error(err)
+	_, err = nodeAddr(netip.MustParsePrefix("10.17.50.1/24"), "cpn-1")
+	assert.Error(err)
+	_, err = nodeAddr(netip.MustParsePrefix("10.17.50.1/24"), "cpn-2")
+	assert.NoError(err)
+}
+
+func TestPromoteDynamicAddr(t *testing.T) {
+	assert := assert.New(t)
+
+	saved := model.Subnets
+	t.Cleanup(func() { model.Subnets = saved })
+	model.Subnets = []model.Subnet{
+		{
+			Gateway: netip.MustParsePrefix("10.17.40.1/24"),
+			Pool:    netipx.MustParseIPRange("10.17.40.100-10.17.40.199"),
+		},
+	}
+
+	db := newTestDB(t)
+	h := &Handler{DB: db}
+	e := newEcho()
+	h.SetupRoutes(e, SocketAuth)
+
+	now := time.Now()
+	for _, mac := range []string{"02:00:00:00:00:01", "02:00:00:00:00:02"} {
+		hwaddr, _ := net.ParseMAC(mac)
+		assert.NoError(db.StoreUnregistered(&model.UnregisteredHost{MAC: hwaddr, FirstSeen: now, LastSeen: now, Count: 1}))
+	}
+
+	other, _ := net.ParseMAC("02:00:00:00:00:99")
+	assert.NoError(db.StoreLease(&model.Lease{
+		MAC:     other,
+		IP:      netip.MustParseAddr("10.17.40.20"),
+		Started: now,
+		Expires: now.Add(time.Hour),
+	}))
+
+	promote := func(body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/v1/unregistered/promote", strings.NewReader(body))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		return rec
+	}
+
+	// Addresses in the dynamic range
+	rec := promote(`{"nodeset": "cpn-150", "subnet": "10.17.40.0/24", "macs": ["02:00:00:00:00:01"]}`)
+	assert.Equal(http.StatusConflict, rec.Code)
+
+	// Addresses leased to another client
+	rec = promote(`{"nodeset": "cpn-20", "subnet": "10.17.40.0/24", "macs": ["02:00:00:00:00:01"]}`)
+	assert.Equal(http.StatusConflict, rec.Code)
+
+	// The gateway
+	rec = promote(`{"nodeset": "cpn-1", "subnet": "10.17.40.0/24", "macs": ["02:00:00:00:00:01"]}`)
+	assert.Equal(http.StatusBadRequest, rec.Code)
+
+	rec = promote(`{"nodeset": "cpn-21", "subnet": "10.17.40.0/24", "macs": ["02:00:00:00:00:01"]}`)
+	assert.Equal(http.StatusCreated, rec.Code)
+}
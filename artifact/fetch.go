@@ -0,0 +1,336 @@
+// Copyright 2019 Grendel Authors. All rights reserved.
+//
+// This file is part of Grendel.
+//
+// Grendel is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Grendel is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with Grendel. If not, see <https://www.gnu.org/licenses/>.
+
+package artifact
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/ubccr/grendel/model"
+)
+
+// DefaultFetchInterval is how often images are checked for sources which
+// need fetching
+const DefaultFetchInterval = 30 * time.Second
+
+// DefaultFetchTimeout is the time allowed to fetch a single source, including
+// reading the whole file
+const DefaultFetchTimeout = time.Hour
+
+// Fetcher pulls the remote sources of boot images into the artifact store and
+// marks the images ready once all their files are stored and verified
+type Fetcher struct {
+	DB       model.DataStore
+	Store    *Store
+	Client   *http.Client
+	Interval time.Duration
+	Timeout  time.Duration
+}
+
+func NewFetcher(db model.DataStore, store *Store) *Fetcher {
+	return &Fetcher{
+		DB:       db,
+		Store:    store,
+		Client:   newHTTPClient(),
+		Interval: DefaultFetchInterval,
+		Timeout:  DefaultFetchTimeout,
+	}
+}
+
+// newHTTPClient returns a client which gives up on servers that don't accept
+// connections or send response headers in time. The body is only bounded by
+// the per source timeout so large files can be fetched.
+func newHTTPClient() *http.Client {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.DialContext = (&net.Dialer{
+		Timeout:   30 * time.Second,
+		KeepAlive: 30 * time.Second,
+	}).DialContext
+	transport.TLSHandshakeTimeout = 10 * time.Second
+	transport.ResponseHeaderTimeout = 30 * time.Second
+
+	return &http.Client{Transport: transport}
+}
+
+// Run fetches image sources until ctx is cancelled
+func (f *Fetcher) Run(ctx context.Context) {
+	ticker := time.NewTicker(f.Interval)
+	defer ticker.Stop()
+
+	for {
+		if err := f.FetchAll(ctx); err != nil {
+			log.Errorf("Failed to fetch image sources: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// FetchAll fetches the sources of all images. Failures are recorded in the
+// status of each image.
+func (f *Fetcher) FetchAll(ctx context.Context) error {
+	images, err := f.DB.BootImages()
+	if err != nil {
+		return err
+	}
+
+	for _, image := range images {
+		if len(image.Sources) == 0 {
+			continue
+		}
+
+		if err := f.Fetch(ctx, image.Name); err != nil {
+			log.WithFields(logrus.Fields{
+				"err":   err,
+				"image": image.Name,
+			}).Error("Failed to fetch image sources")
+		}
+	}
+
+	return nil
+}
+
+// Fetch stores the missing sources of the named image and updates its status.
+// The status is left alone if the sources of the image change while they are
+// fetched, the new sources are fetched on the next run.
+func (f *Fetcher) Fetch(ctx context.Context, name string) error {
+	image, err := f.DB.LoadBootImage(name)
+	if err != nil {
+		return err
+	}
+
+	sources := image.Sources
+	initrd := 0
+	for _, src := range sources {
+		index := 0
+		if src.Kind == model.ArtifactInitrd {
+			index = initrd
+			initrd++
+		}
+
+		if f.stored(image, src, index) {
+			continue
+		}
+
+		if image.Status != model.BootImageStatusPending {
+			if err := f.setStatus(name, sources, model.BootImageStatusPending, ""); err != nil {
+				return err
+			}
+		}
+
+		if err := f.fetchSource(ctx, name, sources, src, index); err != nil {
+			if serr := f.setStatus(name, sources, model.BootImageStatusFailed, fmt.Sprintf("%s: %v", src.URL, err)); serr != nil {
+				return serr
+			}
+			return err
+		}
+
+		image, err = f.DB.LoadBootImage(name)
+		if err != nil {
+			return err
+		}
+	}
+
+	if image.Status == model.BootImageStatusReady {
+		return nil
+	}
+
+	log.Infof("All sources of image %s fetched", name)
+
+	return f.setStatus(name, sources, model.BootImageStatusReady, "")
+}
+
+// stored returns true if the image references a verified artifact fetched
+// from the source
+func (f *Fetcher) stored(image *model.BootImage, src *model.Source, index int) bool {
+	var path string
+	switch src.Kind {
+	case model.ArtifactKernel:
+		path = image.KernelPath
+	case model.ArtifactInitrd:
+		if index < len(image.InitrdPaths) {
+			path = image.InitrdPaths[index]
+		}
+	case model.ArtifactLiveImg:
+		path = image.LiveImage
+	case model.ArtifactRootFS:
+		path = image.RootFS
+	}
+
+	a := f.Store.Find(image, path)
+	if a == nil || a.Kind != src.Kind || a.Name != src.URL {
+		return false
+	}
+
+	if src.SHA256 != "" && a.SHA256 != src.SHA256 {
+		return false
+	}
+
+	return f.Store.Verify(a) == nil
+}
+
+// fetchSource stores the source and attaches it to the latest version of the
+// image, unless its sources are no longer the given ones. It fails if the
+// source isn't stored within the fetcher timeout so a stalled server doesn't
+// hold up the sources of other images.
+func (f *Fetcher) fetchSource(ctx context.Context, name string, sources []*model.Source, src *model.Source, index int) error {
+	if f.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, f.Timeout)
+		defer cancel()
+	}
+
+	commit := func(a *model.Artifact, path string) error {
+		image, err := f.DB.LoadBootImage(name)
+		if err != nil {
+			return err
+		}
+
+		if !sameSources(image.Sources, sources) {
+			return fmt.Errorf("sources of image %s changed while fetching %s", name, src.URL)
+		}
+
+		a.Kind = src.Kind
+		a.Name = src.URL
+
+		if err := f.Store.Attach(image, a, path, index); err != nil {
+			return err
+		}
+
+		return f.store().StoreBootImage(image)
+	}
+
+	if src.SHA256 != "" {
+		a, err := f.Store.AddExisting(src.SHA256, commit)
+		if err != nil || a != nil {
+			return err
+		}
+	}
+
+	u, err := url.Parse(src.URL)
+	if err != nil {
+		return err
+	}
+
+	var body io.ReadCloser
+	digest := src.SHA256
+	switch u.Scheme {
+	case "http", "https":
+		body, err = f.get(ctx, src.URL, "", "")
+	case "oci":
+		body, digest, err = f.fetchOCI(ctx, u, src.SHA256)
+	default:
+		err = fmt.Errorf("unsupported scheme %s", u.Scheme)
+	}
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+
+	log.Infof("Fetching %s for image %s", src.URL, name)
+
+	_, err = f.Store.Add(body, digest, commit)
+
+	return err
+}
+
+// get returns the body of a successful GET request
+func (f *Fetcher) get(ctx context.Context, url, accept, token string) (io.ReadCloser, error) {
+	res, err := f.do(ctx, url, accept, token)
+	if err != nil {
+		return nil, err
+	}
+
+	if res.StatusCode != http.StatusOK {
+		res.Body.Close()
+		return nil, fmt.Errorf("GET %s: %s", url, res.Status)
+	}
+
+	return res.Body, nil
+}
+
+func (f *Fetcher) do(ctx context.Context, url, accept, token string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if accept != "" {
+		req.Header.Set("Accept", accept)
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	return f.Client.Do(req)
+}
+
+// setStatus sets the status of the image if its sources are still the given
+// ones
+func (f *Fetcher) setStatus(name string, sources []*model.Source, status, msg string) error {
+	image, err := f.DB.LoadBootImage(name)
+	if errors.Is(err, model.ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if !sameSources(image.Sources, sources) {
+		log.Infof("Sources of image %s changed while fetching, not setting status %s", name, status)
+		return nil
+	}
+
+	image.Status = status
+	image.StatusMessage = msg
+
+	return f.store().StoreBootImage(image)
+}
+
+// store returns the data store used for image writes, which records them in
+// the audit log
+func (f *Fetcher) store() model.DataStore {
+	return model.NewAuditedStore(f.DB, "fetcher", "")
+}
+
+// sameSources returns true if both lists have the same sources in the same
+// order
+func sameSources(a, b []*model.Source) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	for i := range a {
+		if *a[i] != *b[i] {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,299 @@
+// Copyright 2019 Grendel Authors. All rights reserved.
+//
+// This file is part of Grendel.
+//
+// Grendel is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Grendel is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with Grendel. If not, see <https://www.gnu.org/licenses/>.
+
+package artifact
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/ubccr/grendel/model"
+)
+
+// newTestRegistry returns a server with a file at /files/vmlinuz, a file at
+// /files/stalled which never sends its content and an OCI registry requiring an
+// anonymous bearer token with the single layer artifact images/initrd:v1
+func newTestRegistry(t *testing.T, requests *int32) *httptest.Server {
+	var srv *httptest.Server
+
+	manifest := fmt.Sprintf(`{"schemaVersion": 2, "layers": [{"mediaType": "application/octet-stream", "digest": "sha256:%s", "size": 6}]}`, digest("initrd"))
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/files/vmlinuz", func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(requests, 1)
+		fmt.Fprint(w, "kernel")
+	})
+	mux.HandleFunc("/files/stalled", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		w.(http.Flusher).Flush()
+		<-r.Context().Done()
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("scope") != "repository:images/initrd:pull" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		fmt.Fprint(w, `{"token": "secret"}`)
+	})
+	mux.HandleFunc("/v2/", func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(requests, 1)
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Bearer realm="%s/token",service="registry",scope="repository:images/initrd:pull"`, srv.URL))
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		switch r.URL.Path {
+		case "/v2/images/initrd/manifests/v1":
+			w.Header().Set("Content-Type", "application/vnd.oci.image.manifest.v1+json")
+			fmt.Fprint(w, manifest)
+		case "/v2/images/initrd/blobs/sha256:" + digest("initrd"):
+			fmt.Fprint(w, "initrd")
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	})
+
+	srv = httptest.NewTLSServer(mux)
+	t.Cleanup(srv.Close)
+
+	return srv
+}
+
+func TestFetch(t *testing.T) {
+	assert := assert.New(t)
+
+	var requests int32
+	srv := newTestRegistry(t, &requests)
+	u, _ := url.Parse(srv.URL)
+
+	db, err := model.NewDataStore(":memory:")
+	if !assert.NoError(err) {
+		return
+	}
+
+	store, err := NewStore(t.TempDir())
+	if !assert.NoError(err) {
+		return
+	}
+
+	f := NewFetcher(db, store)
+	f.Client = srv.Client()
+
+	image := &model.BootImage{
+		Name: "compute",
+		Sources: []*model.Source{
+			{Kind: model.ArtifactKernel, URL: srv.URL + "/files/vmlinuz", SHA256: digest("kernel")},
+			{Kind: model.ArtifactInitrd, URL: "oci://" + u.Host + "/images/initrd:v1"},
+		},
+	}
+	for _, src := range image.Sources {
+		assert.NoError(src.Validate())
+	}
+	err = db.StoreBootImage(image)
+	assert.NoError(err)
+
+	err = f.FetchAll(context.Background())
+	assert.NoError(err)
+
+	image, err = db.LoadBootImage("compute")
+	if assert.NoError(err) {
+		assert.Equal(model.BootImageStatusReady, image.Status)
+		assert.True(image.Ready())
+		assert.Equal(store.Path(digest("kernel")), image.KernelPath)
+		assert.Equal([]string{store.Path(digest("initrd"))}, image.InitrdPaths)
+		assert.Equal(2, len(image.Artifacts))
+	}
+
+	// Stored sources are not fetched again
+	fetched := atomic.LoadInt32(&requests)
+	err = f.Fetch(context.Background(), "compute")
+	assert.NoError(err)
+	assert.Equal(fetched, atomic.LoadInt32(&requests))
+
+	// Sources with a known digest reuse the stored artifact
+	image.Name = "compute2"
+	image.KernelPath = ""
+	image.InitrdPaths = nil
+	image.Artifacts = nil
+	image.Sources = image.Sources[:1]
+	image.ResetStatus()
+	err = db.StoreBootImage(image)
+	assert.NoError(err)
+	err = f.Fetch(context.Background(), "compute2")
+	assert.NoError(err)
+	assert.Equal(fetched, atomic.LoadInt32(&requests))
+
+	image.Name = "bad"
+	image.Sources = []*model.Source{{Kind: model.ArtifactKernel, URL: srv.URL + "/files/vmlinuz", SHA256: digest("other")}}
+	image.ResetStatus()
+	err = db.StoreBootImage(image)
+	assert.NoError(err)
+
+	err = f.Fetch(context.Background(), "bad")
+	assert.Error(err)
+
+	image, err = db.LoadBootImage("bad")
+	if assert.NoError(err) {
+		assert.Equal(model.BootImageStatusFailed, image.Status)
+		assert.Contains(image.StatusMessage, "checksum mismatch")
+		assert.False(image.Ready())
+	}
+}
+
+func TestFetchTimeout(t *testing.T) {
+	assert := assert.New(t)
+
+	var requests int32
+	srv := newTestRegistry(t, &requests)
+
+	db, err := model.NewDataStore(":memory:")
+	if !assert.NoError(err) {
+		return
+	}
+
+	store, err := NewStore(t.TempDir())
+	if !assert.NoError(err) {
+		return
+	}
+
+	f := NewFetcher(db, store)
+	f.Client = srv.Client()
+	f.Timeout = 100 * time.Millisecond
+
+	for _, image := range []*model.BootImage{
+		{Name: "a-stalled", Sources: []*model.Source{{Kind: model.ArtifactKernel, URL: srv.URL + "/files/stalled"}}},
+		{Name: "compute", Sources: []*model.Source{{Kind: model.ArtifactKernel, URL: srv.URL + "/files/vmlinuz"}}},
+	} {
+		assert.NoError(db.StoreBootImage(image))
+	}
+
+	// A stalled source fails and doesn't keep other images from being fetched
+	err = f.FetchAll(context.Background())
+	assert.NoError(err)
+
+	image, err := db.LoadBootImage("a-stalled")
+	if assert.NoError(err) {
+		assert.Equal(model.BootImageStatusFailed, image.Status)
+		assert.Contains(image.StatusMessage, context.DeadlineExceeded.Error())
+	}
+
+	image, err = db.LoadBootImage("compute")
+	if assert.NoError(err) {
+		assert.Equal(model.BootImageStatusReady, image.Status)
+	}
+}
+
+func TestFetchSourcesChanged(t *testing.T) {
+	assert := assert.New(t)
+
+	db, err := model.NewDataStore(":memory:")
+	if !assert.NoError(err) {
+		return
+	}
+
+	store, err := NewStore(t.TempDir())
+	if !assert.NoError(err) {
+		return
+	}
+
+	var srv *httptest.Server
+	mux := http.NewServeMux()
+	mux.HandleFunc("/files/old", func(w http.ResponseWriter, r *http.Request) {
+		// The sources are replaced while the old kernel is fetched
+		image, err := db.LoadBootImage("compute")
+		if assert.NoError(err) {
+			image.Sources = []*model.Source{{Kind: model.ArtifactKernel, URL: srv.URL + "/files/new"}}
+			image.ResetStatus()
+			assert.NoError(db.StoreBootImage(image))
+		}
+		fmt.Fprint(w, "old")
+	})
+	mux.HandleFunc("/files/new", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "new")
+	})
+	srv = httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+
+	f := NewFetcher(db, store)
+	f.Client = srv.Client()
+
+	assert.NoError(db.StoreBootImage(&model.BootImage{
+		Name:    "compute",
+		Sources: []*model.Source{{Kind: model.ArtifactKernel, URL: srv.URL + "/files/old"}},
+	}))
+
+	assert.Error(f.Fetch(context.Background(), "compute"))
+
+	image, err := db.LoadBootImage("compute")
+	if assert.NoError(err) {
+		assert.Equal(model.BootImageStatusPending, image.Status)
+		assert.False(image.Ready())
+		assert.Equal("", image.KernelPath)
+	}
+
+	assert.NoError(f.Fetch(context.Background(), "compute"))
+
+	image, err = db.LoadBootImage("compute")
+	if assert.NoError(err) {
+		assert.Equal(model.BootImageStatusReady, image.Status)
+		assert.Equal(store.Path(digest("new")), image.KernelPath)
+	}
+
+	// Fetcher writes are recorded in the audit log
+	entries, err := db.AuditEntries(&model.AuditFilter{})
+	if assert.NoError(err) && assert.NotEmpty(entries) {
+		assert.Equal("fetcher", entries[0].Caller)
+	}
+}
+
+func TestParseOCIReference(t *testing.T) {
+	assert := assert.New(t)
+
+	for _, test := range []struct {
+		url  string
+		repo string
+		ref  string
+	}{
+		{"oci://ghcr.io/ubccr/kernel", "ubccr/kernel", "latest"},
+		{"oci://ghcr.io/ubccr/kernel:6.1", "ubccr/kernel", "6.1"},
+		{"oci://localhost:5000/kernel:6.1", "kernel", "6.1"},
+		{"oci://ghcr.io/ubccr/kernel@sha256:" + digest("kernel"), "ubccr/kernel", "sha256:" + digest("kernel")},
+	} {
+		u, err := url.Parse(test.url)
+		if !assert.NoError(err) {
+			continue
+		}
+
+		repo, ref, err := parseOCIReference(u)
+		if assert.NoErrorf(err, "error for %s", test.url) {
+			assert.Equal(test.repo, repo)
+			assert.Equal(test.ref, ref)
+		}
+	}
+
+	_, _, err := parseOCIReference(&url.URL{Scheme: "oci", Host: "ghcr.io", Path: "/kernel:"})
+	assert.True(err != nil && strings.Contains(err.Error(), "invalid oci reference"))
+}
@@ -0,0 +1,185 @@
+// Copyright 2019 Grendel Authors. All rights reserved.
+//
+// This file is part of Grendel.
+//
+// Grendel is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Grendel is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with Grendel. If not, see <https://www.gnu.org/licenses/>.
+
+package artifact
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+const ociManifestAccept = "application/vnd.oci.image.manifest.v1+json, application/vnd.docker.distribution.manifest.v2+json"
+
+// ociChallengeRegexp matches the parameters of a WWW-Authenticate header
+var ociChallengeRegexp = regexp.MustCompile(`(\w+)="([^"]*)"`)
+
+type ociManifest struct {
+	Layers []struct {
+		MediaType string `json:"mediaType"`
+		Digest    string `json:"digest"`
+		Size      int64  `json:"size"`
+	} `json:"layers"`
+}
+
+// parseOCIReference splits an oci://registry/repository[:tag|@digest] URL
+// into the repository and reference. The tag defaults to latest.
+func parseOCIReference(u *url.URL) (string, string, error) {
+	repo := strings.TrimPrefix(u.Path, "/")
+	ref := "latest"
+
+	if i := strings.Index(repo, "@"); i >= 0 {
+		repo, ref = repo[:i], repo[i+1:]
+	} else if i := strings.LastIndex(repo, ":"); i > strings.LastIndex(repo, "/") {
+		repo, ref = repo[:i], repo[i+1:]
+	}
+
+	if repo == "" || ref == "" {
+		return "", "", fmt.Errorf("invalid oci reference %s", u)
+	}
+
+	return repo, ref, nil
+}
+
+// fetchOCI returns the content and sha256 digest of the single layer of an
+// OCI artifact. Registries requiring a bearer token are supported with
+// anonymous tokens.
+func (f *Fetcher) fetchOCI(ctx context.Context, u *url.URL, expected string) (io.ReadCloser, string, error) {
+	repo, ref, err := parseOCIReference(u)
+	if err != nil {
+		return nil, "", err
+	}
+
+	base := fmt.Sprintf("https://%s/v2/%s", u.Host, repo)
+	manifestURL := fmt.Sprintf("%s/manifests/%s", base, ref)
+
+	res, err := f.do(ctx, manifestURL, ociManifestAccept, "")
+	if err != nil {
+		return nil, "", err
+	}
+
+	token := ""
+	if res.StatusCode == http.StatusUnauthorized {
+		res.Body.Close()
+		token, err = f.ociToken(ctx, res.Header.Get("WWW-Authenticate"))
+		if err != nil {
+			return nil, "", err
+		}
+
+		res, err = f.do(ctx, manifestURL, ociManifestAccept, token)
+		if err != nil {
+			return nil, "", err
+		}
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return nil, "", fmt.Errorf("GET %s: %s", manifestURL, res.Status)
+	}
+
+	data, err := io.ReadAll(res.Body)
+	if err != nil {
+		return nil, "", err
+	}
+
+	if strings.HasPrefix(ref, "sha256:") {
+		sum := sha256.Sum256(data)
+		if "sha256:"+hex.EncodeToString(sum[:]) != ref {
+			return nil, "", fmt.Errorf("manifest of %s does not match its digest:  %w", u, ErrChecksumMismatch)
+		}
+	}
+
+	var manifest ociManifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return nil, "", fmt.Errorf("invalid manifest for %s: %w", u, err)
+	}
+
+	if len(manifest.Layers) != 1 {
+		return nil, "", fmt.Errorf("expected a single layer in %s got %d", u, len(manifest.Layers))
+	}
+
+	digest := strings.TrimPrefix(manifest.Layers[0].Digest, "sha256:")
+	if !digestRegexp.MatchString(digest) {
+		return nil, "", fmt.Errorf("unsupported layer digest %s:  %w", manifest.Layers[0].Digest, ErrInvalidDigest)
+	}
+
+	if expected != "" && expected != digest {
+		return nil, "", fmt.Errorf("expected sha256 %s got %s for %s:  %w", expected, digest, u, ErrChecksumMismatch)
+	}
+
+	body, err := f.get(ctx, fmt.Sprintf("%s/blobs/sha256:%s", base, digest), "", token)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return body, digest, nil
+}
+
+// ociToken requests an anonymous bearer token for the given challenge
+func (f *Fetcher) ociToken(ctx context.Context, challenge string) (string, error) {
+	if !strings.HasPrefix(strings.ToLower(challenge), "bearer ") {
+		return "", fmt.Errorf("unsupported registry auth challenge %q", challenge)
+	}
+
+	params := make(map[string]string)
+	for _, m := range ociChallengeRegexp.FindAllStringSubmatch(challenge, -1) {
+		params[strings.ToLower(m[1])] = m[2]
+	}
+
+	realm, err := url.Parse(params["realm"])
+	if err != nil || realm.Host == "" {
+		return "", fmt.Errorf("invalid registry auth realm %q", params["realm"])
+	}
+
+	query := realm.Query()
+	for _, k := range []string{"service", "scope"} {
+		if params[k] != "" {
+			query.Set(k, params[k])
+		}
+	}
+	realm.RawQuery = query.Encode()
+
+	body, err := f.get(ctx, realm.String(), "application/json", "")
+	if err != nil {
+		return "", err
+	}
+	defer body.Close()
+
+	var res struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+	}
+	if err := json.NewDecoder(body).Decode(&res); err != nil {
+		return "", err
+	}
+
+	if res.Token != "" {
+		return res.Token, nil
+	}
+	if res.AccessToken != "" {
+		return res.AccessToken, nil
+	}
+
+	return "", fmt.Errorf("no token from registry auth realm %s", realm.Host)
+}
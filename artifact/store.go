@@ -0,0 +1,362 @@
+// Copyright 2019 Grendel Authors. All rights reserved.
+//
+// This file is part of Grendel.
+//
+// Grendel is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Grendel is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with Grendel. If not, see <https://www.gnu.org/licenses/>.
+
+// Package artifact implements a content-addressed store for boot image files
+package artifact
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sync"
+	"time"
+
+	"github.com/ubccr/grendel/logger"
+	"github.com/ubccr/grendel/model"
+	"golang.org/x/sync/singleflight"
+)
+
+var log = logger.GetLogger("ARTIFACT")
+
+var (
+	ErrChecksumMismatch = errors.New("checksum mismatch")
+	ErrInvalidDigest    = errors.New("invalid sha256 digest")
+	ErrInvalidIndex     = errors.New("invalid initrd index")
+)
+
+var digestRegexp = regexp.MustCompile(`^[0-9a-f]{64}$`)
+
+// verified records the size and modification time of a file when its
+// checksum was last verified
+type verified struct {
+	size    int64
+	modTime time.Time
+}
+
+// Store is a directory of artifacts named by the sha256 checksum of their
+// content. Files are stored in Dir/sha256/<first 2 hex digits>/<digest>.
+type Store struct {
+	Dir string
+
+	// mu serializes adding artifacts with garbage collection
+	mu sync.Mutex
+
+	vmu      sync.Mutex
+	verified map[string]verified
+
+	// verifying shares the checksum of an artifact between concurrent callers
+	// of Verify so it's computed once when many hosts boot the same image
+	verifying singleflight.Group
+}
+
+// NewStore returns an artifact store in dir, creating it if needed
+func NewStore(dir string) (*Store, error) {
+	for _, d := range []string{filepath.Join(dir, "sha256"), filepath.Join(dir, "tmp")} {
+		if err := os.MkdirAll(d, 0755); err != nil {
+			return nil, err
+		}
+	}
+
+	return &Store{Dir: dir, verified: make(map[string]verified)}, nil
+}
+
+// Path returns the path of the artifact with the given digest or an empty
+// string if the digest is invalid
+func (s *Store) Path(digest string) string {
+	if !digestRegexp.MatchString(digest) {
+		return ""
+	}
+
+	return filepath.Join(s.Dir, "sha256", digest[:2], digest)
+}
+
+// Add stores the content read from r. If digest is not empty the checksum of
+// the content must match it. commit is called with the new artifact and its
+// path while no garbage collection can run and should store the boot image
+// referencing it. The file is removed if commit fails and no other image
+// references it.
+func (s *Store) Add(r io.Reader, digest string, commit func(a *model.Artifact, path string) error) (*model.Artifact, error) {
+	if digest != "" && !digestRegexp.MatchString(digest) {
+		return nil, ErrInvalidDigest
+	}
+
+	tmp, err := os.CreateTemp(filepath.Join(s.Dir, "tmp"), "upload-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(tmp.Name())
+
+	hash := sha256.New()
+	size, err := io.Copy(io.MultiWriter(tmp, hash), r)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	sum := hex.EncodeToString(hash.Sum(nil))
+	if digest != "" && digest != sum {
+		return nil, fmt.Errorf("expected sha256 %s got %s:  %w", digest, sum, ErrChecksumMismatch)
+	}
+
+	a := &model.Artifact{SHA256: sum, Size: size}
+	path := s.Path(sum)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_, err = os.Stat(path)
+	exists := err == nil
+	if !exists {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return nil, err
+		}
+		if err := os.Chmod(tmp.Name(), 0644); err != nil {
+			return nil, err
+		}
+		if err := os.Rename(tmp.Name(), path); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := commit(a, path); err != nil {
+		if !exists {
+			os.Remove(path)
+		}
+		return nil, err
+	}
+
+	log.Infof("Stored artifact %s (%d bytes)", sum, size)
+
+	return a, nil
+}
+
+// AddExisting calls commit with the stored artifact with the given digest, if
+// any, so an image can reference it without adding the content again. It
+// returns nil if the artifact is not stored.
+func (s *Store) AddExisting(digest string, commit func(a *model.Artifact, path string) error) (*model.Artifact, error) {
+	path := s.Path(digest)
+	if path == "" {
+		return nil, ErrInvalidDigest
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	info, err := os.Stat(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	a := &model.Artifact{SHA256: digest, Size: info.Size()}
+	if err := s.Verify(a); errors.Is(err, ErrChecksumMismatch) {
+		log.Warnf("Removing corrupted artifact %s", digest)
+		return nil, os.Remove(path)
+	} else if err != nil {
+		return nil, err
+	}
+
+	if err := commit(a, path); err != nil {
+		return nil, err
+	}
+
+	return a, nil
+}
+
+// Attach sets the path of the artifact on the image according to its kind and
+// records it, dropping the artifacts no longer used by any path of the image.
+// Initrds are replaced at the given index or appended if index is the number
+// of initrds.
+func (s *Store) Attach(image *model.BootImage, a *model.Artifact, path string, index int) error {
+	switch a.Kind {
+	case model.ArtifactKernel:
+		image.KernelPath = path
+	case model.ArtifactInitrd:
+		if index < 0 || index > len(image.InitrdPaths) {
+			return ErrInvalidIndex
+		}
+		if index == len(image.InitrdPaths) {
+			image.InitrdPaths = append(image.InitrdPaths, path)
+		} else {
+			image.InitrdPaths[index] = path
+		}
+	case model.ArtifactLiveImg:
+		image.LiveImage = path
+	case model.ArtifactRootFS:
+		image.RootFS = path
+	default:
+		return fmt.Errorf("invalid artifact kind %q", a.Kind)
+	}
+
+	paths := make(map[string]bool)
+	for _, p := range image.Paths() {
+		paths[p] = true
+	}
+
+	artifacts := make([]*model.Artifact, 0, len(image.Artifacts)+1)
+	for _, old := range image.Artifacts {
+		if old.Kind == a.Kind && old.SHA256 == a.SHA256 {
+			continue
+		}
+		if p := s.Path(old.SHA256); p == "" || !paths[p] {
+			continue
+		}
+		artifacts = append(artifacts, old)
+	}
+
+	image.Artifacts = append(artifacts, a)
+
+	return nil
+}
+
+// Find returns the artifact of the image stored at path or nil if path is not
+// an artifact of the image
+func (s *Store) Find(image *model.BootImage, path string) *model.Artifact {
+	for _, a := range image.Artifacts {
+		if p := s.Path(a.SHA256); p != "" && p == path {
+			return a
+		}
+	}
+
+	return nil
+}
+
+// Verify checks the size and checksum of the stored artifact. The checksum is
+// only computed again when the file has changed since it was last verified,
+// and concurrent calls for the same artifact wait for a single computation.
+func (s *Store) Verify(a *model.Artifact) error {
+	if !digestRegexp.MatchString(a.SHA256) {
+		return ErrInvalidDigest
+	}
+
+	path := s.Path(a.SHA256)
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+
+	if info.Size() != a.Size {
+		return fmt.Errorf("expected size %d got %d for artifact %s:  %w", a.Size, info.Size(), a.SHA256, ErrChecksumMismatch)
+	}
+
+	s.vmu.Lock()
+	v, ok := s.verified[path]
+	s.vmu.Unlock()
+	if ok && v.size == info.Size() && v.modTime.Equal(info.ModTime()) {
+		return nil
+	}
+
+	_, err, _ = s.verifying.Do(path, func() (interface{}, error) {
+		return nil, s.checksum(a, path)
+	})
+
+	return err
+}
+
+// checksum computes the checksum of the artifact stored at path and records it
+// as verified if it matches
+func (s *Store) checksum(a *model.Artifact, path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return err
+	}
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return err
+	}
+
+	if sum := hex.EncodeToString(hash.Sum(nil)); sum != a.SHA256 {
+		return fmt.Errorf("got sha256 %s for artifact %s:  %w", sum, a.SHA256, ErrChecksumMismatch)
+	}
+
+	s.vmu.Lock()
+	s.verified[path] = verified{size: info.Size(), modTime: info.ModTime()}
+	s.vmu.Unlock()
+
+	return nil
+}
+
+// GC removes stored artifacts which are not referenced by any boot image and
+// returns their digests
+func (s *Store) GC(db model.DataStore) ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	images, err := db.BootImages()
+	if err != nil {
+		return nil, err
+	}
+
+	keep := make(map[string]bool)
+	for _, image := range images {
+		for _, a := range image.Artifacts {
+			keep[a.SHA256] = true
+		}
+		for _, src := range image.Sources {
+			keep[src.SHA256] = true
+		}
+		for _, p := range image.Paths() {
+			keep[filepath.Base(p)] = true
+		}
+	}
+
+	removed := make([]string, 0)
+	err = filepath.WalkDir(filepath.Join(s.Dir, "sha256"), func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if d.IsDir() || !digestRegexp.MatchString(d.Name()) || keep[d.Name()] {
+			return nil
+		}
+
+		if err := os.Remove(path); err != nil {
+			return err
+		}
+
+		s.vmu.Lock()
+		delete(s.verified, path)
+		s.vmu.Unlock()
+
+		log.Infof("Removed unreferenced artifact %s", d.Name())
+		removed = append(removed, d.Name())
+
+		return nil
+	})
+	if err != nil {
+		return removed, err
+	}
+
+	return removed, nil
+}
@@ -0,0 +1,149 @@
+// Copyright 2019 Grendel Authors. All rights reserved.
+//
+// This file is part of Grendel.
+//
+// Grendel is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Grendel is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with Grendel. If not, see <https://www.gnu.org/licenses/>.
+
+package artifact
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/ubccr/grendel/model"
+)
+
+func digest(data string) string {
+	sum := sha256.Sum256([]byte(data))
+	return hex.EncodeToString(sum[:])
+}
+
+func TestStore(t *testing.T) {
+	assert := assert.New(t)
+
+	db, err := model.NewDataStore(":memory:")
+	if !assert.NoError(err) {
+		return
+	}
+
+	s, err := NewStore(t.TempDir())
+	if !assert.NoError(err) {
+		return
+	}
+
+	image := &model.BootImage{Name: "compute"}
+	a, err := s.Add(strings.NewReader("kernel"), digest("kernel"), func(a *model.Artifact, path string) error {
+		a.Kind = model.ArtifactKernel
+		image.KernelPath = path
+		image.Artifacts = append(image.Artifacts, a)
+		return db.StoreBootImage(image)
+	})
+	if assert.NoError(err) {
+		assert.Equal(digest("kernel"), a.SHA256)
+		assert.Equal(int64(6), a.Size)
+		assert.Equal(s.Path(a.SHA256), image.KernelPath)
+		assert.Equal(a, s.Find(image, image.KernelPath))
+		assert.NoError(s.Verify(a))
+	}
+
+	_, err = s.Add(strings.NewReader("initrd"), digest("kernel"), func(a *model.Artifact, path string) error {
+		return nil
+	})
+	assert.True(errors.Is(err, ErrChecksumMismatch))
+
+	_, err = s.Add(strings.NewReader("initrd"), "bad", func(a *model.Artifact, path string) error {
+		return nil
+	})
+	assert.True(errors.Is(err, ErrInvalidDigest))
+
+	_, err = s.Add(strings.NewReader("initrd"), "", func(a *model.Artifact, path string) error {
+		return errors.New("failed")
+	})
+	assert.Error(err)
+	_, err = os.Stat(s.Path(digest("initrd")))
+	assert.True(os.IsNotExist(err))
+
+	// Corrupted artifacts of the same size fail verification
+	err = os.WriteFile(image.KernelPath, []byte("KERNEL"), 0644)
+	assert.NoError(err)
+	later := time.Now().Add(time.Hour)
+	err = os.Chtimes(image.KernelPath, later, later)
+	assert.NoError(err)
+	assert.True(errors.Is(s.Verify(a), ErrChecksumMismatch))
+
+	unused, err := s.Add(strings.NewReader("unused"), "", func(a *model.Artifact, path string) error {
+		return nil
+	})
+	assert.NoError(err)
+
+	removed, err := s.GC(db)
+	if assert.NoError(err) {
+		assert.Equal([]string{unused.SHA256}, removed)
+	}
+	_, err = os.Stat(image.KernelPath)
+	assert.NoError(err)
+}
+
+func TestVerifyConcurrent(t *testing.T) {
+	assert := assert.New(t)
+
+	s, err := NewStore(t.TempDir())
+	if !assert.NoError(err) {
+		return
+	}
+
+	data := strings.Repeat("rootfs", 1<<16)
+	a, err := s.Add(strings.NewReader(data), "", func(a *model.Artifact, path string) error {
+		return nil
+	})
+	if !assert.NoError(err) {
+		return
+	}
+
+	verify := func() []error {
+		errs := make([]error, 50)
+		var wg sync.WaitGroup
+		for i := range errs {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				errs[i] = s.Verify(a)
+			}(i)
+		}
+		wg.Wait()
+		return errs
+	}
+
+	for _, err := range verify() {
+		assert.NoError(err)
+	}
+	assert.Len(s.verified, 1)
+
+	// Every caller waiting on a failed checksum gets the error
+	err = os.WriteFile(s.Path(a.SHA256), []byte(strings.ToUpper(data)), 0644)
+	assert.NoError(err)
+	later := time.Now().Add(time.Hour)
+	err = os.Chtimes(s.Path(a.SHA256), later, later)
+	assert.NoError(err)
+	for _, err := range verify() {
+		assert.True(errors.Is(err, ErrChecksumMismatch))
+	}
+}
@@ -12,6 +12,7 @@ package client
 
 import (
 	_context "context"
+	_io "io"
 	_ioutil "io/ioutil"
 	_nethttp "net/http"
 	_neturl "net/url"
@@ -378,3 +379,113 @@ func (a *ImageApiService) StoreImages(ctx _context.Context, body model.BootImage
 
 	return localVarHTTPResponse, nil
 }
+
+// ImageArtifactUploadOpts - Optional Parameters for ImageArtifactUpload
+type ImageArtifactUploadOpts struct {
+	Index    int
+	Filename string
+	Sha256   string
+}
+
+/*
+ImageArtifactUpload Upload a boot image artifact
+Stores a kernel, initrd, live image or rootfs in the artifact store and sets it on the boot image. The file is streamed as the request body.
+ * @param ctx _context.Context - for authentication, logging, cancellation, deadlines, tracing, etc. Passed from http.Request or context.Background().
+ * @param name Name of boot image
+ * @param kind Kind of artifact: kernel, initrd, liveimg or rootfs
+ * @param body Content of the artifact
+ * @param size Size of the artifact in bytes
+ * @param optional nil or *ImageArtifactUploadOpts - Optional Parameters:
+ * @param "Index" (int) index of the initrd to replace
+ * @param "Filename" (string) original file name
+ * @param "Sha256" (string) expected sha256 checksum of the content
+@return Artifact
+*/
+func (a *ImageApiService) ImageArtifactUpload(ctx _context.Context, name, kind string, body _io.Reader, size int64, localVarOptionals *ImageArtifactUploadOpts) (model.Artifact, *_nethttp.Response, error) {
+	var (
+		localVarHTTPMethod   = _nethttp.MethodPost
+		localVarPostBody     interface{}
+		localVarFormFileName string
+		localVarFileName     string
+		localVarFileBytes    []byte
+		localVarReturnValue  model.Artifact
+	)
+
+	// create path and map variables
+	localVarPath := a.client.cfg.BasePath + "/bootimage/{name}/artifact"
+	localVarPath = strings.Replace(localVarPath, "{"+"name"+"}", _neturl.QueryEscape(parameterToString(name, "")), -1)
+
+	localVarHeaderParams := make(map[string]string)
+	localVarQueryParams := _neturl.Values{}
+	localVarFormParams := _neturl.Values{}
+
+	localVarQueryParams.Add("kind", parameterToString(kind, ""))
+	if localVarOptionals != nil && localVarOptionals.Index != 0 {
+		localVarQueryParams.Add("index", parameterToString(localVarOptionals.Index, ""))
+	}
+	if localVarOptionals != nil && localVarOptionals.Filename != "" {
+		localVarQueryParams.Add("filename", parameterToString(localVarOptionals.Filename, ""))
+	}
+	if localVarOptionals != nil && localVarOptionals.Sha256 != "" {
+		localVarQueryParams.Add("sha256", parameterToString(localVarOptionals.Sha256, ""))
+	}
+
+	// set Content-Type header
+	localVarHeaderParams["Content-Type"] = "application/octet-stream"
+
+	// to determine the Accept header
+	localVarHTTPHeaderAccepts := []string{"application/json"}
+
+	// set Accept header
+	localVarHTTPHeaderAccept := selectHeaderAccept(localVarHTTPHeaderAccepts)
+	if localVarHTTPHeaderAccept != "" {
+		localVarHeaderParams["Accept"] = localVarHTTPHeaderAccept
+	}
+	r, err := a.client.prepareRequest(ctx, localVarPath, localVarHTTPMethod, localVarPostBody, localVarHeaderParams, localVarQueryParams, localVarFormParams, localVarFormFileName, localVarFileName, localVarFileBytes)
+	if err != nil {
+		return localVarReturnValue, nil, err
+	}
+
+	// Stream the body instead of buffering it, artifacts can be large
+	r.Body = _ioutil.NopCloser(body)
+	r.ContentLength = size
+
+	localVarHTTPResponse, err := a.client.callAPI(r)
+	if err != nil || localVarHTTPResponse == nil {
+		return localVarReturnValue, localVarHTTPResponse, err
+	}
+
+	localVarBody, err := _ioutil.ReadAll(localVarHTTPResponse.Body)
+	localVarHTTPResponse.Body.Close()
+	if err != nil {
+		return localVarReturnValue, localVarHTTPResponse, err
+	}
+
+	if localVarHTTPResponse.StatusCode >= 300 {
+		newErr := GenericOpenAPIError{
+			body:  localVarBody,
+			error: localVarHTTPResponse.Status,
+		}
+		if localVarHTTPResponse.StatusCode == 400 || localVarHTTPResponse.StatusCode == 500 {
+			var v ErrorResponse
+			err = a.client.decode(&v, localVarBody, localVarHTTPResponse.Header.Get("Content-Type"))
+			if err != nil {
+				newErr.error = err.Error()
+				return localVarReturnValue, localVarHTTPResponse, newErr
+			}
+			newErr.model = v
+		}
+		return localVarReturnValue, localVarHTTPResponse, newErr
+	}
+
+	err = a.client.decode(&localVarReturnValue, localVarBody, localVarHTTPResponse.Header.Get("Content-Type"))
+	if err != nil {
+		newErr := GenericOpenAPIError{
+			body:  localVarBody,
+			error: err.Error(),
+		}
+		return localVarReturnValue, localVarHTTPResponse, newErr
+	}
+
+	return localVarReturnValue, localVarHTTPResponse, nil
+}
@@ -0,0 +1,141 @@
+/*
+ * Grendel API
+ *
+ * Bare Metal Provisioning system for HPC Linux clusters. Find out more about Grendel at [https://github.com/ubccr/grendel](https://github.com/ubccr/grendel)
+ *
+ * API version: 1.0.0
+ * Contact: aebruno2@buffalo.edu
+ * Generated by: OpenAPI Generator (https://openapi-generator.tech)
+ */
+
+package client
+
+import (
+	_context "context"
+	_ioutil "io/ioutil"
+	_nethttp "net/http"
+	_neturl "net/url"
+	"github.com/ubccr/grendel/model"
+)
+
+// Linger please
+var (
+	_ _context.Context
+)
+
+// ProvisionApiService ProvisionApi service
+type ProvisionApiService service
+
+// ProvisionStatusOpts - Optional Parameters for ProvisionStatus
+type ProvisionStatusOpts struct {
+	NodeSet string
+	State   string
+	Stuck   bool
+}
+
+/*
+ProvisionStatus List host provisioning status
+Returns the provisioning record of each host with the last state reached, timestamps and the last client IP
+ * @param ctx _context.Context - for authentication, logging, cancellation, deadlines, tracing, etc. Passed from http.Request or context.Background().
+ * @param optional nil or *ProvisionStatusOpts - Optional Parameters:
+ * @param "NodeSet" (string) only hosts in nodeset
+ * @param "State" (string) only hosts in state. Example: kernel
+ * @param "Stuck" (bool) only hosts stuck in a state longer than the stuck timeout
+@return []ProvisionRecord
+*/
+func (a *ProvisionApiService) ProvisionStatus(ctx _context.Context, localVarOptionals *ProvisionStatusOpts) (model.ProvisionRecordList, *_nethttp.Response, error) {
+	var (
+		localVarHTTPMethod   = _nethttp.MethodGet
+		localVarPostBody     interface{}
+		localVarFormFileName string
+		localVarFileName     string
+		localVarFileBytes    []byte
+		localVarReturnValue  model.ProvisionRecordList
+	)
+
+	// create path and map variables
+	localVarPath := a.client.cfg.BasePath + "/provision/status"
+	localVarHeaderParams := make(map[string]string)
+	localVarQueryParams := _neturl.Values{}
+	localVarFormParams := _neturl.Values{}
+
+	if localVarOptionals != nil && localVarOptionals.NodeSet != "" {
+		localVarQueryParams.Add("nodeset", parameterToString(localVarOptionals.NodeSet, ""))
+	}
+	if localVarOptionals != nil && localVarOptionals.State != "" {
+		localVarQueryParams.Add("state", parameterToString(localVarOptionals.State, ""))
+	}
+	if localVarOptionals != nil && localVarOptionals.Stuck != false {
+		localVarQueryParams.Add("stuck", parameterToString(localVarOptionals.Stuck, ""))
+	}
+	// to determine the Content-Type header
+	localVarHTTPContentTypes := []string{}
+
+	// set Content-Type header
+	localVarHTTPContentType := selectHeaderContentType(localVarHTTPContentTypes)
+	if localVarHTTPContentType != "" {
+		localVarHeaderParams["Content-Type"] = localVarHTTPContentType
+	}
+
+	// to determine the Accept header
+	localVarHTTPHeaderAccepts := []string{"application/json"}
+
+	// set Accept header
+	localVarHTTPHeaderAccept := selectHeaderAccept(localVarHTTPHeaderAccepts)
+	if localVarHTTPHeaderAccept != "" {
+		localVarHeaderParams["Accept"] = localVarHTTPHeaderAccept
+	}
+	r, err := a.client.prepareRequest(ctx, localVarPath, localVarHTTPMethod, localVarPostBody, localVarHeaderParams, localVarQueryParams, localVarFormParams, localVarFormFileName, localVarFileName, localVarFileBytes)
+	if err != nil {
+		return localVarReturnValue, nil, err
+	}
+
+	localVarHTTPResponse, err := a.client.callAPI(r)
+	if err != nil || localVarHTTPResponse == nil {
+		return localVarReturnValue, localVarHTTPResponse, err
+	}
+
+	localVarBody, err := _ioutil.ReadAll(localVarHTTPResponse.Body)
+	localVarHTTPResponse.Body.Close()
+	if err != nil {
+		return localVarReturnValue, localVarHTTPResponse, err
+	}
+
+	if localVarHTTPResponse.StatusCode >= 300 {
+		newErr := GenericOpenAPIError{
+			body:  localVarBody,
+			error: localVarHTTPResponse.Status,
+		}
+		if localVarHTTPResponse.StatusCode == 400 {
+			var v ErrorResponse
+			err = a.client.decode(&v, localVarBody, localVarHTTPResponse.Header.Get("Content-Type"))
+			if err != nil {
+				newErr.error = err.Error()
+				return localVarReturnValue, localVarHTTPResponse, newErr
+			}
+			newErr.model = v
+			return localVarReturnValue, localVarHTTPResponse, newErr
+		}
+		if localVarHTTPResponse.StatusCode == 500 {
+			var v ErrorResponse
+			err = a.client.decode(&v, localVarBody, localVarHTTPResponse.Header.Get("Content-Type"))
+			if err != nil {
+				newErr.error = err.Error()
+				return localVarReturnValue, localVarHTTPResponse, newErr
+			}
+			newErr.model = v
+		}
+		return localVarReturnValue, localVarHTTPResponse, newErr
+	}
+
+	err = a.client.decode(&localVarReturnValue, localVarBody, localVarHTTPResponse.Header.Get("Content-Type"))
+	if err != nil {
+		newErr := GenericOpenAPIError{
+			body:  localVarBody,
+			error: err.Error(),
+		}
+		return localVarReturnValue, localVarHTTPResponse, newErr
+	}
+
+	return localVarReturnValue, localVarHTTPResponse, nil
+}
@@ -49,9 +49,17 @@ type APIClient struct {
 
 	// API Services
 
+	AuditApi *AuditApiService
+
+	DhcpApi *DhcpApiService
+
 	HostApi *HostApiService
 
 	ImageApi *ImageApiService
+
+	ProvisionApi *ProvisionApiService
+
+	UnregisteredApi *UnregisteredApiService
 }
 
 type service struct {
@@ -70,8 +78,12 @@ func NewAPIClient(cfg *Configuration) *APIClient {
 	c.common.client = c
 
 	// API Services
+	c.AuditApi = (*AuditApiService)(&c.common)
+	c.DhcpApi = (*DhcpApiService)(&c.common)
 	c.HostApi = (*HostApiService)(&c.common)
 	c.ImageApi = (*ImageApiService)(&c.common)
+	c.ProvisionApi = (*ProvisionApiService)(&c.common)
+	c.UnregisteredApi = (*UnregisteredApiService)(&c.common)
 
 	return c
 }
@@ -19,10 +19,15 @@ package all
 
 import (
 	_ "github.com/ubccr/grendel/cmd"
+	_ "github.com/ubccr/grendel/cmd/audit"
 	_ "github.com/ubccr/grendel/cmd/bmc"
+	_ "github.com/ubccr/grendel/cmd/db"
+	_ "github.com/ubccr/grendel/cmd/dhcp"
 	_ "github.com/ubccr/grendel/cmd/discover"
 	_ "github.com/ubccr/grendel/cmd/host"
 	_ "github.com/ubccr/grendel/cmd/image"
 	_ "github.com/ubccr/grendel/cmd/serve"
 	_ "github.com/ubccr/grendel/cmd/status"
+	_ "github.com/ubccr/grendel/cmd/token"
+	_ "github.com/ubccr/grendel/cmd/watch"
 )
@@ -0,0 +1,118 @@
+// Copyright 2019 Grendel Authors. All rights reserved.
+//
+// This file is part of Grendel.
+//
+// Grendel is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Grendel is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with Grendel. If not, see <https://www.gnu.org/licenses/>.
+
+package audit
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/ubccr/grendel/client"
+	"github.com/ubccr/grendel/cmd"
+	"github.com/ubccr/grendel/model"
+)
+
+var (
+	opts      client.AuditListOpts
+	since     time.Duration
+	showDiff  bool
+	printJSON bool
+	auditCmd  = &cobra.Command{
+		Use:   "audit [nodeset]",
+		Short: "Show audit log",
+		Long:  `Show audit log of host and boot image changes, newest first`,
+		Args:  cobra.MaximumNArgs(1),
+		RunE: func(command *cobra.Command, args []string) error {
+			gc, err := cmd.NewClient()
+			if err != nil {
+				return err
+			}
+
+			if len(args) == 1 {
+				opts.NodeSet = args[0]
+			}
+
+			if since > 0 {
+				opts.Since = time.Now().Add(-since).Format(time.RFC3339)
+			}
+
+			entries, _, err := gc.AuditApi.AuditList(cmd.NewContext(), &opts)
+			if err != nil {
+				return cmd.NewApiError("Failed to fetch audit log", err)
+			}
+
+			if printJSON {
+				enc := json.NewEncoder(os.Stdout)
+				enc.SetIndent("", "    ")
+				return enc.Encode(entries)
+			}
+
+			fmt.Printf("%-10s%-27s%-20s%-19s%s\n", "Revision", "Time", "Caller", "Operation", "Changed")
+			for _, entry := range entries {
+				fmt.Printf("%-10d%-27s%-20s%-19s%s\n",
+					entry.ID,
+					entry.Timestamp.Local().Format(time.RFC3339),
+					entry.Caller,
+					entry.Operation,
+					entry.NodeSet)
+
+				if showDiff {
+					printChanges(entry)
+				}
+			}
+
+			return nil
+		},
+	}
+)
+
+func printChanges(entry *model.AuditEntry) {
+	for _, c := range entry.Changes {
+		fmt.Printf("    %s %s\n", c.Kind, c.Name)
+		for _, d := range c.Diff {
+			fmt.Printf("        %s: %s -> %s\n", d.Path, formatValue(d.Before), formatValue(d.After))
+		}
+	}
+}
+
+func formatValue(v interface{}) string {
+	if v == nil {
+		return "-"
+	}
+
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprintf("%v", v)
+	}
+
+	return strings.TrimSpace(string(data))
+}
+
+func init() {
+	auditCmd.Flags().StringVar(&opts.Kind, "kind", "", "filter by record kind (host or image)")
+	auditCmd.Flags().StringVar(&opts.Operation, "operation", "", "filter by operation (e.g. host.tag)")
+	auditCmd.Flags().StringVar(&opts.Caller, "caller", "", "filter by API caller")
+	auditCmd.Flags().DurationVar(&since, "since", 0, "only show entries newer than duration (e.g. 24h)")
+	auditCmd.Flags().IntVarP(&opts.Limit, "limit", "n", 50, "maximum number of entries")
+	auditCmd.Flags().BoolVarP(&showDiff, "diff", "d", false, "show changed fields")
+	auditCmd.Flags().BoolVar(&printJSON, "json", false, "output json")
+	cmd.Root.AddCommand(auditCmd)
+}
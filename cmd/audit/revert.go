@@ -0,0 +1,61 @@
+// Copyright 2019 Grendel Authors. All rights reserved.
+//
+// This file is part of Grendel.
+//
+// Grendel is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Grendel is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with Grendel. If not, see <https://www.gnu.org/licenses/>.
+
+package audit
+
+import (
+	"strconv"
+
+	"github.com/spf13/cobra"
+	"github.com/ubccr/grendel/client"
+	"github.com/ubccr/grendel/cmd"
+)
+
+var (
+	revertBefore bool
+	revertCmd    = &cobra.Command{
+		Use:   "revert <host> <revision>",
+		Short: "Revert host to a previous revision",
+		Long:  `Revert host to its state after the given audit log revision. Use --before to restore its state prior to the revision`,
+		Args:  cobra.ExactArgs(2),
+		RunE: func(command *cobra.Command, args []string) error {
+			revision, err := strconv.ParseInt(args[1], 10, 64)
+			if err != nil {
+				return err
+			}
+
+			gc, err := cmd.NewClient()
+			if err != nil {
+				return err
+			}
+
+			_, _, err = gc.HostApi.HostRevert(cmd.NewContext(), args[0], revision, &client.HostRevertOpts{Before: revertBefore})
+			if err != nil {
+				return cmd.NewApiError("Failed to revert host", err)
+			}
+
+			cmd.Log.Infof("Successfully reverted host %s to revision %d", args[0], revision)
+
+			return nil
+		},
+	}
+)
+
+func init() {
+	revertCmd.Flags().BoolVar(&revertBefore, "before", false, "restore state prior to the revision")
+	auditCmd.AddCommand(revertCmd)
+}
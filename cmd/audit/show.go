@@ -0,0 +1,60 @@
+// Copyright 2019 Grendel Authors. All rights reserved.
+//
+// This file is part of Grendel.
+//
+// Grendel is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Grendel is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with Grendel. If not, see <https://www.gnu.org/licenses/>.
+
+package audit
+
+import (
+	"encoding/json"
+	"os"
+	"strconv"
+
+	"github.com/spf13/cobra"
+	"github.com/ubccr/grendel/cmd"
+)
+
+var (
+	showCmd = &cobra.Command{
+		Use:   "show <revision>",
+		Short: "Show audit log entry",
+		Long:  `Show audit log entry including the state of each record before and after the change`,
+		Args:  cobra.ExactArgs(1),
+		RunE: func(command *cobra.Command, args []string) error {
+			id, err := strconv.ParseInt(args[0], 10, 64)
+			if err != nil {
+				return err
+			}
+
+			gc, err := cmd.NewClient()
+			if err != nil {
+				return err
+			}
+
+			entry, _, err := gc.AuditApi.AuditFind(cmd.NewContext(), id)
+			if err != nil {
+				return cmd.NewApiError("Failed to fetch audit log entry", err)
+			}
+
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "    ")
+			return enc.Encode(entry)
+		},
+	}
+)
+
+func init() {
+	auditCmd.AddCommand(showCmd)
+}
@@ -18,7 +18,6 @@
 package bmc
 
 import (
-	"context"
 	"errors"
 	"fmt"
 	"strings"
@@ -97,13 +96,13 @@ func init() {
 		}
 
 		if len(tags) > 0 && len(args) == 0 {
-			hostList, _, err = gc.HostApi.HostTags(context.Background(), strings.Join(tags, ","))
+			hostList, _, err = gc.HostApi.HostTags(cmd.NewContext(), strings.Join(tags, ","))
 			if err != nil {
 				return cmd.NewApiError("Failed to find hosts by tag", err)
 			}
 		} else {
 			nodes := strings.Join(args, ",")
-			hostList, _, err = gc.HostApi.HostFind(context.Background(), nodes)
+			hostList, _, err = gc.HostApi.HostFind(cmd.NewContext(), nodes)
 			if err != nil {
 				return cmd.NewApiError("Failed to find hosts", err)
 			}
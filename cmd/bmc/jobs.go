@@ -26,6 +26,7 @@ import (
 	"github.com/sirupsen/logrus"
 	"github.com/ubccr/grendel/bmc"
 	"github.com/ubccr/grendel/cmd"
+	"github.com/ubccr/grendel/logger"
 	"github.com/ubccr/grendel/model"
 )
 
@@ -46,9 +47,9 @@ func (j *JobRunner) RunStatus(host *model.Host) {
 		sysmgr, err := systemMgr(host)
 		if err != nil {
 			cmd.Log.WithFields(logrus.Fields{
-				"err":  err,
-				"name": host.Name,
-				"ID":   host.ID,
+				"err":            err,
+				logger.FieldHost: host.Name,
+				"ID":             host.ID,
 			}).Error("Failed to connect to BMC")
 			return
 		}
@@ -57,9 +58,9 @@ func (j *JobRunner) RunStatus(host *model.Host) {
 		system, err := sysmgr.GetSystem()
 		if err != nil {
 			cmd.Log.WithFields(logrus.Fields{
-				"err":  err,
-				"name": host.Name,
-				"ID":   host.ID,
+				"err":            err,
+				logger.FieldHost: host.Name,
+				"ID":             host.ID,
 			}).Error("Failed to fetch system info from BMC")
 			return
 		}
@@ -85,9 +86,9 @@ func (j *JobRunner) RunStatus(host *model.Host) {
 
 		if err := enc.Encode(rec); err != nil {
 			cmd.Log.WithFields(logrus.Fields{
-				"err":  err,
-				"name": host.Name,
-				"ID":   host.ID,
+				"err":            err,
+				logger.FieldHost: host.Name,
+				"ID":             host.ID,
 			}).Error("Failed to encode json")
 		}
 	})
@@ -98,9 +99,9 @@ func (j *JobRunner) RunNetBoot(host *model.Host, reboot bool) {
 		sysmgr, err := systemMgr(host)
 		if err != nil {
 			cmd.Log.WithFields(logrus.Fields{
-				"err":  err,
-				"name": host.Name,
-				"ID":   host.ID,
+				"err":            err,
+				logger.FieldHost: host.Name,
+				"ID":             host.ID,
 			}).Error("Failed to connect to BMC")
 			return
 		}
@@ -109,9 +110,9 @@ func (j *JobRunner) RunNetBoot(host *model.Host, reboot bool) {
 		err = sysmgr.EnablePXE()
 		if err != nil {
 			cmd.Log.WithFields(logrus.Fields{
-				"err":  err,
-				"name": host.Name,
-				"ID":   host.ID,
+				"err":            err,
+				logger.FieldHost: host.Name,
+				"ID":             host.ID,
 			}).Error("Failed to enabel PXE on next boot")
 			return
 		}
@@ -120,9 +121,9 @@ func (j *JobRunner) RunNetBoot(host *model.Host, reboot bool) {
 			err = sysmgr.PowerCycle()
 			if err != nil {
 				cmd.Log.WithFields(logrus.Fields{
-					"err":  err,
-					"name": host.Name,
-					"ID":   host.ID,
+					"err":            err,
+					logger.FieldHost: host.Name,
+					"ID":             host.ID,
 				}).Error("Failed to power cycle node")
 				return
 			}
@@ -137,9 +138,9 @@ func (j *JobRunner) RunPower(host *model.Host, powerType int) {
 		sysmgr, err := systemMgr(host)
 		if err != nil {
 			cmd.Log.WithFields(logrus.Fields{
-				"err":  err,
-				"name": host.Name,
-				"ID":   host.ID,
+				"err":            err,
+				logger.FieldHost: host.Name,
+				"ID":             host.ID,
 			}).Error("Failed to connect to BMC")
 			return
 		}
@@ -158,9 +159,9 @@ func (j *JobRunner) RunPower(host *model.Host, powerType int) {
 
 		if err != nil {
 			cmd.Log.WithFields(logrus.Fields{
-				"err":  err,
-				"name": host.Name,
-				"ID":   host.ID,
+				"err":            err,
+				logger.FieldHost: host.Name,
+				"ID":             host.ID,
 			}).Error("Failed to power cycle node")
 			return
 		}
@@ -0,0 +1,72 @@
+// Copyright 2019 Grendel Authors. All rights reserved.
+//
+// This file is part of Grendel.
+//
+// Grendel is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Grendel is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with Grendel. If not, see <https://www.gnu.org/licenses/>.
+
+package db
+
+import (
+	"errors"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"github.com/ubccr/grendel/cmd"
+	"github.com/ubccr/grendel/model"
+)
+
+var (
+	DB    model.DataStore
+	dbCmd = &cobra.Command{
+		Use:   "db",
+		Short: "Database commands",
+		Long:  `Database commands. These open the database directly and should only be run while grendel serve is stopped`,
+	}
+)
+
+func init() {
+	dbCmd.PersistentFlags().String("dbpath", ":memory:", "path to database file or URL (sqlite:///path/to/grendel.db)")
+
+	dbCmd.PersistentPreRunE = func(command *cobra.Command, args []string) error {
+		err := cmd.SetupLogging()
+		if err != nil {
+			return err
+		}
+
+		viper.BindPFlag("dbpath", dbCmd.PersistentFlags().Lookup("dbpath"))
+		dbpath := viper.GetString("dbpath")
+		if dbpath == ":memory:" {
+			return errors.New("Please set dbpath in the grendel config file or use --dbpath")
+		}
+
+		DB, err = model.NewDataStore(dbpath)
+		if err != nil {
+			return err
+		}
+
+		cmd.Log.Infof("Using database path: %s", dbpath)
+
+		return nil
+	}
+
+	dbCmd.PersistentPostRunE = func(command *cobra.Command, args []string) error {
+		if DB != nil {
+			return DB.Close()
+		}
+
+		return nil
+	}
+
+	cmd.Root.AddCommand(dbCmd)
+}
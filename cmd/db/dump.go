@@ -0,0 +1,69 @@
+// Copyright 2019 Grendel Authors. All rights reserved.
+//
+// This file is part of Grendel.
+//
+// Grendel is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Grendel is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with Grendel. If not, see <https://www.gnu.org/licenses/>.
+
+package db
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+
+	"github.com/spf13/cobra"
+	"github.com/ubccr/grendel/cmd"
+	"github.com/ubccr/grendel/model"
+)
+
+var (
+	outFile string
+	dumpCmd = &cobra.Command{
+		Use:   "dump",
+		Short: "Dump hosts and boot images to JSON",
+		Long:  `Dump all hosts and boot images to versioned JSON`,
+		Args:  cobra.NoArgs,
+		RunE: func(command *cobra.Command, args []string) error {
+			dump, err := model.NewDump(DB)
+			if err != nil {
+				return err
+			}
+
+			var out io.Writer = os.Stdout
+			if outFile != "" {
+				file, err := os.Create(outFile)
+				if err != nil {
+					return err
+				}
+				defer file.Close()
+				out = file
+			}
+
+			enc := json.NewEncoder(out)
+			enc.SetIndent("", "    ")
+			if err := enc.Encode(dump); err != nil {
+				return err
+			}
+
+			cmd.Log.Infof("Successfully dumped %d hosts and %d boot images", len(dump.Hosts), len(dump.Images))
+
+			return nil
+		},
+	}
+)
+
+func init() {
+	dumpCmd.Flags().StringVarP(&outFile, "output", "o", "", "write dump to file instead of stdout")
+	dbCmd.AddCommand(dumpCmd)
+}
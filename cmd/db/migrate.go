@@ -0,0 +1,58 @@
+// Copyright 2019 Grendel Authors. All rights reserved.
+//
+// This file is part of Grendel.
+//
+// Grendel is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Grendel is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with Grendel. If not, see <https://www.gnu.org/licenses/>.
+
+package db
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+	"github.com/ubccr/grendel/model"
+)
+
+var (
+	migrateCmd = &cobra.Command{
+		Use:   "migrate",
+		Short: "Upgrade database to the current schema version",
+		Long:  `Upgrade records in the database to the current schema version`,
+		Args:  cobra.NoArgs,
+		RunE: func(command *cobra.Command, args []string) error {
+			version, err := DB.SchemaVersion()
+			if err != nil {
+				return err
+			}
+
+			if version == model.SchemaVersion {
+				fmt.Printf("Database is up to date at schema version %d\n", version)
+				return nil
+			}
+
+			err = DB.Migrate()
+			if err != nil {
+				return err
+			}
+
+			fmt.Printf("Migrated database from schema version %d to %d\n", version, model.SchemaVersion)
+
+			return nil
+		},
+	}
+)
+
+func init() {
+	dbCmd.AddCommand(migrateCmd)
+}
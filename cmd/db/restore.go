@@ -0,0 +1,70 @@
+// Copyright 2019 Grendel Authors. All rights reserved.
+//
+// This file is part of Grendel.
+//
+// Grendel is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Grendel is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with Grendel. If not, see <https://www.gnu.org/licenses/>.
+
+package db
+
+import (
+	"io"
+	"os"
+
+	"github.com/spf13/cobra"
+	"github.com/ubccr/grendel/cmd"
+	"github.com/ubccr/grendel/model"
+)
+
+var (
+	restoreCmd = &cobra.Command{
+		Use:   "restore <file>",
+		Short: "Restore hosts and boot images from JSON",
+		Long:  `Restore hosts and boot images from a JSON dump. Use - to read from stdin. Records from older releases are upgraded to the current schema version`,
+		Args:  cobra.ExactArgs(1),
+		RunE: func(command *cobra.Command, args []string) error {
+			var in io.Reader = os.Stdin
+			if args[0] != "-" {
+				file, err := os.Open(args[0])
+				if err != nil {
+					return err
+				}
+				defer file.Close()
+				in = file
+			}
+
+			data, err := io.ReadAll(in)
+			if err != nil {
+				return err
+			}
+
+			dump, err := model.ParseDump(data)
+			if err != nil {
+				return err
+			}
+
+			err = dump.Restore(model.NewAuditedStore(DB, "db-restore", ""))
+			if err != nil {
+				return err
+			}
+
+			cmd.Log.Infof("Successfully restored %d hosts and %d boot images from dump version %d", len(dump.Hosts), len(dump.Images), dump.Version)
+
+			return nil
+		},
+	}
+)
+
+func init() {
+	dbCmd.AddCommand(restoreCmd)
+}
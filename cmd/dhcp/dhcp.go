@@ -0,0 +1,38 @@
+// Copyright 2019 Grendel Authors. All rights reserved.
+//
+// This file is part of Grendel.
+//
+// Grendel is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Grendel is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with Grendel. If not, see <https://www.gnu.org/licenses/>.
+
+package dhcp
+
+import (
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+	"github.com/ubccr/grendel/cmd"
+)
+
+var (
+	green   = color.New(color.FgGreen)
+	yellow  = color.New(color.FgYellow)
+	dhcpCmd = &cobra.Command{
+		Use:   "dhcp",
+		Short: "DHCP commands",
+		Long:  `DHCP commands`,
+	}
+)
+
+func init() {
+	cmd.Root.AddCommand(dhcpCmd)
+}
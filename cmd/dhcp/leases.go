@@ -0,0 +1,85 @@
+// Copyright 2019 Grendel Authors. All rights reserved.
+//
+// This file is part of Grendel.
+//
+// Grendel is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Grendel is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with Grendel. If not, see <https://www.gnu.org/licenses/>.
+
+package dhcp
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/dustin/go-humanize"
+	"github.com/spf13/cobra"
+	"github.com/ubccr/grendel/client"
+	"github.com/ubccr/grendel/cmd"
+)
+
+var (
+	leasesOpts client.DhcpLeasesOpts
+	printJSON  bool
+	leasesCmd  = &cobra.Command{
+		Use:   "leases",
+		Short: "List dynamic leases",
+		Long:  `List addresses leased from the dynamic DHCP pools`,
+		Args:  cobra.NoArgs,
+		RunE: func(command *cobra.Command, args []string) error {
+			gc, err := cmd.NewClient()
+			if err != nil {
+				return err
+			}
+
+			leases, _, err := gc.DhcpApi.DhcpLeases(cmd.NewContext(), &leasesOpts)
+			if err != nil {
+				return cmd.NewApiError("Failed to fetch dhcp leases", err)
+			}
+
+			if printJSON {
+				enc := json.NewEncoder(os.Stdout)
+				enc.SetIndent("", "    ")
+				return enc.Encode(leases)
+			}
+
+			now := time.Now()
+			fmt.Printf("%-17s%-19s%-25s%-10s%s\n", "IP", "MAC", "Hostname", "State", "Expires")
+			for _, lease := range leases {
+				printer, state := green, "active"
+				switch {
+				case lease.Expired(now):
+					printer, state = yellow, "expired"
+				case lease.Declined():
+					printer, state = yellow, "declined"
+				}
+
+				printer.Printf("%-17s%-19s%-25s%-10s%s\n",
+					lease.IP,
+					lease.MAC,
+					lease.Hostname,
+					state,
+					humanize.Time(lease.Expires))
+			}
+
+			return nil
+		},
+	}
+)
+
+func init() {
+	leasesCmd.Flags().BoolVar(&leasesOpts.Active, "active", false, "only leases which have not expired")
+	leasesCmd.Flags().BoolVar(&printJSON, "json", false, "output json")
+	dhcpCmd.AddCommand(leasesCmd)
+}
@@ -19,14 +19,8 @@ package discover
 
 import (
 	"context"
-	"fmt"
-	"net"
 	"os"
 	"os/signal"
-	"regexp"
-	"strconv"
-	"strings"
-	"sync"
 	"time"
 
 	"github.com/insomniacslk/dhcp/dhcpv4"
@@ -35,95 +29,45 @@ import (
 	"github.com/spf13/viper"
 	"github.com/ubccr/grendel/cmd"
 	"github.com/ubccr/grendel/dhcp"
-	"github.com/ubccr/grendel/nodeset"
+	"github.com/ubccr/grendel/logger"
 )
 
-type discoveryDHCP struct {
-	nodeset *nodeset.NodeSetIterator
-	seen    map[string]bool
-	subnet  net.IP
-	netmask net.IPMask
-	domain  string
-	mu      sync.Mutex
-}
-
 var (
-	trace            bool
-	snoop            bool
-	nodeNumberRegexp = regexp.MustCompile(`(\d+)$`)
-	dhcpCmd          = &cobra.Command{
+	trace   bool
+	snoop   bool
+	dhcpCmd = &cobra.Command{
 		Use:   "dhcp",
-		Short: "Auto-discover hosts from DHCP",
-		Long:  `Auto-discover hosts from DHCP`,
+		Short: "Snoop DHCP packets",
+		Long: `Snoop DHCP packets. To enroll unknown hosts run the DHCP server with
+dhcp.auto_enroll set and promote them with "grendel discover promote"`,
 		RunE: func(command *cobra.Command, args []string) error {
+			cmd.Log.Logger.SetLevel(logrus.DebugLevel)
+
+			handler := snoopDHCP
 			if trace {
-				cmd.Log.Logger.SetLevel(logrus.DebugLevel)
 				log.Infof("Tracing DHCP packets on %s", viper.GetString("discovery.listen"))
-				snooper, err := dhcp.NewSnooper(viper.GetString("discovery.listen"), traceDHCP)
-				if err != nil {
-					return err
-				}
-
-				return runSnoop(snooper)
-			} else if snoop {
-				cmd.Log.Logger.SetLevel(logrus.DebugLevel)
+				handler = traceDHCP
+			} else {
 				log.Infof("Snooping DHCP packets on %s", viper.GetString("discovery.listen"))
-				snooper, err := dhcp.NewSnooper(viper.GetString("discovery.listen"), snoopDHCP)
-				if err != nil {
-					return err
-				}
-
-				return runSnoop(snooper)
-			}
-
-			if subnetStr == "" {
-				return fmt.Errorf("Please provide a subnet (--subnet)")
-			}
-
-			netmask := net.IPv4Mask(255, 255, 255, 0)
-			subnet := net.ParseIP(subnetStr)
-			if subnet == nil || subnet.To4() == nil {
-				return fmt.Errorf("Invalid IPv4 subnet address: %s", subnetStr)
-			}
-
-			if len(args) == 0 {
-				return fmt.Errorf("Please provide a nodeset")
 			}
 
-			ns, err := nodeset.NewNodeSet(strings.Join(args, ","))
+			snooper, err := dhcp.NewSnooper(viper.GetString("discovery.listen"), handler)
 			if err != nil {
 				return err
 			}
 
-			d := &discoveryDHCP{
-				nodeset: ns.Iterator(),
-				seen:    make(map[string]bool),
-				subnet:  subnet,
-				netmask: netmask,
-				domain:  viper.GetString("discovery.domain"),
-			}
-
-			snooper, err := dhcp.NewSnooper(viper.GetString("discovery.listen"), d.handler)
-			if err != nil {
-				return err
-			}
-
-			err = runSnoop(snooper)
-			if err != nil {
-				cmd.Log.Errorf("Shutting down snooper: %s", err)
-			}
-
-			return nil
+			return runSnoop(snooper)
 		},
 	}
 )
 
 func init() {
-	dhcpCmd.Flags().StringP("listen", "l", "0.0.0.0:67", "address to run discovery DHCP server")
+	dhcpCmd.Flags().StringP("listen", "l", "0.0.0.0:67", "address to snoop DHCP packets on")
 	viper.BindPFlag("discovery.listen", dhcpCmd.Flags().Lookup("listen"))
 
 	dhcpCmd.Flags().BoolVar(&trace, "trace", false, "Trace DHCP packets only")
 	dhcpCmd.Flags().BoolVar(&snoop, "snoop", false, "Snoop DHCP packets only")
+	dhcpCmd.Flags().MarkDeprecated("snoop", "packets are snooped by default")
 
 	discoverCmd.AddCommand(dhcpCmd)
 }
@@ -173,14 +117,14 @@ func snoopDHCP(req *dhcpv4.DHCPv4) {
 	}
 
 	log.WithFields(logrus.Fields{
-		"mac":       req.ClientHWAddr.String(),
-		"type":      req.MessageType(),
-		"opcode":    req.OpCode,
-		"userClass": userClass,
-		"hostname":  hostName,
-		"arch":      archType,
-		"clientID":  clientID,
-		"classID":   classID,
+		logger.FieldMAC: req.ClientHWAddr.String(),
+		"type":          req.MessageType(),
+		"opcode":        req.OpCode,
+		"userClass":     userClass,
+		"hostname":      hostName,
+		"arch":          archType,
+		"clientID":      clientID,
+		"classID":       classID,
 	}).Debug()
 }
 
@@ -188,48 +132,3 @@ func traceDHCP(req *dhcpv4.DHCPv4) {
 	log.Debugf("Received DHCPv4 packet")
 	log.Debugf(req.Summary())
 }
-
-func (d *discoveryDHCP) handler(req *dhcpv4.DHCPv4) {
-	log.Debugf("Received DHCPv4 packet")
-	log.Debugf(req.Summary())
-
-	if req.OpCode != dhcpv4.OpcodeBootRequest {
-		log.Warningf("not a BootRequest, ignoring")
-		return
-	}
-
-	if req.MessageType() != dhcpv4.MessageTypeDiscover {
-		log.Warnf("Discovery unhandled message type: %v", req.MessageType())
-		return
-	}
-
-	d.mu.Lock()
-	defer d.mu.Unlock()
-
-	if _, ok := d.seen[req.ClientHWAddr.String()]; ok {
-		log.Infof("Already seen mac address. skipping: %s", req.ClientHWAddr)
-		return
-	}
-
-	if !d.nodeset.Next() {
-		log.Errorf("No more values in nodeset")
-		return
-	}
-
-	d.seen[req.ClientHWAddr.String()] = true
-
-	ip := d.subnet.Mask(d.netmask)
-	matches := nodeNumberRegexp.FindStringSubmatch(d.nodeset.Value())
-	if len(matches) != 2 {
-		log.Errorf("node doesn't end in number. failed to generate IP address: %s", d.nodeset.Value())
-		return
-	}
-	num, _ := strconv.Atoi(matches[1])
-	ip[3] += uint8(num)
-
-	hostName := d.nodeset.Value()
-	fqdn := fmt.Sprintf("%s.%s", hostName, d.domain)
-
-	addNic(hostName, fqdn, req.ClientHWAddr, ip, false)
-	cmd.Log.Infof("%s\t%s\t%s\n", d.nodeset.Value(), req.ClientHWAddr, ip.String())
-}
@@ -56,7 +56,7 @@ func init() {
 
 	discoverCmd.PersistentFlags().StringVar(&hostFile, "hosts", "", "existing hosts file to add to")
 	discoverCmd.PersistentFlags().BoolVar(&noProvision, "disable-provision", false, "don't set host to provision")
-	discoverCmd.PersistentFlags().StringVarP(&subnetStr, "subnet", "s", "", "subnet to use for auto ip assignment (/24)")
+	discoverCmd.PersistentFlags().StringVarP(&subnetStr, "subnet", "s", "", "subnet to use for auto ip assignment, for example 10.17.40.0/24")
 	discoverCmd.MarkFlagRequired("subnet")
 
 	discoverCmd.PersistentPostRunE = func(command *cobra.Command, args []string) error {
@@ -84,10 +84,11 @@ func init() {
 
 		subnet = net.IPv4(0, 0, 0, 0)
 		if subnetStr != "" {
-			subnet = net.ParseIP(subnetStr)
-			if subnet == nil || subnet.To4() == nil {
+			prefix, err := model.ParseIPPrefix(subnetStr)
+			if err != nil || !prefix.Addr().Is4() {
 				return fmt.Errorf("Invalid IPv4 subnet address: %s", subnetStr)
 			}
+			subnet = net.IP(prefix.Addr().AsSlice())
 		}
 
 		firmwareStr := viper.GetString("discovery.firmware")
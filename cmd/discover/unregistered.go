@@ -0,0 +1,147 @@
+// Copyright 2019 Grendel Authors. All rights reserved.
+//
+// This file is part of Grendel.
+//
+// Grendel is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Grendel is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with Grendel. If not, see <https://www.gnu.org/licenses/>.
+
+package discover
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/dustin/go-humanize"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"github.com/ubccr/grendel/cmd"
+	"github.com/ubccr/grendel/model"
+)
+
+var (
+	promoteMACs  []string
+	promoteTags  []string
+	promoteImage string
+	promoteRelay bool
+	listCmd      = &cobra.Command{
+		Use:   "list",
+		Short: "List unregistered hosts",
+		Long:  `List unknown PXE clients recorded by the DHCP server in auto-enroll mode`,
+		Args:  cobra.NoArgs,
+		RunE: func(command *cobra.Command, args []string) error {
+			gc, err := cmd.NewClient()
+			if err != nil {
+				return err
+			}
+
+			unregistered, _, err := gc.UnregisteredApi.UnregisteredList(cmd.NewContext())
+			if err != nil {
+				return cmd.NewApiError("Failed to list unregistered hosts", err)
+			}
+
+			fmt.Printf("%-19s%-16s%-38s%-17s%-20s%-7s%s\n", "MAC", "Arch", "UUID", "Relay", "Circuit ID", "Count", "Last Seen")
+			for _, u := range unregistered {
+				relay := ""
+				if u.RelayAddr.IsValid() {
+					relay = u.RelayAddr.String()
+				}
+
+				fmt.Printf("%-19s%-16s%-38s%-17s%-20s%-7d%s\n",
+					u.MAC,
+					u.Arch,
+					u.UUID,
+					relay,
+					u.CircuitID,
+					u.Count,
+					humanize.Time(u.LastSeen))
+			}
+
+			return nil
+		},
+	}
+	promoteCmd = &cobra.Command{
+		Use:   "promote <nodeset>",
+		Short: "Promote unregistered hosts",
+		Long: `Add unregistered hosts to Grendel. Hosts are named from the nodeset in the
+order they were first seen, or in the order given with --mac. The address of
+each host is the subnet network address plus the number the host name ends in.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(command *cobra.Command, args []string) error {
+			if subnetStr == "" {
+				return fmt.Errorf("Please provide a subnet (--subnet)")
+			}
+
+			gc, err := cmd.NewClient()
+			if err != nil {
+				return err
+			}
+
+			req := model.PromoteRequest{
+				NodeSet:   args[0],
+				Subnet:    subnetStr,
+				MACs:      promoteMACs,
+				Relay:     promoteRelay,
+				Domain:    viper.GetString("discovery.domain"),
+				Provision: !noProvision,
+				Firmware:  viper.GetString("discovery.firmware"),
+				BootImage: promoteImage,
+				Tags:      promoteTags,
+			}
+
+			promoted, _, err := gc.UnregisteredApi.UnregisteredPromote(cmd.NewContext(), req)
+			if err != nil {
+				return cmd.NewApiError("Failed to promote unregistered hosts", err)
+			}
+
+			for _, host := range promoted {
+				hosts[host.Name] = host
+			}
+
+			cmd.Log.Infof("Promoted %d hosts", len(promoted))
+
+			return nil
+		},
+	}
+	forgetCmd = &cobra.Command{
+		Use:   "forget <mac>...",
+		Short: "Delete unregistered hosts",
+		Long:  `Delete unregistered hosts. They are recorded again on their next PXE discover.`,
+		Args:  cobra.MinimumNArgs(1),
+		RunE: func(command *cobra.Command, args []string) error {
+			gc, err := cmd.NewClient()
+			if err != nil {
+				return err
+			}
+
+			for _, mac := range args {
+				_, err := gc.UnregisteredApi.UnregisteredDelete(cmd.NewContext(), strings.TrimSpace(mac))
+				if err != nil {
+					return cmd.NewApiError("Failed to delete unregistered host "+mac, err)
+				}
+			}
+
+			return nil
+		},
+	}
+)
+
+func init() {
+	promoteCmd.Flags().StringSliceVar(&promoteMACs, "mac", []string{}, "unregistered hosts to promote (default all)")
+	promoteCmd.Flags().StringSliceVarP(&promoteTags, "tags", "t", []string{}, "tags to set on the hosts")
+	promoteCmd.Flags().StringVar(&promoteImage, "image", "", "boot image to set on the hosts")
+	promoteCmd.Flags().BoolVar(&promoteRelay, "relay", false, "identify the hosts by the relay agent circuit and remote id they were enrolled through")
+
+	discoverCmd.AddCommand(listCmd)
+	discoverCmd.AddCommand(promoteCmd)
+	discoverCmd.AddCommand(forgetCmd)
+}
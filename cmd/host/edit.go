@@ -18,7 +18,6 @@
 package host
 
 import (
-	"context"
 	"encoding/json"
 	"fmt"
 	"strings"
@@ -52,18 +51,18 @@ var (
 			var hostList model.HostList
 
 			if len(args) == 1 && strings.ToLower(args[0]) == "all" {
-				hostList, _, err = gc.HostApi.HostList(context.Background())
+				hostList, _, err = gc.HostApi.HostList(cmd.NewContext())
 				if err != nil {
 					return cmd.NewApiError("Failed to fetch all hosts", err)
 				}
 			} else if len(tags) > 0 && len(args) == 0 {
-				hostList, _, err = gc.HostApi.HostTags(context.Background(), strings.Join(tags, ","))
+				hostList, _, err = gc.HostApi.HostTags(cmd.NewContext(), strings.Join(tags, ","))
 				if err != nil {
 					return cmd.NewApiError("Failed to fetch hosts by tag", err)
 				}
 			} else {
 				nodes := strings.Join(args, ",")
-				hostList, _, err = gc.HostApi.HostFind(context.Background(), nodes)
+				hostList, _, err = gc.HostApi.HostFind(cmd.NewContext(), nodes)
 				if err != nil {
 					return cmd.NewApiError("Failed to fetch hosts", err)
 				}
@@ -85,7 +84,7 @@ var (
 				return fmt.Errorf("Invalid JSON. Not saving changes: %w", err)
 			}
 
-			_, err = gc.HostApi.StoreHosts(context.Background(), check)
+			_, err = gc.HostApi.StoreHosts(cmd.NewContext(), check)
 			if err != nil {
 				return cmd.NewApiError("Failed to store hosts", err)
 			}
@@ -0,0 +1,144 @@
+// Copyright 2019 Grendel Authors. All rights reserved.
+//
+// This file is part of Grendel.
+//
+// Grendel is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Grendel is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with Grendel. If not, see <https://www.gnu.org/licenses/>.
+
+package host
+
+import (
+	"fmt"
+	"io"
+	"net/netip"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"github.com/ubccr/grendel/dns"
+	"github.com/ubccr/grendel/exporter"
+	"github.com/ubccr/grendel/model"
+	"github.com/ubccr/grendel/util"
+)
+
+var (
+	exportFormat  string
+	exportOutFile string
+	exportOpts    exporter.Options
+	exportNetwork string
+	exportDBPath  string
+	exportCmd     = &cobra.Command{
+		Use:   "export",
+		Short: "Export hosts",
+		Long: `Export hosts to zone files, /etc/hosts, ethers, dhcpd.conf, dnsmasq, CSV or
+an ansible inventory. Hosts are fetched from the API unless --dbpath is given,
+which reads them directly from the database, for example to build fallback
+zone files while grendel serve is down. Zone name servers default to the ns of
+the matching zone in dns.zones or dns.nameservers.`,
+		Args: cobra.MinimumNArgs(0),
+		RunE: func(command *cobra.Command, args []string) error {
+			format, err := exporter.ParseFormat(exportFormat)
+			if err != nil {
+				return err
+			}
+
+			if exportNetwork != "" {
+				exportOpts.Network, err = netip.ParsePrefix(exportNetwork)
+				if err != nil {
+					return fmt.Errorf("Invalid network: %w", err)
+				}
+			}
+
+			if len(exportOpts.NS) == 0 && (format == exporter.FormatZone || format == exporter.FormatReverseZone) {
+				exportOpts.NS, err = zoneNameServers(format)
+				if err != nil {
+					return err
+				}
+			}
+
+			var hostList model.HostList
+			if exportDBPath != "" {
+				db, err := model.NewDataStore(exportDBPath)
+				if err != nil {
+					return err
+				}
+				defer db.Close()
+
+				hostList, err = findHostsDB(db, args)
+			} else {
+				hostList, err = findHosts(args)
+			}
+			if err != nil {
+				return err
+			}
+
+			var out io.Writer = os.Stdout
+			if exportOutFile != "" {
+				file, err := os.Create(exportOutFile)
+				if err != nil {
+					return err
+				}
+				defer file.Close()
+				out = file
+			}
+
+			return exporter.Export(out, format, hostList, exportOpts)
+		},
+	}
+)
+
+func init() {
+	formats := make([]string, 0)
+	for _, f := range exporter.Formats() {
+		formats = append(formats, string(f))
+	}
+
+	exportCmd.Flags().StringVarP(&exportFormat, "format", "f", string(exporter.FormatHosts), "export format ("+strings.Join(formats, ", ")+")")
+	exportCmd.Flags().StringVarP(&exportOutFile, "output", "o", "", "write export to file instead of stdout")
+	exportCmd.Flags().StringVar(&exportOpts.Zone, "zone", "", "zone name for the zone format")
+	exportCmd.Flags().StringVar(&exportNetwork, "network", "", "network prefix for the reverse-zone format (e.g. 10.0.0.0/16)")
+	exportCmd.Flags().StringSliceVar(&exportOpts.NS, "ns", []string{}, "name servers of the zone (default ns of the zone in dns.zones or dns.nameservers)")
+	exportCmd.Flags().StringVar(&exportDBPath, "dbpath", "", "read hosts directly from the database file or URL instead of the API")
+	exportCmd.Flags().Uint32Var(&exportOpts.TTL, "ttl", exporter.DefaultTTL, "ttl of the zone records")
+	hostCmd.AddCommand(exportCmd)
+}
+
+// zoneNameServers returns the name servers configured for the exported zone
+func zoneNameServers(format exporter.Format) ([]string, error) {
+	zones, err := dns.ParseZones()
+	if err != nil {
+		return nil, err
+	}
+
+	name := util.Normalize(exportOpts.Zone)
+	if format == exporter.FormatReverseZone && exportOpts.Network.IsValid() {
+		name, err = util.ReverseZone(exportOpts.Network)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	for _, z := range zones {
+		if util.Normalize(z.Name) == name && len(z.NS) > 0 {
+			return z.NS, nil
+		}
+	}
+
+	nameservers := viper.GetStringSlice("dns.nameservers")
+	if len(nameservers) == 0 {
+		return nil, fmt.Errorf("Please provide name servers (--ns) or set dns.nameservers")
+	}
+
+	return nameservers, nil
+}
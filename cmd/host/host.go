@@ -18,9 +18,15 @@
 package host
 
 import (
+	"errors"
+	"fmt"
+	"strings"
+
 	"github.com/spf13/cobra"
 	"github.com/ubccr/grendel/cmd"
 	"github.com/ubccr/grendel/logger"
+	"github.com/ubccr/grendel/model"
+	"github.com/ubccr/grendel/nodeset"
 )
 
 var (
@@ -37,3 +43,73 @@ func init() {
 	hostCmd.PersistentFlags().StringSliceVarP(&tags, "tags", "t", []string{}, "filter by tags")
 	cmd.Root.AddCommand(hostCmd)
 }
+
+// findHosts returns the hosts in the nodeset given in args, all hosts if args
+// is "all", or the hosts with the tags given in --tags
+func findHosts(args []string) (model.HostList, error) {
+	if len(args) == 0 && len(tags) == 0 {
+		return nil, fmt.Errorf("Please provide tags (--tags) or a nodeset")
+	}
+
+	if len(args) > 0 && len(tags) > 0 {
+		log.Warn("Using both tags (--tags) and a nodeset is not supported yet. Only nodeset is used.")
+	}
+
+	gc, err := cmd.NewClient()
+	if err != nil {
+		return nil, err
+	}
+
+	var hostList model.HostList
+
+	if len(args) == 1 && strings.ToLower(args[0]) == "all" {
+		hostList, _, err = gc.HostApi.HostList(cmd.NewContext())
+		if err != nil {
+			return nil, cmd.NewApiError("Failed to list hosts", err)
+		}
+	} else if len(tags) > 0 && len(args) == 0 {
+		hostList, _, err = gc.HostApi.HostTags(cmd.NewContext(), strings.Join(tags, ","))
+		if err != nil {
+			return nil, cmd.NewApiError("Failed to find hosts by tag", err)
+		}
+	} else {
+		nodes := strings.Join(args, ",")
+		hostList, _, err = gc.HostApi.HostFind(cmd.NewContext(), nodes)
+		if err != nil {
+			return nil, cmd.NewApiError("Failed to find hosts", err)
+		}
+	}
+
+	return hostList, nil
+}
+
+// findHostsDB is findHosts reading the hosts directly from the data store
+func findHostsDB(db model.DataStore, args []string) (model.HostList, error) {
+	if len(args) == 0 && len(tags) == 0 {
+		return nil, fmt.Errorf("Please provide tags (--tags) or a nodeset")
+	}
+
+	if len(args) > 0 && len(tags) > 0 {
+		log.Warn("Using both tags (--tags) and a nodeset is not supported yet. Only nodeset is used.")
+	}
+
+	if len(args) == 1 && strings.ToLower(args[0]) == "all" {
+		return db.Hosts()
+	}
+
+	var ns *nodeset.NodeSet
+	var err error
+	if len(tags) > 0 && len(args) == 0 {
+		ns, err = db.FindTags(tags)
+		if errors.Is(err, model.ErrNotFound) {
+			return model.HostList{}, nil
+		}
+	} else {
+		ns, err = nodeset.NewNodeSet(strings.Join(args, ","))
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return db.FindHosts(ns)
+}
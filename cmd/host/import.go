@@ -18,7 +18,6 @@
 package host
 
 import (
-	"context"
 	"encoding/json"
 	"os"
 
@@ -52,7 +51,7 @@ var (
 					return err
 				}
 
-				_, err = gc.HostApi.StoreHosts(context.Background(), hosts)
+				_, err = gc.HostApi.StoreHosts(cmd.NewContext(), hosts)
 				if err != nil {
 					return cmd.NewApiError("Failed to store hosts", err)
 				}
@@ -18,7 +18,6 @@
 package host
 
 import (
-	"context"
 	"fmt"
 	"strings"
 
@@ -43,7 +42,7 @@ var (
 			}
 
 			nodes := strings.Join(args, ",")
-			_, err = gc.HostApi.HostTag(context.Background(), nodes, strings.Join(tags, ","))
+			_, err = gc.HostApi.HostTag(cmd.NewContext(), nodes, strings.Join(tags, ","))
 			if err != nil {
 				return cmd.NewApiError("Failed to tag hosts", err)
 			}
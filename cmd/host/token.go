@@ -18,7 +18,6 @@
 package host
 
 import (
-	"context"
 	"errors"
 	"fmt"
 	"strings"
@@ -41,7 +40,7 @@ var (
 				return err
 			}
 
-			hostList, _, err := gc.HostApi.HostFind(context.Background(), strings.Join(args, ","))
+			hostList, _, err := gc.HostApi.HostFind(cmd.NewContext(), strings.Join(args, ","))
 			if err != nil {
 				return cmd.NewApiError("Failed to find hosts for boot token generation", err)
 			}
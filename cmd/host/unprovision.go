@@ -18,7 +18,6 @@
 package host
 
 import (
-	"context"
 	"fmt"
 	"strings"
 
@@ -48,7 +47,7 @@ var (
 
 			nodes := strings.Join(args, ",")
 			if len(tags) > 0 && len(args) == 0 {
-				hostList, _, err := gc.HostApi.HostTags(context.Background(), strings.Join(tags, ","))
+				hostList, _, err := gc.HostApi.HostTags(cmd.NewContext(), strings.Join(tags, ","))
 				if err != nil {
 					return cmd.NewApiError("Failed to find hosts by tag", err)
 				}
@@ -61,7 +60,7 @@ var (
 				nodes = ns.String()
 			}
 
-			_, err = gc.HostApi.HostUnprovision(context.Background(), nodes)
+			_, err = gc.HostApi.HostUnprovision(cmd.NewContext(), nodes)
 			if err != nil {
 				return cmd.NewApiError("Failed to unprovision hosts", err)
 			}
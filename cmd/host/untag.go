@@ -18,7 +18,6 @@
 package host
 
 import (
-	"context"
 	"fmt"
 	"strings"
 
@@ -43,7 +42,7 @@ var (
 			}
 
 			nodes := strings.Join(args, ",")
-			_, err = gc.HostApi.HostUntag(context.Background(), nodes, strings.Join(tags, ","))
+			_, err = gc.HostApi.HostUntag(cmd.NewContext(), nodes, strings.Join(tags, ","))
 			if err != nil {
 				return cmd.NewApiError("Failed to untag hosts", err)
 			}
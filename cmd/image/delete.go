@@ -18,7 +18,6 @@
 package image
 
 import (
-	"context"
 	"fmt"
 
 	"github.com/spf13/cobra"
@@ -37,7 +36,7 @@ var (
 				return err
 			}
 
-			_, err = gc.ImageApi.ImageDelete(context.Background(), args[0])
+			_, err = gc.ImageApi.ImageDelete(cmd.NewContext(), args[0])
 			if err != nil {
 				return cmd.NewApiError("Failed to delete hosts", err)
 			}
@@ -18,7 +18,6 @@
 package image
 
 import (
-	"context"
 	"encoding/json"
 	"fmt"
 
@@ -40,7 +39,7 @@ var (
 				return err
 			}
 
-			imageList, _, err := gc.ImageApi.ImageFind(context.Background(), args[0])
+			imageList, _, err := gc.ImageApi.ImageFind(cmd.NewContext(), args[0])
 			if err != nil {
 				return cmd.NewApiError("Failed to find images to edit", err)
 			}
@@ -61,7 +60,7 @@ var (
 				return fmt.Errorf("Invalid JSON. Not saving changes: %w", err)
 			}
 
-			_, err = gc.ImageApi.StoreImages(context.Background(), check)
+			_, err = gc.ImageApi.StoreImages(cmd.NewContext(), check)
 			if err != nil {
 				return cmd.NewApiError("Failed to store images", err)
 			}
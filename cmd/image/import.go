@@ -18,7 +18,6 @@
 package image
 
 import (
-	"context"
 	"encoding/json"
 	"os"
 
@@ -52,7 +51,7 @@ var (
 					return err
 				}
 
-				_, err = gc.ImageApi.StoreImages(context.Background(), images)
+				_, err = gc.ImageApi.StoreImages(cmd.NewContext(), images)
 				if err != nil {
 					return cmd.NewApiError("Failed to store images", err)
 				}
@@ -0,0 +1,128 @@
+// Copyright 2019 Grendel Authors. All rights reserved.
+//
+// This file is part of Grendel.
+//
+// Grendel is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Grendel is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with Grendel. If not, see <https://www.gnu.org/licenses/>.
+
+package image
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/dustin/go-humanize"
+	"github.com/spf13/cobra"
+	"github.com/ubccr/grendel/client"
+	"github.com/ubccr/grendel/cmd"
+	"github.com/ubccr/grendel/model"
+)
+
+var (
+	pushKernel  string
+	pushInitrds []string
+	pushLiveImg string
+	pushRootFS  string
+	pushCmd     = &cobra.Command{
+		Use:   "push <image>",
+		Short: "Upload image files",
+		Long:  `Upload a kernel, initrds, live image or rootfs to the artifact store and set them on the image. The image is created if it doesn't exist.`,
+		Args:  cobra.ExactArgs(1),
+		RunE: func(command *cobra.Command, args []string) error {
+			if pushKernel == "" && len(pushInitrds) == 0 && pushLiveImg == "" && pushRootFS == "" {
+				return errors.New("Please provide at least one file to push")
+			}
+
+			gc, err := cmd.NewClient()
+			if err != nil {
+				return err
+			}
+
+			if pushKernel != "" {
+				if err := pushArtifact(gc, args[0], model.ArtifactKernel, 0, pushKernel); err != nil {
+					return err
+				}
+			}
+
+			for i, initrd := range pushInitrds {
+				if err := pushArtifact(gc, args[0], model.ArtifactInitrd, i, initrd); err != nil {
+					return err
+				}
+			}
+
+			if pushLiveImg != "" {
+				if err := pushArtifact(gc, args[0], model.ArtifactLiveImg, 0, pushLiveImg); err != nil {
+					return err
+				}
+			}
+
+			if pushRootFS != "" {
+				if err := pushArtifact(gc, args[0], model.ArtifactRootFS, 0, pushRootFS); err != nil {
+					return err
+				}
+			}
+
+			return nil
+		},
+	}
+)
+
+func init() {
+	pushCmd.Flags().StringVar(&pushKernel, "kernel", "", "path to kernel")
+	pushCmd.Flags().StringArrayVar(&pushInitrds, "initrd", []string{}, "path to initrd (repeat for multiple initrds)")
+	pushCmd.Flags().StringVar(&pushLiveImg, "liveimg", "", "path to live image")
+	pushCmd.Flags().StringVar(&pushRootFS, "rootfs", "", "path to root filesystem")
+	imageCmd.AddCommand(pushCmd)
+}
+
+// pushArtifact uploads the file with its sha256 checksum so the server can
+// verify it was received intact
+func pushArtifact(gc *client.APIClient, name, kind string, index int, path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return err
+	}
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return err
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+
+	cmd.Log.Infof("Uploading %s %s (%s)", kind, path, humanize.Bytes(uint64(info.Size())))
+
+	a, _, err := gc.ImageApi.ImageArtifactUpload(cmd.NewContext(), name, kind, file, info.Size(), &client.ImageArtifactUploadOpts{
+		Index:    index,
+		Filename: filepath.Base(path),
+		Sha256:   hex.EncodeToString(hash.Sum(nil)),
+	})
+	if err != nil {
+		return cmd.NewApiError("Failed to upload "+kind, err)
+	}
+
+	cmd.Log.Infof("Successfully uploaded %s %s sha256:%s", kind, path, a.SHA256)
+
+	return nil
+}
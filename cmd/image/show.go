@@ -18,8 +18,8 @@
 package image
 
 import (
-	"context"
 	"encoding/json"
+	"fmt"
 	"os"
 	"strings"
 
@@ -29,7 +29,8 @@ import (
 )
 
 var (
-	showCmd = &cobra.Command{
+	showStatus bool
+	showCmd    = &cobra.Command{
 		Use:   "show",
 		Short: "Show images",
 		Long:  `Show images`,
@@ -43,17 +44,33 @@ var (
 			var imageList model.BootImageList
 
 			if strings.ToLower(args[0]) == "all" {
-				imageList, _, err = gc.ImageApi.ImageList(context.Background())
+				imageList, _, err = gc.ImageApi.ImageList(cmd.NewContext())
 				if err != nil {
 					return cmd.NewApiError("Failed to list images", err)
 				}
 			} else {
-				imageList, _, err = gc.ImageApi.ImageFind(context.Background(), args[0])
+				imageList, _, err = gc.ImageApi.ImageFind(cmd.NewContext(), args[0])
 				if err != nil {
 					return cmd.NewApiError("Failed to find images", err)
 				}
 			}
 
+			if showStatus {
+				fmt.Printf("%-25s%-10s%s\n", "Name", "Status", "Message")
+				for _, image := range imageList {
+					status := image.Status
+					switch {
+					case len(image.Sources) == 0:
+						status = model.BootImageStatusReady
+					case status == "":
+						status = model.BootImageStatusPending
+					}
+					fmt.Printf("%-25s%-10s%s\n", image.Name, status, image.StatusMessage)
+				}
+
+				return nil
+			}
+
 			enc := json.NewEncoder(os.Stdout)
 			enc.SetIndent("", "    ")
 			if err := enc.Encode(imageList); err != nil {
@@ -67,5 +84,6 @@ var (
 )
 
 func init() {
+	showCmd.Flags().BoolVar(&showStatus, "status", false, "only show the status of images")
 	imageCmd.AddCommand(showCmd)
 }
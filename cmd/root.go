@@ -23,6 +23,7 @@ import (
 	"crypto/x509"
 	"errors"
 	"fmt"
+	"io/fs"
 	"io/ioutil"
 	golog "log"
 	"net"
@@ -37,6 +38,7 @@ import (
 	"github.com/spf13/viper"
 	"github.com/ubccr/grendel/api"
 	"github.com/ubccr/grendel/client"
+	"github.com/ubccr/grendel/firmware"
 	"github.com/ubccr/grendel/logger"
 	"github.com/ubccr/grendel/model"
 	"github.com/ubccr/grendel/util"
@@ -111,11 +113,26 @@ func NewClient() (*client.APIClient, error) {
 	cfg := client.NewConfiguration()
 	cfg.HTTPClient = rclient.StandardClient()
 
+	if strings.HasPrefix(endpoint, "http://") || strings.HasPrefix(endpoint, "https://") {
+		cfg.BasePath = strings.TrimSuffix(endpoint, "/") + "/v1"
+	}
+
 	client := client.NewAPIClient(cfg)
 
 	return client, nil
 }
 
+// NewContext returns the context passed to API client requests. When
+// client.api_token is set it carries the token for the bearer_auth scheme.
+func NewContext() context.Context {
+	ctx := context.Background()
+	if token := viper.GetString("client.api_token"); token != "" {
+		ctx = context.WithValue(ctx, client.ContextAccessToken, token)
+	}
+
+	return ctx
+}
+
 func NewApiError(msg string, err error) error {
 	var ge client.GenericOpenAPIError
 	if errors.As(err, &ge) {
@@ -129,12 +146,21 @@ func NewApiError(msg string, err error) error {
 }
 
 func SetupLogging() error {
+	level := logrus.WarnLevel
 	if debug {
-		Log.Logger.SetLevel(logrus.DebugLevel)
+		level = logrus.DebugLevel
 	} else if verbose {
-		Log.Logger.SetLevel(logrus.InfoLevel)
-	} else {
-		Log.Logger.SetLevel(logrus.WarnLevel)
+		level = logrus.InfoLevel
+	} else if viper.IsSet("log.level") {
+		var err error
+		level, err = logrus.ParseLevel(viper.GetString("log.level"))
+		if err != nil {
+			return fmt.Errorf("invalid log level: %w", err)
+		}
+	}
+
+	if err := logger.Configure(level); err != nil {
+		return err
 	}
 	golog.SetOutput(ioutil.Discard)
 
@@ -180,6 +206,16 @@ func initConfig() {
 		viper.Set("api.secret", secret)
 	}
 
+	// Custom firmware builds are registered before parsing the config so
+	// firmware.overrides can be checked against them. The directory is
+	// optional for commands run on other machines, hosts and images referring
+	// to builds missing there keep the build name.
+	if dir := viper.GetString("firmware.dir"); dir != "" {
+		if _, err := firmware.LoadDir(dir); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			Log.Fatalf("Failed to load firmware builds: %s", err)
+		}
+	}
+
 	err := model.ParseConfigs()
 	if err != nil {
 		Log.Fatal(err)
@@ -68,6 +68,7 @@ func serveAPI(t *tomb.Tomb) error {
 
 	apiServer.KeyFile = viper.GetString("api.key")
 	apiServer.CertFile = viper.GetString("api.cert")
+	apiServer.Artifacts = Artifacts
 
 	t.Go(func() error {
 		time.Sleep(1 * time.Second)
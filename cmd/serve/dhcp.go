@@ -19,6 +19,7 @@ package serve
 
 import (
 	"context"
+	"fmt"
 	"time"
 
 	"github.com/spf13/cobra"
@@ -41,6 +42,14 @@ func init() {
 	viper.BindPFlag("dhcp.mtu", dhcpCmd.PersistentFlags().Lookup("dhcp-mtu"))
 	dhcpCmd.PersistentFlags().Bool("dhcp-proxy-only", false, "only run boot proxy")
 	viper.BindPFlag("dhcp.proxy_only", dhcpCmd.PersistentFlags().Lookup("dhcp-proxy-only"))
+	dhcpCmd.PersistentFlags().Bool("dhcp-auto-enroll", false, "record unknown PXE clients as unregistered hosts")
+	viper.BindPFlag("dhcp.auto_enroll", dhcpCmd.PersistentFlags().Lookup("dhcp-auto-enroll"))
+	dhcpCmd.PersistentFlags().Int("dhcp-auto-enroll-max", 1024, "max number of unregistered hosts recorded, 0 for no limit")
+	viper.BindPFlag("dhcp.auto_enroll_max", dhcpCmd.PersistentFlags().Lookup("dhcp-auto-enroll-max"))
+	dhcpCmd.PersistentFlags().String("dhcp-auto-enroll-expire", "168h", "time after which unregistered hosts not seen are deleted, 0 to keep them")
+	viper.BindPFlag("dhcp.auto_enroll_expire", dhcpCmd.PersistentFlags().Lookup("dhcp-auto-enroll-expire"))
+	dhcpCmd.PersistentFlags().Int("dhcp-uuid-relearn-boots", 3, "boots after which a new MAC sending the uuid of a host replaces its boot MAC, 0 to disable")
+	viper.BindPFlag("dhcp.uuid_relearn_boots", dhcpCmd.PersistentFlags().Lookup("dhcp-uuid-relearn-boots"))
 	dhcpCmd.PersistentFlags().Int("dhcp-router-octet4", 0, "automatic router configuration")
 	viper.BindPFlag("dhcp.router_octet4", dhcpCmd.PersistentFlags().Lookup("dhcp-router-octet4"))
 	dhcpCmd.PersistentFlags().String("dhcp-gateway", "", "static gateway address")
@@ -89,6 +98,19 @@ func serveDHCP(t *tomb.Tomb) error {
 		dhcpLog.Infof("Running in ProxyOnly mode")
 	}
 
+	srv.AutoEnroll = viper.GetBool("dhcp.auto_enroll")
+	if srv.AutoEnroll {
+		dhcpLog.Infof("Auto-enrolling unknown PXE clients")
+	}
+
+	srv.EnrollMax = viper.GetInt("dhcp.auto_enroll_max")
+	srv.EnrollExpire, err = time.ParseDuration(viper.GetString("dhcp.auto_enroll_expire"))
+	if err != nil {
+		return fmt.Errorf("Invalid dhcp.auto_enroll_expire: %w", err)
+	}
+
+	srv.UUIDRelearnBoots = viper.GetInt("dhcp.uuid_relearn_boots")
+
 	t.Go(srv.Serve)
 	t.Go(func() error {
 		time.Sleep(1 * time.Second)
@@ -0,0 +1,89 @@
+// Copyright 2019 Grendel Authors. All rights reserved.
+//
+// This file is part of Grendel.
+//
+// Grendel is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Grendel is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with Grendel. If not, see <https://www.gnu.org/licenses/>.
+package serve
+
+import (
+	"context"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"github.com/ubccr/grendel/dhcp"
+	"gopkg.in/tomb.v2"
+)
+
+func init() {
+	dhcp6Cmd.PersistentFlags().String("dhcp6-listen", "", "address to listen on, empty to disable")
+	viper.BindPFlag("dhcp6.listen", dhcp6Cmd.PersistentFlags().Lookup("dhcp6-listen"))
+	dhcp6Cmd.PersistentFlags().String("dhcp6-lease-time", "24h", "default lease time")
+	viper.BindPFlag("dhcp6.lease_time", dhcp6Cmd.PersistentFlags().Lookup("dhcp6-lease-time"))
+
+	serveCmd.AddCommand(dhcp6Cmd)
+}
+
+var (
+	dhcp6Cmd = &cobra.Command{
+		Use:   "dhcp6",
+		Short: "Run DHCPv6 server",
+		Long:  `Run DHCPv6 server`,
+		RunE: func(command *cobra.Command, args []string) error {
+			t := NewInterruptTomb()
+			t.Go(func() error { return serveDHCP6(t) })
+			return t.Wait()
+		},
+	}
+)
+
+func serveDHCP6(t *tomb.Tomb) error {
+	// The global --listen address is usually IPv4 so it's not applied here
+	dhcp6Listen := viper.GetString("dhcp6.listen")
+	if dhcp6Listen == "" {
+		dhcpLog.Info("DHCPv6 server disabled")
+		return nil
+	}
+
+	srv, err := dhcp.NewServer6(DB, dhcp6Listen)
+	if err != nil {
+		return err
+	}
+
+	leaseTime, err := time.ParseDuration(viper.GetString("dhcp6.lease_time"))
+	if err != nil {
+		return err
+	}
+
+	srv.LeaseTime = leaseTime
+	dhcpLog.Infof("Default DHCPv6 lease time: %s", srv.LeaseTime)
+
+	t.Go(srv.Serve)
+	t.Go(func() error {
+		time.Sleep(1 * time.Second)
+		<-t.Dying()
+		dhcpLog.Info("Shutting down DHCPv6 server...")
+		ctxShutdown, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		if err := srv.Shutdown(ctxShutdown); err != nil {
+			dhcpLog.Errorf("Failed shutting down DHCPv6 server: %s", err)
+			return err
+		}
+
+		return nil
+	})
+
+	return nil
+}
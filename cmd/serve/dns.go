@@ -19,12 +19,14 @@ package serve
 
 import (
 	"context"
+	"strings"
 	"time"
 
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 	"github.com/ubccr/grendel/cmd"
 	"github.com/ubccr/grendel/dns"
+	"github.com/ubccr/grendel/model"
 	"gopkg.in/tomb.v2"
 )
 
@@ -33,6 +35,16 @@ func init() {
 	dnsCmd.PersistentFlags().Int("dns-ttl", 300, "ttl for dns records")
 	viper.BindPFlag("dns.listen", dnsCmd.PersistentFlags().Lookup("dns-listen"))
 	viper.BindPFlag("dns.ttl", dnsCmd.PersistentFlags().Lookup("dns-ttl"))
+	dnsCmd.PersistentFlags().StringSlice("dns-forwarders", []string{}, "upstream dns servers for names not in grendel")
+	viper.BindPFlag("dns.forwarders", dnsCmd.PersistentFlags().Lookup("dns-forwarders"))
+	dnsCmd.PersistentFlags().Int("dns-cache-size", dns.DefaultCacheSize, "max number of cached forwarded responses")
+	viper.BindPFlag("dns.cache_size", dnsCmd.PersistentFlags().Lookup("dns-cache-size"))
+	dnsCmd.PersistentFlags().StringSlice("dns-transfer-acl", []string{}, "networks allowed to transfer zones")
+	viper.BindPFlag("dns.transfer_acl", dnsCmd.PersistentFlags().Lookup("dns-transfer-acl"))
+	dnsCmd.PersistentFlags().StringSlice("dns-recursion-acl", []string{}, "networks allowed to resolve names through the forwarders (default dhcp subnets and loopback)")
+	viper.BindPFlag("dns.recursion_acl", dnsCmd.PersistentFlags().Lookup("dns-recursion-acl"))
+	dnsCmd.PersistentFlags().StringSlice("dns-notify", []string{}, "secondary dns servers to notify when zones change")
+	viper.BindPFlag("dns.notify", dnsCmd.PersistentFlags().Lookup("dns-notify"))
 
 	serveCmd.AddCommand(dnsCmd)
 }
@@ -56,11 +68,47 @@ func serveDNS(t *tomb.Tomb) error {
 		return err
 	}
 
-	dnsServer, err := dns.NewServer(DB, dnsListen, viper.GetInt("dns.ttl"))
+	zones, err := dns.ParseZones()
 	if err != nil {
 		return err
 	}
 
+	dnsServer, err := dns.NewServer(DB, dnsListen, viper.GetInt("dns.ttl"), zones...)
+	if err != nil {
+		return err
+	}
+
+	if forwarders := viper.GetStringSlice("dns.forwarders"); len(forwarders) > 0 {
+		err := dnsServer.SetForwarders(forwarders, viper.GetInt("dns.cache_size"))
+		if err != nil {
+			return err
+		}
+		cmd.Log.Infof("Forwarding DNS queries to: %s", strings.Join(forwarders, ", "))
+
+		acl := viper.GetStringSlice("dns.recursion_acl")
+		if len(acl) == 0 {
+			acl = defaultRecursionACL()
+		}
+		if err := dnsServer.SetRecursionACL(acl); err != nil {
+			return err
+		}
+		cmd.Log.Infof("Allowing recursive DNS queries from: %s", strings.Join(acl, ", "))
+	}
+
+	if acl := viper.GetStringSlice("dns.transfer_acl"); len(acl) > 0 {
+		if err := dnsServer.SetTransferACL(acl); err != nil {
+			return err
+		}
+		cmd.Log.Infof("Allowing DNS zone transfers from: %s", strings.Join(acl, ", "))
+	}
+
+	if secondaries := viper.GetStringSlice("dns.notify"); len(secondaries) > 0 {
+		if err := dnsServer.SetNotify(secondaries); err != nil {
+			return err
+		}
+		cmd.Log.Infof("Sending DNS NOTIFY to: %s", strings.Join(secondaries, ", "))
+	}
+
 	t.Go(func() error {
 		time.Sleep(1 * time.Second)
 		<-t.Dying()
@@ -78,3 +126,14 @@ func serveDNS(t *tomb.Tomb) error {
 
 	return dnsServer.Serve()
 }
+
+// defaultRecursionACL returns the networks of the configured dhcp subnets and
+// loopback
+func defaultRecursionACL() []string {
+	acl := []string{"127.0.0.0/8", "::1"}
+	for _, subnet := range model.Subnets {
+		acl = append(acl, subnet.Gateway.Masked().String())
+	}
+
+	return acl
+}
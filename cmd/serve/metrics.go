@@ -0,0 +1,83 @@
+// Copyright 2019 Grendel Authors. All rights reserved.
+//
+// This file is part of Grendel.
+//
+// Grendel is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Grendel is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with Grendel. If not, see <https://www.gnu.org/licenses/>.
+
+package serve
+
+import (
+	"context"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"github.com/ubccr/grendel/cmd"
+	"github.com/ubccr/grendel/metrics"
+	"gopkg.in/tomb.v2"
+)
+
+func init() {
+	metricsCmd.PersistentFlags().String("metrics-listen", "", "address to listen on (e.g. 127.0.0.1:9400), empty to disable")
+	viper.BindPFlag("metrics.listen", metricsCmd.PersistentFlags().Lookup("metrics-listen"))
+
+	serveCmd.AddCommand(metricsCmd)
+}
+
+var (
+	metricsCmd = &cobra.Command{
+		Use:   "metrics",
+		Short: "Run Prometheus metrics server",
+		Long:  `Run Prometheus metrics server`,
+		RunE: func(command *cobra.Command, args []string) error {
+			t := NewInterruptTomb()
+			t.Go(func() error { return serveMetrics(t) })
+			return t.Wait()
+		},
+	}
+)
+
+func serveMetrics(t *tomb.Tomb) error {
+	if viper.GetString("metrics.listen") == "" {
+		cmd.Log.Info("Metrics server disabled")
+		return nil
+	}
+
+	metricsListen, err := GetListenAddress(viper.GetString("metrics.listen"))
+	if err != nil {
+		return err
+	}
+
+	metricsServer, err := metrics.NewServer(metricsListen)
+	if err != nil {
+		return err
+	}
+
+	t.Go(func() error {
+		time.Sleep(1 * time.Second)
+		<-t.Dying()
+		cmd.Log.Info("Shutting down Metrics server...")
+		ctxShutdown, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		if err := metricsServer.Shutdown(ctxShutdown); err != nil {
+			cmd.Log.Errorf("Failed shutting down Metrics server: %s", err)
+			return err
+		}
+
+		return nil
+	})
+
+	return metricsServer.Serve()
+}
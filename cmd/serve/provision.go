@@ -23,6 +23,7 @@ import (
 
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
+	"github.com/ubccr/grendel/artifact"
 	"github.com/ubccr/grendel/cmd"
 	"github.com/ubccr/grendel/provision"
 	"gopkg.in/tomb.v2"
@@ -70,6 +71,21 @@ func serveProvision(t *tomb.Tomb) error {
 	srv.KeyFile = viper.GetString("provision.key")
 	srv.CertFile = viper.GetString("provision.cert")
 	srv.RepoDir = viper.GetString("provision.repo_dir")
+	srv.Artifacts = Artifacts
+
+	if Artifacts != nil {
+		fetcher := artifact.NewFetcher(DB, Artifacts)
+		if interval := viper.GetDuration("artifacts.fetch_interval"); interval > 0 {
+			fetcher.Interval = interval
+		}
+		if timeout := viper.GetDuration("artifacts.fetch_timeout"); timeout > 0 {
+			fetcher.Timeout = timeout
+		}
+		t.Go(func() error {
+			fetcher.Run(t.Context(nil))
+			return nil
+		})
+	}
 
 	t.Go(func() error {
 		time.Sleep(1 * time.Second)
@@ -29,14 +29,19 @@ import (
 
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
+	"github.com/ubccr/grendel/artifact"
 	"github.com/ubccr/grendel/cmd"
 	"github.com/ubccr/grendel/cmd/watch"
+	"github.com/ubccr/grendel/firmware"
+	"github.com/ubccr/grendel/metrics"
 	"github.com/ubccr/grendel/model"
+	"github.com/ubccr/grendel/provision"
 	"gopkg.in/tomb.v2"
 )
 
 var (
 	DB            model.DataStore
+	Artifacts     *artifact.Store
 	hostsFile     string
 	imagesFile    string
 	listenAddress string
@@ -55,6 +60,11 @@ var (
 				cancel()
 			}()
 
+			// Start tracking provisioning before the hosts are loaded so
+			// hosts set to provision get a pending record
+			tracker := provision.NewTracker(DB, viper.GetDuration("provision.stuck_timeout"))
+			go tracker.Run(ctx)
+
 			errChan := make(chan error)
 
 			go watch.WatchConfig(ctx, []string{hostsFile, imagesFile}, func() error {
@@ -73,7 +83,7 @@ var (
 )
 
 func init() {
-	serveCmd.PersistentFlags().String("dbpath", ":memory:", "path to database file")
+	serveCmd.PersistentFlags().String("dbpath", ":memory:", "path to database file or URL (sqlite:///path/to/grendel.db)")
 	viper.BindPFlag("dbpath", serveCmd.PersistentFlags().Lookup("dbpath"))
 	serveCmd.PersistentFlags().StringVar(&hostsFile, "hosts", "", "path to hosts file")
 	serveCmd.MarkPersistentFlagRequired("hosts")
@@ -89,6 +99,24 @@ func init() {
 			return err
 		}
 
+		if dir := viper.GetString("firmware.dir"); dir != "" {
+			names, err := firmware.LoadDir(dir)
+			if err != nil {
+				return fmt.Errorf("Failed to load firmware builds: %w", err)
+			}
+
+			cmd.Log.Infof("Loaded %d firmware builds from %s", len(names), dir)
+		}
+
+		if dir := viper.GetString("artifacts.dir"); dir != "" {
+			Artifacts, err = artifact.NewStore(dir)
+			if err != nil {
+				return fmt.Errorf("Failed to open artifact store: %w", err)
+			}
+
+			cmd.Log.Infof("Using artifact dir: %s", dir)
+		}
+
 		DB, err = model.NewDataStore(viper.GetString("dbpath"))
 		if err != nil {
 			return err
@@ -96,6 +124,22 @@ func init() {
 
 		cmd.Log.Infof("Using database path: %s", viper.GetString("dbpath"))
 
+		version, err := DB.SchemaVersion()
+		if err != nil {
+			return err
+		}
+
+		if version < model.SchemaVersion {
+			cmd.Log.Warnf("Upgrading database from schema version %d to %d", version, model.SchemaVersion)
+			err = DB.Migrate()
+			if err != nil {
+				return fmt.Errorf("Failed to migrate database: %w", err)
+			}
+		}
+
+		DB = model.NewInstrumentedStore(DB)
+		metrics.Registry.MustRegister(provision.NewStateCollector(DB))
+
 		return nil
 	}
 
@@ -132,7 +176,13 @@ func loadHostJSON() error {
 		return err
 	}
 
-	err = DB.StoreHosts(hostList)
+	for _, host := range hostList {
+		if !host.Firmware.IsNil() && !host.Firmware.Available() {
+			return fmt.Errorf("Unknown firmware build %s for host %s", host.Firmware, host.Name)
+		}
+	}
+
+	err = model.NewAuditedStore(DB, "hosts-file", "").StoreHosts(hostList)
 	if err != nil {
 		return err
 	}
@@ -159,7 +209,14 @@ func loadImageJSON() error {
 		return err
 	}
 
-	err = DB.StoreBootImages(imageList)
+	for _, image := range imageList {
+		if !image.Firmware.IsNil() && !image.Firmware.Available() {
+			return fmt.Errorf("Unknown firmware build %s for image %s", image.Firmware, image.Name)
+		}
+		image.ResetStatus()
+	}
+
+	err = model.NewAuditedStore(DB, "images-file", "").StoreBootImages(imageList)
 	if err != nil {
 		return err
 	}
@@ -175,9 +232,11 @@ func runServices(ctx context.Context) error {
 		t.Go(func() error { return serveTFTP(t) })
 		t.Go(func() error { return serveDNS(t) })
 		t.Go(func() error { return serveDHCP(t) })
+		t.Go(func() error { return serveDHCP6(t) })
 		t.Go(func() error { return servePXE(t) })
 		t.Go(func() error { return serveAPI(t) })
 		t.Go(func() error { return serveProvision(t) })
+		t.Go(func() error { return serveMetrics(t) })
 		return nil
 	})
 	go func() {
@@ -221,7 +280,7 @@ func GetListenAddress(address string) (string, error) {
 		return "", err
 	}
 
-	return fmt.Sprintf("%s:%s", listenAddress, port), nil
+	return net.JoinHostPort(listenAddress, port), nil
 }
 
 func NewInterruptContext() (context.Context, context.CancelFunc) {
@@ -18,7 +18,6 @@
 package status
 
 import (
-	"context"
 	"fmt"
 	"strings"
 
@@ -55,9 +54,9 @@ var (
 			var hostList model.HostList
 
 			if inputTags == "" {
-				hostList, _, err = gc.HostApi.HostList(context.Background())
+				hostList, _, err = gc.HostApi.HostList(cmd.NewContext())
 			} else {
-				hostList, _, err = gc.HostApi.HostTags(context.Background(), inputTags)
+				hostList, _, err = gc.HostApi.HostTags(cmd.NewContext(), inputTags)
 			}
 
 			if err != nil {
@@ -0,0 +1,122 @@
+// Copyright 2019 Grendel Authors. All rights reserved.
+//
+// This file is part of Grendel.
+//
+// Grendel is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Grendel is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with Grendel. If not, see <https://www.gnu.org/licenses/>.
+
+package status
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/dustin/go-humanize"
+	"github.com/spf13/cobra"
+	"github.com/ubccr/grendel/client"
+	"github.com/ubccr/grendel/cmd"
+	"github.com/ubccr/grendel/model"
+)
+
+var (
+	provisionOpts client.ProvisionStatusOpts
+	provisionLong bool
+	provisionCmd  = &cobra.Command{
+		Use:   "provision [nodeset]",
+		Short: "Host provisioning status",
+		Long:  `Show the provisioning state of hosts and hosts stuck provisioning`,
+		Args:  cobra.MaximumNArgs(1),
+		RunE: func(command *cobra.Command, args []string) error {
+			gc, err := cmd.NewClient()
+			if err != nil {
+				return err
+			}
+
+			if len(args) == 1 {
+				provisionOpts.NodeSet = args[0]
+			}
+
+			records, _, err := gc.ProvisionApi.ProvisionStatus(cmd.NewContext(), &provisionOpts)
+			if err != nil {
+				return cmd.NewApiError("Failed to fetch provision status", err)
+			}
+
+			counts := make(map[model.ProvisionState]int)
+			stuck := 0
+			for _, record := range records {
+				counts[record.State]++
+				if record.Stuck {
+					stuck++
+				}
+			}
+
+			fmt.Printf("%-20s%-12s%-17s%-16s%-16s%s\n", "Name", "State", "Last IP", "Updated", "Started", "Message")
+			for _, record := range records {
+				printer := yellow
+				switch {
+				case record.Stuck || record.State == model.ProvisionStateFailed:
+					printer = red
+				case record.State == model.ProvisionStateComplete:
+					printer = green
+				}
+
+				state := string(record.State)
+				if record.Stuck {
+					state += "*"
+				}
+
+				printer.Printf("%-20s%-12s%-17s%-16s%-16s%s\n",
+					record.Name,
+					state,
+					record.LastIP,
+					humanize.Time(record.Updated),
+					humanize.Time(record.Started),
+					record.Message)
+
+				if provisionLong {
+					for _, tr := range record.History {
+						fmt.Printf("    %-12s%-27s%s\n", tr.State, tr.Time.Local().Format(time.RFC3339), tr.IP)
+					}
+				}
+			}
+
+			fmt.Println()
+			for _, state := range []model.ProvisionState{
+				model.ProvisionStatePending,
+				model.ProvisionStateDHCP,
+				model.ProvisionStateFirmware,
+				model.ProvisionStateIpxe,
+				model.ProvisionStateKernel,
+				model.ProvisionStateInstalling,
+				model.ProvisionStateComplete,
+				model.ProvisionStateFailed,
+			} {
+				fmt.Printf("%s: %d  ", state, counts[state])
+			}
+			fmt.Println()
+
+			if stuck > 0 {
+				red.Printf("Stuck (*): %d\n", stuck)
+			}
+
+			return nil
+		},
+	}
+)
+
+func init() {
+	provisionCmd.Flags().StringVar(&provisionOpts.State, "state", "", "only hosts in state (pending, dhcp, firmware, ipxe, kernel, installing, complete, failed)")
+	provisionCmd.Flags().BoolVar(&provisionOpts.Stuck, "stuck", false, "only hosts stuck provisioning")
+	provisionCmd.Flags().BoolVarP(&provisionLong, "long", "l", false, "show state history")
+	statusCmd.AddCommand(provisionCmd)
+}
@@ -18,7 +18,6 @@
 package status
 
 import (
-	"context"
 	"fmt"
 	"strings"
 
@@ -63,7 +62,7 @@ var (
 			defaultImage := viper.GetString("provision.default_image")
 			inputTags := strings.Join(args, ",")
 
-			imageList, _, err := gc.ImageApi.ImageList(context.Background())
+			imageList, _, err := gc.ImageApi.ImageList(cmd.NewContext())
 			if err != nil {
 				return cmd.NewApiError("Failed to list images", err)
 			}
@@ -77,9 +76,9 @@ var (
 			var hostList model.HostList
 
 			if inputTags == "" {
-				hostList, _, err = gc.HostApi.HostList(context.Background())
+				hostList, _, err = gc.HostApi.HostList(cmd.NewContext())
 			} else {
-				hostList, _, err = gc.HostApi.HostTags(context.Background(), inputTags)
+				hostList, _, err = gc.HostApi.HostTags(cmd.NewContext(), inputTags)
 			}
 
 			if err != nil {
@@ -0,0 +1,70 @@
+// Copyright 2019 Grendel Authors. All rights reserved.
+//
+// This file is part of Grendel.
+//
+// Grendel is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Grendel is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with Grendel. If not, see <https://www.gnu.org/licenses/>.
+
+package token
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"github.com/ubccr/grendel/api"
+)
+
+var (
+	role      string
+	name      string
+	ttl       time.Duration
+	createCmd = &cobra.Command{
+		Use:   "create",
+		Short: "Create signed API token",
+		Long:  `Create signed API token using the api.secret config option`,
+		Args:  cobra.NoArgs,
+		RunE: func(command *cobra.Command, args []string) error {
+			// A random secret is generated if none is configured which would
+			// result in a token the API server can't verify
+			if _, ok := os.LookupEnv("GRENDEL_API_SECRET"); !ok && !viper.InConfig("api.secret") {
+				return errors.New("Please set api.secret in the grendel config file")
+			}
+
+			r, err := api.ParseRole(role)
+			if err != nil {
+				return err
+			}
+
+			token, err := api.NewAPIToken(name, r, ttl)
+			if err != nil {
+				return fmt.Errorf("Failed to generate signed API token: %w", err)
+			}
+
+			fmt.Println(token)
+
+			return nil
+		},
+	}
+)
+
+func init() {
+	createCmd.Flags().StringVar(&role, "role", string(api.RoleRead), "token role (read or admin)")
+	createCmd.Flags().StringVar(&name, "name", "", "name of token owner")
+	createCmd.Flags().DurationVar(&ttl, "ttl", 0, "token lifetime (default never expires)")
+	createCmd.MarkFlagRequired("name")
+	tokenCmd.AddCommand(createCmd)
+}
@@ -0,0 +1,167 @@
+// Copyright 2019 Grendel Authors. All rights reserved.
+//
+// This file is part of Grendel.
+//
+// Grendel is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Grendel is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with Grendel. If not, see <https://www.gnu.org/licenses/>.
+
+package watch
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"github.com/ubccr/grendel/cmd"
+	"github.com/ubccr/grendel/events"
+)
+
+var (
+	eventTags  []string
+	eventTypes []string
+	printJSON  bool
+	eventsCmd  = &cobra.Command{
+		Use:   "events [nodeset]",
+		Short: "Watch provisioning events",
+		Long:  `Watch DHCP, PXE, TFTP, provision and host change events live`,
+		Args:  cobra.MaximumNArgs(1),
+		RunE: func(command *cobra.Command, args []string) error {
+			gc, err := cmd.NewClient()
+			if err != nil {
+				return err
+			}
+
+			params := url.Values{}
+			if len(args) == 1 {
+				params.Set("nodeset", args[0])
+			}
+			if len(eventTags) > 0 {
+				params.Set("tags", strings.Join(eventTags, ","))
+			}
+			if len(eventTypes) > 0 {
+				params.Set("type", strings.Join(eventTypes, ","))
+			}
+
+			cfg := gc.GetConfig()
+			endpoint := cfg.BasePath + "/events?" + params.Encode()
+
+			if !printJSON {
+				fmt.Printf("%-27s%-11s%-21s%-20s%-19s%-17s%s\n", "Time", "Service", "Type", "Host", "MAC", "IP", "Message")
+			}
+
+			for {
+				err := streamEvents(command, cfg.HTTPClient, endpoint, viper.GetString("client.api_token"))
+				if command.Context().Err() != nil {
+					return nil
+				}
+
+				var apiErr *eventsError
+				if errors.As(err, &apiErr) {
+					return err
+				}
+
+				// The server closed the stream or restarted, reconnect
+				cmd.Log.Debugf("Event stream closed, reconnecting: %v", err)
+				time.Sleep(time.Second)
+			}
+		},
+	}
+)
+
+// eventsError is returned when the API rejects the event stream request
+type eventsError struct {
+	status  int
+	message string
+}
+
+func (e *eventsError) Error() string {
+	return fmt.Sprintf("Failed to watch events: %d %s", e.status, e.message)
+}
+
+func init() {
+	eventsCmd.Flags().StringSliceVarP(&eventTags, "tags", "t", []string{}, "filter by host tags")
+	eventsCmd.Flags().StringSliceVar(&eventTypes, "type", []string{}, "filter by event type or prefix (e.g. dhcp,tftp.firmware)")
+	eventsCmd.Flags().BoolVar(&printJSON, "json", false, "output json")
+	watchCmd.AddCommand(eventsCmd)
+}
+
+func streamEvents(command *cobra.Command, client *http.Client, endpoint, token string) error {
+	req, err := http.NewRequestWithContext(command.Context(), http.MethodGet, endpoint, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "text/event-stream")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	res, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		var apiErr struct {
+			Message string `json:"message"`
+		}
+		body, _ := io.ReadAll(res.Body)
+		if json.Unmarshal(body, &apiErr) != nil {
+			apiErr.Message = strings.TrimSpace(string(body))
+		}
+		return &eventsError{status: res.StatusCode, message: apiErr.Message}
+	}
+
+	scanner := bufio.NewScanner(res.Body)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if !strings.HasPrefix(line, "data: ") {
+			continue
+		}
+
+		data := strings.TrimPrefix(line, "data: ")
+		if printJSON {
+			fmt.Println(data)
+			continue
+		}
+
+		var e events.Event
+		if err := json.Unmarshal([]byte(data), &e); err != nil {
+			cmd.Log.Warnf("Failed to parse event: %v", err)
+			continue
+		}
+
+		fmt.Printf("%-27s%-11s%-21s%-20s%-19s%-17s%s\n",
+			e.Time.Local().Format(time.RFC3339),
+			e.Service,
+			e.Type,
+			e.Host,
+			e.MAC,
+			e.IP,
+			e.Message)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+
+	return io.EOF
+}
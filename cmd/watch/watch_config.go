@@ -20,7 +20,7 @@ func WatchConfig(ctx context.Context, files []string, configLoader func() error,
 		for _, file := range files {
 			fileStat, err := os.Stat(file)
 			if err != nil {
-				cmd.Log.Errorf("failed to stat %s: %w", file, err)
+				cmd.Log.Errorf("failed to stat %s: %v", file, err)
 				errChan <- err
 				return
 			}
@@ -29,7 +29,7 @@ func WatchConfig(ctx context.Context, files []string, configLoader func() error,
 
 		cmd.Log.Infof("initial config detected")
 		if err := configLoader(); err != nil {
-			cmd.Log.Errorf("failed to load config : %w", err)
+			cmd.Log.Errorf("failed to load config : %v", err)
 			errChan <- err
 			return
 		}
@@ -65,7 +65,7 @@ func WatchConfig(ctx context.Context, files []string, configLoader func() error,
 			for i, file := range files {
 				fileStat, err := os.Stat(file)
 				if err != nil {
-					cmd.Log.Errorf("failed to stat %s: %w", file, err)
+					cmd.Log.Errorf("failed to stat %s: %v", file, err)
 					errChan <- err
 					return
 				}
@@ -78,7 +78,7 @@ func WatchConfig(ctx context.Context, files []string, configLoader func() error,
 				cmd.Log.Infof("new config detected")
 
 				if err := configLoader(); err != nil {
-					cmd.Log.Errorf("failed to load config : %w", err)
+					cmd.Log.Errorf("failed to load config : %v", err)
 					continue
 				}
 				select {
@@ -94,7 +94,7 @@ func WatchConfig(ctx context.Context, files []string, configLoader func() error,
 			if !ok {
 				return
 			}
-			cmd.Log.Errorf("config reloader thrown an error : %w", err)
+			cmd.Log.Errorf("config reloader thrown an error : %v", err)
 		}
 	}
 }
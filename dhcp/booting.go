@@ -20,46 +20,108 @@ package dhcp
 import (
 	"fmt"
 	"net"
+	"strings"
 
 	"github.com/insomniacslk/dhcp/dhcpv4"
 	"github.com/sirupsen/logrus"
 	"github.com/ubccr/grendel/firmware"
+	"github.com/ubccr/grendel/logger"
 	"github.com/ubccr/grendel/model"
 )
 
+// isHTTPClient returns true if the request was sent by UEFI HTTP boot firmware
+func isHTTPClient(req *dhcpv4.DHCPv4) bool {
+	if strings.HasPrefix(req.ClassIdentifier(), httpClientClass) {
+		return true
+	}
+
+	archs := req.ClientArch()
+	return len(archs) > 0 && firmware.IsHTTPArch(archs[0])
+}
+
+// firmwareOverride returns the firmware build set on the host, else the build
+// of the first matching firmware.overrides entry, else the build set on the
+// boot image of the host. Builds which are not loaded are ignored so the
+// client falls back to the detected build.
+func firmwareOverride(db model.DataStore, host *model.Host, client *firmware.Client) firmware.Build {
+	fw := host.Firmware
+	if fw.IsNil() {
+		fw = firmware.MatchOverride(client)
+	}
+	if fw.IsNil() && host.BootImage != "" {
+		if image, err := db.LoadBootImage(host.BootImage); err == nil {
+			fw = image.Firmware
+		}
+	}
+
+	if !fw.IsNil() && !fw.Available() {
+		log.Warnf("Firmware build %s for host %s is not loaded", fw, host.Name)
+		return firmware.Build(0)
+	}
+
+	return fw
+}
+
+// bootFirmware returns the build to serve the client. The build detected for
+// first stage firmware, PXE ROMs and UEFI, is replaced by the override from
+// firmwareOverride if it runs on the same platform. Overrides for another
+// platform, such as an EFI build for a BIOS client, are ignored.
+func bootFirmware(db model.DataStore, host *model.Host, client *firmware.Client) (firmware.Build, error) {
+	fwtype, err := firmware.Detect(client)
+	if err != nil {
+		return firmware.Build(0), err
+	}
+
+	if fwtype != firmware.UNDI && !fwtype.IsEFI() {
+		return fwtype, nil
+	}
+
+	fw := firmwareOverride(db, host, client)
+	if fw.IsNil() {
+		return fwtype, nil
+	}
+
+	if fw.Platform() != fwtype.Platform() {
+		log.Warnf("Ignoring firmware %s for host %s, it runs on %s and the client on %s", fw, host.Name, fw.Platform(), fwtype.Platform())
+		return fwtype, nil
+	}
+
+	log.Infof("Overriding firmware for host %s: %s", host.Name, fw)
+
+	return fw, nil
+}
+
 func (s *Server) bootingHandler4(host *model.Host, serverIP net.IP, req, resp *dhcpv4.DHCPv4) error {
 	if !host.Provision {
 		log.Infof("Host not set to provision: %s", req.ClientHWAddr.String())
 		return nil
 	}
 
-	if !req.Options.Has(dhcpv4.OptionClientSystemArchitectureType) {
+	client := firmware.NewClient4(req)
+	client.Tags = host.Tags
+	if len(client.Archs) == 0 {
 		log.Debugf("Ignoring packet - missing client system architecture type")
 		return nil
 	}
 
-	userClass := ""
-	if req.Options.Has(dhcpv4.OptionUserClassInformation) {
-		userClass = string(req.Options.Get(dhcpv4.OptionUserClassInformation))
-	}
-
-	fwtype, err := firmware.DetectBuild(req.ClientArch(), userClass)
+	fwtype, err := bootFirmware(s.DB, host, client)
 	if err != nil {
 		return fmt.Errorf("Failed to get PXE firmware from DHCP: %s", err)
 	}
 
 	log.WithFields(logrus.Fields{
-		"mac":      req.ClientHWAddr.String(),
-		"name":     host.Name,
-		"firmware": fwtype.String(),
+		logger.FieldMAC:  req.ClientHWAddr.String(),
+		logger.FieldHost: host.Name,
+		"firmware":       fwtype.String(),
+		"http":           isHTTPClient(req),
 	}).Info("Got valid PXE boot request")
 	log.Debugf(req.Summary())
 
 	// This logic was adopted from pixiecore
 	// https://github.com/danderson/netboot/tree/master/pixiecore
 	// Written by @danderson
-	switch fwtype {
-	case firmware.UNDI:
+	switch {
+	case fwtype == firmware.UNDI, fwtype.IsCustom() && !fwtype.IsEFI():
 		if !s.ProxyOnly {
 			// If we're running both dhcp server and PXE Server then we need to
 			// bail here to direct the PXE client over to port 4011 for the
@@ -80,7 +142,7 @@ func (s *Server) bootingHandler4(host *model.Host, serverIP net.IP, req, resp *d
 		}
 		resp.UpdateOption(dhcpv4.OptBootFileName(token))
 
-	case firmware.IPXE:
+	case fwtype == firmware.IPXE:
 		log.Printf("Found iPXE firmware telling PXE client to boot tftp")
 		pxe := dhcpv4.OptionsFromList(dhcpv4.OptGeneric(dhcpv4.GenericOptionCode(6), []byte{8}))
 		resp.UpdateOption(dhcpv4.OptGeneric(dhcpv4.OptionVendorSpecificInformation, pxe.ToBytes()))
@@ -94,35 +156,27 @@ func (s *Server) bootingHandler4(host *model.Host, serverIP net.IP, req, resp *d
 		endpoints := model.NewEndpoints(serverIP.String(), token)
 		resp.UpdateOption(dhcpv4.OptBootFileName(endpoints.BootFileURL()))
 
-	case firmware.EFI386, firmware.EFI64:
-		log.Printf("EFI boot PXE client")
-		if host.Firmware != 0 {
-			log.Infof("Overriding firmware for host: %s", req.ClientHWAddr.String())
-			fwtype = host.Firmware
-		}
-		resp.UpdateOption(dhcpv4.OptTFTPServerName(serverIP.String()))
-
+	case fwtype.IsEFI():
 		token, err := model.NewFirmwareToken(req.ClientHWAddr.String(), fwtype)
 		if err != nil {
 			return fmt.Errorf("EFI failed to generated signed Firmware token")
 		}
-		resp.UpdateOption(dhcpv4.OptBootFileName(token))
 
-	case firmware.EFIARM64:
-		log.Printf("EFI ARM64 boot PXE client")
-		if host.Firmware != 0 {
-			log.Infof("Overriding firmware for host: %s", req.ClientHWAddr.String())
-			fwtype = host.Firmware
+		if !isHTTPClient(req) {
+			log.Printf("EFI boot PXE client")
+			resp.UpdateOption(dhcpv4.OptTFTPServerName(serverIP.String()))
+			resp.UpdateOption(dhcpv4.OptBootFileName(token))
+			break
 		}
-		resp.UpdateOption(dhcpv4.OptTFTPServerName(serverIP.String()))
 
-		token, err := model.NewFirmwareToken(req.ClientHWAddr.String(), fwtype)
-		if err != nil {
-			return fmt.Errorf("EFI failed to generated signed Firmware token")
-		}
-		resp.UpdateOption(dhcpv4.OptBootFileName(token))
+		// UEFI HTTP boot clients only accept offers which echo back the
+		// HTTPClient vendor class and expect a URL as the boot file name
+		log.Printf("EFI HTTP boot client")
+		endpoints := model.NewEndpoints(serverIP.String(), token)
+		resp.UpdateOption(dhcpv4.OptClassIdentifier(httpClientClass))
+		resp.UpdateOption(dhcpv4.OptBootFileName(endpoints.FirmwareURL(fwtype)))
 
-	case firmware.GRENDEL:
+	case fwtype == firmware.GRENDEL:
 		// Chainload to HTTP
 		token, err := model.NewBootToken(host.ID.String(), req.ClientHWAddr.String())
 		if err != nil {
@@ -0,0 +1,129 @@
+// Copyright 2019 Grendel Authors. All rights reserved.
+//
+// This file is part of Grendel.
+//
+// Grendel is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Grendel is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with Grendel. If not, see <https://www.gnu.org/licenses/>.
+package dhcp
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+
+	"github.com/insomniacslk/dhcp/dhcpv6"
+	"github.com/sirupsen/logrus"
+	"github.com/ubccr/grendel/firmware"
+	"github.com/ubccr/grendel/logger"
+	"github.com/ubccr/grendel/model"
+)
+
+const httpClientClass = "HTTPClient"
+
+// httpClientVendorClass returns the vendor class sent by UEFI HTTP boot
+// clients or nil if the client is not doing HTTP boot
+func httpClientVendorClass(msg *dhcpv6.Message) *dhcpv6.OptVendorClass {
+	for _, vc := range msg.Options.VendorClasses() {
+		for _, data := range vc.Data {
+			if bytes.HasPrefix(data, []byte(httpClientClass)) {
+				return vc
+			}
+		}
+	}
+
+	return nil
+}
+
+func (s *Server6) bootingHandler6(host *model.Host, mac net.HardwareAddr, serverIP net.IP, msg, resp *dhcpv6.Message) error {
+	if !host.Provision {
+		log.Infof("Host not set to provision: %s", mac)
+		return nil
+	}
+
+	client := firmware.NewClient6(msg, mac)
+	client.Tags = host.Tags
+	if len(client.Archs) == 0 {
+		log.Debugf("Ignoring packet - missing client system architecture type")
+		return nil
+	}
+
+	fwtype, err := bootFirmware(s.DB, host, client)
+	if err != nil {
+		return fmt.Errorf("Failed to get PXE firmware from DHCPv6: %s", err)
+	}
+
+	vendorClass := httpClientVendorClass(msg)
+
+	log.WithFields(logrus.Fields{
+		logger.FieldMAC:  mac.String(),
+		logger.FieldHost: host.Name,
+		"firmware":       fwtype.String(),
+		"http":           vendorClass != nil || firmware.IsHTTPArch(client.Archs[0]),
+	}).Info("Got valid PXE boot request")
+
+	switch {
+	case fwtype == firmware.UNDI, fwtype.IsCustom() && !fwtype.IsEFI():
+		return fmt.Errorf("legacy PXE firmware does not support DHCPv6")
+
+	case fwtype == firmware.IPXE:
+		token, err := model.NewFirmwareToken(mac.String(), fwtype)
+		if err != nil {
+			return fmt.Errorf("iPXE firmware - failed to generated signed Firmware token")
+		}
+		endpoints := model.NewEndpoints(serverIP.String(), token)
+		resp.UpdateOption(dhcpv6.OptBootFileURL(endpoints.BootFileURL()))
+
+	case fwtype.IsEFI():
+		token, err := model.NewFirmwareToken(mac.String(), fwtype)
+		if err != nil {
+			return fmt.Errorf("EFI failed to generated signed Firmware token")
+		}
+		endpoints := model.NewEndpoints(serverIP.String(), token)
+
+		if vendorClass == nil && !firmware.IsHTTPArch(client.Archs[0]) {
+			log.Printf("EFI boot PXE client")
+			resp.UpdateOption(dhcpv6.OptBootFileURL(endpoints.BootFileURL()))
+			break
+		}
+
+		// UEFI HTTP boot clients only accept offers which echo back the
+		// HTTPClient vendor class
+		log.Printf("EFI HTTP boot client")
+		resp.UpdateOption(dhcpv6.OptBootFileURL(endpoints.FirmwareURL(fwtype)))
+		enterprise := uint32(0)
+		if vendorClass != nil {
+			enterprise = vendorClass.EnterpriseNumber
+		}
+		resp.UpdateOption(&dhcpv6.OptVendorClass{
+			EnterpriseNumber: enterprise,
+			Data:             [][]byte{[]byte(httpClientClass)},
+		})
+
+	case fwtype == firmware.GRENDEL:
+		// Chainload to HTTP
+		token, err := model.NewBootToken(host.ID.String(), mac.String())
+		if err != nil {
+			return fmt.Errorf("Failed to generate signed boot token: %s", err)
+		}
+
+		endpoints := model.NewEndpoints(serverIP.String(), token)
+		ipxeUrl := endpoints.IpxeURL()
+		log.Debugf("BootFile iPXE script: %s", ipxeUrl)
+		resp.UpdateOption(dhcpv6.OptBootFileURL(ipxeUrl))
+
+	default:
+		return fmt.Errorf("unknown firmware type %d", fwtype)
+	}
+
+	return nil
+}
@@ -0,0 +1,287 @@
+// Copyright 2019 Grendel Authors. All rights reserved.
+//
+// This file is part of Grendel.
+//
+// Grendel is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Grendel is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with Grendel. If not, see <https://www.gnu.org/licenses/>.
+
+package dhcp
+
+import (
+	"bytes"
+	"debug/pe"
+	"encoding/binary"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/insomniacslk/dhcp/dhcpv4"
+	"github.com/insomniacslk/dhcp/dhcpv6"
+	"github.com/insomniacslk/dhcp/iana"
+	"github.com/stretchr/testify/assert"
+	"github.com/ubccr/grendel/firmware"
+	"github.com/ubccr/grendel/internal/tests"
+	"github.com/ubccr/grendel/model"
+)
+
+func TestBootingHTTPClient(t *testing.T) {
+	assert := assert.New(t)
+
+	host := tests.HostFactory.MustCreate().(*model.Host)
+	host.Provision = true
+	host.Firmware = 0
+
+	s, _ := newTestPoolServer(t, model.HostList{host})
+	serverIP := net.ParseIP("10.17.40.1")
+
+	tt := []struct {
+		arch  iana.Arch
+		class string
+		http  bool
+		build string
+	}{
+		{iana.EFI_X86_64, "PXEClient:Arch:00007:UNDI:003016", false, "ipxe-x86_64.efi"},
+		{iana.EFI_X86_64, "PXEClient:Arch:00007:UNDI:003000", false, "snponly-x86_64.efi"},
+		{iana.EFI_X86_64_HTTP, "HTTPClient:Arch:00016:UNDI:003001", true, "ipxe-x86_64.efi"},
+		{iana.EFI_X86_64_HTTP, "HTTPClient:Arch:00016:UNDI:003000", true, "snponly-x86_64.efi"},
+		{iana.EFI_ARM64_HTTP, "HTTPClient:Arch:00019:UNDI:003001", true, "ipxe-arm64.efi"},
+		{iana.EFI_X86_64, "HTTPClient:Arch:00007:UNDI:003001", true, "ipxe-x86_64.efi"},
+	}
+
+	for _, test := range tt {
+		req := newTestPoolRequest(t, host.BootInterface().MAC.String())
+		req.UpdateOption(dhcpv4.OptClientArch(test.arch))
+		req.UpdateOption(dhcpv4.OptClassIdentifier(test.class))
+
+		resp, err := dhcpv4.NewReplyFromRequest(req,
+			dhcpv4.WithServerIP(serverIP),
+			dhcpv4.WithMessageType(dhcpv4.MessageTypeOffer),
+			dhcpv4.WithOption(dhcpv4.OptClassIdentifier("PXEClient")),
+		)
+		if !assert.NoError(err) {
+			continue
+		}
+
+		assert.NoError(s.bootingHandler4(host, serverIP, req, resp))
+
+		bootFile := resp.BootFileNameOption()
+		if !test.http {
+			assert.Equal("PXEClient", resp.ClassIdentifier())
+			assert.Equal("10.17.40.1", resp.TFTPServerName())
+			build, err := model.ParseFirmwareToken(bootFile)
+			if assert.NoError(err) {
+				assert.Equal(test.build, build.String())
+			}
+			continue
+		}
+
+		assert.Equal("HTTPClient", resp.ClassIdentifier())
+		assert.Equal("", resp.TFTPServerName())
+		assert.Contains(bootFile, "://10.17.40.1")
+		assert.Contains(bootFile, "/firmware/")
+		assert.True(strings.HasSuffix(bootFile, "/"+test.build), bootFile)
+	}
+}
+
+// testEFIImage returns a minimal PE image for the machine with no sections
+func testEFIImage(machine uint16) []byte {
+	var b bytes.Buffer
+	dos := make([]byte, 0x40)
+	copy(dos, "MZ")
+	binary.LittleEndian.PutUint32(dos[0x3c:], 0x40)
+	b.Write(dos)
+	b.WriteString("PE\x00\x00")
+	binary.Write(&b, binary.LittleEndian, pe.FileHeader{Machine: machine})
+	b.Write(make([]byte, 0x200-b.Len()))
+
+	return b.Bytes()
+}
+
+func TestBootingEFIBuilds(t *testing.T) {
+	assert := assert.New(t)
+
+	for name, machine := range map[string]uint16{
+		"ipxe-arm32.efi":   pe.IMAGE_FILE_MACHINE_ARMNT,
+		"ipxe-riscv32.efi": pe.IMAGE_FILE_MACHINE_RISCV32,
+		"ipxe-riscv64.efi": pe.IMAGE_FILE_MACHINE_RISCV64,
+	} {
+		_, err := firmware.Register(name, testEFIImage(machine))
+		if !assert.NoError(err) {
+			return
+		}
+	}
+
+	host := tests.HostFactory.MustCreate().(*model.Host)
+	host.Provision = true
+	host.Firmware = 0
+
+	s, _ := newTestPoolServer(t, model.HostList{host})
+	s6 := &Server6{DB: s.DB}
+	serverIP := net.ParseIP("10.17.40.1")
+
+	tt := []struct {
+		arch  iana.Arch
+		class string
+		build string
+	}{
+		{iana.EFI_ARM32, "PXEClient:Arch:00010:UNDI:003000", "ipxe-arm32.efi"},
+		{iana.EFI_ARM32_HTTP, "HTTPClient:Arch:00018:UNDI:003000", "ipxe-arm32.efi"},
+		{iana.EFI_RISCV32, "PXEClient:Arch:00025:UNDI:003000", "ipxe-riscv32.efi"},
+		{iana.EFI_RISCV64, "PXEClient:Arch:00027:UNDI:003000", "ipxe-riscv64.efi"},
+		{iana.EFI_RISCV64_HTTP, "HTTPClient:Arch:00029:UNDI:003000", "ipxe-riscv64.efi"},
+		{iana.EFI_X86_64, "PXEClient:Arch:00007:UNDI:003000", "snponly-x86_64.efi"},
+		{iana.EFI_X86_64_HTTP, "HTTPClient:Arch:00016:UNDI:003000", "snponly-x86_64.efi"},
+	}
+
+	for _, test := range tt {
+		req := newTestPoolRequest(t, host.BootInterface().MAC.String())
+		req.UpdateOption(dhcpv4.OptClientArch(test.arch))
+		req.UpdateOption(dhcpv4.OptClassIdentifier(test.class))
+
+		resp, err := dhcpv4.NewReplyFromRequest(req, dhcpv4.WithServerIP(serverIP))
+		if !assert.NoError(err) {
+			continue
+		}
+
+		if assert.NoError(s.bootingHandler4(host, serverIP, req, resp), test.class) {
+			assert.Equal(test.build, bootFileBuild(resp.BootFileNameOption()), test.class)
+		}
+
+		sol, err := dhcpv6.NewSolicit(host.BootInterface().MAC,
+			dhcpv6.WithArchType(test.arch),
+			dhcpv6.WithOption(&dhcpv6.OptVendorClass{Data: [][]byte{[]byte(test.class)}}),
+		)
+		if !assert.NoError(err) {
+			continue
+		}
+
+		adv, err := dhcpv6.NewAdvertiseFromSolicit(sol)
+		if !assert.NoError(err) {
+			continue
+		}
+
+		if assert.NoError(s6.bootingHandler6(host, host.BootInterface().MAC, net.IPv6loopback, sol, adv), test.class) {
+			assert.Equal(test.build, bootFileBuild(adv.Options.BootFileURL()), test.class)
+		}
+	}
+}
+
+// bootFileBuild returns the name of the build served by a boot file, either a
+// firmware token, a TFTP URL of a token or an HTTP firmware URL
+func bootFileBuild(bootFile string) string {
+	if strings.Contains(bootFile, "/firmware/") {
+		return bootFile[strings.LastIndex(bootFile, "/")+1:]
+	}
+
+	build, err := model.ParseFirmwareToken(bootFile[strings.LastIndex(bootFile, "/")+1:])
+	if err != nil {
+		return ""
+	}
+
+	return build.String()
+}
+
+func TestFirmwareOverride(t *testing.T) {
+	assert := assert.New(t)
+
+	host := tests.HostFactory.MustCreate().(*model.Host)
+	host.Firmware = 0
+	host.BootImage = "centos"
+	host.Tags = []string{"k16"}
+
+	s, _ := newTestPoolServer(t, model.HostList{host})
+	assert.NoError(s.DB.StoreBootImage(&model.BootImage{Name: "centos", KernelPath: "/vmlinuz", Firmware: firmware.SNPONLY}))
+
+	client := firmware.NewClient4(newTestEnrollRequest(t, host.BootInterface().MAC.String()))
+	client.Tags = host.Tags
+
+	assert.Equal(firmware.SNPONLY, firmwareOverride(s.DB, host, client))
+
+	// Overrides take precedence over the boot image
+	firmware.Overrides = []firmware.Override{{Tag: "k16", Build: firmware.EFI386}}
+	t.Cleanup(func() { firmware.Overrides = nil })
+	assert.Equal(firmware.EFI386, firmwareOverride(s.DB, host, client))
+
+	host.Firmware = firmware.EFI64
+	assert.Equal(firmware.EFI64, firmwareOverride(s.DB, host, client))
+
+	// Builds which are not loaded fall back to the detected build
+	host.Firmware = firmware.Build(99999)
+	assert.True(firmwareOverride(s.DB, host, client).IsNil())
+}
+
+func TestBootFirmware(t *testing.T) {
+	assert := assert.New(t)
+
+	kpxe, err := firmware.Register("undionly-serial.kpxe", []byte("undionly"))
+	if !assert.NoError(err) {
+		return
+	}
+
+	host := tests.HostFactory.MustCreate().(*model.Host)
+	host.Provision = true
+	s, _ := newTestPoolServer(t, model.HostList{host})
+
+	client := func(arch iana.Arch, class string, userClass string) *firmware.Client {
+		req := newTestPoolRequest(t, host.BootInterface().MAC.String())
+		req.UpdateOption(dhcpv4.OptClientArch(arch))
+		req.UpdateOption(dhcpv4.OptClassIdentifier(class))
+		if userClass != "" {
+			req.UpdateOption(dhcpv4.OptUserClass(userClass))
+		}
+		return firmware.NewClient4(req)
+	}
+
+	bios := client(iana.INTEL_X86PC, "PXEClient:Arch:00000:UNDI:002001", "")
+	ipxe := client(iana.INTEL_X86PC, "PXEClient:Arch:00000:UNDI:002001", "iPXE")
+	efi64 := client(iana.EFI_X86_64, "PXEClient:Arch:00007:UNDI:003016", "")
+	arm64 := client(iana.EFI_ARM64, "PXEClient:Arch:00011:UNDI:003016", "")
+
+	tt := []struct {
+		host   firmware.Build
+		client *firmware.Client
+		build  firmware.Build
+	}{
+		{0, bios, firmware.UNDI},
+		{0, efi64, firmware.EFI64},
+		{firmware.SNPONLY, efi64, firmware.SNPONLY},
+		{kpxe, bios, kpxe},
+		// Overrides for another platform are ignored
+		{firmware.SNPONLY, bios, firmware.UNDI},
+		{firmware.EFI386, efi64, firmware.EFI64},
+		{firmware.EFI64, arm64, firmware.EFIARM64},
+		{kpxe, efi64, firmware.EFI64},
+		// Clients already running iPXE are not overridden
+		{kpxe, ipxe, firmware.IPXE},
+	}
+
+	for _, test := range tt {
+		host.Firmware = test.host
+		build, err := bootFirmware(s.DB, host, test.client)
+		if assert.NoError(err) {
+			assert.Equal(test.build, build, "host firmware %s client %v", test.host, test.client.Archs)
+		}
+	}
+
+	// A BIOS client overridden with a custom build is sent it from the PXE
+	// boot server
+	s.ProxyOnly = true
+	host.Firmware = kpxe
+	req := newTestPoolRequest(t, host.BootInterface().MAC.String())
+	req.UpdateOption(dhcpv4.OptClientArch(iana.INTEL_X86PC))
+	req.UpdateOption(dhcpv4.OptClassIdentifier("PXEClient:Arch:00000:UNDI:002001"))
+	resp, err := dhcpv4.NewReplyFromRequest(req)
+	if assert.NoError(err) && assert.NoError(s.bootingHandler4(host, net.ParseIP("10.17.40.1"), req, resp)) {
+		assert.Equal("undionly-serial.kpxe", bootFileBuild(resp.BootFileNameOption()))
+	}
+}
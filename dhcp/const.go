@@ -24,3 +24,7 @@ import (
 var (
 	CancelTime = time.Unix(1, 0)
 )
+
+// maxBootFileNameLen is the longest boot file name which fits in the 128 byte
+// BOOTP file field along with its terminating NUL
+const maxBootFileNameLen = 127
@@ -0,0 +1,220 @@
+// Copyright 2019 Grendel Authors. All rights reserved.
+//
+// This file is part of Grendel.
+//
+// Grendel is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Grendel is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with Grendel. If not, see <https://www.gnu.org/licenses/>.
+
+package dhcp
+
+import (
+	"errors"
+	"fmt"
+	"net/netip"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/insomniacslk/dhcp/dhcpv4"
+	"github.com/sirupsen/logrus"
+	"github.com/ubccr/grendel/events"
+	"github.com/ubccr/grendel/logger"
+	"github.com/ubccr/grendel/model"
+)
+
+// enrollUpdateInterval is the minimum time between two writes of the same
+// unregistered host, and between two counts of the unregistered hosts. The
+// discovers received in between are added to the count of the next write.
+var enrollUpdateInterval = time.Minute
+
+// isPXEClient returns true if the request was sent by PXE or UEFI HTTP boot
+// firmware
+func isPXEClient(req *dhcpv4.DHCPv4) bool {
+	class := req.ClassIdentifier()
+	return strings.HasPrefix(class, "PXEClient") || strings.HasPrefix(class, httpClientClass)
+}
+
+// clientUUID returns the client machine identifier (option 97) formatted as
+// the SMBIOS system UUID or an empty string if the client didn't send one.
+// The first three fields are little endian as in SMBIOS 2.6 and later so the
+// UUID matches the one reported by dmidecode and the BMC.
+func clientUUID(req *dhcpv4.DHCPv4) string {
+	b := req.Options.Get(dhcpv4.OptionClientMachineIdentifier)
+	if len(b) != 17 || b[0] != 0 {
+		return ""
+	}
+	b = b[1:]
+
+	return fmt.Sprintf("%02x%02x%02x%02x-%02x%02x-%02x%02x-%02x%02x-%x",
+		b[3], b[2], b[1], b[0], b[5], b[4], b[7], b[6], b[8], b[9], b[10:])
+}
+
+// relayID returns a relay agent sub-option as a string if it's printable or
+// as colon separated hex bytes otherwise
+func relayID(b []byte) string {
+	printable := len(b) > 0
+	for _, c := range b {
+		if c > unicode.MaxASCII || !unicode.IsPrint(rune(c)) {
+			printable = false
+			break
+		}
+	}
+
+	if printable {
+		return string(b)
+	}
+
+	hex := make([]string, len(b))
+	for i, c := range b {
+		hex[i] = fmt.Sprintf("%02x", c)
+	}
+
+	return strings.Join(hex, ":")
+}
+
+// relayAgentInfo returns the circuit id and remote id of the relay agent
+// information option (82)
+func relayAgentInfo(req *dhcpv4.DHCPv4) (circuitID, remoteID string) {
+	info := req.RelayAgentInfo()
+	if info == nil {
+		return "", ""
+	}
+
+	return relayID(info.Get(dhcpv4.AgentCircuitIDSubOption)), relayID(info.Get(dhcpv4.AgentRemoteIDSubOption))
+}
+
+// enroll records an unknown client sending a PXE discover as an unregistered
+// host. Known clients are written at most every enrollUpdateInterval and new
+// clients are dropped while EnrollMax unregistered hosts are recorded, so a
+// boot storm or a flood of spoofed MACs doesn't turn into unbounded writes.
+func (s *Server) enroll(req *dhcpv4.DHCPv4) {
+	if !s.AutoEnroll || req.MessageType() != dhcpv4.MessageTypeDiscover || !isPXEClient(req) {
+		return
+	}
+
+	now := time.Now()
+	mac := req.ClientHWAddr.String()
+
+	s.enrollMu.Lock()
+	defer s.enrollMu.Unlock()
+
+	if s.enrollPending == nil {
+		s.enrollPending = make(map[string]int)
+	}
+
+	host, err := s.DB.LoadUnregistered(mac)
+	if err != nil && !errors.Is(err, model.ErrNotFound) {
+		log.Errorf("Failed to load unregistered host %s: %s", mac, err)
+		return
+	}
+
+	isNew := host == nil
+	switch {
+	case isNew && !s.enrollRoom(now):
+		log.Debugf("Not enrolling %s, the unregistered hosts table is full", mac)
+		return
+	case isNew:
+		host = &model.UnregisteredHost{MAC: req.ClientHWAddr, FirstSeen: now}
+	case now.Sub(host.LastSeen) < enrollUpdateInterval:
+		s.enrollPending[mac]++
+		return
+	}
+
+	archs := req.ClientArch()
+	if len(archs) > 0 {
+		host.Arch = archs[0].String()
+	}
+	host.VendorClass = req.ClassIdentifier()
+	if req.Options.Has(dhcpv4.OptionUserClassInformation) {
+		host.UserClass = string(req.Options.Get(dhcpv4.OptionUserClassInformation))
+	}
+	if uuid := clientUUID(req); uuid != "" {
+		host.UUID = uuid
+	}
+	if hostname := req.HostName(); hostname != "" {
+		host.Hostname = hostname
+	}
+	if relay, ok := netip.AddrFromSlice(req.GatewayIPAddr.To4()); ok && !relay.IsUnspecified() {
+		host.RelayAddr = relay
+	}
+	host.CircuitID, host.RemoteID = relayAgentInfo(req)
+	host.LastSeen = now
+	host.Count += 1 + s.enrollPending[mac]
+	delete(s.enrollPending, mac)
+
+	if err := s.DB.StoreUnregistered(host); err != nil {
+		log.Errorf("Failed to store unregistered host %s: %s", mac, err)
+		return
+	}
+
+	if !isNew {
+		return
+	}
+
+	s.enrollCount++
+
+	log.WithFields(logrus.Fields{
+		logger.FieldMAC: mac,
+		"arch":          host.Arch,
+		"uuid":          host.UUID,
+		"circuit_id":    host.CircuitID,
+	}).Info("Enrolled unregistered host")
+
+	events.Publish(&events.Event{
+		Service: "dhcp",
+		Type:    events.TypeHostUnregistered,
+		MAC:     mac,
+		Message: host.VendorClass,
+	})
+}
+
+// enrollRoom returns true if a new unregistered host can be recorded. At most
+// every enrollUpdateInterval the hosts are counted again and the ones not seen
+// for EnrollExpire are deleted.
+func (s *Server) enrollRoom(now time.Time) bool {
+	if now.Sub(s.enrollCounted) >= enrollUpdateInterval {
+		hosts, err := s.DB.UnregisteredHosts()
+		if err != nil {
+			log.Errorf("Failed to count unregistered hosts: %s", err)
+			return false
+		}
+
+		pending := make(map[string]int)
+		count := 0
+		for _, u := range hosts {
+			mac := u.MAC.String()
+			if s.EnrollExpire > 0 && now.Sub(u.LastSeen) > s.EnrollExpire {
+				if err := s.DB.DeleteUnregistered(mac); err != nil {
+					log.Errorf("Failed to delete expired unregistered host %s: %s", mac, err)
+				} else {
+					continue
+				}
+			}
+
+			if n, ok := s.enrollPending[mac]; ok {
+				pending[mac] = n
+			}
+			count++
+		}
+
+		s.enrollPending = pending
+		s.enrollCount = count
+		s.enrollCounted = now
+
+		if s.EnrollMax > 0 && count >= s.EnrollMax {
+			log.Warnf("Unregistered hosts table is full with %d hosts, not enrolling new clients", count)
+		}
+	}
+
+	return s.EnrollMax <= 0 || s.enrollCount < s.EnrollMax
+}
@@ -0,0 +1,145 @@
+// Copyright 2019 Grendel Authors. All rights reserved.
+//
+// This file is part of Grendel.
+//
+// Grendel is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Grendel is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with Grendel. If not, see <https://www.gnu.org/licenses/>.
+
+package dhcp
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/insomniacslk/dhcp/dhcpv4"
+	"github.com/insomniacslk/dhcp/iana"
+	"github.com/stretchr/testify/assert"
+	"github.com/ubccr/grendel/events"
+	"github.com/ubccr/grendel/model"
+)
+
+func newTestEnrollRequest(t *testing.T, mac string) *dhcpv4.DHCPv4 {
+	req := newTestPoolRequest(t, mac)
+	req.UpdateOption(dhcpv4.OptClassIdentifier("PXEClient:Arch:00007:UNDI:003016"))
+	req.UpdateOption(dhcpv4.OptClientArch(iana.EFI_X86_64))
+	req.UpdateOption(dhcpv4.OptGeneric(dhcpv4.OptionClientMachineIdentifier, []byte{
+		0x00, 0x44, 0x45, 0x4c, 0x4c, 0x42, 0x00, 0x10, 0x35,
+		0x80, 0x52, 0xb4, 0xc0, 0x4f, 0x4a, 0x4d, 0x32,
+	}))
+	req.GatewayIPAddr = net.ParseIP("10.17.40.254")
+	req.UpdateOption(dhcpv4.OptRelayAgentInfo(
+		dhcpv4.OptGeneric(dhcpv4.AgentCircuitIDSubOption, []byte("Ethernet1/1")),
+		dhcpv4.OptGeneric(dhcpv4.AgentRemoteIDSubOption, []byte{0x00, 0x11, 0x22, 0x33, 0x44, 0x55}),
+	))
+
+	return req
+}
+
+func TestEnroll(t *testing.T) {
+	assert := assert.New(t)
+
+	s, _ := newTestPoolServer(t, model.HostList{})
+	s.AutoEnroll = true
+
+	sub := events.Subscribe(&events.Filter{Types: []string{events.TypeHostUnregistered}}, 10)
+	defer sub.Close()
+
+	req := newTestEnrollRequest(t, "02:00:00:00:00:01")
+	s.enroll(req)
+	s.enroll(req)
+
+	host, err := s.DB.LoadUnregistered("02:00:00:00:00:01")
+	if assert.NoError(err) {
+		assert.Equal("EFI x86-64", host.Arch)
+		assert.Equal("PXEClient:Arch:00007:UNDI:003016", host.VendorClass)
+		assert.Equal("4c4c4544-0042-3510-8052-b4c04f4a4d32", host.UUID)
+		assert.Equal("10.17.40.254", host.RelayAddr.String())
+		assert.Equal("Ethernet1/1", host.CircuitID)
+		assert.Equal("00:11:22:33:44:55", host.RemoteID)
+		assert.Equal(1, host.Count)
+	}
+
+	// Discovers sent within enrollUpdateInterval are counted on the next write
+	host.LastSeen = host.LastSeen.Add(-enrollUpdateInterval)
+	assert.NoError(s.DB.StoreUnregistered(host))
+	s.enroll(req)
+
+	host, err = s.DB.LoadUnregistered("02:00:00:00:00:01")
+	if assert.NoError(err) {
+		assert.Equal(3, host.Count)
+	}
+
+	// Only the first discover is published
+	select {
+	case e := <-sub.C:
+		assert.Equal("02:00:00:00:00:01", e.MAC)
+	case <-time.After(time.Second):
+		assert.Fail("no unregistered event published")
+	}
+	select {
+	case e := <-sub.C:
+		assert.Fail("unexpected event", e.Type)
+	default:
+	}
+
+	// Non PXE clients are ignored
+	s.enroll(newTestPoolRequest(t, "02:00:00:00:00:02"))
+	_, err = s.DB.LoadUnregistered("02:00:00:00:00:02")
+	assert.ErrorIs(err, model.ErrNotFound)
+
+	s.AutoEnroll = false
+	s.enroll(newTestEnrollRequest(t, "02:00:00:00:00:03"))
+	_, err = s.DB.LoadUnregistered("02:00:00:00:00:03")
+	assert.ErrorIs(err, model.ErrNotFound)
+}
+
+func TestEnrollLimit(t *testing.T) {
+	assert := assert.New(t)
+
+	s, _ := newTestPoolServer(t, model.HostList{})
+	s.AutoEnroll = true
+	s.EnrollMax = 2
+	s.EnrollExpire = time.Hour
+
+	stale, _ := net.ParseMAC("02:00:00:00:00:01")
+	now := time.Now()
+	assert.NoError(s.DB.StoreUnregistered(&model.UnregisteredHost{MAC: stale, FirstSeen: now.Add(-2 * time.Hour), LastSeen: now.Add(-2 * time.Hour), Count: 1}))
+
+	// Hosts not seen for EnrollExpire are deleted to make room
+	s.enroll(newTestEnrollRequest(t, "02:00:00:00:00:02"))
+	s.enroll(newTestEnrollRequest(t, "02:00:00:00:00:03"))
+	_, err := s.DB.LoadUnregistered("02:00:00:00:00:01")
+	assert.ErrorIs(err, model.ErrNotFound)
+
+	// The table is full
+	s.enroll(newTestEnrollRequest(t, "02:00:00:00:00:04"))
+	_, err = s.DB.LoadUnregistered("02:00:00:00:00:04")
+	assert.ErrorIs(err, model.ErrNotFound)
+
+	hosts, err := s.DB.UnregisteredHosts()
+	if assert.NoError(err) {
+		assert.Len(hosts, 2)
+	}
+
+	// Room is counted again after enrollUpdateInterval
+	assert.NoError(s.DB.DeleteUnregistered("02:00:00:00:00:02"))
+	s.enroll(newTestEnrollRequest(t, "02:00:00:00:00:04"))
+	_, err = s.DB.LoadUnregistered("02:00:00:00:00:04")
+	assert.ErrorIs(err, model.ErrNotFound)
+
+	s.enrollCounted = s.enrollCounted.Add(-enrollUpdateInterval)
+	s.enroll(newTestEnrollRequest(t, "02:00:00:00:00:04"))
+	_, err = s.DB.LoadUnregistered("02:00:00:00:00:04")
+	assert.NoError(err)
+}
@@ -0,0 +1,68 @@
+// Copyright 2019 Grendel Authors. All rights reserved.
+//
+// This file is part of Grendel.
+//
+// Grendel is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Grendel is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with Grendel. If not, see <https://www.gnu.org/licenses/>.
+
+package dhcp
+
+import (
+	"strings"
+
+	"github.com/insomniacslk/dhcp/dhcpv4"
+	"github.com/insomniacslk/dhcp/dhcpv6"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/ubccr/grendel/metrics"
+)
+
+const (
+	resultIgnored = "ignored"
+	resultError   = "error"
+)
+
+var (
+	packetsTotal = metrics.Factory.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metrics.Namespace,
+		Subsystem: "dhcp",
+		Name:      "packets_total",
+		Help:      "DHCP requests by message type and result (offer, ack, nak, advertise, reply, ignored or error)",
+	}, []string{"type", "result"})
+
+	pxeRequestsTotal = metrics.Factory.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metrics.Namespace,
+		Subsystem: "pxe",
+		Name:      "requests_total",
+		Help:      "PXE boot server replies by firmware build",
+	}, []string{"build"})
+)
+
+// observePacket counts a DHCP request and the result sent back to the client
+func observePacket(req *dhcpv4.DHCPv4, result string) {
+	packetsTotal.WithLabelValues(strings.ToLower(req.MessageType().String()), result).Inc()
+}
+
+// observeReply counts a DHCP request by the type of reply sent
+func observeReply(req, resp *dhcpv4.DHCPv4) {
+	observePacket(req, strings.ToLower(resp.MessageType().String()))
+}
+
+// observePacket6 counts a DHCPv6 request and the result sent back to the client
+func observePacket6(msg *dhcpv6.Message, result string) {
+	packetsTotal.WithLabelValues(strings.ToLower(msg.Type().String()), result).Inc()
+}
+
+// observeReply6 counts a DHCPv6 request by the type of reply sent
+func observeReply6(msg, resp *dhcpv6.Message) {
+	observePacket6(msg, strings.ToLower(resp.Type().String()))
+}
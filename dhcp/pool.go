@@ -0,0 +1,402 @@
+// Copyright 2019 Grendel Authors. All rights reserved.
+//
+// This file is part of Grendel.
+//
+// Grendel is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Grendel is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with Grendel. If not, see <https://www.gnu.org/licenses/>.
+
+package dhcp
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/netip"
+	"time"
+
+	"github.com/insomniacslk/dhcp/dhcpv4"
+	"github.com/insomniacslk/dhcp/rfc1035label"
+	"github.com/sirupsen/logrus"
+	"github.com/ubccr/grendel/logger"
+	"github.com/ubccr/grendel/model"
+	"golang.org/x/net/ipv4"
+)
+
+// offerHold is how long an address offered from a dynamic pool is reserved
+// for the client before it's sent a REQUEST
+var offerHold = 2 * time.Minute
+
+// declineHold is how long an address declined by a client is kept out of the
+// dynamic pool. The client found it in use by another machine.
+var declineHold = time.Hour
+
+// poolSubnet returns the subnet with a dynamic pool serving the network the
+// request came from or nil if there is none. Relayed requests are matched on
+// the relay agent address, otherwise the server address is used.
+func poolSubnet(req *dhcpv4.DHCPv4, serverIP net.IP) *model.Subnet {
+	ip := serverIP
+	if req.GatewayIPAddr != nil && !req.GatewayIPAddr.IsUnspecified() {
+		ip = req.GatewayIPAddr
+	}
+
+	addr, ok := netip.AddrFromSlice(ip.To4())
+	if !ok {
+		return nil
+	}
+
+	for i := range model.Subnets {
+		subnet := &model.Subnets[i]
+		if subnet.Pool.IsValid() && subnet.Gateway.Contains(addr) {
+			return subnet
+		}
+	}
+
+	return nil
+}
+
+// reserved returns true if the address can never be leased from the subnet
+func reserved(subnet *model.Subnet, addr netip.Addr) bool {
+	prefix := subnet.Gateway.Masked()
+	if addr == subnet.Gateway.Addr() || addr == prefix.Addr() {
+		return true
+	}
+
+	if subnet.Gateway.Bits() < 31 {
+		bcast := prefix.Addr().As4()
+		for i := subnet.Gateway.Bits(); i < 32; i++ {
+			bcast[i/8] |= 1 << (7 - i%8)
+		}
+		if addr == netip.AddrFrom4(bcast) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// allocate leases an address from the subnet pool to the client for at least
+// hold. The client's current address is kept if it's still in the pool.
+// Otherwise free addresses are tried first, then addresses of expired leases.
+func (s *Server) allocate(subnet *model.Subnet, req *dhcpv4.DHCPv4, hold time.Duration) (*model.Lease, error) {
+	s.poolMu.Lock()
+	defer s.poolMu.Unlock()
+
+	now := time.Now()
+	mac := req.ClientHWAddr
+
+	current, err := s.DB.LoadLease(mac.String())
+	if err != nil && !errors.Is(err, model.ErrNotFound) {
+		return nil, err
+	}
+
+	lease := &model.Lease{
+		MAC:      mac,
+		Hostname: req.HostName(),
+		Started:  now,
+		Expires:  now.Add(hold),
+	}
+
+	if current != nil && subnet.Pool.Contains(current.IP) && !reserved(subnet, current.IP) {
+		lease.IP = current.IP
+		lease.Started = current.Started
+		if current.Expires.After(lease.Expires) {
+			lease.Expires = current.Expires
+		}
+		if lease.Hostname == "" {
+			lease.Hostname = current.Hostname
+		}
+
+		err := s.DB.StoreLease(lease)
+		if err == nil {
+			return lease, nil
+		}
+		if !errors.Is(err, model.ErrDuplicateEntry) {
+			return nil, err
+		}
+
+		// Address was taken by a host in the meantime
+		lease.Started = now
+		lease.Expires = now.Add(hold)
+	}
+
+	leases, err := s.DB.Leases()
+	if err != nil {
+		return nil, err
+	}
+
+	held := make(map[netip.Addr]*model.Lease, len(leases))
+	for _, l := range leases {
+		held[l.IP] = l
+	}
+
+	// First pass skips all leased addresses, the second takes over expired
+	// leases of other clients
+	for pass := 0; pass < 2; pass++ {
+		for ip := subnet.Pool.From(); subnet.Pool.Contains(ip); ip = ip.Next() {
+			if reserved(subnet, ip) {
+				continue
+			}
+
+			if l, ok := held[ip]; ok {
+				if pass == 0 || !l.Expired(now) {
+					continue
+				}
+			}
+
+			lease.IP = ip
+			err := s.DB.StoreLease(lease)
+			if err == nil {
+				return lease, nil
+			}
+			if !errors.Is(err, model.ErrDuplicateEntry) {
+				return nil, err
+			}
+		}
+	}
+
+	return nil, fmt.Errorf("no free address in pool %s", subnet.Pool)
+}
+
+// setPoolOptions sets the address and network options of a dynamic lease
+func (s *Server) setPoolOptions(subnet *model.Subnet, req, resp *dhcpv4.DHCPv4) {
+	resp.UpdateOption(dhcpv4.OptSubnetMask(net.CIDRMask(subnet.Gateway.Bits(), 32)))
+	resp.UpdateOption(dhcpv4.OptRouter(net.IP(subnet.Gateway.Addr().AsSlice())))
+
+	dnsServers := subnet.DNS
+	if len(dnsServers) == 0 {
+		dnsServers = model.DefaultDNS
+	}
+	if len(dnsServers) > 0 && req.IsOptionRequested(dhcpv4.OptionDomainNameServer) {
+		resp.UpdateOption(dhcpv4.OptDNS(dnsServers...))
+	}
+
+	domainSearch := subnet.DomainSearch
+	if len(domainSearch) == 0 {
+		domainSearch = model.DefaultDomainSearch
+	}
+	if len(domainSearch) > 0 {
+		resp.UpdateOption(dhcpv4.OptDomainSearch(&rfc1035label.Labels{
+			Labels: domainSearch,
+		}))
+	}
+
+	if req.IsOptionRequested(dhcpv4.OptionInterfaceMTU) {
+		mtu := subnet.MTU
+		if mtu == 0 {
+			mtu = model.DefaultMTU
+		}
+		resp.UpdateOption(dhcpv4.OptGeneric(dhcpv4.OptionInterfaceMTU, dhcpv4.Uint16(mtu).ToBytes()))
+	}
+}
+
+// dynamicHandler4 serves clients which are not in the data store from the
+// dynamic pool of the subnet the request came from
+func (s *Server) dynamicHandler4(req *dhcpv4.DHCPv4, oob *ipv4.ControlMessage) {
+	serverIP := s.interfaceIP(oob)
+
+	subnet := poolSubnet(req, serverIP)
+	if s.ProxyOnly || subnet == nil {
+		log.Debugf("Ignoring unknown client mac address: %s", req.ClientHWAddr)
+		observePacket(req, resultIgnored)
+		return
+	}
+
+	resp, err := dhcpv4.NewReplyFromRequest(req,
+		dhcpv4.WithServerIP(serverIP),
+		dhcpv4.WithOption(dhcpv4.OptServerIdentifier(serverIP)),
+	)
+	if err != nil {
+		log.Printf("DHCP failed to build reply: %v", err)
+		observePacket(req, resultError)
+		return
+	}
+
+	resp.HopCount = req.HopCount
+
+	fields := logrus.Fields{
+		logger.FieldMAC: req.ClientHWAddr.String(),
+		"pool":          subnet.Pool.String(),
+		"dhcp_message":  req.MessageType().String(),
+	}
+
+	switch mt := req.MessageType(); mt {
+	case dhcpv4.MessageTypeDiscover:
+		lease, err := s.allocate(subnet, req, offerHold)
+		if err != nil {
+			log.WithFields(fields).Errorf("Failed to allocate dynamic lease: %s", err)
+			observePacket(req, resultError)
+			return
+		}
+
+		fields[logger.FieldIP] = lease.IP.String()
+		log.WithFields(fields).Info("Offering dynamic lease")
+
+		resp.UpdateOption(dhcpv4.OptMessageType(dhcpv4.MessageTypeOffer))
+		resp.YourIPAddr = net.IP(lease.IP.AsSlice())
+		resp.UpdateOption(dhcpv4.OptIPAddressLeaseTime(s.LeaseTime))
+		s.setPoolOptions(subnet, req, resp)
+	case dhcpv4.MessageTypeRequest:
+		if sid := req.ServerIdentifier(); sid != nil && !sid.IsUnspecified() && !sid.Equal(serverIP) {
+			// Client selected another server
+			observePacket(req, resultIgnored)
+			return
+		}
+
+		requestedIP := req.RequestedIPAddress()
+		if requestedIP == nil || requestedIP.IsUnspecified() {
+			requestedIP = req.ClientIPAddr
+		}
+
+		lease, err := s.renew(subnet, req, requestedIP)
+		if err != nil {
+			log.WithFields(fields).Errorf("Failed to renew dynamic lease: %s", err)
+			observePacket(req, resultError)
+			return
+		}
+
+		if lease == nil {
+			msg := fmt.Sprintf("Requested IP address %v is not leased to client", requestedIP)
+			log.WithFields(fields).Info(msg)
+			resp.UpdateOption(dhcpv4.OptMessage(msg))
+			resp.UpdateOption(dhcpv4.OptMessageType(dhcpv4.MessageTypeNak))
+			break
+		}
+
+		fields[logger.FieldIP] = lease.IP.String()
+		log.WithFields(fields).Info("Acknowledging dynamic lease")
+
+		if req.ClientIPAddr != nil && !req.ClientIPAddr.IsUnspecified() {
+			resp.ClientIPAddr = req.ClientIPAddr
+		}
+		resp.UpdateOption(dhcpv4.OptMessageType(dhcpv4.MessageTypeAck))
+		resp.YourIPAddr = net.IP(lease.IP.AsSlice())
+		resp.UpdateOption(dhcpv4.OptIPAddressLeaseTime(s.LeaseTime))
+		s.setPoolOptions(subnet, req, resp)
+	case dhcpv4.MessageTypeInform:
+		resp.UpdateOption(dhcpv4.OptMessageType(dhcpv4.MessageTypeAck))
+		s.setPoolOptions(subnet, req, resp)
+	case dhcpv4.MessageTypeRelease:
+		log.WithFields(fields).Info("Client released dynamic lease")
+
+		if err := s.DB.DeleteLease(req.ClientHWAddr.String()); err != nil && !errors.Is(err, model.ErrNotFound) {
+			log.WithFields(fields).Errorf("Failed to delete dynamic lease: %s", err)
+			observePacket(req, resultError)
+			return
+		}
+
+		observePacket(req, resultIgnored)
+		return
+	case dhcpv4.MessageTypeDecline:
+		lease, err := s.decline(req)
+		if err != nil {
+			log.WithFields(fields).Errorf("Failed to hold declined dynamic lease: %s", err)
+			observePacket(req, resultError)
+			return
+		}
+
+		if lease != nil {
+			fields[logger.FieldIP] = lease.IP.String()
+			log.WithFields(fields).Warnf("Client declined dynamic lease, holding address until %s", lease.Expires.Format(time.RFC3339))
+		}
+
+		observePacket(req, resultIgnored)
+		return
+	default:
+		log.Warnf("DHCP Unhandled message type: %v", mt)
+		observePacket(req, resultIgnored)
+		return
+	}
+
+	if err := s.send(req, resp, oob); err != nil {
+		log.Printf("DHCP write failed: %v", err)
+		observePacket(req, resultError)
+		return
+	}
+
+	observeReply(req, resp)
+	publishEvent(nil, req, resp)
+}
+
+// decline replaces the lease of the client with a lease without owner holding
+// the declined address for declineHold, so it isn't offered again while the
+// machine using it is still up. Only the address leased to the client can be
+// declined. Returns nil if the client holds no lease for the address.
+func (s *Server) decline(req *dhcpv4.DHCPv4) (*model.Lease, error) {
+	s.poolMu.Lock()
+	defer s.poolMu.Unlock()
+
+	current, err := s.DB.LoadLease(req.ClientHWAddr.String())
+	if errors.Is(err, model.ErrNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if ip := req.RequestedIPAddress(); ip != nil && !ip.IsUnspecified() && !ip.Equal(net.IP(current.IP.AsSlice())) {
+		return nil, nil
+	}
+
+	if err := s.DB.DeleteLease(req.ClientHWAddr.String()); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	lease := &model.Lease{
+		IP:      current.IP,
+		Started: now,
+		Expires: now.Add(declineHold),
+	}
+
+	return lease, s.DB.StoreLease(lease)
+}
+
+// renew extends the lease of the client for the lease time if it holds the
+// requested address. Returns nil if the address is not leased to the client.
+func (s *Server) renew(subnet *model.Subnet, req *dhcpv4.DHCPv4, requestedIP net.IP) (*model.Lease, error) {
+	addr, ok := netip.AddrFromSlice(requestedIP.To4())
+	if !ok || !subnet.Pool.Contains(addr) {
+		return nil, nil
+	}
+
+	s.poolMu.Lock()
+	defer s.poolMu.Unlock()
+
+	lease, err := s.DB.LoadLease(req.ClientHWAddr.String())
+	if errors.Is(err, model.ErrNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if lease.IP != addr {
+		return nil, nil
+	}
+
+	lease.Expires = time.Now().Add(s.LeaseTime)
+	if hostname := req.HostName(); hostname != "" {
+		lease.Hostname = hostname
+	}
+
+	err = s.DB.StoreLease(lease)
+	if errors.Is(err, model.ErrDuplicateEntry) {
+		// Address was assigned to a host or taken over after the lease expired
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return lease, nil
+}
@@ -0,0 +1,220 @@
+// Copyright 2019 Grendel Authors. All rights reserved.
+//
+// This file is part of Grendel.
+//
+// Grendel is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Grendel is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with Grendel. If not, see <https://www.gnu.org/licenses/>.
+
+package dhcp
+
+import (
+	"net"
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/insomniacslk/dhcp/dhcpv4"
+	"github.com/stretchr/testify/assert"
+	"github.com/ubccr/grendel/internal/tests"
+	"github.com/ubccr/grendel/model"
+	"go4.org/netipx"
+)
+
+func newTestPoolServer(t *testing.T, hosts model.HostList) (*Server, *model.Subnet) {
+	db, err := model.NewDataStore(":memory:")
+	if err != nil {
+		assert.Fail(t, err.Error())
+	}
+	t.Cleanup(func() { db.Close() })
+
+	err = db.StoreHosts(hosts)
+	if err != nil {
+		assert.Fail(t, err.Error())
+	}
+
+	subnet := &model.Subnet{
+		Gateway: netip.MustParsePrefix("10.17.40.1/24"),
+		Pool:    netipx.IPRangeFrom(netip.MustParseAddr("10.17.40.1"), netip.MustParseAddr("10.17.40.3")),
+	}
+
+	return &Server{DB: db, LeaseTime: time.Hour, ServerAddress: net.ParseIP("10.17.40.1")}, subnet
+}
+
+func newTestPoolRequest(t *testing.T, mac string) *dhcpv4.DHCPv4 {
+	hwaddr, err := net.ParseMAC(mac)
+	if err != nil {
+		assert.Fail(t, err.Error())
+	}
+
+	req, err := dhcpv4.NewDiscovery(hwaddr)
+	if err != nil {
+		assert.Fail(t, err.Error())
+	}
+
+	return req
+}
+
+func TestPoolAllocate(t *testing.T) {
+	assert := assert.New(t)
+
+	host := tests.HostFactory.MustCreate().(*model.Host)
+	host.Interfaces[0].IP = netip.MustParsePrefix("10.17.40.2/24")
+
+	s, subnet := newTestPoolServer(t, model.HostList{host})
+
+	req1 := newTestPoolRequest(t, "02:00:00:00:00:01")
+	lease, err := s.allocate(subnet, req1, offerHold)
+	if assert.NoError(err) {
+		// Gateway and host addresses are skipped
+		assert.Equal("10.17.40.3", lease.IP.String())
+	}
+
+	// Same client gets the same address
+	lease, err = s.allocate(subnet, req1, offerHold)
+	if assert.NoError(err) {
+		assert.Equal("10.17.40.3", lease.IP.String())
+	}
+
+	req2 := newTestPoolRequest(t, "02:00:00:00:00:02")
+	_, err = s.allocate(subnet, req2, offerHold)
+	assert.Error(err)
+
+	// Expired leases are taken over by other clients
+	lease.Expires = time.Now().Add(-time.Minute)
+	assert.NoError(s.DB.StoreLease(lease))
+	lease, err = s.allocate(subnet, req2, offerHold)
+	if assert.NoError(err) {
+		assert.Equal("10.17.40.3", lease.IP.String())
+		assert.Equal("02:00:00:00:00:02", lease.MAC.String())
+	}
+
+	_, err = s.DB.LoadLease("02:00:00:00:00:01")
+	assert.ErrorIs(err, model.ErrNotFound)
+}
+
+func TestPoolRenew(t *testing.T) {
+	assert := assert.New(t)
+
+	s, subnet := newTestPoolServer(t, model.HostList{})
+
+	req := newTestPoolRequest(t, "02:00:00:00:00:01")
+	offer, err := s.allocate(subnet, req, offerHold)
+	if !assert.NoError(err) {
+		return
+	}
+
+	lease, err := s.renew(subnet, req, net.IP(offer.IP.AsSlice()))
+	if assert.NoError(err) && assert.NotNil(lease) {
+		assert.Equal(offer.IP, lease.IP)
+		assert.True(lease.Expires.After(time.Now().Add(offerHold)))
+	}
+
+	lease, err = s.renew(subnet, req, net.ParseIP("10.17.40.3"))
+	assert.NoError(err)
+	assert.Nil(lease)
+
+	other := newTestPoolRequest(t, "02:00:00:00:00:02")
+	lease, err = s.renew(subnet, other, net.IP(offer.IP.AsSlice()))
+	assert.NoError(err)
+	assert.Nil(lease)
+}
+
+func TestPoolDecline(t *testing.T) {
+	assert := assert.New(t)
+
+	s, subnet := newTestPoolServer(t, model.HostList{})
+
+	req := newTestPoolRequest(t, "02:00:00:00:00:01")
+	offer, err := s.allocate(subnet, req, offerHold)
+	if !assert.NoError(err) {
+		return
+	}
+	assert.Equal("10.17.40.2", offer.IP.String())
+
+	// Only the address leased to the client can be declined
+	decline := newTestPoolRequest(t, "02:00:00:00:00:01")
+	decline.UpdateOption(dhcpv4.OptMessageType(dhcpv4.MessageTypeDecline))
+	decline.UpdateOption(dhcpv4.OptRequestedIPAddress(net.ParseIP("10.17.40.3")))
+	lease, err := s.decline(decline)
+	assert.NoError(err)
+	assert.Nil(lease)
+
+	decline.UpdateOption(dhcpv4.OptRequestedIPAddress(net.IP(offer.IP.AsSlice())))
+	lease, err = s.decline(decline)
+	if assert.NoError(err) && assert.NotNil(lease) {
+		assert.True(lease.Declined())
+		assert.Equal(offer.IP, lease.IP)
+	}
+
+	_, err = s.DB.LoadLease("02:00:00:00:00:01")
+	assert.ErrorIs(err, model.ErrNotFound)
+
+	leases, err := s.DB.Leases()
+	if assert.NoError(err) && assert.Len(leases, 1) {
+		assert.True(leases[0].Declined())
+		assert.Equal("10.17.40.2", leases[0].IP.String())
+	}
+
+	// The declined address is not offered again while it's held
+	lease, err = s.allocate(subnet, req, offerHold)
+	if assert.NoError(err) {
+		assert.Equal("10.17.40.3", lease.IP.String())
+	}
+
+	other := newTestPoolRequest(t, "02:00:00:00:00:02")
+	_, err = s.allocate(subnet, other, offerHold)
+	assert.Error(err)
+
+	// It's reused once the hold expired
+	leases[0].Expires = time.Now().Add(-time.Minute)
+	assert.NoError(s.DB.StoreLease(leases[0]))
+	lease, err = s.allocate(subnet, other, offerHold)
+	if assert.NoError(err) {
+		assert.Equal("10.17.40.2", lease.IP.String())
+	}
+
+	leases, err = s.DB.Leases()
+	if assert.NoError(err) {
+		assert.Len(leases, 2)
+	}
+}
+
+func TestPoolSubnet(t *testing.T) {
+	assert := assert.New(t)
+
+	saved := model.Subnets
+	t.Cleanup(func() { model.Subnets = saved })
+
+	model.Subnets = []model.Subnet{
+		{Gateway: netip.MustParsePrefix("10.17.40.1/24")},
+		{
+			Gateway: netip.MustParsePrefix("10.17.41.1/24"),
+			Pool:    netipx.MustParseIPRange("10.17.41.100-10.17.41.199"),
+		},
+	}
+
+	req := newTestPoolRequest(t, "02:00:00:00:00:01")
+	assert.Nil(poolSubnet(req, net.ParseIP("10.17.40.1")))
+
+	subnet := poolSubnet(req, net.ParseIP("10.17.41.1"))
+	if assert.NotNil(subnet) {
+		assert.Equal("10.17.41.100-10.17.41.199", subnet.Pool.String())
+	}
+
+	req.GatewayIPAddr = net.ParseIP("10.17.41.1")
+	assert.NotNil(poolSubnet(req, net.ParseIP("10.17.40.1")))
+
+	assert.True(reserved(subnet, netip.MustParseAddr("10.17.41.255")))
+	assert.True(reserved(subnet, netip.MustParseAddr("10.17.41.0")))
+	assert.False(reserved(subnet, netip.MustParseAddr("10.17.41.100")))
+}
@@ -28,6 +28,7 @@ import (
 	"github.com/insomniacslk/dhcp/dhcpv4"
 	"github.com/insomniacslk/dhcp/dhcpv4/server4"
 	"github.com/sirupsen/logrus"
+	"github.com/ubccr/grendel/events"
 	"github.com/ubccr/grendel/firmware"
 	"github.com/ubccr/grendel/logger"
 	"github.com/ubccr/grendel/model"
@@ -101,6 +102,10 @@ func NewPXEServer(db model.DataStore, address string) (*PXEServer, error) {
 }
 
 func (s *PXEServer) pxeHandler4(peer *net.UDPAddr, req *dhcpv4.DHCPv4, oob *ipv4.ControlMessage) {
+	// Clients are only matched by MAC. The DHCP server records the MAC of a
+	// client identified by its UUID once it is learned, and boot tokens are
+	// issued for that MAC, so neither this server nor token issuance look up
+	// hosts by UUID.
 	host, err := s.DB.LoadHostFromMAC(req.ClientHWAddr.String())
 	if err != nil {
 		if !errors.Is(err, model.ErrNotFound) {
@@ -122,20 +127,18 @@ func (s *PXEServer) pxeHandler4(peer *net.UDPAddr, req *dhcpv4.DHCPv4, oob *ipv4
 		return
 	}
 
-	if !req.Options.Has(dhcpv4.OptionClientSystemArchitectureType) {
+	client := firmware.NewClient4(req)
+	client.Tags = host.Tags
+	if len(client.Archs) == 0 {
 		s.log.Infof("ignoring packet - missing client system architecture type")
 		return
 	}
 
-	fwtype, err := firmware.DetectBuild(req.ClientArch(), "")
+	fwtype, err := bootFirmware(s.DB, host, client)
 	if err != nil {
 		s.log.Errorf("failed to get firmware: %s", err)
 		return
 	}
-	if host.Firmware != 0 {
-		s.log.Infof("Overriding firmware for host: %s", req.ClientHWAddr.String())
-		fwtype = host.Firmware
-	}
 
 	serverIP := s.ServerAddress
 	// Use the IP address of the interface the request came in on for the
@@ -168,6 +171,10 @@ func (s *PXEServer) pxeHandler4(peer *net.UDPAddr, req *dhcpv4.DHCPv4, oob *ipv4
 		s.log.Errorf("Failed to generated signed firmware token: %v", err)
 		return
 	}
+	if len(token) > maxBootFileNameLen {
+		s.log.Errorf("Firmware token for build %s is %d bytes and doesn't fit in the boot file name", fwtype, len(token))
+		return
+	}
 	resp.BootFileName = token
 
 	var woob *ipv4.ControlMessage
@@ -185,7 +192,20 @@ func (s *PXEServer) pxeHandler4(peer *net.UDPAddr, req *dhcpv4.DHCPv4, oob *ipv4
 
 	if _, err := s.conn.WriteTo(resp.ToBytes(), woob, peer); err != nil {
 		s.log.Errorf("UDP write to %v failed: %v", peer, err)
+		return
 	}
+
+	pxeRequestsTotal.WithLabelValues(fwtype.String()).Inc()
+
+	events.Publish(&events.Event{
+		Service: "pxe",
+		Type:    events.TypePXEAck,
+		Host:    host.Name,
+		MAC:     req.ClientHWAddr.String(),
+		IP:      peer.IP.String(),
+		Tags:    host.Tags,
+		Message: fmt.Sprintf("Sent firmware %s", fwtype),
+	})
 }
 
 func (s *PXEServer) Serve() error {
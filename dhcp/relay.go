@@ -0,0 +1,68 @@
+// Copyright 2019 Grendel Authors. All rights reserved.
+//
+// This file is part of Grendel.
+//
+// Grendel is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Grendel is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with Grendel. If not, see <https://www.gnu.org/licenses/>.
+
+package dhcp
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/insomniacslk/dhcp/dhcpv4"
+	"github.com/sirupsen/logrus"
+	"github.com/ubccr/grendel/logger"
+	"github.com/ubccr/grendel/model"
+)
+
+// hostFromRelay returns the host with an interface identified by the relay
+// agent information of the request. The client MAC is recorded on the matching
+// interface so later lookups by MAC, for example from TFTP and the provision
+// server, find the host as well.
+func (s *Server) hostFromRelay(req *dhcpv4.DHCPv4) (*model.Host, error) {
+	circuitID, remoteID := relayAgentInfo(req)
+	if circuitID == "" {
+		return nil, fmt.Errorf("no relay agent circuit id:  %w", model.ErrNotFound)
+	}
+
+	host, err := s.DB.LoadHostFromRelay(circuitID, remoteID)
+	if err != nil {
+		return nil, err
+	}
+
+	nic := host.InterfaceFromRelay(circuitID, remoteID)
+	if nic == nil {
+		return nil, fmt.Errorf("no interface with circuit id %s:  %w", circuitID, model.ErrNotFound)
+	}
+
+	if bytes.Equal(nic.MAC, req.ClientHWAddr) {
+		return host, nil
+	}
+
+	log.WithFields(logrus.Fields{
+		logger.FieldHost: host.Name,
+		"circuit_id":     circuitID,
+		"remote_id":      remoteID,
+		"old_mac":        nic.MAC.String(),
+		logger.FieldMAC:  req.ClientHWAddr.String(),
+	}).Info("Learned new MAC address from relay agent information")
+
+	nic.MAC = req.ClientHWAddr
+	if err := model.NewAuditedStore(s.DB, "dhcp", "").StoreHost(host); err != nil {
+		return nil, err
+	}
+
+	return host, nil
+}
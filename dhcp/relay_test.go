@@ -0,0 +1,63 @@
+// Copyright 2019 Grendel Authors. All rights reserved.
+//
+// This file is part of Grendel.
+//
+// Grendel is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Grendel is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with Grendel. If not, see <https://www.gnu.org/licenses/>.
+
+package dhcp
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/ubccr/grendel/internal/tests"
+	"github.com/ubccr/grendel/model"
+)
+
+func TestHostFromRelay(t *testing.T) {
+	assert := assert.New(t)
+
+	host := tests.HostFactory.MustCreate().(*model.Host)
+	host.Interfaces[0].CircuitID = "Ethernet1/1"
+	oldMAC := host.Interfaces[0].MAC.String()
+
+	s, _ := newTestPoolServer(t, model.HostList{host})
+
+	// Replaced motherboard relayed from the same switch port
+	req := newTestEnrollRequest(t, "02:00:00:00:00:01")
+	testHost, err := s.hostFromRelay(req)
+	if assert.NoError(err) {
+		assert.Equal(host.Name, testHost.Name)
+		assert.Equal("02:00:00:00:00:01", testHost.Interfaces[0].MAC.String())
+	}
+
+	testHost, err = s.DB.LoadHostFromMAC("02:00:00:00:00:01")
+	if assert.NoError(err) {
+		assert.Equal(host.Name, testHost.Name)
+	}
+
+	_, err = s.DB.LoadHostFromMAC(oldMAC)
+	assert.True(errors.Is(err, model.ErrNotFound))
+
+	// Requests without relay agent information never match
+	_, err = s.hostFromRelay(newTestPoolRequest(t, "02:00:00:00:00:02"))
+	assert.True(errors.Is(err, model.ErrNotFound))
+
+	// Remote id must match when set on the interface
+	testHost.Interfaces[0].RemoteID = "leaf-01"
+	assert.NoError(s.DB.StoreHost(testHost))
+	_, err = s.hostFromRelay(newTestEnrollRequest(t, "02:00:00:00:00:03"))
+	assert.True(errors.Is(err, model.ErrNotFound))
+}
@@ -29,6 +29,7 @@ import (
 	"github.com/insomniacslk/dhcp/dhcpv4"
 	"github.com/insomniacslk/dhcp/dhcpv4/server4"
 	"github.com/sirupsen/logrus"
+	"github.com/ubccr/grendel/events"
 	"github.com/ubccr/grendel/logger"
 	"github.com/ubccr/grendel/model"
 	"github.com/ubccr/grendel/util"
@@ -44,10 +45,33 @@ type Server struct {
 	Port           int
 	ProxyOnly      bool
 	DB             model.DataStore
+	AutoEnroll     bool
 	LeaseTime      time.Duration
 	conn           *ipv4.PacketConn
+	poolMu         sync.Mutex
 	quit           chan interface{}
 	wg             sync.WaitGroup
+
+	// UUIDRelearnBoots is the number of boots after which the MAC of an
+	// unknown client sending the UUID of a host is learned as the MAC of
+	// its boot interface. Zero disables learning.
+	UUIDRelearnBoots int
+	uuidMu           sync.Mutex
+	uuidCandidates   map[string]*uuidCandidate
+
+	// EnrollMax is the maximum number of unregistered hosts recorded with
+	// AutoEnroll. New clients are not recorded while the table is full. Zero
+	// disables the limit.
+	EnrollMax int
+
+	// EnrollExpire is how long an unregistered host which stopped sending
+	// discovers is kept before it's deleted. Zero keeps them until they are
+	// promoted or deleted.
+	EnrollExpire  time.Duration
+	enrollMu      sync.Mutex
+	enrollCount   int
+	enrollCounted time.Time
+	enrollPending map[string]int
 }
 
 func NewServer(db model.DataStore, address string) (*Server, error) {
@@ -102,25 +126,32 @@ func NewServer(db model.DataStore, address string) (*Server, error) {
 func (s *Server) mainHandler4(peer *net.UDPAddr, req *dhcpv4.DHCPv4, oob *ipv4.ControlMessage) {
 	if req.OpCode != dhcpv4.OpcodeBootRequest {
 		log.Debugf("Ignoring not a BootRequest")
+		observePacket(req, resultIgnored)
 		return
 	}
 
 	host, err := s.DB.LoadHostFromMAC(req.ClientHWAddr.String())
+	if errors.Is(err, model.ErrNotFound) {
+		host, err = s.hostFromUUID(req)
+	}
+	if errors.Is(err, model.ErrNotFound) {
+		host, err = s.hostFromRelay(req)
+	}
 	if err != nil {
 		if errors.Is(err, model.ErrNotFound) {
-			log.Debugf("Ignoring unknown client mac address: %s", req.ClientHWAddr)
+			s.enroll(req)
+			s.dynamicHandler4(req, oob)
 		} else {
 			log.Errorf("Failed to find host from database: %s", err)
+			observePacket(req, resultError)
 		}
 		return
 	}
 
-	serverIP := s.ServerAddress
-	// Use the IP address of the interface the request came in on for the
-	// ServerIP if available.
-	if intfIP, ok := s.InterfaceIPMap[oob.IfIndex]; ok {
-		serverIP = intfIP
-	}
+	s.activeMAC(host, req)
+	s.learnUUID(host, req)
+
+	serverIP := s.interfaceIP(oob)
 
 	resp, err := dhcpv4.NewReplyFromRequest(req,
 		dhcpv4.WithServerIP(serverIP),
@@ -130,6 +161,7 @@ func (s *Server) mainHandler4(peer *net.UDPAddr, req *dhcpv4.DHCPv4, oob *ipv4.C
 	)
 	if err != nil {
 		log.Printf("DHCP failed to build reply: %v", err)
+		observePacket(req, resultError)
 		return
 	}
 
@@ -145,10 +177,11 @@ func (s *Server) mainHandler4(peer *net.UDPAddr, req *dhcpv4.DHCPv4, oob *ipv4.C
 		err := s.bootingHandler4(host, serverIP, req, resp)
 		if err != nil && s.ProxyOnly {
 			log.WithFields(logrus.Fields{
-				"mac":     req.ClientHWAddr.String(),
-				"host_id": host.ID.String(),
-				"err":     err,
+				logger.FieldMAC:    req.ClientHWAddr.String(),
+				logger.FieldHostID: host.ID.String(),
+				"err":              err,
 			}).Error("Failed to add boot options to DHCP request")
+			observePacket(req, resultError)
 			return
 		}
 
@@ -156,26 +189,55 @@ func (s *Server) mainHandler4(peer *net.UDPAddr, req *dhcpv4.DHCPv4, oob *ipv4.C
 			err := s.staticHandler4(host, serverIP, req, resp)
 			if err != nil {
 				log.Errorf("Failed to add client ip to DHCP DISCOVER: %s", err)
+				observePacket(req, resultError)
 				return
 			}
 		}
 	case dhcpv4.MessageTypeRequest, dhcpv4.MessageTypeInform:
 		if s.ProxyOnly {
+			observePacket(req, resultIgnored)
 			return
 		}
 
 		err := s.staticAckHandler4(host, serverIP, req, resp)
 		if err != nil {
 			log.Errorf("Failed to ack DHCP REQUEST: %s", err)
+			observePacket(req, resultError)
 			return
 		}
 	default:
 		log.Warnf("DHCP Unhandled message type: %v", mt)
 		log.Debugf(resp.Summary())
+		observePacket(req, resultIgnored)
+		return
+	}
+
+	if err := s.send(req, resp, oob); err != nil {
+		log.Printf("DHCP write failed: %v", err)
+		observePacket(req, resultError)
 		return
 	}
 
-	peer = &net.UDPAddr{IP: net.IPv4bcast, Port: dhcpv4.ClientPort}
+	observeReply(req, resp)
+	publishEvent(host, req, resp)
+}
+
+// interfaceIP returns the IP address of the interface the request came in on
+// or the ServerAddress if unknown
+func (s *Server) interfaceIP(oob *ipv4.ControlMessage) net.IP {
+	if oob != nil {
+		if intfIP, ok := s.InterfaceIPMap[oob.IfIndex]; ok {
+			return intfIP
+		}
+	}
+
+	return s.ServerAddress
+}
+
+// send writes the reply to the client or the relay agent the request came
+// from
+func (s *Server) send(req, resp *dhcpv4.DHCPv4, oob *ipv4.ControlMessage) error {
+	peer := &net.UDPAddr{IP: net.IPv4bcast, Port: dhcpv4.ClientPort}
 	if !req.GatewayIPAddr.IsUnspecified() {
 		peer = &net.UDPAddr{IP: req.GatewayIPAddr, Port: dhcpv4.ServerPort}
 		resp.SetBroadcast()
@@ -198,8 +260,42 @@ func (s *Server) mainHandler4(peer *net.UDPAddr, req *dhcpv4.DHCPv4, oob *ipv4.C
 	log.Debugf(resp.Summary())
 
 	if _, err := s.conn.WriteTo(resp.ToBytes(), woob, peer); err != nil {
-		log.Printf("DHCP write to %v failed: %v", peer, err)
+		return fmt.Errorf("write to %v: %w", peer, err)
 	}
+
+	return nil
+}
+
+// publishEvent publishes the DHCP response sent to a host on the event bus.
+// Host is nil for clients served from a dynamic pool.
+func publishEvent(host *model.Host, req, resp *dhcpv4.DHCPv4) {
+	var etype string
+	switch resp.MessageType() {
+	case dhcpv4.MessageTypeOffer:
+		etype = events.TypeDHCPOffer
+	case dhcpv4.MessageTypeAck:
+		etype = events.TypeDHCPAck
+	case dhcpv4.MessageTypeNak:
+		etype = events.TypeDHCPNak
+	default:
+		return
+	}
+
+	e := &events.Event{
+		Service: "dhcp",
+		Type:    etype,
+		MAC:     req.ClientHWAddr.String(),
+		Message: resp.Message(),
+	}
+	if host != nil {
+		e.Host = host.Name
+		e.Tags = host.Tags
+	}
+	if resp.YourIPAddr != nil && !resp.YourIPAddr.IsUnspecified() {
+		e.IP = resp.YourIPAddr.String()
+	}
+
+	events.Publish(e)
 }
 
 func (s *Server) Serve() error {
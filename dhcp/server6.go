@@ -0,0 +1,406 @@
+// Copyright 2019 Grendel Authors. All rights reserved.
+//
+// This file is part of Grendel.
+//
+// Grendel is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Grendel is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with Grendel. If not, see <https://www.gnu.org/licenses/>.
+package dhcp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/insomniacslk/dhcp/dhcpv6"
+	"github.com/insomniacslk/dhcp/dhcpv6/server6"
+	"github.com/insomniacslk/dhcp/iana"
+	"github.com/insomniacslk/dhcp/interfaces"
+	"github.com/sirupsen/logrus"
+	"github.com/ubccr/grendel/events"
+	"github.com/ubccr/grendel/logger"
+	"github.com/ubccr/grendel/model"
+	"github.com/ubccr/grendel/util"
+	"golang.org/x/net/ipv6"
+)
+
+type Server6 struct {
+	ListenAddress  net.IP
+	ServerAddress  net.IP
+	InterfaceIPMap map[int]net.IP
+	Port           int
+	DB             model.DataStore
+	LeaseTime      time.Duration
+	ServerID       dhcpv6.DUID
+	conn           *ipv6.PacketConn
+	quit           chan interface{}
+	wg             sync.WaitGroup
+}
+
+func NewServer6(db model.DataStore, address string) (*Server6, error) {
+	s := &Server6{DB: db, quit: make(chan interface{})}
+
+	if address == "" {
+		address = net.JoinHostPort(net.IPv6unspecified.String(), strconv.Itoa(dhcpv6.DefaultServerPort))
+	}
+
+	ipStr, portStr, err := net.SplitHostPort(address)
+	if err != nil {
+		return nil, err
+	}
+
+	port, err := strconv.Atoi(portStr)
+	if err != nil {
+		return nil, err
+	}
+
+	s.Port = port
+
+	ip := net.ParseIP(ipStr)
+	if ip == nil || ip.To4() != nil {
+		return nil, fmt.Errorf("Invalid IPv6 address: %s", ipStr)
+	}
+
+	s.ListenAddress = ip
+
+	if !ip.IsUnspecified() {
+		s.ServerAddress = ip
+	} else {
+		ipaddr, err := util.GetFirstExternalIPv6FromInterfaces()
+		if err != nil {
+			return nil, err
+		}
+
+		log.Infof("Using default IPv6 ServerAddress: %s", ipaddr)
+		s.ServerAddress = ipaddr
+
+		intfMap, err := util.GetInterfaceIPv6Map()
+		if err != nil {
+			return nil, err
+		}
+
+		s.InterfaceIPMap = intfMap
+	}
+
+	duid, err := serverDUID(s.ServerAddress)
+	if err != nil {
+		return nil, err
+	}
+
+	s.ServerID = duid
+
+	return s, nil
+}
+
+// serverDUID returns a DUID-LL built from the hardware address of the
+// interface with the given ip address
+func serverDUID(ip net.IP) (dhcpv6.DUID, error) {
+	name, _, err := util.GetInterfaceFromIP(ip)
+	if err != nil {
+		return nil, err
+	}
+
+	intf, err := net.InterfaceByName(name)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(intf.HardwareAddr) == 0 {
+		return nil, fmt.Errorf("Interface %s has no hardware address for the DHCPv6 server DUID", name)
+	}
+
+	return &dhcpv6.DUIDLL{HWType: iana.HWTypeEthernet, LinkLayerAddr: intf.HardwareAddr}, nil
+}
+
+func (s *Server6) mainHandler6(peer *net.UDPAddr, req dhcpv6.DHCPv6, oob *ipv6.ControlMessage) {
+	msg, err := req.GetInnerMessage()
+	if err != nil {
+		log.Errorf("Failed to decapsulate DHCPv6 request: %s", err)
+		return
+	}
+
+	mac, err := dhcpv6.ExtractMAC(req)
+	if err != nil {
+		log.Debugf("Ignoring DHCPv6 request without client mac address: %s", err)
+		observePacket6(msg, resultIgnored)
+		return
+	}
+
+	host, err := s.DB.LoadHostFromMAC(mac.String())
+	if err != nil {
+		if errors.Is(err, model.ErrNotFound) {
+			log.Debugf("Ignoring unknown client mac address: %s", mac)
+			observePacket6(msg, resultIgnored)
+		} else {
+			log.Errorf("Failed to find host from database: %s", err)
+			observePacket6(msg, resultError)
+		}
+		return
+	}
+
+	// Messages carrying a Server Identifier are meant for a specific server
+	if sid := msg.Options.ServerID(); sid != nil && !sid.Equal(s.ServerID) {
+		log.Debugf("Ignoring DHCPv6 %s for another server: %s", msg.Type(), sid)
+		observePacket6(msg, resultIgnored)
+		return
+	}
+
+	serverIP := s.ServerAddress
+	// Use the IP address of the interface the request came in on for the
+	// ServerIP if available.
+	if oob != nil {
+		if intfIP, ok := s.InterfaceIPMap[oob.IfIndex]; ok {
+			serverIP = intfIP
+		}
+	}
+
+	var resp *dhcpv6.Message
+	switch mt := msg.Type(); mt {
+	case dhcpv6.MessageTypeSolicit:
+		if msg.GetOneOption(dhcpv6.OptionRapidCommit) != nil {
+			resp, err = dhcpv6.NewReplyFromMessage(msg)
+		} else {
+			resp, err = dhcpv6.NewAdvertiseFromSolicit(msg)
+		}
+	case dhcpv6.MessageTypeRequest, dhcpv6.MessageTypeConfirm, dhcpv6.MessageTypeRenew,
+		dhcpv6.MessageTypeRebind, dhcpv6.MessageTypeRelease, dhcpv6.MessageTypeInformationRequest:
+		resp, err = dhcpv6.NewReplyFromMessage(msg)
+	default:
+		log.Warnf("DHCPv6 Unhandled message type: %v", mt)
+		observePacket6(msg, resultIgnored)
+		return
+	}
+	if err != nil {
+		log.Printf("DHCPv6 failed to build reply: %v", err)
+		observePacket6(msg, resultError)
+		return
+	}
+
+	resp.AddOption(dhcpv6.OptServerID(s.ServerID))
+
+	switch msg.Type() {
+	case dhcpv6.MessageTypeRelease:
+		// Addresses are static so there is nothing to release
+		resp.AddOption(&dhcpv6.OptStatusCode{StatusCode: iana.StatusSuccess})
+	case dhcpv6.MessageTypeConfirm:
+		s.confirmHandler6(host, mac, msg, resp)
+	case dhcpv6.MessageTypeInformationRequest:
+		s.staticOptions6(host, mac, msg, resp)
+	default:
+		err := s.bootingHandler6(host, mac, serverIP, msg, resp)
+		if err != nil {
+			log.WithFields(logrus.Fields{
+				logger.FieldMAC:    mac.String(),
+				logger.FieldHostID: host.ID.String(),
+				"err":              err,
+			}).Error("Failed to add boot options to DHCPv6 request")
+		}
+
+		s.staticHandler6(host, mac, msg, resp)
+	}
+
+	var reply dhcpv6.DHCPv6 = resp
+	if req.IsRelay() {
+		reply, err = dhcpv6.NewRelayReplFromRelayForw(req.(*dhcpv6.RelayMessage), resp)
+		if err != nil {
+			log.Errorf("Failed to build DHCPv6 relay reply: %s", err)
+			observePacket6(msg, resultError)
+			return
+		}
+	}
+
+	var woob *ipv6.ControlMessage
+	if peer.IP.IsLinkLocalUnicast() {
+		switch {
+		case oob != nil && oob.IfIndex != 0:
+			woob = &ipv6.ControlMessage{IfIndex: oob.IfIndex}
+		default:
+			log.Errorf("mainHandler6: Did not receive interface information")
+		}
+	}
+
+	log.Debugf("Sending DHCPv6 packet response")
+	log.Debugf(reply.Summary())
+
+	if _, err := s.conn.WriteTo(reply.ToBytes(), woob, peer); err != nil {
+		log.Printf("DHCPv6 write to %v failed: %v", peer, err)
+		observePacket6(msg, resultError)
+		return
+	}
+
+	observeReply6(msg, resp)
+	publishEvent6(host, mac, resp)
+}
+
+// publishEvent6 publishes the DHCPv6 response sent to a host on the event bus
+func publishEvent6(host *model.Host, mac net.HardwareAddr, resp *dhcpv6.Message) {
+	var etype string
+	switch resp.Type() {
+	case dhcpv6.MessageTypeAdvertise:
+		etype = events.TypeDHCPOffer
+	case dhcpv6.MessageTypeReply:
+		etype = events.TypeDHCPAck
+	default:
+		return
+	}
+
+	e := &events.Event{
+		Service: "dhcp",
+		Type:    etype,
+		Host:    host.Name,
+		MAC:     mac.String(),
+		Tags:    host.Tags,
+		Message: fmt.Sprintf("DHCPv6 %s", resp.Type()),
+	}
+
+	for _, ia := range resp.Options.IANA() {
+		for _, addr := range ia.Options.Addresses() {
+			if addr.ValidLifetime > 0 {
+				e.IP = addr.IPv6Addr.String()
+			}
+		}
+	}
+
+	events.Publish(e)
+}
+
+func (s *Server6) Serve() error {
+	listener := &net.UDPAddr{
+		IP:   s.ListenAddress,
+		Port: s.Port,
+	}
+
+	intf := ""
+	if !s.ListenAddress.IsUnspecified() {
+		iface, _, err := util.GetInterfaceFromIP(s.ListenAddress)
+		if err != nil {
+			return err
+		}
+		intf = iface
+		listener = &net.UDPAddr{Port: s.Port}
+		log.Printf("Binding to interface: %s", intf)
+	}
+
+	udpConn, err := server6.NewIPv6UDPConn(intf, listener)
+	if err != nil {
+		return err
+	}
+
+	s.conn = ipv6.NewPacketConn(udpConn)
+	err = s.conn.SetControlMessage(ipv6.FlagInterface, true)
+	if err != nil {
+		return err
+	}
+
+	if err := s.joinMulticast(intf); err != nil {
+		return err
+	}
+
+	log.Infof("Server listening on: %s", net.JoinHostPort(s.ListenAddress.String(), strconv.Itoa(s.Port)))
+	return s.serve()
+}
+
+// joinMulticast joins the All_DHCP_Relay_Agents_and_Servers group on the
+// given interface or all multicast capable interfaces if intf is empty
+func (s *Server6) joinMulticast(intf string) error {
+	var intfs []net.Interface
+	if intf != "" {
+		iface, err := net.InterfaceByName(intf)
+		if err != nil {
+			return err
+		}
+		intfs = append(intfs, *iface)
+	} else {
+		var err error
+		intfs, err = interfaces.GetNonLoopbackInterfaces()
+		if err != nil {
+			return err
+		}
+	}
+
+	group := &net.UDPAddr{IP: dhcpv6.AllDHCPRelayAgentsAndServers}
+	for i := range intfs {
+		if intfs[i].Flags&net.FlagMulticast == 0 {
+			continue
+		}
+
+		if err := s.conn.JoinGroup(&intfs[i], group); err != nil {
+			log.Warnf("Failed to join %s on interface %s: %s", group.IP, intfs[i].Name, err)
+		}
+	}
+
+	return nil
+}
+
+func (s *Server6) serve() error {
+	var buf [1500]byte
+	for {
+		n, oob, peer, err := s.conn.ReadFrom(buf[:])
+		if err != nil {
+			select {
+			case <-s.quit:
+				return nil
+			default:
+				log.Errorf("Failed to read packet: %s", err)
+			}
+		} else {
+			log.Debugf("Handling request from %v", peer)
+
+			m, err := dhcpv6.FromBytes(buf[:n])
+			if err != nil {
+				log.Printf("Error parsing DHCPv6 request: %v", err)
+				continue
+			}
+
+			upeer, ok := peer.(*net.UDPAddr)
+			if !ok {
+				log.Printf("Not a UDP connection? Peer is %s", peer)
+				continue
+			}
+
+			s.wg.Add(1)
+			go func() {
+				s.mainHandler6(upeer, m, oob)
+				s.wg.Done()
+			}()
+		}
+	}
+}
+
+func (s *Server6) Shutdown(ctx context.Context) error {
+	close(s.quit)
+	if s.conn == nil {
+		return nil
+	}
+
+	defer s.conn.Close()
+	s.conn.SetReadDeadline(CancelTime)
+
+	done := make(chan struct{})
+	go func() {
+		s.wg.Wait()
+		close(done)
+	}()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-done:
+			return nil
+		}
+	}
+}
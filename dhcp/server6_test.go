@@ -0,0 +1,199 @@
+// Copyright 2019 Grendel Authors. All rights reserved.
+//
+// This file is part of Grendel.
+//
+// Grendel is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Grendel is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with Grendel. If not, see <https://www.gnu.org/licenses/>.
+package dhcp
+
+import (
+	"context"
+	"net"
+	"net/netip"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/insomniacslk/dhcp/dhcpv6"
+	"github.com/insomniacslk/dhcp/iana"
+	"github.com/stretchr/testify/assert"
+	"github.com/ubccr/grendel/internal/tests"
+	"github.com/ubccr/grendel/model"
+	"golang.org/x/net/ipv6"
+)
+
+func newTestServer6(t *testing.T, hosts model.HostList) (*Server6, *net.UDPConn) {
+	db, err := model.NewDataStore(":memory:")
+	if err != nil {
+		assert.Fail(t, err.Error())
+	}
+	t.Cleanup(func() { db.Close() })
+
+	err = db.StoreHosts(hosts)
+	if err != nil {
+		assert.Fail(t, err.Error())
+	}
+
+	udpConn, err := net.ListenUDP("udp6", &net.UDPAddr{IP: net.IPv6loopback})
+	if err != nil {
+		t.Skipf("IPv6 loopback not available: %s", err)
+	}
+
+	s := &Server6{
+		ListenAddress: net.IPv6loopback,
+		ServerAddress: net.IPv6loopback,
+		DB:            db,
+		LeaseTime:     time.Hour,
+		ServerID:      &dhcpv6.DUIDLL{HWType: iana.HWTypeEthernet, LinkLayerAddr: net.HardwareAddr{0x02, 0, 0, 0, 0, 0x01}},
+		conn:          ipv6.NewPacketConn(udpConn),
+		quit:          make(chan interface{}),
+	}
+	s.conn.SetControlMessage(ipv6.FlagInterface, true)
+
+	go s.serve()
+	t.Cleanup(func() { s.Shutdown(context.Background()) })
+
+	client, err := net.DialUDP("udp6", nil, udpConn.LocalAddr().(*net.UDPAddr))
+	if err != nil {
+		assert.Fail(t, err.Error())
+	}
+	t.Cleanup(func() { client.Close() })
+
+	return s, client
+}
+
+func exchange6(t *testing.T, client *net.UDPConn, req *dhcpv6.Message) *dhcpv6.Message {
+	_, err := client.Write(req.ToBytes())
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+
+	client.SetReadDeadline(time.Now().Add(2 * time.Second))
+	buf := make([]byte, 1500)
+	n, err := client.Read(buf)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+
+	resp, err := dhcpv6.MessageFromBytes(buf[:n])
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+
+	return resp
+}
+
+func newTestHost6() *model.Host {
+	host := tests.HostFactory.MustCreate().(*model.Host)
+	host.Provision = true
+	host.Firmware = 0
+	host.Interfaces[0].Addrs = []netip.Prefix{netip.MustParsePrefix("fd00:10::10/64")}
+	host.Interfaces[0].FQDN = "tux-01.compute.local"
+	return host
+}
+
+func TestServer6Solicit(t *testing.T) {
+	assert := assert.New(t)
+
+	host := newTestHost6()
+	s, client := newTestServer6(t, model.HostList{host})
+
+	sol, err := dhcpv6.NewSolicit(host.Interfaces[0].MAC, dhcpv6.WithArchType(iana.EFI_X86_64))
+	assert.NoError(err)
+
+	adv := exchange6(t, client, sol)
+	assert.Equal(dhcpv6.MessageTypeAdvertise, adv.Type())
+	assert.Equal(sol.TransactionID, adv.TransactionID)
+	assert.True(s.ServerID.Equal(adv.Options.ServerID()))
+
+	if ia := adv.Options.OneIANA(); assert.NotNil(ia) {
+		if addr := ia.Options.OneAddress(); assert.NotNil(addr) {
+			assert.Equal("fd00:10::10", addr.IPv6Addr.String())
+			assert.Equal(time.Hour, addr.ValidLifetime)
+		}
+	}
+
+	bootURL := adv.Options.BootFileURL()
+	assert.True(strings.HasPrefix(bootURL, "tftp://[::1]/"), bootURL)
+	build, err := model.ParseFirmwareToken(strings.TrimPrefix(bootURL, "tftp://[::1]/"))
+	assert.NoError(err)
+	assert.Equal("ipxe-x86_64.efi", build.String())
+
+	// A request for another server is ignored
+	req, err := dhcpv6.NewRequestFromAdvertise(adv, dhcpv6.WithServerID(&dhcpv6.DUIDLL{HWType: iana.HWTypeEthernet, LinkLayerAddr: net.HardwareAddr{0x02, 0, 0, 0, 0, 0x02}}))
+	assert.NoError(err)
+	_, err = client.Write(req.ToBytes())
+	assert.NoError(err)
+
+	req, err = dhcpv6.NewRequestFromAdvertise(adv)
+	assert.NoError(err)
+	reply := exchange6(t, client, req)
+	assert.Equal(dhcpv6.MessageTypeReply, reply.Type())
+	assert.Equal(req.TransactionID, reply.TransactionID)
+	if ia := reply.Options.OneIANA(); assert.NotNil(ia) {
+		if addr := ia.Options.OneAddress(); assert.NotNil(addr) {
+			assert.Equal("fd00:10::10", addr.IPv6Addr.String())
+		}
+	}
+}
+
+func TestServer6HTTPBoot(t *testing.T) {
+	assert := assert.New(t)
+
+	host := newTestHost6()
+	_, client := newTestServer6(t, model.HostList{host})
+
+	sol, err := dhcpv6.NewSolicit(host.Interfaces[0].MAC,
+		dhcpv6.WithArchType(iana.EFI_X86_64_HTTP),
+		dhcpv6.WithOption(&dhcpv6.OptVendorClass{
+			EnterpriseNumber: 343,
+			Data:             [][]byte{[]byte("HTTPClient:Arch:00016:UNDI:003016")},
+		}),
+	)
+	assert.NoError(err)
+
+	adv := exchange6(t, client, sol)
+	assert.Equal(dhcpv6.MessageTypeAdvertise, adv.Type())
+
+	bootURL := adv.Options.BootFileURL()
+	assert.Contains(bootURL, "://[::1]")
+	assert.Contains(bootURL, "/firmware/")
+	assert.True(strings.HasSuffix(bootURL, "/ipxe-x86_64.efi"), bootURL)
+
+	if vcs := adv.Options.VendorClasses(); assert.Len(vcs, 1) {
+		assert.Equal(uint32(343), vcs[0].EnterpriseNumber)
+		assert.Equal([][]byte{[]byte("HTTPClient")}, vcs[0].Data)
+	}
+}
+
+func TestServer6NoAddress(t *testing.T) {
+	assert := assert.New(t)
+
+	host := newTestHost6()
+	host.Provision = false
+	host.Interfaces[0].Addrs = nil
+	_, client := newTestServer6(t, model.HostList{host})
+
+	sol, err := dhcpv6.NewSolicit(host.Interfaces[0].MAC)
+	assert.NoError(err)
+
+	adv := exchange6(t, client, sol)
+	assert.Equal(dhcpv6.MessageTypeAdvertise, adv.Type())
+	assert.Equal("", adv.Options.BootFileURL())
+	if ia := adv.Options.OneIANA(); assert.NotNil(ia) {
+		assert.Nil(ia.Options.OneAddress())
+		if status := ia.Options.Status(); assert.NotNil(status) {
+			assert.Equal(iana.StatusNoAddrsAvail, status.StatusCode)
+		}
+	}
+}
@@ -24,6 +24,7 @@ import (
 	"github.com/insomniacslk/dhcp/dhcpv4"
 	"github.com/insomniacslk/dhcp/rfc1035label"
 	"github.com/sirupsen/logrus"
+	"github.com/ubccr/grendel/logger"
 	"github.com/ubccr/grendel/model"
 )
 
@@ -38,8 +39,8 @@ func (s *Server) setZTD(host *model.Host, nic *model.NetInterface, serverIP net.
 		// See: https://i.dell.com/sites/doccontent/shared-content/Documents/Bare_Metal_Provisioning.pdf
 
 		log.WithFields(logrus.Fields{
-			"ip":   nic.AddrString(),
-			"name": host.Name,
+			logger.FieldIP:   nic.AddrString(),
+			logger.FieldHost: host.Name,
 		}).Info("Host tagged with Dell BMP. Setting FTOS image URL and config dhcp options")
 
 		token, _ := model.NewBootToken(host.ID.String(), nic.MAC.String())
@@ -64,8 +65,8 @@ func (s *Server) setZTD(host *model.Host, nic *model.NetInterface, serverIP net.
 		// See: https://www.dell.com/support/manuals/en-in/networking-mx7116n/smartfabric-os-user-guide-10-5-0/dell-emc-smartfabric-os10-zero-touch-deployment?guid=guid-95ca07a2-2bcb-4ea2-84ef-ef9d11a4fa0e&lang=en-us
 
 		log.WithFields(logrus.Fields{
-			"ip":   nic.AddrString(),
-			"name": host.Name,
+			logger.FieldIP:   nic.AddrString(),
+			logger.FieldHost: host.Name,
 		}).Info("Host tagged with Dell ZTD. Setting ZTD provision URL dhcp option")
 
 		token, _ := model.NewBootToken(host.ID.String(), nic.MAC.String())
@@ -85,15 +86,21 @@ func (s *Server) staticHandler4(host *model.Host, serverIP net.IP, req, resp *dh
 	}
 
 	log.WithFields(logrus.Fields{
-		"ip":           nic.AddrString(),
-		"mac":          req.ClientHWAddr.String(),
-		"name":         host.Name,
-		"dhcp_message": req.MessageType().String(),
+		logger.FieldIP:   nic.AddrString(),
+		logger.FieldMAC:  req.ClientHWAddr.String(),
+		logger.FieldHost: host.Name,
+		"dhcp_message":   req.MessageType().String(),
 	}).Info("Found host")
 	log.Debugf(req.Summary())
 
-	resp.YourIPAddr = nic.ToStdAddr()
-	resp.UpdateOption(dhcpv4.OptSubnetMask(nic.Netmask()))
+	ip := nic.IPv4()
+	if !ip.IsValid() {
+		log.Warnf("no IPv4 address for host: %s", req.ClientHWAddr)
+		return nil
+	}
+
+	resp.YourIPAddr = net.IP(ip.Addr().AsSlice())
+	resp.UpdateOption(dhcpv4.OptSubnetMask(net.CIDRMask(ip.Bits(), 32)))
 	resp.UpdateOption(dhcpv4.OptIPAddressLeaseTime(s.LeaseTime))
 
 	if req.IsOptionRequested(dhcpv4.OptionInterfaceMTU) {
@@ -146,10 +153,11 @@ func (s *Server) staticAckHandler4(host *model.Host, serverIP net.IP, req, resp
 	}
 
 	nic := host.Interface(req.ClientHWAddr)
-	if !nic.ToStdAddr().Equal(requestedIP) {
+	ip := nic.IPv4()
+	if !net.IP(ip.Addr().AsSlice()).Equal(requestedIP) {
 		// Need to return NACK here. The client is asking for a different IP
 		// address than what's configured in Grendel.
-		msg := fmt.Sprintf("Requested IP address %v does not match address configured in Grendel: %v", requestedIP, nic.AddrString())
+		msg := fmt.Sprintf("Requested IP address %v does not match address configured in Grendel: %v", requestedIP, ip.Addr())
 		log.Info(msg)
 		resp.UpdateOption(dhcpv4.OptMessage(msg))
 		resp.UpdateOption(dhcpv4.OptMessageType(dhcpv4.MessageTypeNak))
@@ -0,0 +1,147 @@
+// Copyright 2019 Grendel Authors. All rights reserved.
+//
+// This file is part of Grendel.
+//
+// Grendel is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Grendel is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with Grendel. If not, see <https://www.gnu.org/licenses/>.
+package dhcp
+
+import (
+	"net"
+
+	"github.com/insomniacslk/dhcp/dhcpv6"
+	"github.com/insomniacslk/dhcp/iana"
+	"github.com/insomniacslk/dhcp/rfc1035label"
+	"github.com/sirupsen/logrus"
+	"github.com/ubccr/grendel/logger"
+	"github.com/ubccr/grendel/model"
+)
+
+// staticHandler6 assigns the IPv6 address configured on the host interface to
+// each IA_NA in the request
+func (s *Server6) staticHandler6(host *model.Host, mac net.HardwareAddr, msg, resp *dhcpv6.Message) {
+	nic := host.Interface(mac)
+	if nic == nil {
+		log.Warnf("invalid mac address for host: %s", mac)
+		return
+	}
+
+	ip := nic.IPv6()
+
+	log.WithFields(logrus.Fields{
+		logger.FieldIP:   ip.String(),
+		logger.FieldMAC:  mac.String(),
+		logger.FieldHost: host.Name,
+		"dhcp_message":   msg.Type().String(),
+	}).Info("Found host")
+	log.Debugf(msg.Summary())
+
+	for _, reqIA := range msg.Options.IANA() {
+		ia := &dhcpv6.OptIANA{
+			IaId: reqIA.IaId,
+			T1:   s.LeaseTime / 2,
+			T2:   s.LeaseTime * 4 / 5,
+		}
+
+		if !ip.IsValid() {
+			ia.Options.Add(&dhcpv6.OptStatusCode{
+				StatusCode:    iana.StatusNoAddrsAvail,
+				StatusMessage: "no IPv6 address configured for host",
+			})
+			resp.AddOption(ia)
+			continue
+		}
+
+		addr := net.IP(ip.Addr().AsSlice())
+		ia.Options.Add(&dhcpv6.OptIAAddress{
+			IPv6Addr:          addr,
+			PreferredLifetime: s.LeaseTime,
+			ValidLifetime:     s.LeaseTime,
+		})
+
+		// Any other address the client has should no longer be used so send
+		// it back with zero lifetimes
+		for _, old := range reqIA.Options.Addresses() {
+			if !old.IPv6Addr.Equal(addr) {
+				ia.Options.Add(&dhcpv6.OptIAAddress{IPv6Addr: old.IPv6Addr})
+			}
+		}
+
+		resp.AddOption(ia)
+	}
+
+	if !ip.IsValid() {
+		log.Warnf("no IPv6 address for host: %s", mac)
+	}
+
+	s.staticOptions6(host, mac, msg, resp)
+}
+
+// staticOptions6 sets the DNS and domain options for the host interface
+func (s *Server6) staticOptions6(host *model.Host, mac net.HardwareAddr, msg, resp *dhcpv6.Message) {
+	nic := host.Interface(mac)
+	if nic == nil {
+		return
+	}
+
+	if msg.IsOptionRequested(dhcpv6.OptionDNSRecursiveNameServer) {
+		dnsServers := make([]net.IP, 0)
+		for _, dip := range nic.DNS() {
+			if dip.To4() == nil {
+				dnsServers = append(dnsServers, dip)
+			}
+		}
+
+		if len(dnsServers) > 0 {
+			resp.UpdateOption(dhcpv6.OptDNS(dnsServers...))
+		}
+	}
+
+	domainSearch := nic.DomainSearch()
+	if len(domainSearch) > 0 && msg.IsOptionRequested(dhcpv6.OptionDomainSearchList) {
+		resp.UpdateOption(dhcpv6.OptDomainSearchList(&rfc1035label.Labels{
+			Labels: domainSearch,
+		}))
+	}
+
+	if nic.FQDN != "" {
+		resp.UpdateOption(&dhcpv6.OptFQDN{
+			DomainName: &rfc1035label.Labels{Labels: []string{nic.FQDN}},
+		})
+	}
+}
+
+// confirmHandler6 replies to a CONFIRM with whether the addresses the client
+// has are still the ones configured for the host
+func (s *Server6) confirmHandler6(host *model.Host, mac net.HardwareAddr, msg, resp *dhcpv6.Message) {
+	status := &dhcpv6.OptStatusCode{StatusCode: iana.StatusSuccess}
+
+	var ip net.IP
+	if nic := host.Interface(mac); nic != nil && nic.IPv6().IsValid() {
+		ip = net.IP(nic.IPv6().Addr().AsSlice())
+	}
+
+	for _, reqIA := range msg.Options.IANA() {
+		for _, addr := range reqIA.Options.Addresses() {
+			if ip == nil || !addr.IPv6Addr.Equal(ip) {
+				log.Infof("Confirmed IPv6 address %s does not match address configured in Grendel: %s", addr.IPv6Addr, ip)
+				status = &dhcpv6.OptStatusCode{
+					StatusCode:    iana.StatusNotOnLink,
+					StatusMessage: "address not configured for host",
+				}
+			}
+		}
+	}
+
+	resp.AddOption(status)
+}
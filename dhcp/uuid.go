@@ -0,0 +1,180 @@
+// Copyright 2019 Grendel Authors. All rights reserved.
+//
+// This file is part of Grendel.
+//
+// Grendel is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Grendel is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with Grendel. If not, see <https://www.gnu.org/licenses/>.
+
+package dhcp
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/insomniacslk/dhcp/dhcpv4"
+	"github.com/sirupsen/logrus"
+	"github.com/ubccr/grendel/logger"
+	"github.com/ubccr/grendel/model"
+)
+
+// uuidCandidate is an unknown MAC which sent the system UUID of a host along
+// with the transaction ids of the discovers it sent
+type uuidCandidate struct {
+	mac  string
+	xids map[dhcpv4.TransactionID]struct{}
+}
+
+// hostFromUUID returns the host with the system UUID sent by the client in
+// option 97. The MAC of the client is unknown, for example after a NIC swap,
+// so it's recorded on the boot interface of the host once the client booted
+// UUIDRelearnBoots times without the registered MAC showing up in between.
+// Until then the host isn't matched, so a server trying to PXE boot from its
+// other NICs never takes over the MAC of the boot interface.
+func (s *Server) hostFromUUID(req *dhcpv4.DHCPv4) (*model.Host, error) {
+	uuid := clientUUID(req)
+	if uuid == "" {
+		return nil, fmt.Errorf("no client machine identifier:  %w", model.ErrNotFound)
+	}
+
+	host, err := s.DB.LoadHostFromUUID(uuid)
+	if errors.Is(err, model.ErrDuplicateEntry) {
+		log.WithFields(logrus.Fields{
+			"uuid":          uuid,
+			logger.FieldMAC: req.ClientHWAddr.String(),
+		}).Warnf("Not matching client on a uuid shared by several hosts: %s", err)
+		return nil, fmt.Errorf("ambiguous uuid %s:  %w", uuid, model.ErrNotFound)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	nic := host.BootInterface()
+	if nic == nil {
+		return nil, fmt.Errorf("no boot interface for host with uuid %s:  %w", uuid, model.ErrNotFound)
+	}
+
+	if !s.relearnMAC(uuid, req) {
+		log.WithFields(logrus.Fields{
+			logger.FieldHost: host.Name,
+			"uuid":           uuid,
+			logger.FieldMAC:  req.ClientHWAddr.String(),
+		}).Debug("Not learning MAC address from client UUID while the registered MAC is active")
+		return nil, fmt.Errorf("mac %s not learned for host with uuid %s:  %w", req.ClientHWAddr, uuid, model.ErrNotFound)
+	}
+
+	log.WithFields(logrus.Fields{
+		logger.FieldHost: host.Name,
+		"uuid":           uuid,
+		"old_mac":        nic.MAC.String(),
+		logger.FieldMAC:  req.ClientHWAddr.String(),
+	}).Info("Learned new MAC address from client UUID")
+
+	nic.MAC = req.ClientHWAddr
+	if err := model.NewAuditedStore(s.DB, "dhcp", "").StoreHost(host); err != nil {
+		return nil, err
+	}
+
+	return host, nil
+}
+
+// relearnMAC returns true once the unknown MAC sending the UUID has sent
+// discovers in UUIDRelearnBoots different transactions
+func (s *Server) relearnMAC(uuid string, req *dhcpv4.DHCPv4) bool {
+	if s.UUIDRelearnBoots <= 0 {
+		return false
+	}
+
+	s.uuidMu.Lock()
+	defer s.uuidMu.Unlock()
+
+	if s.uuidCandidates == nil {
+		s.uuidCandidates = make(map[string]*uuidCandidate)
+	}
+
+	mac := req.ClientHWAddr.String()
+	c, ok := s.uuidCandidates[uuid]
+	if !ok || c.mac != mac {
+		c = &uuidCandidate{mac: mac, xids: make(map[dhcpv4.TransactionID]struct{})}
+		s.uuidCandidates[uuid] = c
+	}
+
+	if req.MessageType() == dhcpv4.MessageTypeDiscover {
+		c.xids[req.TransactionID] = struct{}{}
+	}
+
+	if len(c.xids) < s.UUIDRelearnBoots {
+		return false
+	}
+
+	delete(s.uuidCandidates, uuid)
+	return true
+}
+
+// activeMAC resets the MAC relearning of a host whose boot interface sent a
+// request, which means its MAC is still in use
+func (s *Server) activeMAC(host *model.Host, req *dhcpv4.DHCPv4) {
+	if host.UUID == "" {
+		return
+	}
+
+	nic := host.BootInterface()
+	if nic == nil || nic.MAC.String() != req.ClientHWAddr.String() {
+		return
+	}
+
+	s.uuidMu.Lock()
+	delete(s.uuidCandidates, host.UUID)
+	s.uuidMu.Unlock()
+}
+
+// learnUUID records the system UUID sent by the client on hosts which don't
+// have one yet. Vendor placeholder UUIDs and UUIDs already set on another host
+// are never learned.
+func (s *Server) learnUUID(host *model.Host, req *dhcpv4.DHCPv4) {
+	uuid := clientUUID(req)
+	if uuid == "" || host.UUID != "" || host.Interface(req.ClientHWAddr) == nil {
+		return
+	}
+
+	if model.PlaceholderUUID(uuid) {
+		return
+	}
+
+	owner, err := s.DB.LoadHostFromUUID(uuid)
+	switch {
+	case errors.Is(err, model.ErrNotFound):
+	case err != nil:
+		log.Warnf("Not learning uuid %s for host %s: %s", uuid, host.Name, err)
+		return
+	default:
+		log.WithFields(logrus.Fields{
+			logger.FieldHost: host.Name,
+			"owner":          owner.Name,
+			"uuid":           uuid,
+			logger.FieldMAC:  req.ClientHWAddr.String(),
+		}).Warn("Not learning client UUID already set on another host")
+		return
+	}
+
+	host.UUID = uuid
+	if err := model.NewAuditedStore(s.DB, "dhcp", "").StoreHost(host); err != nil {
+		log.Errorf("Failed to store uuid for host %s: %s", host.Name, err)
+		return
+	}
+
+	log.WithFields(logrus.Fields{
+		logger.FieldHost: host.Name,
+		"uuid":           uuid,
+		logger.FieldMAC:  req.ClientHWAddr.String(),
+	}).Info("Learned client UUID")
+}
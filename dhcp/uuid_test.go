@@ -0,0 +1,155 @@
+// Copyright 2019 Grendel Authors. All rights reserved.
+//
+// This file is part of Grendel.
+//
+// Grendel is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Grendel is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with Grendel. If not, see <https://www.gnu.org/licenses/>.
+
+package dhcp
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/insomniacslk/dhcp/dhcpv4"
+	"github.com/stretchr/testify/assert"
+	"github.com/ubccr/grendel/internal/tests"
+	"github.com/ubccr/grendel/model"
+)
+
+func TestHostFromUUID(t *testing.T) {
+	assert := assert.New(t)
+
+	host := tests.HostFactory.MustCreate().(*model.Host)
+	host.UUID = "4C4C4544-0042-3510-8052-B4C04F4A4D32"
+
+	s, _ := newTestPoolServer(t, model.HostList{host})
+	s.UUIDRelearnBoots = 2
+
+	// NIC swap on a host with a known UUID. The new MAC is learned on the
+	// second boot.
+	_, err := s.hostFromUUID(newTestEnrollRequest(t, "02:00:00:00:00:01"))
+	assert.True(errors.Is(err, model.ErrNotFound))
+
+	testHost, err := s.hostFromUUID(newTestEnrollRequest(t, "02:00:00:00:00:01"))
+	if assert.NoError(err) {
+		assert.Equal(host.Name, testHost.Name)
+		assert.Equal("02:00:00:00:00:01", testHost.BootInterface().MAC.String())
+	}
+
+	testHost, err = s.DB.LoadHostFromMAC("02:00:00:00:00:01")
+	if assert.NoError(err) {
+		assert.Equal(host.Name, testHost.Name)
+	}
+
+	// Requests without option 97 never match
+	_, err = s.hostFromUUID(newTestPoolRequest(t, "02:00:00:00:00:02"))
+	assert.True(errors.Is(err, model.ErrNotFound))
+}
+
+func TestHostFromUUIDTwoNICs(t *testing.T) {
+	assert := assert.New(t)
+
+	host := tests.HostFactory.MustCreate().(*model.Host)
+	host.UUID = "4c4c4544-0042-3510-8052-b4c04f4a4d32"
+	mac := host.BootInterface().MAC.String()
+
+	s, _ := newTestPoolServer(t, model.HostList{host})
+	s.UUIDRelearnBoots = 2
+
+	// A server tries to PXE boot from its second NIC before the registered
+	// one on every boot. Both send the same UUID.
+	for i := 0; i < 5; i++ {
+		req := newTestEnrollRequest(t, "02:00:00:00:00:02")
+		_, err := s.hostFromUUID(req)
+		assert.True(errors.Is(err, model.ErrNotFound))
+
+		// Retransmits of the same discover are one boot
+		_, err = s.hostFromUUID(req)
+		assert.True(errors.Is(err, model.ErrNotFound))
+
+		s.activeMAC(host, newTestEnrollRequest(t, mac))
+	}
+
+	testHost, err := s.DB.LoadHostFromName(host.Name)
+	if assert.NoError(err) {
+		assert.Equal(mac, testHost.BootInterface().MAC.String())
+	}
+
+	_, err = s.DB.LoadHostFromMAC("02:00:00:00:00:02")
+	assert.True(errors.Is(err, model.ErrNotFound))
+
+	// Learning is disabled with zero boots
+	s.UUIDRelearnBoots = 0
+	for i := 0; i < 5; i++ {
+		_, err := s.hostFromUUID(newTestEnrollRequest(t, "02:00:00:00:00:02"))
+		assert.True(errors.Is(err, model.ErrNotFound))
+	}
+}
+
+func TestLearnUUID(t *testing.T) {
+	assert := assert.New(t)
+
+	host := tests.HostFactory.MustCreate().(*model.Host)
+	s, _ := newTestPoolServer(t, model.HostList{host})
+
+	mac := host.BootInterface().MAC.String()
+	s.learnUUID(host, newTestEnrollRequest(t, mac))
+
+	testHost, err := s.DB.LoadHostFromUUID("4c4c4544-0042-3510-8052-b4c04f4a4d32")
+	if assert.NoError(err) {
+		assert.Equal(host.Name, testHost.Name)
+	}
+
+	// A known UUID is never overwritten
+	testHost.UUID = "4c4c4544-0042-3510-8052-b4c04f4a4d33"
+	assert.NoError(s.DB.StoreHost(testHost))
+	s.learnUUID(testHost, newTestEnrollRequest(t, mac))
+
+	testHost, err = s.DB.LoadHostFromName(host.Name)
+	if assert.NoError(err) {
+		assert.Equal("4c4c4544-0042-3510-8052-b4c04f4a4d33", testHost.UUID)
+	}
+}
+
+func TestLearnUUIDRejected(t *testing.T) {
+	assert := assert.New(t)
+
+	host := tests.HostFactory.MustCreate().(*model.Host)
+	host.UUID = "4c4c4544-0042-3510-8052-b4c04f4a4d32"
+	other := tests.HostFactory.MustCreate().(*model.Host)
+	s, _ := newTestPoolServer(t, model.HostList{host, other})
+
+	// The UUID is already set on another host
+	mac := other.BootInterface().MAC.String()
+	s.learnUUID(other, newTestEnrollRequest(t, mac))
+
+	testHost, err := s.DB.LoadHostFromName(other.Name)
+	if assert.NoError(err) {
+		assert.Equal("", testHost.UUID)
+	}
+
+	// Vendor placeholder UUID
+	req := newTestEnrollRequest(t, mac)
+	req.UpdateOption(dhcpv4.OptGeneric(dhcpv4.OptionClientMachineIdentifier, []byte{
+		0x00, 0x00, 0x02, 0x00, 0x03, 0x00, 0x04, 0x00, 0x05,
+		0x00, 0x06, 0x00, 0x07, 0x00, 0x08, 0x00, 0x09,
+	}))
+	assert.Equal("03000200-0400-0500-0006-000700080009", clientUUID(req))
+	s.learnUUID(other, req)
+
+	testHost, err = s.DB.LoadHostFromName(other.Name)
+	if assert.NoError(err) {
+		assert.Equal("", testHost.UUID)
+	}
+}
@@ -0,0 +1,253 @@
+// Copyright 2019 Grendel Authors. All rights reserved.
+//
+// This file is part of Grendel.
+//
+// Grendel is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Grendel is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with Grendel. If not, see <https://www.gnu.org/licenses/>.
+package dns
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+const (
+	DefaultCacheSize = 10000
+)
+
+// forwarder sends queries for names Grendel doesn't own to the upstream
+// resolvers and caches the responses honoring their TTLs
+type forwarder struct {
+	upstreams []string
+	client    *dns.Client
+	cache     *cache
+}
+
+func newForwarder(upstreams []string, cacheSize int) (*forwarder, error) {
+	f := &forwarder{
+		client: &dns.Client{Net: "udp", Timeout: 2 * time.Second},
+		cache:  newCache(cacheSize),
+	}
+
+	for _, u := range upstreams {
+		addr, ok := serverAddr(u)
+		if !ok {
+			return nil, fmt.Errorf("Invalid DNS forwarder address: %s", u)
+		}
+
+		f.upstreams = append(f.upstreams, addr)
+	}
+
+	if len(f.upstreams) == 0 {
+		return nil, errors.New("No DNS forwarders configured")
+	}
+
+	return f, nil
+}
+
+// serverAddr returns the ip:port address of a DNS server, adding the default
+// port if missing
+func serverAddr(addr string) (string, bool) {
+	if _, _, err := net.SplitHostPort(addr); err != nil {
+		addr = net.JoinHostPort(addr, "53")
+	}
+
+	host, _, _ := net.SplitHostPort(addr)
+	return addr, net.ParseIP(host) != nil
+}
+
+// Forward returns the response to the query from the cache or the first
+// upstream resolver that answers
+func (f *forwarder) Forward(r *dns.Msg) (*dns.Msg, error) {
+	q := r.Question[0]
+	key := cacheKey(q)
+
+	if m := f.cache.Get(key); m != nil {
+		m.Id = r.Id
+		cacheLookupsTotal.WithLabelValues("hit").Inc()
+		return m, nil
+	}
+	cacheLookupsTotal.WithLabelValues("miss").Inc()
+
+	req := new(dns.Msg)
+	req.SetQuestion(q.Name, q.Qtype)
+	req.Question[0].Qclass = q.Qclass
+	req.RecursionDesired = true
+
+	var lastErr error
+	for _, upstream := range f.upstreams {
+		m, _, err := f.client.Exchange(req, upstream)
+		if err == nil && m.Truncated {
+			tcp := &dns.Client{Net: "tcp", Timeout: f.client.Timeout}
+			m, _, err = tcp.Exchange(req, upstream)
+		}
+		if err != nil {
+			log.Debugf("DNS forwarder %s failed: %s", upstream, err)
+			lastErr = err
+			continue
+		}
+
+		f.cache.Set(key, m)
+
+		m = m.Copy()
+		m.Id = r.Id
+		return m, nil
+	}
+
+	return nil, fmt.Errorf("all DNS forwarders failed: %w", lastErr)
+}
+
+func cacheKey(q dns.Question) string {
+	return fmt.Sprintf("%s/%d/%d", strings.ToLower(q.Name), q.Qtype, q.Qclass)
+}
+
+type cacheEntry struct {
+	msg     *dns.Msg
+	stored  time.Time
+	expires time.Time
+}
+
+// cache is an in memory cache of DNS responses which expire with the
+// smallest TTL of their records
+type cache struct {
+	size    int
+	entries map[string]*cacheEntry
+	now     func() time.Time
+	mu      sync.Mutex
+}
+
+func newCache(size int) *cache {
+	if size <= 0 {
+		size = DefaultCacheSize
+	}
+
+	return &cache{
+		size:    size,
+		entries: make(map[string]*cacheEntry),
+		now:     time.Now,
+	}
+}
+
+// Get returns a copy of the cached response with the TTLs reduced by the time
+// spent in the cache or nil if the key is not cached
+func (c *cache) Get(key string) *dns.Msg {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	e, ok := c.entries[key]
+	if !ok {
+		return nil
+	}
+
+	now := c.now()
+	if !now.Before(e.expires) {
+		delete(c.entries, key)
+		return nil
+	}
+
+	m := e.msg.Copy()
+	elapsed := uint32(now.Sub(e.stored) / time.Second)
+	for _, rrs := range [][]dns.RR{m.Answer, m.Ns, m.Extra} {
+		for _, rr := range rrs {
+			if rr.Header().Rrtype == dns.TypeOPT {
+				continue
+			}
+			rr.Header().Ttl -= elapsed
+		}
+	}
+
+	return m
+}
+
+// Set caches the response. Responses without a TTL, such as server failures
+// or negative answers without a SOA, are not cached.
+func (c *cache) Set(key string, m *dns.Msg) {
+	ttl, ok := msgTTL(m)
+	if !ok || ttl == 0 {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := c.now()
+	if len(c.entries) >= c.size {
+		c.evict(now)
+	}
+
+	c.entries[key] = &cacheEntry{
+		msg:     m.Copy(),
+		stored:  now,
+		expires: now.Add(time.Duration(ttl) * time.Second),
+	}
+}
+
+// evict removes expired entries or a random entry if none have expired
+func (c *cache) evict(now time.Time) {
+	for k, e := range c.entries {
+		if !now.Before(e.expires) {
+			delete(c.entries, k)
+		}
+	}
+
+	for k := range c.entries {
+		if len(c.entries) < c.size {
+			break
+		}
+		delete(c.entries, k)
+	}
+}
+
+// msgTTL returns the smallest TTL of the records in the response. Negative
+// answers use the SOA minimum as defined in RFC 2308.
+func msgTTL(m *dns.Msg) (uint32, bool) {
+	if m.Rcode != dns.RcodeSuccess && m.Rcode != dns.RcodeNameError {
+		return 0, false
+	}
+
+	if len(m.Answer) == 0 {
+		for _, rr := range m.Ns {
+			if soa, ok := rr.(*dns.SOA); ok {
+				if soa.Minttl < soa.Hdr.Ttl {
+					return soa.Minttl, true
+				}
+				return soa.Hdr.Ttl, true
+			}
+		}
+
+		return 0, false
+	}
+
+	found := false
+	var ttl uint32
+	for _, rrs := range [][]dns.RR{m.Answer, m.Ns, m.Extra} {
+		for _, rr := range rrs {
+			if rr.Header().Rrtype == dns.TypeOPT {
+				continue
+			}
+
+			if !found || rr.Header().Ttl < ttl {
+				ttl = rr.Header().Ttl
+				found = true
+			}
+		}
+	}
+
+	return ttl, found
+}
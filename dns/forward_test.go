@@ -0,0 +1,228 @@
+// Copyright 2019 Grendel Authors. All rights reserved.
+//
+// This file is part of Grendel.
+//
+// Grendel is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Grendel is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with Grendel. If not, see <https://www.gnu.org/licenses/>.
+package dns
+
+import (
+	"net"
+	"net/netip"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+	"github.com/ubccr/grendel/model"
+)
+
+// newTestUpstream starts a stand-in upstream resolver which answers A queries
+// for example.org and returns NXDOMAIN with a SOA for everything else
+func newTestUpstream(t *testing.T, queries *int32) string {
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+
+	mux := dns.NewServeMux()
+	mux.HandleFunc(".", func(w dns.ResponseWriter, r *dns.Msg) {
+		atomic.AddInt32(queries, 1)
+		m := new(dns.Msg)
+		m.SetReply(r)
+
+		if r.Question[0].Name == "example.org." && r.Question[0].Qtype == dns.TypeA {
+			rr, _ := dns.NewRR("example.org. 60 IN A 192.0.2.1")
+			m.Answer = append(m.Answer, rr)
+		} else {
+			soa, _ := dns.NewRR("org. 3600 IN SOA ns.org. admin.org. 1 7200 3600 1209600 30")
+			m.Ns = append(m.Ns, soa)
+			m.SetRcode(r, dns.RcodeNameError)
+		}
+
+		w.WriteMsg(m)
+	})
+
+	srv := &dns.Server{PacketConn: pc, Handler: mux}
+	go srv.ActivateAndServe()
+	t.Cleanup(func() { srv.Shutdown() })
+
+	return pc.LocalAddr().String()
+}
+
+func TestForward(t *testing.T) {
+	assert := assert.New(t)
+
+	db := newTestDB(t)
+	defer db.Close()
+
+	assert.NoError(db.StoreHosts(model.HostList{
+		newTestHost("tux-01", "tux-01.compute.local", "10.0.0.1/24"),
+		newTestHost("ext-01", "ext-01.example.org", "10.0.0.2/24"),
+	}))
+
+	var queries int32
+	upstream := newTestUpstream(t, &queries)
+
+	h, err := NewHandler(db, 300, &Zone{Name: "compute.local"})
+	assert.NoError(err)
+	h.forwarder, err = newForwarder([]string{upstream}, 0)
+	assert.NoError(err)
+	h.recursionACL = []netip.Prefix{netip.MustParsePrefix("127.0.0.0/8")}
+
+	m := query(t, h, "example.org.", dns.TypeA)
+	assert.Equal(dns.RcodeSuccess, m.Rcode)
+	assert.False(m.Authoritative)
+	assert.True(m.RecursionAvailable)
+	if assert.Equal(1, len(m.Answer)) {
+		assert.Equal("192.0.2.1", m.Answer[0].(*dns.A).A.String())
+	}
+	assert.Equal(int32(1), atomic.LoadInt32(&queries))
+
+	// Second query is answered from the cache
+	m = query(t, h, "EXAMPLE.org.", dns.TypeA)
+	if assert.Equal(1, len(m.Answer)) {
+		assert.Equal("192.0.2.1", m.Answer[0].(*dns.A).A.String())
+	}
+	assert.Equal(int32(1), atomic.LoadInt32(&queries))
+
+	// Negative answers are cached too
+	m = query(t, h, "missing.org.", dns.TypeA)
+	assert.Equal(dns.RcodeNameError, m.Rcode)
+	m = query(t, h, "missing.org.", dns.TypeA)
+	assert.Equal(dns.RcodeNameError, m.Rcode)
+	assert.Equal(int32(2), atomic.LoadInt32(&queries))
+
+	// Names in the datastore are answered locally even outside a zone
+	m = query(t, h, "ext-01.example.org.", dns.TypeA)
+	assert.True(m.Authoritative)
+	if assert.Equal(1, len(m.Answer)) {
+		assert.Equal("10.0.0.2", m.Answer[0].(*dns.A).A.String())
+	}
+
+	// Names in our zones are never forwarded
+	m = query(t, h, "tux-02.compute.local.", dns.TypeA)
+	assert.Equal(dns.RcodeNameError, m.Rcode)
+	assert.Equal(int32(2), atomic.LoadInt32(&queries))
+}
+
+func TestForwardRefused(t *testing.T) {
+	assert := assert.New(t)
+
+	db := newTestDB(t)
+	defer db.Close()
+
+	assert.NoError(db.StoreHosts(model.HostList{
+		newTestHost("tux-01", "tux-01.compute.local", "10.0.0.1/24"),
+	}))
+
+	var queries int32
+	upstream := newTestUpstream(t, &queries)
+
+	h, err := NewHandler(db, 300, &Zone{Name: "compute.local"})
+	assert.NoError(err)
+	h.forwarder, err = newForwarder([]string{upstream}, 0)
+	assert.NoError(err)
+	h.recursionACL = []netip.Prefix{netip.MustParsePrefix("10.0.0.0/24")}
+
+	req := new(dns.Msg)
+	req.SetQuestion("example.org.", dns.TypeA)
+
+	// Clients outside the ACL are refused recursion
+	w := &testWriter{remote: net.ParseIP("192.0.2.10")}
+	h.ServeDNS(w, req)
+	assert.Equal(dns.RcodeRefused, w.msg.Rcode)
+	assert.False(w.msg.RecursionAvailable)
+	assert.Equal(0, len(w.msg.Answer))
+	assert.Equal(int32(0), atomic.LoadInt32(&queries))
+
+	// but still get answers for names Grendel owns
+	req.SetQuestion("tux-01.compute.local.", dns.TypeA)
+	h.ServeDNS(w, req)
+	assert.Equal(dns.RcodeSuccess, w.msg.Rcode)
+	assert.Equal(1, len(w.msg.Answer))
+
+	// Clients inside the ACL are forwarded
+	req.SetQuestion("example.org.", dns.TypeA)
+	w = &testWriter{remote: net.ParseIP("10.0.0.5")}
+	h.ServeDNS(w, req)
+	assert.Equal(dns.RcodeSuccess, w.msg.Rcode)
+	assert.True(w.msg.RecursionAvailable)
+	assert.Equal(1, len(w.msg.Answer))
+	assert.Equal(int32(1), atomic.LoadInt32(&queries))
+}
+
+func TestForwardFailure(t *testing.T) {
+	assert := assert.New(t)
+
+	db := newTestDB(t)
+	defer db.Close()
+
+	h, err := NewHandler(db, 300)
+	assert.NoError(err)
+
+	// Nothing listens on the discard port
+	h.forwarder, err = newForwarder([]string{"127.0.0.1:9"}, 0)
+	assert.NoError(err)
+	h.forwarder.client.Timeout = 100 * time.Millisecond
+	h.recursionACL = []netip.Prefix{netip.MustParsePrefix("127.0.0.0/8")}
+
+	m := query(t, h, "example.org.", dns.TypeA)
+	assert.Equal(dns.RcodeServerFailure, m.Rcode)
+}
+
+func TestCacheTTL(t *testing.T) {
+	assert := assert.New(t)
+
+	now := time.Now()
+	c := newCache(2)
+	c.now = func() time.Time { return now }
+
+	m := new(dns.Msg)
+	m.SetQuestion("example.org.", dns.TypeA)
+	rr, _ := dns.NewRR("example.org. 60 IN A 192.0.2.1")
+	m.Answer = append(m.Answer, rr)
+	rr, _ = dns.NewRR("example.org. 30 IN A 192.0.2.2")
+	m.Answer = append(m.Answer, rr)
+	c.Set("a", m)
+
+	now = now.Add(10 * time.Second)
+	if cached := c.Get("a"); assert.NotNil(cached) {
+		assert.Equal(uint32(50), cached.Answer[0].Header().Ttl)
+		assert.Equal(uint32(20), cached.Answer[1].Header().Ttl)
+	}
+
+	// Expires with the smallest TTL
+	now = now.Add(20 * time.Second)
+	assert.Nil(c.Get("a"))
+
+	// Server failures are not cached
+	fail := new(dns.Msg)
+	fail.Rcode = dns.RcodeServerFailure
+	c.Set("b", fail)
+	assert.Nil(c.Get("b"))
+
+	// The cache is bounded
+	for _, k := range []string{"c", "d", "e"} {
+		c.Set(k, m)
+	}
+	assert.Equal(2, len(c.entries))
+
+	_, err := newForwarder([]string{"not-an-ip"}, 0)
+	assert.Error(err)
+	f, err := newForwarder([]string{"192.0.2.53", "[2001:db8::53]:5353"}, 0)
+	if assert.NoError(err) {
+		assert.Equal([]string{"192.0.2.53:53", "[2001:db8::53]:5353"}, f.upstreams)
+	}
+}
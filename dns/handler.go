@@ -19,7 +19,10 @@ package dns
 
 import (
 	"net"
+	"net/netip"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/miekg/dns"
 	"github.com/sirupsen/logrus"
@@ -28,14 +31,34 @@ import (
 )
 
 type handler struct {
-	db  model.DataStore
-	ttl uint32
+	db           model.DataStore
+	ttl          uint32
+	records      map[string][]dns.RR
+	zones        []*Zone
+	forwarder    *forwarder
+	transferACL  []netip.Prefix
+	recursionACL []netip.Prefix
+	secondaries  []string
+
+	mu      sync.Mutex
+	serials map[string]*zoneSerial
 }
 
-func NewHandler(db model.DataStore, ttl uint32) (*handler, error) {
+func NewHandler(db model.DataStore, ttl uint32, zones ...*Zone) (*handler, error) {
 	h := &handler{
-		db:  db,
-		ttl: ttl,
+		db:      db,
+		ttl:     ttl,
+		records: make(map[string][]dns.RR),
+		zones:   zones,
+		serials: make(map[string]*zoneSerial),
+	}
+
+	serial := uint32(time.Now().Unix())
+	for _, z := range zones {
+		h.serials[z.Owner("@")] = &zoneSerial{serial: serial}
+		for _, rr := range z.Records(ttl) {
+			h.records[rr.Header().Name] = append(h.records[rr.Header().Name], rr)
+		}
 	}
 
 	return h, nil
@@ -46,42 +69,214 @@ func (h *handler) ServeDNS(w dns.ResponseWriter, r *dns.Msg) {
 	m.SetReply(r)
 
 	qname := h.Name(r)
-	answers := []dns.RR{}
+	qtype := h.QType(r)
+
+	if qtype == dns.TypeAXFR || qtype == dns.TypeIXFR {
+		h.transfer(w, r)
+		return
+	}
 
 	log.Debugf("Got query %s", qname)
-	switch h.QType(r) {
-	case dns.TypePTR:
-		names, err := h.db.ReverseResolve(util.ExtractAddressFromReverse(qname))
-		if err != nil {
-			log.WithFields(logrus.Fields{
-				"qname": qname,
-				"err":   err,
-			}).Error("Failed to reverse resolve IP")
-		}
-		answers = h.ptr(qname, h.ttl, names)
-	case dns.TypeA:
-		ips, err := h.db.ResolveIPv4(qname)
-		if err != nil {
-			log.WithFields(logrus.Fields{
-				"qname": qname,
-				"err":   err,
-			}).Error("Failed to resolve FQDN")
+	answers, exists := h.lookup(qname, qtype)
+
+	recursion := h.forwarder != nil && aclContains(h.recursionACL, w.RemoteAddr())
+	if len(answers) == 0 && !exists && h.forwarder != nil && len(r.Question) > 0 && !h.authoritative(qname) {
+		if !recursion {
+			log.Debugf("Refusing recursive query %s from %s", qname, w.RemoteAddr())
+			m.SetRcode(r, dns.RcodeRefused)
+			observeQuery(qtype, m)
+			w.WriteMsg(m)
+			return
 		}
-		answers = a(qname, h.ttl, ips)
+
+		h.forward(w, r)
+		return
 	}
 
+	m.RecursionAvailable = recursion
 	if len(answers) != 0 {
 		m.Authoritative = true
 		m.Answer = answers
 		m.SetRcode(r, dns.RcodeSuccess)
+	} else if exists {
+		// The name exists but has no records of the requested type
+		m.Authoritative = true
+		m.SetRcode(r, dns.RcodeSuccess)
 	} else {
-		// XXX consider sending back NXDOMAIN here
 		m.SetRcode(r, dns.RcodeNameError)
 	}
 
+	if len(answers) == 0 {
+		// Negative answers in our zones carry the SOA so resolvers can cache
+		// them
+		if z := h.enclosing(qname); z != nil {
+			m.Authoritative = true
+			m.Ns = []dns.RR{h.soa(z, h.serial(z))}
+		}
+	}
+
+	observeQuery(qtype, m)
 	w.WriteMsg(m)
 }
 
+// forward sends the response to a query for a name Grendel doesn't own from
+// the upstream forwarders
+func (h *handler) forward(w dns.ResponseWriter, r *dns.Msg) {
+	m, err := h.forwarder.Forward(r)
+	if err != nil {
+		log.WithFields(logrus.Fields{
+			"qname": h.Name(r),
+			"err":   err,
+		}).Error("Failed to forward query")
+
+		m = new(dns.Msg)
+		m.SetRcode(r, dns.RcodeServerFailure)
+	}
+
+	m.RecursionAvailable = true
+	observeQuery(h.QType(r), m)
+	w.WriteMsg(m)
+}
+
+// authoritative returns true if the name is in one of the configured zones
+func (h *handler) authoritative(qname string) bool {
+	return h.enclosing(qname) != nil
+}
+
+// enclosing returns the most specific configured zone containing the name or
+// nil if the name is not in any zone
+func (h *handler) enclosing(qname string) *Zone {
+	var zone *Zone
+	for _, z := range h.zones {
+		if !dns.IsSubDomain(z.Owner("@"), qname) {
+			continue
+		}
+
+		if zone == nil || dns.CountLabel(z.Owner("@")) > dns.CountLabel(zone.Owner("@")) {
+			zone = z
+		}
+	}
+
+	return zone
+}
+
+// apex returns the configured zone named qname or nil
+func (h *handler) apex(qname string) *Zone {
+	for _, z := range h.zones {
+		if z.Owner("@") == qname {
+			return z
+		}
+	}
+
+	return nil
+}
+
+// lookup returns the answers for the query and whether the name exists
+func (h *handler) lookup(qname string, qtype uint16) ([]dns.RR, bool) {
+	answers := []dns.RR{}
+	exists := false
+
+	if z := h.apex(qname); z != nil {
+		exists = true
+		if qtype == dns.TypeSOA || qtype == dns.TypeANY {
+			answers = append(answers, h.soa(z, h.serial(z)))
+		}
+		if qtype == dns.TypeNS || qtype == dns.TypeANY {
+			answers = append(answers, h.ns(z)...)
+		}
+	}
+
+	if rrs, ok := h.records[qname]; ok {
+		exists = true
+		for _, rr := range rrs {
+			if qtype == dns.TypeANY || rr.Header().Rrtype == qtype {
+				answers = append(answers, rr)
+			}
+		}
+	}
+
+	if qtype == dns.TypePTR {
+		names, err := h.db.ReverseResolve(util.ExtractAddressFromReverse(qname))
+		if err != nil {
+			log.WithFields(logrus.Fields{
+				"qname": qname,
+				"err":   err,
+			}).Error("Failed to reverse resolve IP")
+		}
+		answers = append(answers, h.ptr(qname, h.ttl, names)...)
+		return answers, exists || len(answers) > 0
+	}
+
+	target, err := h.db.ResolveAlias(qname)
+	if err != nil {
+		log.WithFields(logrus.Fields{
+			"qname": qname,
+			"err":   err,
+		}).Error("Failed to resolve alias")
+	}
+
+	if target != "" {
+		answers = append(answers, cname(qname, h.ttl, target))
+		if qtype != dns.TypeCNAME {
+			// Include the addresses of the target so clients don't need a
+			// second query
+			ips4, ips6 := h.resolve(util.Normalize(target))
+			answers = append(answers, h.addrs(dns.Fqdn(target), qtype, ips4, ips6)...)
+		}
+		return answers, true
+	}
+
+	ips4, ips6 := h.resolve(qname)
+	if len(ips4) > 0 || len(ips6) > 0 {
+		exists = true
+	}
+
+	answers = append(answers, h.addrs(qname, qtype, ips4, ips6)...)
+	return answers, exists
+}
+
+// resolve returns the IPv4 and IPv6 addresses of the FQDN
+func (h *handler) resolve(fqdn string) ([]net.IP, []net.IP) {
+	ips4, err := h.db.ResolveIPv4(fqdn)
+	if err != nil {
+		log.WithFields(logrus.Fields{
+			"qname": fqdn,
+			"err":   err,
+		}).Error("Failed to resolve FQDN")
+	}
+
+	ips6, err := h.db.ResolveIPv6(fqdn)
+	if err != nil {
+		log.WithFields(logrus.Fields{
+			"qname": fqdn,
+			"err":   err,
+		}).Error("Failed to resolve FQDN")
+	}
+
+	return ips4, ips6
+}
+
+// addrs returns the A and AAAA RRs matching the query type
+func (h *handler) addrs(zone string, qtype uint16, ips4, ips6 []net.IP) []dns.RR {
+	answers := []dns.RR{}
+	if qtype == dns.TypeA || qtype == dns.TypeANY {
+		answers = append(answers, a(zone, h.ttl, ips4)...)
+	}
+	if qtype == dns.TypeAAAA || qtype == dns.TypeANY {
+		answers = append(answers, aaaa(zone, h.ttl, ips6)...)
+	}
+
+	return answers
+}
+
+// cname returns a CNAME RR pointing the alias to target
+func cname(alias string, ttl uint32, target string) dns.RR {
+	r := new(dns.CNAME)
+	r.Hdr = dns.RR_Header{Name: alias, Rrtype: dns.TypeCNAME, Class: dns.ClassINET, Ttl: ttl}
+	r.Target = dns.Fqdn(target)
+	return r
+}
+
 // The code below was adopted from the hosts plugin from coredns
 // https://github.com/coredns/coredns/tree/master/plugin/hosts
 // Copyright coredns authors Apache License
@@ -110,14 +305,22 @@ func aaaa(zone string, ttl uint32, ips []net.IP) []dns.RR {
 	return answers
 }
 
-// ptr takes a slice of host names and filters out the ones that aren't in Origins, if specified, and returns a slice of PTR RRs.
+// ptr takes a slice of host names and returns a slice of PTR RRs, one for each
+// unique name.
 func (h *handler) ptr(zone string, ttl uint32, names []string) []dns.RR {
-	answers := make([]dns.RR, len(names))
-	for i, n := range names {
+	seen := make(map[string]struct{}, len(names))
+	answers := make([]dns.RR, 0, len(names))
+	for _, n := range names {
+		name := util.Normalize(n)
+		if _, ok := seen[name]; ok {
+			continue
+		}
+		seen[name] = struct{}{}
+
 		r := new(dns.PTR)
 		r.Hdr = dns.RR_Header{Name: zone, Rrtype: dns.TypePTR, Class: dns.ClassINET, Ttl: ttl}
 		r.Ptr = dns.Fqdn(n)
-		answers[i] = r
+		answers = append(answers, r)
 	}
 	return answers
 }
@@ -0,0 +1,310 @@
+// Copyright 2019 Grendel Authors. All rights reserved.
+//
+// This file is part of Grendel.
+//
+// Grendel is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Grendel is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with Grendel. If not, see <https://www.gnu.org/licenses/>.
+
+package dns
+
+import (
+	"net"
+	"net/netip"
+	"strings"
+	"testing"
+
+	"github.com/miekg/dns"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/ubccr/grendel/internal/tests"
+	"github.com/ubccr/grendel/model"
+)
+
+type testWriter struct {
+	msg    *dns.Msg
+	remote net.IP
+}
+
+func (w *testWriter) LocalAddr() net.Addr {
+	return &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 53}
+}
+
+func (w *testWriter) RemoteAddr() net.Addr {
+	if w.remote != nil {
+		return &net.UDPAddr{IP: w.remote, Port: 40212}
+	}
+
+	return &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 40212}
+}
+
+func (w *testWriter) WriteMsg(m *dns.Msg) error {
+	w.msg = m
+	return nil
+}
+
+func (w *testWriter) Write(b []byte) (int, error) { return len(b), nil }
+func (w *testWriter) Close() error                { return nil }
+func (w *testWriter) TsigStatus() error           { return nil }
+func (w *testWriter) TsigTimersOnly(bool)         {}
+func (w *testWriter) Hijack()                     {}
+
+func newTestDB(t *testing.T) model.DataStore {
+	db, err := model.NewDataStore(":memory:")
+	if err != nil {
+		assert.Fail(t, err.Error())
+	}
+
+	return db
+}
+
+func newTestHost(name, fqdn, ip string) *model.Host {
+	host := tests.HostFactory.MustCreate().(*model.Host)
+	host.Name = name
+	host.Interfaces[0].FQDN = fqdn
+	host.Interfaces[0].IP = netip.MustParsePrefix(ip)
+	host.Interfaces[1].IP = netip.Prefix{}
+	return host
+}
+
+func query(t *testing.T, h *handler, name string, qtype uint16) *dns.Msg {
+	req := new(dns.Msg)
+	req.SetQuestion(name, qtype)
+
+	w := &testWriter{}
+	h.ServeDNS(w, req)
+	if !assert.NotNil(t, w.msg) {
+		t.FailNow()
+	}
+
+	return w.msg
+}
+
+func TestPTRPrefixCollision(t *testing.T) {
+	assert := assert.New(t)
+
+	db := newTestDB(t)
+	defer db.Close()
+
+	err := db.StoreHosts(model.HostList{
+		newTestHost("tux-100", "tux-100.compute.local", "10.0.0.100/24"),
+		newTestHost("tux-10", "tux-10.compute.local", "10.0.0.10/24"),
+		newTestHost("tux-1", "tux-1.compute.local", "10.0.0.1/24"),
+	})
+	assert.NoError(err)
+
+	h, err := NewHandler(db, 300)
+	assert.NoError(err)
+
+	for _, test := range []struct {
+		qname string
+		name  string
+	}{
+		{"1.0.0.10.in-addr.arpa.", "tux-1.compute.local."},
+		{"10.0.0.10.in-addr.arpa.", "tux-10.compute.local."},
+		{"100.0.0.10.in-addr.arpa.", "tux-100.compute.local."},
+	} {
+		m := query(t, h, test.qname, dns.TypePTR)
+		assert.Equal(dns.RcodeSuccess, m.Rcode)
+		if assert.Equal(1, len(m.Answer), test.qname) {
+			assert.Equal(test.name, m.Answer[0].(*dns.PTR).Ptr)
+		}
+	}
+
+	m := query(t, h, "2.0.0.10.in-addr.arpa.", dns.TypePTR)
+	assert.Equal(dns.RcodeNameError, m.Rcode)
+	assert.Equal(0, len(m.Answer))
+}
+
+func TestPTRMultipleNames(t *testing.T) {
+	assert := assert.New(t)
+
+	db := newTestDB(t)
+	defer db.Close()
+
+	host := newTestHost("tux-1", "tux-1.compute.local", "10.0.0.1/24")
+	host.Interfaces[1].FQDN = "tux-1-ib.compute.local"
+	host.Interfaces[1].IP = netip.MustParsePrefix("10.0.0.1/24")
+
+	err := db.StoreHosts(model.HostList{
+		host,
+		newTestHost("vip", "vip.compute.local", "10.0.0.1/24"),
+	})
+	assert.NoError(err)
+
+	h, err := NewHandler(db, 300)
+	assert.NoError(err)
+
+	m := query(t, h, "1.0.0.10.in-addr.arpa.", dns.TypePTR)
+	assert.Equal(dns.RcodeSuccess, m.Rcode)
+
+	names := make([]string, 0)
+	for _, rr := range m.Answer {
+		names = append(names, rr.(*dns.PTR).Ptr)
+	}
+	assert.ElementsMatch([]string{"tux-1.compute.local.", "tux-1-ib.compute.local.", "vip.compute.local."}, names)
+}
+
+func TestAAAA(t *testing.T) {
+	assert := assert.New(t)
+
+	db := newTestDB(t)
+	defer db.Close()
+
+	host := newTestHost("tux-01", "tux-01.compute.local", "10.0.0.1/24")
+	host.Interfaces[0].Addrs = []netip.Prefix{netip.MustParsePrefix("2001:db8::1/64")}
+	v4only := newTestHost("tux-02", "tux-02.compute.local", "10.0.0.2/24")
+	assert.NoError(db.StoreHosts(model.HostList{host, v4only}))
+
+	h, err := NewHandler(db, 300)
+	assert.NoError(err)
+
+	m := query(t, h, "tux-01.compute.local.", dns.TypeAAAA)
+	assert.Equal(dns.RcodeSuccess, m.Rcode)
+	if assert.Equal(1, len(m.Answer)) {
+		assert.Equal("2001:db8::1", m.Answer[0].(*dns.AAAA).AAAA.String())
+	}
+
+	m = query(t, h, "tux-01.compute.local.", dns.TypeA)
+	if assert.Equal(1, len(m.Answer)) {
+		assert.Equal("10.0.0.1", m.Answer[0].(*dns.A).A.String())
+	}
+
+	m = query(t, h, "1.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.8.b.d.0.1.0.0.2.ip6.arpa.", dns.TypePTR)
+	assert.Equal(dns.RcodeSuccess, m.Rcode)
+	if assert.Equal(1, len(m.Answer)) {
+		assert.Equal("tux-01.compute.local.", m.Answer[0].(*dns.PTR).Ptr)
+	}
+
+	// Names with only IPv4 addresses return NODATA for AAAA
+	m = query(t, h, "tux-02.compute.local.", dns.TypeAAAA)
+	assert.Equal(dns.RcodeSuccess, m.Rcode)
+	assert.True(m.Authoritative)
+	assert.Equal(0, len(m.Answer))
+
+	m = query(t, h, "tux-02.compute.local.", dns.TypeMX)
+	assert.Equal(dns.RcodeSuccess, m.Rcode)
+	assert.Equal(0, len(m.Answer))
+
+	m = query(t, h, "tux-03.compute.local.", dns.TypeAAAA)
+	assert.Equal(dns.RcodeNameError, m.Rcode)
+}
+
+func TestCNAME(t *testing.T) {
+	assert := assert.New(t)
+
+	db := newTestDB(t)
+	defer db.Close()
+
+	host := newTestHost("tux-01", "tux-01.compute.local", "10.0.0.1/24")
+	host.Interfaces[0].BMC = false
+	host.Interfaces[0].Aliases = []string{"www.compute.local"}
+	host.Aliases = []string{"login.compute.local"}
+	assert.NoError(db.StoreHosts(model.HostList{host}))
+
+	h, err := NewHandler(db, 300)
+	assert.NoError(err)
+
+	for _, alias := range []string{"www.compute.local.", "login.compute.local."} {
+		m := query(t, h, alias, dns.TypeA)
+		assert.Equal(dns.RcodeSuccess, m.Rcode)
+		if assert.Equal(2, len(m.Answer), alias) {
+			assert.Equal("tux-01.compute.local.", m.Answer[0].(*dns.CNAME).Target)
+			assert.Equal("tux-01.compute.local.", m.Answer[1].Header().Name)
+			assert.Equal("10.0.0.1", m.Answer[1].(*dns.A).A.String())
+		}
+
+		m = query(t, h, alias, dns.TypeCNAME)
+		if assert.Equal(1, len(m.Answer), alias) {
+			assert.Equal("tux-01.compute.local.", m.Answer[0].(*dns.CNAME).Target)
+		}
+
+		m = query(t, h, alias, dns.TypeAAAA)
+		assert.Equal(dns.RcodeSuccess, m.Rcode)
+		assert.Equal(1, len(m.Answer), alias)
+	}
+}
+
+func TestZoneRecords(t *testing.T) {
+	assert := assert.New(t)
+
+	db := newTestDB(t)
+	defer db.Close()
+
+	zone := &Zone{
+		Name: "compute.local",
+		SRV: []SRVRecord{
+			{Name: "_ldap._tcp", Target: "ldap.compute.local", Port: 389, Priority: 10, Weight: 5},
+		},
+		TXT: []TXTRecord{
+			{Name: "@", Text: []string{"cluster=tux"}},
+			{Name: "info.example.org.", Text: []string{"a", "b"}},
+		},
+	}
+
+	h, err := NewHandler(db, 300, zone)
+	assert.NoError(err)
+
+	m := query(t, h, "_ldap._tcp.compute.local.", dns.TypeSRV)
+	assert.Equal(dns.RcodeSuccess, m.Rcode)
+	if assert.Equal(1, len(m.Answer)) {
+		srv := m.Answer[0].(*dns.SRV)
+		assert.Equal("ldap.compute.local.", srv.Target)
+		assert.Equal(uint16(389), srv.Port)
+		assert.Equal(uint16(10), srv.Priority)
+		assert.Equal(uint16(5), srv.Weight)
+	}
+
+	m = query(t, h, "compute.local.", dns.TypeTXT)
+	if assert.Equal(1, len(m.Answer)) {
+		assert.Equal([]string{"cluster=tux"}, m.Answer[0].(*dns.TXT).Txt)
+	}
+
+	m = query(t, h, "info.example.org.", dns.TypeTXT)
+	if assert.Equal(1, len(m.Answer)) {
+		assert.Equal([]string{"a", "b"}, m.Answer[0].(*dns.TXT).Txt)
+	}
+
+	m = query(t, h, "_ldap._tcp.compute.local.", dns.TypeTXT)
+	assert.Equal(dns.RcodeSuccess, m.Rcode)
+	assert.Equal(0, len(m.Answer))
+
+	m = query(t, h, "_kerberos._tcp.compute.local.", dns.TypeSRV)
+	assert.Equal(dns.RcodeNameError, m.Rcode)
+}
+
+func TestParseZones(t *testing.T) {
+	assert := assert.New(t)
+
+	viper.SetConfigType("toml")
+	defer viper.Reset()
+	err := viper.ReadConfig(strings.NewReader(`
+[dns]
+nameservers = ["ns1.compute.local"]
+reverse_zones = ["10.0.0.0/16"]
+[[dns.zones]]
+name = "compute.local"
+srv = [{name = "_ldap._tcp", target = "ldap.compute.local", port = 389, priority = 10}]
+txt = [{name = "@", text = "cluster=tux"}]
+`))
+	assert.NoError(err)
+
+	zones, err := ParseZones()
+	if assert.NoError(err) && assert.Equal(2, len(zones)) {
+		assert.Equal("compute.local", zones[0].Name)
+		assert.Equal([]string{"ns1.compute.local"}, zones[0].NS)
+		assert.Equal("0.10.in-addr.arpa.", zones[1].Name)
+		assert.Equal([]string{"ns1.compute.local"}, zones[1].NS)
+		assert.Equal([]SRVRecord{{Name: "_ldap._tcp", Target: "ldap.compute.local", Port: 389, Priority: 10}}, zones[0].SRV)
+		assert.Equal([]TXTRecord{{Name: "@", Text: []string{"cluster=tux"}}}, zones[0].TXT)
+	}
+}
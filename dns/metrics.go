@@ -0,0 +1,64 @@
+// Copyright 2019 Grendel Authors. All rights reserved.
+//
+// This file is part of Grendel.
+//
+// Grendel is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Grendel is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with Grendel. If not, see <https://www.gnu.org/licenses/>.
+
+package dns
+
+import (
+	"github.com/miekg/dns"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/ubccr/grendel/metrics"
+)
+
+var (
+	queriesTotal = metrics.Factory.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metrics.Namespace,
+		Subsystem: "dns",
+		Name:      "queries_total",
+		Help:      "DNS queries by query type and response code",
+	}, []string{"qtype", "rcode"})
+
+	cacheLookupsTotal = metrics.Factory.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metrics.Namespace,
+		Subsystem: "dns",
+		Name:      "forward_cache_lookups_total",
+		Help:      "Forwarded DNS queries by cache result (hit or miss)",
+	}, []string{"result"})
+
+	transfersTotal = metrics.Factory.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metrics.Namespace,
+		Subsystem: "dns",
+		Name:      "zone_transfers_total",
+		Help:      "Zone transfer requests by query type and response code",
+	}, []string{"qtype", "rcode"})
+
+	notifiesTotal = metrics.Factory.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metrics.Namespace,
+		Subsystem: "dns",
+		Name:      "notifies_total",
+		Help:      "DNS NOTIFY messages sent to secondaries by result (success or error)",
+	}, []string{"result"})
+)
+
+// observeQuery counts a DNS query by the response code sent
+func observeQuery(qtype uint16, m *dns.Msg) {
+	queriesTotal.WithLabelValues(dns.TypeToString[qtype], dns.RcodeToString[m.Rcode]).Inc()
+}
+
+// observeTransfer counts a zone transfer by the response code sent
+func observeTransfer(qtype uint16, m *dns.Msg) {
+	transfersTotal.WithLabelValues(dns.TypeToString[qtype], dns.RcodeToString[m.Rcode]).Inc()
+}
@@ -0,0 +1,128 @@
+// Copyright 2019 Grendel Authors. All rights reserved.
+//
+// This file is part of Grendel.
+//
+// Grendel is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Grendel is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with Grendel. If not, see <https://www.gnu.org/licenses/>.
+
+package dns
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/sirupsen/logrus"
+	"github.com/ubccr/grendel/events"
+)
+
+const (
+	// notifyBufferSize is the number of host change events buffered while
+	// the zones are refreshed
+	notifyBufferSize = 1024
+
+	notifyTimeout = 2 * time.Second
+)
+
+// notifyDelay is how long host changes are collected before the zones are
+// refreshed so bulk changes send a single NOTIFY per zone
+var notifyDelay = 2 * time.Second
+
+// watch bumps the serial of the zones whose records changed and notifies the
+// secondaries when hosts are changed in the datastore until ctx is cancelled
+func (h *handler) watch(ctx context.Context) {
+	sub := events.Subscribe(&events.Filter{Types: []string{events.TypeHostChanged}}, notifyBufferSize)
+	defer sub.Close()
+
+	// Record the current records so the first change bumps the serial
+	h.refresh()
+
+	var pending <-chan time.Time
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-sub.C:
+			if pending == nil {
+				pending = time.After(notifyDelay)
+			}
+		case <-pending:
+			pending = nil
+			for _, z := range h.refresh() {
+				h.notify(z)
+			}
+		}
+	}
+}
+
+// refresh synthesizes the records of all zones and returns the zones whose
+// serial was bumped
+func (h *handler) refresh() []*Zone {
+	changed := make([]*Zone, 0)
+	for _, z := range h.zones {
+		rrs, err := h.zoneRecords(z)
+		if err != nil {
+			log.WithFields(logrus.Fields{
+				"zone": z.Owner("@"),
+				"err":  err,
+			}).Error("Failed to load zone records")
+			continue
+		}
+
+		if serial, ok := h.update(z, rrs); ok {
+			log.WithFields(logrus.Fields{
+				"zone":   z.Owner("@"),
+				"serial": serial,
+			}).Info("Zone changed")
+			changed = append(changed, z)
+		}
+	}
+
+	return changed
+}
+
+// notify sends a DNS NOTIFY for the zone to each secondary
+func (h *handler) notify(z *Zone) {
+	if len(h.secondaries) == 0 {
+		return
+	}
+
+	m := new(dns.Msg)
+	m.SetNotify(z.Owner("@"))
+	m.Answer = []dns.RR{h.soa(z, h.serial(z))}
+
+	c := &dns.Client{Net: "udp", Timeout: notifyTimeout}
+	for _, addr := range h.secondaries {
+		resp, _, err := c.Exchange(m, addr)
+		if err == nil && resp.Rcode != dns.RcodeSuccess {
+			err = fmt.Errorf("secondary returned %s", dns.RcodeToString[resp.Rcode])
+		}
+
+		if err != nil {
+			notifiesTotal.WithLabelValues("error").Inc()
+			log.WithFields(logrus.Fields{
+				"zone":      z.Owner("@"),
+				"secondary": addr,
+				"err":       err,
+			}).Error("Failed to send NOTIFY")
+			continue
+		}
+
+		notifiesTotal.WithLabelValues("success").Inc()
+		log.WithFields(logrus.Fields{
+			"zone":      z.Owner("@"),
+			"secondary": addr,
+		}).Debug("Sent NOTIFY")
+	}
+}
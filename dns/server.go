@@ -19,6 +19,9 @@ package dns
 
 import (
 	"context"
+	"fmt"
+	"net/netip"
+	"strings"
 
 	"github.com/miekg/dns"
 	"github.com/ubccr/grendel/logger"
@@ -30,28 +33,137 @@ var log = logger.GetLogger("DNS")
 type Server struct {
 	Address string
 
-	srv *dns.Server
+	srv     *dns.Server
+	tcp     *dns.Server
+	handler *handler
+	ctx     context.Context
+	cancel  context.CancelFunc
 }
 
-func NewServer(db model.DataStore, address string, ttl int) (*Server, error) {
+func NewServer(db model.DataStore, address string, ttl int, zones ...*Zone) (*Server, error) {
 	s := &Server{Address: address}
 
-	s.srv = &dns.Server{Addr: address, Net: "udp"}
-	h, err := NewHandler(db, uint32(ttl))
+	h, err := NewHandler(db, uint32(ttl), zones...)
 	if err != nil {
 		return nil, err
 	}
 
-	s.srv.Handler = h
+	s.srv = &dns.Server{Addr: address, Net: "udp", Handler: h}
+	s.tcp = &dns.Server{Addr: address, Net: "tcp", Handler: h}
+	s.handler = h
+	s.ctx, s.cancel = context.WithCancel(context.Background())
 
 	return s, nil
 }
 
+// SetForwarders forwards queries for names outside the configured zones which
+// are not in the datastore to the upstream resolvers. Responses are cached
+// for their TTL in a cache of at most cacheSize entries.
+func (s *Server) SetForwarders(upstreams []string, cacheSize int) error {
+	f, err := newForwarder(upstreams, cacheSize)
+	if err != nil {
+		return err
+	}
+
+	s.handler.forwarder = f
+	return nil
+}
+
+// SetTransferACL allows zone transfers to clients in the given networks. IP
+// addresses without a prefix length match a single host. Transfers are refused
+// to everyone by default.
+func (s *Server) SetTransferACL(acl []string) error {
+	prefixes, err := parseACL(acl)
+	if err != nil {
+		return fmt.Errorf("Invalid zone transfer ACL entry %w", err)
+	}
+
+	s.handler.transferACL = prefixes
+	return nil
+}
+
+// SetRecursionACL allows clients in the given networks to resolve names through
+// the forwarders. Queries from other clients for names Grendel doesn't own are
+// refused so the server can't be used as an open resolver.
+func (s *Server) SetRecursionACL(acl []string) error {
+	prefixes, err := parseACL(acl)
+	if err != nil {
+		return fmt.Errorf("Invalid recursion ACL entry %w", err)
+	}
+
+	s.handler.recursionACL = prefixes
+	return nil
+}
+
+// parseACL parses a list of networks. IP addresses without a prefix length
+// match a single host.
+func parseACL(acl []string) ([]netip.Prefix, error) {
+	prefixes := make([]netip.Prefix, 0, len(acl))
+	for _, a := range acl {
+		if !strings.Contains(a, "/") {
+			addr, err := netip.ParseAddr(a)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", a, err)
+			}
+			prefixes = append(prefixes, netip.PrefixFrom(addr.Unmap(), addr.Unmap().BitLen()))
+			continue
+		}
+
+		p, err := netip.ParsePrefix(a)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", a, err)
+		}
+		prefixes = append(prefixes, p.Masked())
+	}
+
+	return prefixes, nil
+}
+
+// SetNotify sends a DNS NOTIFY to the secondaries when the records of a zone
+// change
+func (s *Server) SetNotify(secondaries []string) error {
+	addrs := make([]string, 0, len(secondaries))
+	for _, sec := range secondaries {
+		addr, ok := serverAddr(sec)
+		if !ok {
+			return fmt.Errorf("Invalid DNS secondary address: %s", sec)
+		}
+		addrs = append(addrs, addr)
+	}
+
+	s.handler.secondaries = addrs
+	return nil
+}
+
 func (s *Server) Serve() error {
-	log.Infof("Server listening on: %s", s.Address)
-	return s.srv.ListenAndServe()
+	if len(s.handler.zones) > 0 {
+		go s.handler.watch(s.ctx)
+	}
+
+	errs := make(chan error, 2)
+	for _, srv := range []*dns.Server{s.srv, s.tcp} {
+		go func(srv *dns.Server) {
+			errs <- srv.ListenAndServe()
+		}(srv)
+	}
+
+	log.Infof("Server listening on: %s (udp and tcp)", s.Address)
+	for i := 0; i < 2; i++ {
+		if err := <-errs; err != nil {
+			return err
+		}
+	}
+
+	return nil
 }
 
 func (s *Server) Shutdown(ctx context.Context) error {
-	return s.srv.ShutdownContext(ctx)
+	s.cancel()
+
+	tcpErr := s.tcp.ShutdownContext(ctx)
+	if err := s.srv.ShutdownContext(ctx); err != nil {
+		return err
+	}
+
+	return tcpErr
 }
@@ -0,0 +1,262 @@
+// Copyright 2019 Grendel Authors. All rights reserved.
+//
+// This file is part of Grendel.
+//
+// Grendel is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Grendel is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with Grendel. If not, see <https://www.gnu.org/licenses/>.
+
+package dns
+
+import (
+	"crypto/sha256"
+	"net"
+	"net/netip"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/sirupsen/logrus"
+	"github.com/ubccr/grendel/exporter"
+	"github.com/ubccr/grendel/logger"
+)
+
+// transferBatchSize is the number of records sent per message in a zone
+// transfer
+const transferBatchSize = 100
+
+// zoneSerial is the SOA serial of a zone along with a digest of the records
+// it was issued for
+type zoneSerial struct {
+	serial uint32
+	digest [sha256.Size]byte
+}
+
+// serial returns the current SOA serial of the zone
+func (h *handler) serial(z *Zone) uint32 {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	return h.serials[z.Owner("@")].serial
+}
+
+// update bumps the serial of the zone if its records changed since the serial
+// was issued and returns the serial and whether it was bumped
+func (h *handler) update(z *Zone, rrs []dns.RR) (uint32, bool) {
+	hash := sha256.New()
+	for _, rr := range rrs {
+		hash.Write([]byte(rr.String()))
+		hash.Write([]byte{'\n'})
+	}
+	var digest [sha256.Size]byte
+	copy(digest[:], hash.Sum(nil))
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	zs := h.serials[z.Owner("@")]
+	if zs.digest == digest {
+		return zs.serial, false
+	}
+
+	first := zs.digest == [sha256.Size]byte{}
+	zs.digest = digest
+	if first {
+		// Records of a zone which was never synthesized before
+		return zs.serial, false
+	}
+
+	// Serials are seconds since the epoch unless the zone changed more than
+	// once in the same second
+	serial := uint32(time.Now().Unix())
+	if int32(serial-zs.serial) <= 0 {
+		serial = zs.serial + 1
+	}
+	zs.serial = serial
+
+	return zs.serial, true
+}
+
+// soa returns the SOA record of the zone
+func (h *handler) soa(z *Zone, serial uint32) dns.RR {
+	return exporter.SOA(z.Owner("@"), z.NS, h.ttl, serial)
+}
+
+// ns returns the NS records of the zone
+func (h *handler) ns(z *Zone) []dns.RR {
+	return exporter.NS(z.Owner("@"), z.NS, h.ttl)
+}
+
+// zoneRecords synthesizes the records of the zone from the datastore, not
+// including the SOA record. Forward zones have the A, AAAA and CNAME records
+// of all names under the zone along with the static records of the zone.
+// Reverse zones have the PTR records of all addresses in the prefix.
+func (h *handler) zoneRecords(z *Zone) ([]dns.RR, error) {
+	hosts, err := h.db.Hosts()
+	if err != nil {
+		return nil, err
+	}
+
+	if z.IsReverse() {
+		return append(h.ns(z), exporter.ReverseZoneRecords(z.Prefix, hosts, h.ttl)...), nil
+	}
+
+	apex := z.Owner("@")
+	rrs := h.ns(z)
+	seen := make(map[string]struct{})
+	add := func(rr dns.RR) {
+		key := rr.String()
+		if _, ok := seen[key]; ok {
+			return
+		}
+		seen[key] = struct{}{}
+		rrs = append(rrs, rr)
+	}
+
+	for _, rr := range exporter.ZoneRecords(apex, hosts, h.ttl) {
+		add(rr)
+	}
+
+	for _, rr := range z.Records(h.ttl) {
+		if dns.IsSubDomain(apex, rr.Header().Name) {
+			add(rr)
+		}
+	}
+
+	return rrs, nil
+}
+
+// transfer answers AXFR and IXFR requests for a zone from clients allowed by
+// the transfer ACL. Incremental transfers are answered with the full zone
+// unless the client is up to date.
+func (h *handler) transfer(w dns.ResponseWriter, r *dns.Msg) {
+	qname := h.Name(r)
+	qtype := h.QType(r)
+	_, udp := w.RemoteAddr().(*net.UDPAddr)
+
+	logger := log.WithFields(logrus.Fields{
+		"zone":         qname,
+		"qtype":        dns.TypeToString[qtype],
+		logger.FieldIP: w.RemoteAddr().String(),
+	})
+
+	refuse := func(rcode int) {
+		m := new(dns.Msg)
+		m.SetRcode(r, rcode)
+		observeTransfer(qtype, m)
+		w.WriteMsg(m)
+	}
+
+	z := h.apex(qname)
+	if z == nil {
+		logger.Warn("Zone transfer requested for unknown zone")
+		refuse(dns.RcodeNotAuth)
+		return
+	}
+
+	if !h.allowTransfer(w.RemoteAddr()) {
+		logger.Warn("Zone transfer refused by ACL")
+		refuse(dns.RcodeRefused)
+		return
+	}
+
+	if qtype == dns.TypeAXFR && udp {
+		// AXFR is only supported over TCP
+		refuse(dns.RcodeRefused)
+		return
+	}
+
+	records, err := h.zoneRecords(z)
+	if err != nil {
+		logger.WithField("err", err).Error("Failed to load zone records")
+		refuse(dns.RcodeServerFailure)
+		return
+	}
+
+	serial, _ := h.update(z, records)
+	soa := h.soa(z, serial)
+
+	if qtype == dns.TypeIXFR {
+		// A single SOA tells the client it is up to date or, over UDP, to
+		// retry the transfer over TCP
+		if udp || (len(r.Ns) > 0 && r.Ns[0].Header().Rrtype == dns.TypeSOA && int32(serial-r.Ns[0].(*dns.SOA).Serial) <= 0) {
+			m := new(dns.Msg)
+			m.SetReply(r)
+			m.Authoritative = true
+			m.Answer = []dns.RR{soa}
+			observeTransfer(qtype, m)
+			w.WriteMsg(m)
+			return
+		}
+	}
+
+	logger.WithField("serial", serial).Info("Sending zone transfer")
+
+	rrs := make([]dns.RR, 0, len(records)+2)
+	rrs = append(rrs, soa)
+	rrs = append(rrs, records...)
+	rrs = append(rrs, soa)
+
+	ch := make(chan *dns.Envelope)
+	go func() {
+		defer close(ch)
+		for i := 0; i < len(rrs); i += transferBatchSize {
+			end := i + transferBatchSize
+			if end > len(rrs) {
+				end = len(rrs)
+			}
+			ch <- &dns.Envelope{RR: rrs[i:end]}
+		}
+	}()
+
+	tr := new(dns.Transfer)
+	err = tr.Out(w, r, ch)
+	for range ch {
+		// Drain the remaining batches if the client went away
+	}
+
+	m := new(dns.Msg)
+	m.SetReply(r)
+	if err != nil {
+		logger.WithField("err", err).Error("Failed sending zone transfer")
+		m.Rcode = dns.RcodeServerFailure
+	}
+	observeTransfer(qtype, m)
+}
+
+// allowTransfer returns true if the transfer ACL allows the address
+func (h *handler) allowTransfer(addr net.Addr) bool {
+	return aclContains(h.transferACL, addr)
+}
+
+// aclContains returns true if the address is in one of the networks
+func aclContains(acl []netip.Prefix, addr net.Addr) bool {
+	var ip net.IP
+	switch a := addr.(type) {
+	case *net.TCPAddr:
+		ip = a.IP
+	case *net.UDPAddr:
+		ip = a.IP
+	}
+
+	ipaddr, ok := netip.AddrFromSlice(ip)
+	if !ok {
+		return false
+	}
+
+	for _, p := range acl {
+		if p.Contains(ipaddr.Unmap()) {
+			return true
+		}
+	}
+
+	return false
+}
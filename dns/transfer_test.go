@@ -0,0 +1,278 @@
+// Copyright 2019 Grendel Authors. All rights reserved.
+//
+// This file is part of Grendel.
+//
+// Grendel is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Grendel is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with Grendel. If not, see <https://www.gnu.org/licenses/>.
+
+package dns
+
+import (
+	"context"
+	"net"
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+	"github.com/ubccr/grendel/model"
+	"github.com/ubccr/grendel/nodeset"
+)
+
+// serveTCP serves the handler over TCP on a random local port
+func serveTCP(t *testing.T, h *handler) string {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+
+	srv := &dns.Server{Listener: l, Handler: h}
+	go srv.ActivateAndServe()
+	t.Cleanup(func() { srv.Shutdown() })
+
+	return l.Addr().String()
+}
+
+func axfr(t *testing.T, addr, zone string) ([]dns.RR, error) {
+	m := new(dns.Msg)
+	m.SetAxfr(zone)
+
+	tr := new(dns.Transfer)
+	ch, err := tr.In(m, addr)
+	if err != nil {
+		return nil, err
+	}
+
+	var rrs []dns.RR
+	for env := range ch {
+		if env.Error != nil {
+			return nil, env.Error
+		}
+		rrs = append(rrs, env.RR...)
+	}
+
+	return rrs, nil
+}
+
+func TestNewReverseZone(t *testing.T) {
+	assert := assert.New(t)
+
+	for _, test := range []struct {
+		prefix string
+		name   string
+	}{
+		{"10.0.0.0/8", "10.in-addr.arpa."},
+		{"10.1.2.0/24", "2.1.10.in-addr.arpa."},
+		{"10.1.2.3/16", "1.10.in-addr.arpa."},
+		{"2001:db8::/32", "8.b.d.0.1.0.0.2.ip6.arpa."},
+		{"2001:db8:ab00::/40", "b.a.8.b.d.0.1.0.0.2.ip6.arpa."},
+	} {
+		z, err := NewReverseZone(netip.MustParsePrefix(test.prefix))
+		if assert.NoError(err, test.prefix) {
+			assert.Equal(test.name, z.Owner("@"))
+			assert.True(z.IsReverse())
+		}
+	}
+
+	_, err := NewReverseZone(netip.MustParsePrefix("10.0.0.0/23"))
+	assert.Error(err)
+	_, err = NewReverseZone(netip.MustParsePrefix("2001:db8::/34"))
+	assert.Error(err)
+}
+
+func TestAXFR(t *testing.T) {
+	assert := assert.New(t)
+
+	db := newTestDB(t)
+	defer db.Close()
+
+	host := newTestHost("tux-01", "tux-01.compute.local", "10.0.0.1/24")
+	host.Interfaces[0].BMC = false
+	host.Interfaces[0].Addrs = []netip.Prefix{netip.MustParsePrefix("2001:db8::1/64")}
+	host.Aliases = []string{"login.compute.local"}
+	assert.NoError(db.StoreHosts(model.HostList{
+		host,
+		newTestHost("tux-02", "tux-02.compute.local", "10.0.0.2/24"),
+		newTestHost("ext-01", "ext-01.example.org", "10.1.0.1/24"),
+	}))
+
+	forward := &Zone{
+		Name: "compute.local",
+		NS:   []string{"ns1.compute.local", "ns2.compute.local"},
+		TXT:  []TXTRecord{{Name: "@", Text: []string{"cluster=tux"}}},
+	}
+	reverse, err := NewReverseZone(netip.MustParsePrefix("10.0.0.0/24"))
+	assert.NoError(err)
+
+	h, err := NewHandler(db, 300, forward, reverse)
+	assert.NoError(err)
+	h.transferACL = []netip.Prefix{netip.MustParsePrefix("127.0.0.0/8")}
+	addr := serveTCP(t, h)
+
+	rrs, err := axfr(t, addr, "compute.local.")
+	if !assert.NoError(err) || !assert.True(len(rrs) > 2) {
+		t.FailNow()
+	}
+
+	soa, ok := rrs[0].(*dns.SOA)
+	if assert.True(ok) {
+		assert.Equal("ns1.compute.local.", soa.Ns)
+		assert.Equal("hostmaster.compute.local.", soa.Mbox)
+	}
+	assert.Equal(rrs[0].String(), rrs[len(rrs)-1].String())
+	zoneSize := len(rrs)
+
+	records := make([]string, 0)
+	for _, rr := range rrs[1 : len(rrs)-1] {
+		records = append(records, rr.String())
+	}
+	assert.ElementsMatch([]string{
+		"compute.local.\t300\tIN\tNS\tns1.compute.local.",
+		"compute.local.\t300\tIN\tNS\tns2.compute.local.",
+		"tux-01.compute.local.\t300\tIN\tA\t10.0.0.1",
+		"tux-01.compute.local.\t300\tIN\tAAAA\t2001:db8::1",
+		"tux-02.compute.local.\t300\tIN\tA\t10.0.0.2",
+		"login.compute.local.\t300\tIN\tCNAME\ttux-01.compute.local.",
+		"compute.local.\t300\tIN\tTXT\t\"cluster=tux\"",
+	}, records)
+
+	rrs, err = axfr(t, addr, "0.0.10.in-addr.arpa.")
+	if assert.NoError(err) && assert.Equal(4, len(rrs)) {
+		assert.Equal("1.0.0.10.in-addr.arpa.\t300\tIN\tPTR\ttux-01.compute.local.", rrs[1].String())
+		assert.Equal("2.0.0.10.in-addr.arpa.\t300\tIN\tPTR\ttux-02.compute.local.", rrs[2].String())
+	}
+
+	// Clients which are up to date get a single SOA for IXFR
+	m := new(dns.Msg)
+	m.SetIxfr("compute.local.", soa.Serial, soa.Ns, soa.Mbox)
+	c := &dns.Client{Net: "tcp"}
+	resp, _, err := c.Exchange(m, addr)
+	if assert.NoError(err) && assert.Equal(1, len(resp.Answer)) {
+		assert.Equal(soa.Serial, resp.Answer[0].(*dns.SOA).Serial)
+	}
+
+	// Older clients get the full zone
+	m.SetIxfr("compute.local.", soa.Serial-1, soa.Ns, soa.Mbox)
+	resp, _, err = c.Exchange(m, addr)
+	if assert.NoError(err) {
+		assert.Equal(zoneSize, len(resp.Answer))
+	}
+
+	_, err = axfr(t, addr, "example.org.")
+	assert.Error(err)
+
+	// SOA and NS are served at the apex and with negative answers
+	q := query(t, h, "compute.local.", dns.TypeSOA)
+	if assert.Equal(1, len(q.Answer)) {
+		assert.Equal(soa.Serial, q.Answer[0].(*dns.SOA).Serial)
+	}
+	q = query(t, h, "compute.local.", dns.TypeNS)
+	assert.Equal(2, len(q.Answer))
+	q = query(t, h, "tux-03.compute.local.", dns.TypeA)
+	assert.Equal(dns.RcodeNameError, q.Rcode)
+	assert.True(q.Authoritative)
+	assert.Equal(1, len(q.Ns))
+}
+
+func TestTransferRefused(t *testing.T) {
+	assert := assert.New(t)
+
+	db := newTestDB(t)
+	defer db.Close()
+
+	h, err := NewHandler(db, 300, &Zone{Name: "compute.local"})
+	assert.NoError(err)
+	addr := serveTCP(t, h)
+
+	m := new(dns.Msg)
+	m.SetAxfr("compute.local.")
+	c := &dns.Client{Net: "tcp"}
+	resp, _, err := c.Exchange(m, addr)
+	if assert.NoError(err) {
+		assert.Equal(dns.RcodeRefused, resp.Rcode)
+		assert.Equal(0, len(resp.Answer))
+	}
+}
+
+func TestNotify(t *testing.T) {
+	assert := assert.New(t)
+
+	db := newTestDB(t)
+	defer db.Close()
+
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if !assert.NoError(err) {
+		t.FailNow()
+	}
+
+	notifies := make(chan *dns.Msg, 10)
+	secondary := &dns.Server{PacketConn: pc, Handler: dns.HandlerFunc(func(w dns.ResponseWriter, r *dns.Msg) {
+		notifies <- r
+		m := new(dns.Msg)
+		m.SetReply(r)
+		w.WriteMsg(m)
+	})}
+	go secondary.ActivateAndServe()
+	defer secondary.Shutdown()
+
+	defer func(d time.Duration) { notifyDelay = d }(notifyDelay)
+	notifyDelay = 10 * time.Millisecond
+
+	h, err := NewHandler(db, 300, &Zone{Name: "compute.local"})
+	assert.NoError(err)
+	h.secondaries = []string{pc.LocalAddr().String()}
+	initial := h.serial(h.zones[0])
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go h.watch(ctx)
+
+	// Wait for the watcher to subscribe
+	time.Sleep(50 * time.Millisecond)
+
+	audited := model.NewAuditedStore(db, "test", "")
+	assert.NoError(audited.StoreHosts(model.HostList{newTestHost("tux-01", "tux-01.compute.local", "10.0.0.1/24")}))
+
+	var serial uint32
+	select {
+	case m := <-notifies:
+		assert.Equal(dns.OpcodeNotify, m.Opcode)
+		assert.Equal("compute.local.", m.Question[0].Name)
+		if assert.Equal(1, len(m.Answer)) {
+			serial = m.Answer[0].(*dns.SOA).Serial
+			assert.True(int32(serial-initial) > 0)
+		}
+	case <-time.After(5 * time.Second):
+		assert.Fail("no NOTIFY received after StoreHosts")
+	}
+
+	ns, err := nodeset.NewNodeSet("tux-01")
+	assert.NoError(err)
+	assert.NoError(audited.DeleteHosts(ns))
+
+	select {
+	case m := <-notifies:
+		assert.True(int32(m.Answer[0].(*dns.SOA).Serial-serial) > 0)
+	case <-time.After(5 * time.Second):
+		assert.Fail("no NOTIFY received after DeleteHosts")
+	}
+
+	// Changes outside the zone don't send a NOTIFY
+	assert.NoError(audited.StoreHosts(model.HostList{newTestHost("ext-01", "ext-01.example.org", "10.1.0.1/24")}))
+	select {
+	case <-notifies:
+		assert.Fail("NOTIFY sent for change outside the zone")
+	case <-time.After(200 * time.Millisecond):
+	}
+}
@@ -0,0 +1,158 @@
+// Copyright 2019 Grendel Authors. All rights reserved.
+//
+// This file is part of Grendel.
+//
+// Grendel is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Grendel is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with Grendel. If not, see <https://www.gnu.org/licenses/>.
+package dns
+
+import (
+	"fmt"
+	"net/netip"
+	"strings"
+
+	"github.com/miekg/dns"
+	"github.com/spf13/viper"
+	"github.com/ubccr/grendel/util"
+)
+
+// Zone is a DNS domain with static records configured in dns.zones or a
+// reverse zone configured in dns.reverse_zones
+type Zone struct {
+	Name string
+
+	// NS are the authoritative name servers of the zone. The first one is the
+	// primary in the SOA record. Defaults to dns.nameservers.
+	NS  []string
+	SRV []SRVRecord
+	TXT []TXTRecord
+
+	// Prefix is the network of a reverse zone
+	Prefix netip.Prefix `mapstructure:"-"`
+}
+
+// SRVRecord is a SRV record of a zone. Name is relative to the zone unless it
+// ends with a dot.
+type SRVRecord struct {
+	Name     string
+	Target   string
+	Port     uint16
+	Priority uint16
+	Weight   uint16
+}
+
+// TXTRecord is a TXT record of a zone. Name is relative to the zone unless it
+// ends with a dot, "@" is the zone itself.
+type TXTRecord struct {
+	Name string
+	Text []string
+}
+
+// ParseZones returns the zones configured in dns.zones followed by the reverse
+// zones configured in dns.reverse_zones
+func ParseZones() ([]*Zone, error) {
+	var zones []*Zone
+
+	err := viper.UnmarshalKey("dns.zones", &zones)
+	if err != nil {
+		return nil, fmt.Errorf("Failed parsing dns.zones config: %w", err)
+	}
+
+	for _, z := range zones {
+		if z.Name == "" {
+			return nil, fmt.Errorf("Failed parsing dns.zones config. Missing zone name")
+		}
+
+		for _, srv := range z.SRV {
+			if srv.Name == "" || srv.Target == "" {
+				return nil, fmt.Errorf("Failed parsing dns.zones config. SRV record in zone %s requires name and target", z.Name)
+			}
+		}
+	}
+
+	for _, r := range viper.GetStringSlice("dns.reverse_zones") {
+		prefix, err := netip.ParsePrefix(r)
+		if err != nil {
+			return nil, fmt.Errorf("Failed parsing dns.reverse_zones config: %w", err)
+		}
+
+		z, err := NewReverseZone(prefix)
+		if err != nil {
+			return nil, fmt.Errorf("Failed parsing dns.reverse_zones config: %w", err)
+		}
+
+		zones = append(zones, z)
+	}
+
+	nameservers := viper.GetStringSlice("dns.nameservers")
+	for _, z := range zones {
+		if len(z.NS) == 0 {
+			z.NS = nameservers
+		}
+	}
+
+	return zones, nil
+}
+
+// NewReverseZone returns the in-addr.arpa or ip6.arpa zone of the prefix. The
+// prefix length must fall on an octet boundary for IPv4 and a nibble boundary
+// for IPv6.
+func NewReverseZone(prefix netip.Prefix) (*Zone, error) {
+	name, err := util.ReverseZone(prefix)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Zone{Name: name, Prefix: prefix.Masked()}, nil
+}
+
+// IsReverse returns true if the zone is a reverse zone
+func (z *Zone) IsReverse() bool {
+	return z.Prefix.IsValid()
+}
+
+// Owner returns the fully qualified owner name of a record name in the zone
+func (z *Zone) Owner(name string) string {
+	switch {
+	case name == "" || name == "@":
+		return util.Normalize(z.Name)
+	case strings.HasSuffix(name, "."):
+		return util.Normalize(name)
+	}
+
+	return util.Normalize(name + "." + z.Name)
+}
+
+// Records returns the resource records of the zone
+func (z *Zone) Records(ttl uint32) []dns.RR {
+	rrs := make([]dns.RR, 0, len(z.SRV)+len(z.TXT))
+
+	for _, srv := range z.SRV {
+		r := new(dns.SRV)
+		r.Hdr = dns.RR_Header{Name: z.Owner(srv.Name), Rrtype: dns.TypeSRV, Class: dns.ClassINET, Ttl: ttl}
+		r.Target = dns.Fqdn(srv.Target)
+		r.Port = srv.Port
+		r.Priority = srv.Priority
+		r.Weight = srv.Weight
+		rrs = append(rrs, r)
+	}
+
+	for _, txt := range z.TXT {
+		r := new(dns.TXT)
+		r.Hdr = dns.RR_Header{Name: z.Owner(txt.Name), Rrtype: dns.TypeTXT, Class: dns.ClassINET, Ttl: ttl}
+		r.Txt = txt.Text
+		rrs = append(rrs, r)
+	}
+
+	return rrs
+}
@@ -0,0 +1,322 @@
+// Copyright 2019 Grendel Authors. All rights reserved.
+//
+// This file is part of Grendel.
+//
+// Grendel is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Grendel is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with Grendel. If not, see <https://www.gnu.org/licenses/>.
+
+// Package events provides a bus for publishing provisioning events from the
+// grendel services to subscribers such as the API event stream
+package events
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ubccr/grendel/logger"
+	"github.com/ubccr/grendel/nodeset"
+)
+
+const (
+	TypeDHCPOffer          = "dhcp.offer"
+	TypeDHCPAck            = "dhcp.ack"
+	TypeDHCPNak            = "dhcp.nak"
+	TypePXEAck             = "pxe.ack"
+	TypeTFTPFirmware       = "tftp.firmware"
+	TypeTFTPKernel         = "tftp.kernel"
+	TypeTFTPInitrd         = "tftp.initrd"
+	TypeProvisionFirmware  = "provision.firmware"
+	TypeProvisionIpxe      = "provision.ipxe"
+	TypeProvisionKickstart = "provision.kickstart"
+	TypeProvisionFile      = "provision.file"
+	TypeProvisionComplete  = "provision.complete"
+	TypeProvisionStuck     = "provision.stuck"
+	TypeHostChanged        = "host.changed"
+	TypeHostUnregistered   = "host.unregistered"
+
+	// DefaultBufferSize is the number of events buffered for each subscriber.
+	// Events published to a subscriber with a full buffer are dropped.
+	DefaultBufferSize = 256
+)
+
+var (
+	log = logger.GetLogger("EVENTS")
+
+	// defaultBus is the global event bus used by the grendel services
+	defaultBus = NewBus()
+)
+
+// Event is a single provisioning event
+type Event struct {
+	ID      uint64    `json:"id"`
+	Time    time.Time `json:"time"`
+	Service string    `json:"service"`
+	Type    string    `json:"type"`
+	Host    string    `json:"host,omitempty"`
+	MAC     string    `json:"mac,omitempty"`
+	IP      string    `json:"ip,omitempty"`
+	Tags    []string  `json:"tags,omitempty"`
+	Message string    `json:"message,omitempty"`
+}
+
+// Filter selects the events delivered to a subscriber. Empty fields match all
+// events.
+type Filter struct {
+	// NodeSet matches events for hosts in the nodeset
+	NodeSet *nodeset.NodeSet
+
+	// Tags matches events for hosts with any of the tags
+	Tags []string
+
+	// Types matches events with the given type or type prefix, for example
+	// "dhcp" matches "dhcp.offer" and "dhcp.ack"
+	Types []string
+
+	hosts map[string]struct{}
+}
+
+// compile expands the nodeset of the filter for fast lookups
+func (f *Filter) compile() {
+	if f == nil || f.NodeSet == nil || f.hosts != nil {
+		return
+	}
+
+	f.hosts = make(map[string]struct{}, f.NodeSet.Len())
+	it := f.NodeSet.Iterator()
+	for it.Next() {
+		f.hosts[it.Value()] = struct{}{}
+	}
+}
+
+// Match returns true if the event is selected by the filter
+func (f *Filter) Match(e *Event) bool {
+	if f == nil {
+		return true
+	}
+
+	if f.NodeSet != nil && f.NodeSet.Len() > 0 {
+		f.compile()
+		if _, ok := f.hosts[e.Host]; !ok {
+			return false
+		}
+	}
+
+	if len(f.Tags) > 0 && !hasAny(e.Tags, f.Tags) {
+		return false
+	}
+
+	if len(f.Types) > 0 {
+		found := false
+		for _, t := range f.Types {
+			if e.Type == t || strings.HasPrefix(e.Type, t+".") {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+
+	return true
+}
+
+func hasAny(a, b []string) bool {
+	for _, x := range a {
+		for _, y := range b {
+			if x == y {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// Subscription receives the events matching its filter on C until Close is
+// called
+type Subscription struct {
+	C <-chan *Event
+
+	c      chan *Event
+	filter *Filter
+	bus    *Bus
+	once   sync.Once
+
+	// Lossless subscriptions queue events which don't fit in c. pump sends
+	// them on c in order.
+	mu     sync.Mutex
+	queue  []*Event
+	notify chan struct{}
+	done   chan struct{}
+}
+
+// Close removes the subscription from the bus and closes C. Events still
+// queued by a lossless subscription are discarded.
+func (s *Subscription) Close() {
+	s.once.Do(func() {
+		s.bus.mu.Lock()
+		delete(s.bus.subs, s)
+		s.bus.mu.Unlock()
+
+		if s.done != nil {
+			close(s.done)
+			return
+		}
+		close(s.c)
+	})
+}
+
+// enqueue adds the event to the queue of a lossless subscription
+func (s *Subscription) enqueue(e *Event) {
+	s.mu.Lock()
+	s.queue = append(s.queue, e)
+	s.mu.Unlock()
+
+	select {
+	case s.notify <- struct{}{}:
+	default:
+	}
+}
+
+// pump sends the queued events of a lossless subscription on c until it is
+// closed
+func (s *Subscription) pump() {
+	defer close(s.c)
+
+	for {
+		select {
+		case <-s.done:
+			return
+		case <-s.notify:
+		}
+
+		s.mu.Lock()
+		queue := s.queue
+		s.queue = nil
+		s.mu.Unlock()
+
+		for _, e := range queue {
+			select {
+			case s.c <- e:
+			case <-s.done:
+				return
+			}
+		}
+	}
+}
+
+// Bus delivers published events to subscribers. Publishing never blocks, slow
+// subscribers miss events instead of stalling the services, except lossless
+// subscribers whose events are queued until they are received.
+type Bus struct {
+	mu   sync.RWMutex
+	seq  uint64
+	subs map[*Subscription]struct{}
+}
+
+// NewBus returns a new event bus
+func NewBus() *Bus {
+	return &Bus{subs: make(map[*Subscription]struct{})}
+}
+
+// Subscribe returns a new subscription for events matching filter which
+// buffers up to size events. A size of 0 uses DefaultBufferSize.
+func (b *Bus) Subscribe(filter *Filter, size int) *Subscription {
+	if size <= 0 {
+		size = DefaultBufferSize
+	}
+
+	c := make(chan *Event, size)
+	s := &Subscription{C: c, c: c, filter: filter, bus: b}
+	filter.compile()
+
+	b.mu.Lock()
+	b.subs[s] = struct{}{}
+	b.mu.Unlock()
+
+	return s
+}
+
+// SubscribeLossless returns a new subscription for events matching filter
+// which never drops events. Events the subscriber hasn't received yet are
+// queued without limit, so it must keep up with the services on average. It
+// is meant for subscribers which keep state from the events, such as the
+// provisioning tracker.
+func (b *Bus) SubscribeLossless(filter *Filter) *Subscription {
+	c := make(chan *Event, DefaultBufferSize)
+	s := &Subscription{
+		C:      c,
+		c:      c,
+		filter: filter,
+		bus:    b,
+		notify: make(chan struct{}, 1),
+		done:   make(chan struct{}),
+	}
+	filter.compile()
+
+	go s.pump()
+
+	b.mu.Lock()
+	b.subs[s] = struct{}{}
+	b.mu.Unlock()
+
+	return s
+}
+
+// Publish sets the ID and time of the event and delivers it to all matching
+// subscribers
+func (b *Bus) Publish(e *Event) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.seq++
+	e.ID = b.seq
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+
+	for s := range b.subs {
+		if !s.filter.Match(e) {
+			continue
+		}
+
+		if s.done != nil {
+			s.enqueue(e)
+			continue
+		}
+
+		select {
+		case s.c <- e:
+		default:
+			log.Debugf("Dropping event %d for slow subscriber", e.ID)
+		}
+	}
+}
+
+// Subscribe returns a new subscription to the global event bus
+func Subscribe(filter *Filter, size int) *Subscription {
+	return defaultBus.Subscribe(filter, size)
+}
+
+// SubscribeLossless returns a new lossless subscription to the global event
+// bus
+func SubscribeLossless(filter *Filter) *Subscription {
+	return defaultBus.SubscribeLossless(filter)
+}
+
+// Publish publishes the event on the global event bus
+func Publish(e *Event) {
+	defaultBus.Publish(e)
+}
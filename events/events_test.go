@@ -0,0 +1,108 @@
+// Copyright 2019 Grendel Authors. All rights reserved.
+//
+// This file is part of Grendel.
+//
+// Grendel is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Grendel is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with Grendel. If not, see <https://www.gnu.org/licenses/>.
+
+package events
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/ubccr/grendel/nodeset"
+)
+
+func TestBusFilter(t *testing.T) {
+	assert := assert.New(t)
+
+	ns, err := nodeset.NewNodeSet("cpn-[01-04]")
+	if assert.NoError(err) {
+		bus := NewBus()
+		all := bus.Subscribe(nil, 0)
+		defer all.Close()
+		hosts := bus.Subscribe(&Filter{NodeSet: ns}, 0)
+		defer hosts.Close()
+		tags := bus.Subscribe(&Filter{Tags: []string{"k16"}, Types: []string{"dhcp"}}, 0)
+		defer tags.Close()
+
+		bus.Publish(&Event{Service: "dhcp", Type: TypeDHCPOffer, Host: "cpn-02", Tags: []string{"k16"}})
+		bus.Publish(&Event{Service: "tftp", Type: TypeTFTPFirmware, Host: "cpn-09", Tags: []string{"k16"}})
+		bus.Publish(&Event{Service: "dhcp", Type: TypeDHCPAck, Host: "cpn-03"})
+
+		assert.Len(all.C, 3)
+		assert.Len(hosts.C, 2)
+		assert.Len(tags.C, 1)
+
+		e := <-all.C
+		assert.Equal(uint64(1), e.ID)
+		assert.False(e.Time.IsZero())
+
+		e = <-tags.C
+		assert.Equal("cpn-02", e.Host)
+	}
+}
+
+func TestBusSlowSubscriber(t *testing.T) {
+	assert := assert.New(t)
+
+	bus := NewBus()
+	sub := bus.Subscribe(nil, 0)
+
+	for i := 0; i < DefaultBufferSize+10; i++ {
+		bus.Publish(&Event{Service: "dhcp", Type: TypeDHCPOffer})
+	}
+
+	assert.Len(sub.C, DefaultBufferSize)
+
+	sub.Close()
+	sub.Close()
+	bus.Publish(&Event{Service: "dhcp", Type: TypeDHCPOffer})
+
+	n := 0
+	for range sub.C {
+		n++
+	}
+	assert.Equal(DefaultBufferSize, n)
+}
+
+func TestBusLosslessSubscriber(t *testing.T) {
+	assert := assert.New(t)
+
+	bus := NewBus()
+	sub := bus.SubscribeLossless(&Filter{Types: []string{"dhcp"}})
+
+	total := DefaultBufferSize * 10
+	for i := 0; i < total; i++ {
+		bus.Publish(&Event{Service: "dhcp", Type: TypeDHCPOffer})
+		bus.Publish(&Event{Service: "tftp", Type: TypeTFTPKernel})
+	}
+
+	// Every matching event is delivered in order
+	var last uint64
+	for i := 0; i < total; i++ {
+		e := <-sub.C
+		assert.Equal(TypeDHCPOffer, e.Type)
+		assert.Greater(e.ID, last)
+		last = e.ID
+	}
+
+	sub.Close()
+	sub.Close()
+	bus.Publish(&Event{Service: "dhcp", Type: TypeDHCPOffer})
+
+	for range sub.C {
+		assert.Fail("event delivered after close")
+	}
+}
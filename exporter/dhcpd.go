@@ -0,0 +1,122 @@
+// Copyright 2019 Grendel Authors. All rights reserved.
+//
+// This file is part of Grendel.
+//
+// Grendel is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Grendel is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with Grendel. If not, see <https://www.gnu.org/licenses/>.
+
+package exporter
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/ubccr/grendel/model"
+)
+
+// declName returns the name of the dhcpd host declaration of the interface.
+// Names are unique per host: the boot interface uses the host name and the
+// other interfaces append their interface name or index.
+func declName(host *model.Host, i int, nic *model.NetInterface) string {
+	switch {
+	case nic == host.BootInterface():
+		return host.Name
+	case nic.Name != "":
+		return host.Name + "-" + nic.Name
+	}
+
+	return fmt.Sprintf("%s-%d", host.Name, i)
+}
+
+// exportDhcpd writes dhcpd.conf host declarations with a fixed address for
+// each interface with a MAC and an IPv4 address
+func exportDhcpd(w io.Writer, hosts model.HostList, opts *Options) error {
+	for _, host := range hosts {
+		for i, nic := range host.Interfaces {
+			ip := nic.IPv4()
+			if len(nic.MAC) == 0 || !ip.IsValid() {
+				continue
+			}
+
+			var b strings.Builder
+			fmt.Fprintf(&b, "host %s {\n", declName(host, i, nic))
+			fmt.Fprintf(&b, "\thardware ethernet %s;\n", nic.MAC)
+			fmt.Fprintf(&b, "\tfixed-address %s;\n", ip.Addr())
+			if name := hostname(host, nic); name != "" {
+				fmt.Fprintf(&b, "\toption host-name \"%s\";\n", name)
+			}
+			b.WriteString("}\n\n")
+
+			if _, err := io.WriteString(w, b.String()); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// exportDnsmasq writes dnsmasq dhcp-host entries for each interface with a MAC
+// address followed by host-record and cname entries for DNS
+func exportDnsmasq(w io.Writer, hosts model.HostList, opts *Options) error {
+	var dhcp, records strings.Builder
+
+	for _, host := range hosts {
+		for _, nic := range host.Interfaces {
+			name := hostname(host, nic)
+			list := addrs(nic)
+
+			if len(nic.MAC) > 0 && len(list) > 0 {
+				fields := []string{nic.MAC.String()}
+				for _, addr := range list {
+					if addr.Is4() {
+						fields = append(fields, addr.String())
+					} else {
+						fields = append(fields, "["+addr.String()+"]")
+					}
+				}
+				if name != "" {
+					fields = append(fields, shortname(name))
+				}
+				fmt.Fprintf(&dhcp, "dhcp-host=%s\n", strings.Join(fields, ","))
+			}
+
+			if nic.FQDN == "" || len(list) == 0 {
+				continue
+			}
+
+			fields := []string{name}
+			if shortname(name) != name {
+				fields = append(fields, shortname(name))
+			}
+			for _, addr := range list {
+				fields = append(fields, addr.String())
+			}
+			fmt.Fprintf(&records, "host-record=%s\n", strings.Join(fields, ","))
+		}
+
+		aliases := host.Aliases
+		for _, nic := range host.Interfaces {
+			aliases = append(aliases, nic.Aliases...)
+		}
+		for _, alias := range aliases {
+			if target := host.AliasTarget(alias); target != "" {
+				fmt.Fprintf(&records, "cname=%s,%s\n", strings.TrimSuffix(alias, "."), strings.TrimSuffix(target, "."))
+			}
+		}
+	}
+
+	_, err := io.WriteString(w, dhcp.String()+records.String())
+	return err
+}
@@ -0,0 +1,148 @@
+// Copyright 2019 Grendel Authors. All rights reserved.
+//
+// This file is part of Grendel.
+//
+// Grendel is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Grendel is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with Grendel. If not, see <https://www.gnu.org/licenses/>.
+
+// Package exporter renders hosts into configuration file formats of other
+// systems such as BIND zone files, /etc/hosts, ethers, dhcpd.conf and dnsmasq
+package exporter
+
+import (
+	"fmt"
+	"io"
+	"net/netip"
+	"strings"
+
+	"github.com/ubccr/grendel/model"
+)
+
+// Format is an export file format
+type Format string
+
+const (
+	FormatZone             Format = "zone"
+	FormatReverseZone      Format = "reverse-zone"
+	FormatHosts            Format = "hosts"
+	FormatEthers           Format = "ethers"
+	FormatDhcpd            Format = "dhcpd"
+	FormatDnsmasq          Format = "dnsmasq"
+	FormatCSV              Format = "csv"
+	FormatAnsibleInventory Format = "ansible-inventory"
+
+	// DefaultTTL is the TTL of zone records when none is given
+	DefaultTTL = 86400
+)
+
+// Options configures zone exports. Other formats ignore them.
+type Options struct {
+	// Zone is the origin of zone exports
+	Zone string
+
+	// Network is the prefix of reverse zone exports
+	Network netip.Prefix
+
+	// NS are the name servers of the zone. The first one is the primary in
+	// the SOA record. Required for zone exports.
+	NS []string
+
+	// TTL of the zone records. Defaults to DefaultTTL.
+	TTL uint32
+
+	// Serial of the SOA record. Defaults to the current time.
+	Serial uint32
+}
+
+type exportFunc func(w io.Writer, hosts model.HostList, opts *Options) error
+
+var exporters = map[Format]exportFunc{
+	FormatZone:             exportZone,
+	FormatReverseZone:      exportReverseZone,
+	FormatHosts:            exportHosts,
+	FormatEthers:           exportEthers,
+	FormatDhcpd:            exportDhcpd,
+	FormatDnsmasq:          exportDnsmasq,
+	FormatCSV:              exportCSV,
+	FormatAnsibleInventory: exportAnsibleInventory,
+}
+
+// Formats returns the supported export formats
+func Formats() []Format {
+	return []Format{
+		FormatZone,
+		FormatReverseZone,
+		FormatHosts,
+		FormatEthers,
+		FormatDhcpd,
+		FormatDnsmasq,
+		FormatCSV,
+		FormatAnsibleInventory,
+	}
+}
+
+// ParseFormat returns the export format with the given name
+func ParseFormat(name string) (Format, error) {
+	format := Format(strings.ToLower(name))
+	if _, ok := exporters[format]; !ok {
+		return "", fmt.Errorf("Unsupported export format: %s", name)
+	}
+
+	return format, nil
+}
+
+// Export writes the hosts to w in the given format
+func Export(w io.Writer, format Format, hosts model.HostList, opts Options) error {
+	fn, ok := exporters[format]
+	if !ok {
+		return fmt.Errorf("Unsupported export format: %s", format)
+	}
+
+	if opts.TTL == 0 {
+		opts.TTL = DefaultTTL
+	}
+
+	return fn(w, hosts, &opts)
+}
+
+// hostname returns the name an interface is known by. This is the FQDN of the
+// interface, the host name for a boot interface without one, or an empty
+// string.
+func hostname(host *model.Host, nic *model.NetInterface) string {
+	if nic.FQDN != "" {
+		return strings.TrimSuffix(nic.FQDN, ".")
+	}
+
+	if nic == host.BootInterface() {
+		return host.Name
+	}
+
+	return ""
+}
+
+// shortname returns the first label of the name
+func shortname(name string) string {
+	return strings.SplitN(name, ".", 2)[0]
+}
+
+// addrs returns the addresses of the interface with IPv4 mapped IPv6
+// addresses unmapped
+func addrs(nic *model.NetInterface) []netip.Addr {
+	prefixes := nic.Prefixes()
+	addrs := make([]netip.Addr, 0, len(prefixes))
+	for _, p := range prefixes {
+		addrs = append(addrs, p.Addr().Unmap())
+	}
+
+	return addrs
+}
@@ -0,0 +1,196 @@
+// Copyright 2019 Grendel Authors. All rights reserved.
+//
+// This file is part of Grendel.
+//
+// Grendel is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Grendel is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with Grendel. If not, see <https://www.gnu.org/licenses/>.
+
+package exporter
+
+import (
+	"bytes"
+	"net"
+	"net/netip"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/ubccr/grendel/firmware"
+	"github.com/ubccr/grendel/model"
+)
+
+func testHosts() model.HostList {
+	mac1, _ := net.ParseMAC("00:11:22:33:44:01")
+	bmc1, _ := net.ParseMAC("00:11:22:33:44:a1")
+	mac2, _ := net.ParseMAC("00:11:22:33:44:02")
+
+	return model.HostList{
+		{
+			Name:      "tux-01",
+			Provision: true,
+			BootImage: "centos",
+			Firmware:  firmware.SNPONLY,
+			Tags:      []string{"compute", "k16-gpu"},
+			Aliases:   []string{"login.compute.local"},
+			Interfaces: []*model.NetInterface{
+				{
+					Name:  "eno1",
+					MAC:   mac1,
+					FQDN:  "tux-01.compute.local",
+					IP:    netip.MustParsePrefix("10.0.0.1/24"),
+					Addrs: []netip.Prefix{netip.MustParsePrefix("2001:db8::1/64")},
+				},
+				{
+					Name: "bmc",
+					MAC:  bmc1,
+					FQDN: "tux-01-bmc.mgmt.local",
+					IP:   netip.MustParsePrefix("10.1.0.1/24"),
+					BMC:  true,
+				},
+			},
+		},
+		{
+			Name: "tux-02",
+			Tags: []string{"compute"},
+			Interfaces: []*model.NetInterface{
+				{
+					MAC: mac2,
+					IP:  netip.MustParsePrefix("10.0.0.2/24"),
+					MTU: 9000,
+				},
+			},
+		},
+	}
+}
+
+func export(t *testing.T, format Format, opts Options) string {
+	var buf bytes.Buffer
+	err := Export(&buf, format, testHosts(), opts)
+	if !assert.NoError(t, err, format) {
+		t.FailNow()
+	}
+
+	return buf.String()
+}
+
+func TestExportZone(t *testing.T) {
+	assert := assert.New(t)
+
+	out := export(t, FormatZone, Options{Zone: "compute.local", NS: []string{"ns1.compute.local"}, Serial: 42, TTL: 300})
+	assert.Equal(`$ORIGIN compute.local.
+$TTL 300
+compute.local.	300	IN	SOA	ns1.compute.local. hostmaster.compute.local. 42 3600 600 604800 300
+compute.local.	300	IN	NS	ns1.compute.local.
+tux-01.compute.local.	300	IN	A	10.0.0.1
+tux-01.compute.local.	300	IN	AAAA	2001:db8::1
+login.compute.local.	300	IN	CNAME	tux-01.compute.local.
+`, out)
+
+	out = export(t, FormatReverseZone, Options{Network: netip.MustParsePrefix("10.0.0.0/16"), NS: []string{"ns1.compute.local"}, Serial: 42, TTL: 300})
+	assert.Equal(`$ORIGIN 0.10.in-addr.arpa.
+$TTL 300
+0.10.in-addr.arpa.	300	IN	SOA	ns1.compute.local. hostmaster.0.10.in-addr.arpa. 42 3600 600 604800 300
+0.10.in-addr.arpa.	300	IN	NS	ns1.compute.local.
+1.0.0.10.in-addr.arpa.	300	IN	PTR	tux-01.compute.local.
+`, out)
+
+	ns := []string{"ns1.compute.local"}
+	var buf bytes.Buffer
+	assert.Error(Export(&buf, FormatZone, testHosts(), Options{NS: ns}))
+	assert.Error(Export(&buf, FormatReverseZone, testHosts(), Options{NS: ns}))
+	assert.Error(Export(&buf, FormatReverseZone, testHosts(), Options{Network: netip.MustParsePrefix("10.0.0.0/12"), NS: ns}))
+
+	// Zones without name servers can't be loaded
+	assert.Error(Export(&buf, FormatZone, testHosts(), Options{Zone: "compute.local"}))
+	assert.Error(Export(&buf, FormatReverseZone, testHosts(), Options{Network: netip.MustParsePrefix("10.0.0.0/16")}))
+}
+
+func TestExportHosts(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Equal(`10.0.0.1	tux-01.compute.local tux-01 login.compute.local
+2001:db8::1	tux-01.compute.local tux-01 login.compute.local
+10.1.0.1	tux-01-bmc.mgmt.local tux-01-bmc
+10.0.0.2	tux-02
+`, export(t, FormatHosts, Options{}))
+
+	assert.Equal(`00:11:22:33:44:01	tux-01.compute.local
+00:11:22:33:44:a1	tux-01-bmc.mgmt.local
+00:11:22:33:44:02	tux-02
+`, export(t, FormatEthers, Options{}))
+}
+
+func TestExportDhcpd(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Equal(`host tux-01 {
+	hardware ethernet 00:11:22:33:44:01;
+	fixed-address 10.0.0.1;
+	option host-name "tux-01.compute.local";
+}
+
+host tux-01-bmc {
+	hardware ethernet 00:11:22:33:44:a1;
+	fixed-address 10.1.0.1;
+	option host-name "tux-01-bmc.mgmt.local";
+}
+
+host tux-02 {
+	hardware ethernet 00:11:22:33:44:02;
+	fixed-address 10.0.0.2;
+	option host-name "tux-02";
+}
+
+`, export(t, FormatDhcpd, Options{}))
+
+	assert.Equal(`dhcp-host=00:11:22:33:44:01,10.0.0.1,[2001:db8::1],tux-01
+dhcp-host=00:11:22:33:44:a1,10.1.0.1,tux-01-bmc
+dhcp-host=00:11:22:33:44:02,10.0.0.2,tux-02
+host-record=tux-01.compute.local,tux-01,10.0.0.1,2001:db8::1
+host-record=tux-01-bmc.mgmt.local,tux-01-bmc,10.1.0.1
+cname=login.compute.local,tux-01.compute.local
+`, export(t, FormatDnsmasq, Options{}))
+}
+
+func TestExportInventory(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Equal(`name,ifname,mac,fqdn,ip,bmc,vlan,mtu,provision,boot_image,firmware,tags
+tux-01,eno1,00:11:22:33:44:01,tux-01.compute.local,10.0.0.1/24 2001:db8::1/64,false,,,true,centos,snponly-x86_64.efi,compute k16-gpu
+tux-01,bmc,00:11:22:33:44:a1,tux-01-bmc.mgmt.local,10.1.0.1/24,true,,,true,centos,snponly-x86_64.efi,compute k16-gpu
+tux-02,,00:11:22:33:44:02,,10.0.0.2/24,false,,9000,false,,,compute
+`, export(t, FormatCSV, Options{}))
+
+	assert.Equal(`tux-01 ansible_host=tux-01.compute.local
+tux-02 ansible_host=10.0.0.2
+
+[compute]
+tux-01
+tux-02
+
+[k16_gpu]
+tux-01
+`, export(t, FormatAnsibleInventory, Options{}))
+}
+
+func TestParseFormat(t *testing.T) {
+	assert := assert.New(t)
+
+	for _, f := range Formats() {
+		format, err := ParseFormat(string(f))
+		assert.NoError(err)
+		assert.Equal(f, format)
+	}
+
+	_, err := ParseFormat("bind")
+	assert.Error(err)
+}
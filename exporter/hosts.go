@@ -0,0 +1,104 @@
+// Copyright 2019 Grendel Authors. All rights reserved.
+//
+// This file is part of Grendel.
+//
+// Grendel is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Grendel is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with Grendel. If not, see <https://www.gnu.org/licenses/>.
+
+package exporter
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/ubccr/grendel/model"
+)
+
+// names returns the /etc/hosts names of the interface starting with the
+// canonical name. The boot interface is also known by the host name and the
+// host aliases.
+func names(host *model.Host, nic *model.NetInterface) []string {
+	list := make([]string, 0)
+	if name := hostname(host, nic); name != "" {
+		list = append(list, name, shortname(name))
+	}
+
+	if nic == host.BootInterface() {
+		list = append(list, host.Name)
+		list = append(list, host.Aliases...)
+	}
+	list = append(list, nic.Aliases...)
+
+	seen := make(map[string]struct{}, len(list))
+	uniq := make([]string, 0, len(list))
+	for _, n := range list {
+		n = strings.TrimSuffix(n, ".")
+		if _, ok := seen[n]; ok || n == "" {
+			continue
+		}
+		seen[n] = struct{}{}
+		uniq = append(uniq, n)
+	}
+
+	return uniq
+}
+
+// exportHosts writes an /etc/hosts file with a line for each address of each
+// interface
+func exportHosts(w io.Writer, hosts model.HostList, opts *Options) error {
+	for _, host := range hosts {
+		for _, nic := range host.Interfaces {
+			list := names(host, nic)
+			if len(list) == 0 {
+				continue
+			}
+
+			for _, addr := range addrs(nic) {
+				_, err := fmt.Fprintf(w, "%s\t%s\n", addr, strings.Join(list, " "))
+				if err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+// exportEthers writes an /etc/ethers file mapping the MAC address of each
+// interface to its name or IPv4 address
+func exportEthers(w io.Writer, hosts model.HostList, opts *Options) error {
+	for _, host := range hosts {
+		for _, nic := range host.Interfaces {
+			if len(nic.MAC) == 0 {
+				continue
+			}
+
+			name := hostname(host, nic)
+			if ip := nic.IPv4(); name == "" && ip.IsValid() {
+				name = ip.Addr().String()
+			}
+
+			if name == "" {
+				continue
+			}
+
+			if _, err := fmt.Fprintf(w, "%s\t%s\n", nic.MAC, name); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,136 @@
+// Copyright 2019 Grendel Authors. All rights reserved.
+//
+// This file is part of Grendel.
+//
+// Grendel is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Grendel is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with Grendel. If not, see <https://www.gnu.org/licenses/>.
+
+package exporter
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/ubccr/grendel/model"
+)
+
+var csvHeader = []string{"name", "ifname", "mac", "fqdn", "ip", "bmc", "vlan", "mtu", "provision", "boot_image", "firmware", "tags"}
+
+// invalidGroupChars matches characters not allowed in ansible group names
+var invalidGroupChars = regexp.MustCompile(`[^A-Za-z0-9_]`)
+
+// exportCSV writes a CSV file with a row for each interface. Hosts without
+// interfaces get a single row with empty interface columns. Multiple
+// addresses and tags are separated by spaces.
+func exportCSV(w io.Writer, hosts model.HostList, opts *Options) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(csvHeader); err != nil {
+		return err
+	}
+
+	for _, host := range hosts {
+		hostCols := []string{
+			strconv.FormatBool(host.Provision),
+			host.BootImage,
+			host.Firmware.String(),
+			strings.Join(host.Tags, " "),
+		}
+
+		if len(host.Interfaces) == 0 {
+			row := append([]string{host.Name, "", "", "", "", "", "", ""}, hostCols...)
+			if err := cw.Write(row); err != nil {
+				return err
+			}
+			continue
+		}
+
+		for _, nic := range host.Interfaces {
+			prefixes := make([]string, 0)
+			for _, p := range nic.Prefixes() {
+				prefixes = append(prefixes, p.String())
+			}
+
+			mac := ""
+			if len(nic.MAC) > 0 {
+				mac = nic.MAC.String()
+			}
+
+			mtu := ""
+			if nic.MTU != 0 {
+				mtu = strconv.Itoa(int(nic.MTU))
+			}
+
+			row := append([]string{
+				host.Name,
+				nic.Name,
+				mac,
+				nic.FQDN,
+				strings.Join(prefixes, " "),
+				strconv.FormatBool(nic.BMC),
+				nic.VLAN,
+				mtu,
+			}, hostCols...)
+			if err := cw.Write(row); err != nil {
+				return err
+			}
+		}
+	}
+
+	cw.Flush()
+	return cw.Error()
+}
+
+// exportAnsibleInventory writes an ansible INI inventory with every host and
+// a group for each tag. The ansible_host of each host is the FQDN or address
+// of its boot interface.
+func exportAnsibleInventory(w io.Writer, hosts model.HostList, opts *Options) error {
+	var b strings.Builder
+	groups := make(map[string][]string)
+
+	for _, host := range hosts {
+		b.WriteString(host.Name)
+		if nic := host.BootInterface(); nic != nil {
+			target := strings.TrimSuffix(nic.FQDN, ".")
+			if list := addrs(nic); target == "" && len(list) > 0 {
+				target = list[0].String()
+			}
+			if target != "" && target != host.Name {
+				fmt.Fprintf(&b, " ansible_host=%s", target)
+			}
+		}
+		b.WriteString("\n")
+
+		for _, tag := range host.Tags {
+			group := invalidGroupChars.ReplaceAllString(tag, "_")
+			groups[group] = append(groups[group], host.Name)
+		}
+	}
+
+	names := make([]string, 0, len(groups))
+	for g := range groups {
+		names = append(names, g)
+	}
+	sort.Strings(names)
+
+	for _, g := range names {
+		fmt.Fprintf(&b, "\n[%s]\n%s\n", g, strings.Join(groups[g], "\n"))
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
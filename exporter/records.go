@@ -0,0 +1,165 @@
+// Copyright 2019 Grendel Authors. All rights reserved.
+//
+// This file is part of Grendel.
+//
+// Grendel is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Grendel is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with Grendel. If not, see <https://www.gnu.org/licenses/>.
+
+package exporter
+
+import (
+	"net"
+	"net/netip"
+
+	"github.com/miekg/dns"
+	"github.com/ubccr/grendel/model"
+	"github.com/ubccr/grendel/util"
+)
+
+// SOA timers of synthesized zones
+const (
+	soaRefresh = 3600
+	soaRetry   = 600
+	soaExpire  = 604800
+)
+
+// SOA returns the SOA record of the zone. The first name server is the primary
+// or the zone itself if there are none.
+func SOA(origin string, ns []string, ttl, serial uint32) dns.RR {
+	mname := origin
+	if len(ns) > 0 {
+		mname = dns.Fqdn(ns[0])
+	}
+
+	return &dns.SOA{
+		Hdr:     header(origin, dns.TypeSOA, ttl),
+		Ns:      mname,
+		Mbox:    "hostmaster." + origin,
+		Serial:  serial,
+		Refresh: soaRefresh,
+		Retry:   soaRetry,
+		Expire:  soaExpire,
+		Minttl:  ttl,
+	}
+}
+
+// NS returns the NS records of the zone
+func NS(origin string, ns []string, ttl uint32) []dns.RR {
+	rrs := make([]dns.RR, len(ns))
+	for i, n := range ns {
+		rrs[i] = &dns.NS{Hdr: header(origin, dns.TypeNS, ttl), Ns: dns.Fqdn(n)}
+	}
+
+	return rrs
+}
+
+// ZoneRecords synthesizes the A, AAAA and CNAME records of the host names
+// under the zone. Duplicate records are skipped.
+func ZoneRecords(origin string, hosts model.HostList, ttl uint32) []dns.RR {
+	set := newRecordSet()
+
+	for _, host := range hosts {
+		for _, nic := range host.Interfaces {
+			if nic.FQDN == "" {
+				continue
+			}
+
+			fqdn := util.Normalize(nic.FQDN)
+			if !dns.IsSubDomain(origin, fqdn) {
+				continue
+			}
+
+			for _, addr := range addrs(nic) {
+				if addr.Is4() {
+					set.add(&dns.A{Hdr: header(fqdn, dns.TypeA, ttl), A: net.IP(addr.AsSlice())})
+				} else {
+					set.add(&dns.AAAA{Hdr: header(fqdn, dns.TypeAAAA, ttl), AAAA: net.IP(addr.AsSlice())})
+				}
+			}
+		}
+
+		aliases := host.Aliases
+		for _, nic := range host.Interfaces {
+			aliases = append(aliases, nic.Aliases...)
+		}
+
+		for _, alias := range aliases {
+			name := util.Normalize(alias)
+			if !dns.IsSubDomain(origin, name) {
+				continue
+			}
+
+			if target := host.AliasTarget(name); target != "" {
+				set.add(&dns.CNAME{Hdr: header(name, dns.TypeCNAME, ttl), Target: util.Normalize(target)})
+			}
+		}
+	}
+
+	return set.rrs
+}
+
+// ReverseZoneRecords synthesizes the PTR records of the host addresses in the
+// network. Duplicate records are skipped.
+func ReverseZoneRecords(network netip.Prefix, hosts model.HostList, ttl uint32) []dns.RR {
+	set := newRecordSet()
+	network = network.Masked()
+
+	for _, host := range hosts {
+		for _, nic := range host.Interfaces {
+			if nic.FQDN == "" {
+				continue
+			}
+
+			for _, addr := range addrs(nic) {
+				if !network.Contains(addr) {
+					continue
+				}
+
+				arpa, err := dns.ReverseAddr(addr.String())
+				if err != nil {
+					continue
+				}
+
+				set.add(&dns.PTR{Hdr: header(arpa, dns.TypePTR, ttl), Ptr: util.Normalize(nic.FQDN)})
+			}
+		}
+	}
+
+	return set.rrs
+}
+
+func header(name string, rrtype uint16, ttl uint32) dns.RR_Header {
+	return dns.RR_Header{Name: name, Rrtype: rrtype, Class: dns.ClassINET, Ttl: ttl}
+}
+
+// recordSet collects records skipping duplicates
+type recordSet struct {
+	rrs  []dns.RR
+	seen map[string]struct{}
+}
+
+func newRecordSet() *recordSet {
+	return &recordSet{seen: make(map[string]struct{})}
+}
+
+func (s *recordSet) add(rrs ...dns.RR) {
+	for _, rr := range rrs {
+		key := rr.String()
+		if _, ok := s.seen[key]; ok {
+			continue
+		}
+
+		s.seen[key] = struct{}{}
+		s.rrs = append(s.rrs, rr)
+	}
+}
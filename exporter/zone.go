@@ -0,0 +1,107 @@
+// Copyright 2019 Grendel Authors. All rights reserved.
+//
+// This file is part of Grendel.
+//
+// Grendel is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Grendel is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with Grendel. If not, see <https://www.gnu.org/licenses/>.
+
+package exporter
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/ubccr/grendel/model"
+	"github.com/ubccr/grendel/util"
+)
+
+// zoneWriter collects the records of a zone file skipping duplicates
+type zoneWriter struct {
+	origin string
+	ttl    uint32
+	set    *recordSet
+}
+
+func newZoneWriter(origin string, opts *Options) *zoneWriter {
+	z := &zoneWriter{
+		origin: origin,
+		ttl:    opts.TTL,
+		set:    newRecordSet(),
+	}
+
+	serial := opts.Serial
+	if serial == 0 {
+		serial = uint32(time.Now().Unix())
+	}
+
+	z.set.add(SOA(origin, opts.NS, opts.TTL, serial))
+	z.set.add(NS(origin, opts.NS, opts.TTL)...)
+
+	return z
+}
+
+func (z *zoneWriter) write(w io.Writer) error {
+	if _, err := fmt.Fprintf(w, "$ORIGIN %s\n$TTL %d\n", z.origin, z.ttl); err != nil {
+		return err
+	}
+
+	for _, rr := range z.set.rrs {
+		if _, err := fmt.Fprintln(w, rr.String()); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// exportZone writes a BIND zone file with the A, AAAA and CNAME records of
+// the names under the zone
+func exportZone(w io.Writer, hosts model.HostList, opts *Options) error {
+	if opts.Zone == "" {
+		return errors.New("Exporting a zone requires a zone name")
+	}
+
+	if len(opts.NS) == 0 {
+		return errors.New("Exporting a zone requires name servers")
+	}
+
+	origin := util.Normalize(opts.Zone)
+	z := newZoneWriter(origin, opts)
+	z.set.add(ZoneRecords(origin, hosts, opts.TTL)...)
+
+	return z.write(w)
+}
+
+// exportReverseZone writes a BIND zone file with the PTR records of the
+// addresses in the network
+func exportReverseZone(w io.Writer, hosts model.HostList, opts *Options) error {
+	if !opts.Network.IsValid() {
+		return errors.New("Exporting a reverse zone requires a network")
+	}
+
+	if len(opts.NS) == 0 {
+		return errors.New("Exporting a reverse zone requires name servers")
+	}
+
+	origin, err := util.ReverseZone(opts.Network)
+	if err != nil {
+		return err
+	}
+
+	z := newZoneWriter(origin, opts)
+	z.set.add(ReverseZoneRecords(opts.Network, hosts, opts.TTL)...)
+
+	return z.write(w)
+}
@@ -0,0 +1,272 @@
+// Copyright 2019 Grendel Authors. All rights reserved.
+//
+// This file is part of Grendel.
+//
+// Grendel is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Grendel is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with Grendel. If not, see <https://www.gnu.org/licenses/>.
+
+package firmware
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/insomniacslk/dhcp/dhcpv4"
+	"github.com/insomniacslk/dhcp/dhcpv6"
+	"github.com/insomniacslk/dhcp/iana"
+)
+
+// Client is the boot firmware information advertised by a DHCP client
+type Client struct {
+	MAC         net.HardwareAddr
+	Archs       iana.Archs
+	VendorClass string
+	UserClasses []string
+
+	// Tags of the host the client belongs to, used to match overrides
+	Tags []string
+}
+
+// NewClient4 returns the Client advertised in a DHCPv4 request. Clients which
+// don't send the client system architecture option (93) are matched on the
+// architecture in the PXEClient or HTTPClient vendor class.
+func NewClient4(req *dhcpv4.DHCPv4) *Client {
+	c := &Client{
+		MAC:         req.ClientHWAddr,
+		Archs:       req.ClientArch(),
+		VendorClass: req.ClassIdentifier(),
+		UserClasses: req.UserClass(),
+	}
+
+	if len(c.Archs) == 0 {
+		c.Archs = vendorClassArchs(c.VendorClass)
+	}
+
+	return c
+}
+
+// NewClient6 returns the Client advertised in a DHCPv6 message
+func NewClient6(msg *dhcpv6.Message, mac net.HardwareAddr) *Client {
+	c := &Client{
+		MAC:   mac,
+		Archs: msg.Options.ArchTypes(),
+	}
+
+	for _, vc := range msg.Options.VendorClasses() {
+		if len(vc.Data) > 0 {
+			c.VendorClass = string(vc.Data[0])
+			break
+		}
+	}
+
+	for _, uc := range msg.Options.UserClasses() {
+		c.UserClasses = append(c.UserClasses, string(uc))
+	}
+
+	if len(c.Archs) == 0 {
+		c.Archs = vendorClassArchs(c.VendorClass)
+	}
+
+	return c
+}
+
+// vendorClassArchs returns the architecture in a vendor class of the form
+// PXEClient:Arch:00007:UNDI:003016
+func vendorClassArchs(class string) iana.Archs {
+	fields := strings.Split(class, ":")
+	if len(fields) < 3 || fields[1] != "Arch" {
+		return nil
+	}
+
+	if fields[0] != "PXEClient" && fields[0] != "HTTPClient" {
+		return nil
+	}
+
+	arch, err := strconv.ParseUint(fields[2], 10, 16)
+	if err != nil {
+		return nil
+	}
+
+	return iana.Archs{iana.Arch(arch)}
+}
+
+// UNDIVersion returns the UNDI version in a vendor class of the form
+// PXEClient:Arch:00007:UNDI:003016, or an empty string if it has none
+func (c *Client) UNDIVersion() string {
+	fields := strings.Split(c.VendorClass, ":")
+	if len(fields) < 5 || fields[3] != "UNDI" {
+		return ""
+	}
+
+	return fields[4]
+}
+
+// HasUserClass returns true if the client sent the given user class
+func (c *Client) HasUserClass(class string) bool {
+	for _, uc := range c.UserClasses {
+		if uc == class {
+			return true
+		}
+	}
+
+	return false
+}
+
+// rule maps clients with one of the archs, or any arch if archs is empty, and
+// the user class and UNDI version, if set, to a firmware build. Rules naming a
+// build refer to custom builds which are not embedded and must be loaded with
+// LoadDir.
+type rule struct {
+	archs     []iana.Arch
+	userClass string
+	undi      string
+	build     Build
+	name      string
+}
+
+func (r *rule) hasArch(arch iana.Arch) bool {
+	for _, a := range r.archs {
+		if a == arch {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (r *rule) result() (Build, error) {
+	if r.name == "" {
+		return r.build, nil
+	}
+
+	b := NewFromString(r.name)
+	if !b.Available() {
+		return Build(0), fmt.Errorf("Firmware build %s is not loaded", r.name)
+	}
+
+	return b, nil
+}
+
+// rules are evaluated in order. Rules without archs are checked first, then
+// the rules for each advertised arch in the order the client sent them.
+var rules = []rule{
+	{userClass: "grendel", build: GRENDEL},
+	{archs: []iana.Arch{iana.INTEL_X86PC}, userClass: "iPXE", build: IPXE},
+	{archs: []iana.Arch{iana.INTEL_X86PC}, build: UNDI},
+	{archs: []iana.Arch{iana.EFI_IA32, iana.EFI_X86_HTTP}, build: EFI386},
+	// EDK2 sends the UNDI (NII) version of the NIC driver in the vendor class
+	// and falls back to 003000 when the driver only installs SNP, as virtio-net
+	// and other NIC drivers without an UNDI do. These NICs boot the SNP only
+	// build so iPXE keeps using the firmware driver instead of binding one of
+	// its own. iPXE itself always advertises 003016, so only first stage UEFI
+	// firmware is matched.
+	{archs: []iana.Arch{iana.EFI_BC, iana.EFI_X86_64, iana.EFI_BC_HTTP, iana.EFI_X86_64_HTTP}, undi: "003000", build: SNPONLY},
+	{archs: []iana.Arch{iana.EFI_BC, iana.EFI_X86_64, iana.EFI_BC_HTTP, iana.EFI_X86_64_HTTP}, build: EFI64},
+	{archs: []iana.Arch{iana.EFI_ARM64, iana.EFI_ARM64_HTTP}, build: EFIARM64},
+	{archs: []iana.Arch{iana.EFI_ARM32, iana.EFI_ARM32_HTTP}, name: "ipxe-arm32.efi"},
+	{archs: []iana.Arch{iana.EFI_RISCV32, iana.EFI_RISCV32_HTTP}, name: "ipxe-riscv32.efi"},
+	{archs: []iana.Arch{iana.EFI_RISCV64, iana.EFI_RISCV64_HTTP}, name: "ipxe-riscv64.efi"},
+}
+
+// Detect returns the firmware build for the client. Every advertised arch is
+// tried in order so a client listing an unsupported arch first still boots.
+func Detect(c *Client) (Build, error) {
+	for _, r := range rules {
+		if len(r.archs) == 0 && c.HasUserClass(r.userClass) {
+			return r.result()
+		}
+	}
+
+	if len(c.Archs) == 0 {
+		return Build(0), fmt.Errorf("No Client System Architecture Types provided")
+	}
+
+	for _, arch := range c.Archs {
+		for _, r := range rules {
+			if !r.hasArch(arch) {
+				continue
+			}
+
+			if r.userClass != "" && !c.HasUserClass(r.userClass) {
+				continue
+			}
+
+			if r.undi != "" && c.UNDIVersion() != r.undi {
+				continue
+			}
+
+			return r.result()
+		}
+	}
+
+	return Build(0), fmt.Errorf("Unsupported Client System Architecture Types: %v", c.Archs)
+}
+
+// Override selects a firmware build for clients matching all of its set
+// fields. The MAC prefix is compared to the lower case, colon separated client
+// MAC, the vendor class is a
+// prefix of the client vendor class and the tag must be set on the host.
+type Override struct {
+	MACPrefix   string
+	VendorClass string
+	Tag         string
+	Build       Build
+}
+
+// Overrides are checked in order by MatchOverride. They're set from
+// firmware.overrides in the config file.
+var Overrides []Override
+
+// Match returns true if the client matches the override
+func (o *Override) Match(c *Client) bool {
+	if o.MACPrefix == "" && o.VendorClass == "" && o.Tag == "" {
+		return false
+	}
+
+	if o.MACPrefix != "" && !strings.HasPrefix(c.MAC.String(), o.MACPrefix) {
+		return false
+	}
+
+	if o.VendorClass != "" && !strings.HasPrefix(c.VendorClass, o.VendorClass) {
+		return false
+	}
+
+	if o.Tag != "" {
+		found := false
+		for _, t := range c.Tags {
+			if t == o.Tag {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+
+	return true
+}
+
+// MatchOverride returns the build of the first override matching the client or
+// the zero Build if none match
+func MatchOverride(c *Client) Build {
+	for i := range Overrides {
+		if Overrides[i].Match(c) {
+			return Overrides[i].Build
+		}
+	}
+
+	return Build(0)
+}
@@ -0,0 +1,129 @@
+// Copyright 2019 Grendel Authors. All rights reserved.
+//
+// This file is part of Grendel.
+//
+// Grendel is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Grendel is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with Grendel. If not, see <https://www.gnu.org/licenses/>.
+
+package firmware
+
+import (
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/insomniacslk/dhcp/dhcpv4"
+	"github.com/stretchr/testify/assert"
+)
+
+// loadTestPacket returns the DHCP request in testdata/name.hex. The packets are
+// synthetic stand-ins for captures, see testdata/README.md.
+func loadTestPacket(t *testing.T, name string) *dhcpv4.DHCPv4 {
+	data, err := os.ReadFile(filepath.Join("testdata", name+".hex"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := hex.DecodeString(strings.TrimSpace(string(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req, err := dhcpv4.FromBytes(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return req
+}
+
+func TestDetect(t *testing.T) {
+	_, err := Register("ipxe-arm32.efi", testEFIImage())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tt := []struct {
+		packet string
+		build  string
+		err    bool
+	}{
+		{packet: "bios-pxe", build: "undionly.kpxe"},
+		{packet: "bios-ipxe", build: "ipxe.pxe"},
+		{packet: "bios-ipxe-rfc3004", build: "ipxe.pxe"},
+		{packet: "efi-x86_64-pxe", build: "ipxe-x86_64.efi"},
+		{packet: "efi-x86_64-http", build: "ipxe-x86_64.efi"},
+		{packet: "efi-x86_64-snp", build: "snponly-x86_64.efi"},
+		{packet: "efi-ia32-http", build: "ipxe-i386.efi"},
+		{packet: "efi-arm64-pxe", build: "ipxe-arm64.efi"},
+		{packet: "efi-arm32-pxe", build: "ipxe-arm32.efi"},
+		{packet: "efi-riscv64-pxe", err: true},
+		{packet: "multi-arch", build: "ipxe-x86_64.efi"},
+		{packet: "vendor-class-only", build: "ipxe-x86_64.efi"},
+		{packet: "dhclient", err: true},
+	}
+
+	for _, test := range tt {
+		t.Run(test.packet, func(t *testing.T) {
+			b, err := Detect(NewClient4(loadTestPacket(t, test.packet)))
+			if test.err {
+				assert.Error(t, err)
+				return
+			}
+
+			if assert.NoError(t, err) {
+				assert.Equal(t, test.build, b.String())
+			}
+		})
+	}
+
+	// The grendel user class has no build name
+	b, err := Detect(NewClient4(loadTestPacket(t, "efi-x86_64-grendel")))
+	if assert.NoError(t, err) {
+		assert.Equal(t, GRENDEL, b)
+	}
+}
+
+func TestMatchOverride(t *testing.T) {
+	Overrides = []Override{
+		{VendorClass: "PXEClient:Arch:00007:UNDI:003016", Tag: "k16", Build: IPXE},
+		{MACPrefix: "b8:59:9f", Build: SNPONLY},
+		{VendorClass: "HTTPClient", Build: EFI386},
+		{Tag: "arm", Build: EFIARM64},
+	}
+	t.Cleanup(func() { Overrides = nil })
+
+	tt := []struct {
+		packet string
+		tags   []string
+		build  Build
+	}{
+		{packet: "efi-x86_64-pxe", tags: []string{"k16"}, build: IPXE},
+		{packet: "efi-x86_64-pxe", build: SNPONLY},
+		{packet: "efi-x86_64-http", build: SNPONLY},
+		{packet: "efi-ia32-http", build: EFI386},
+		{packet: "efi-arm64-pxe", tags: []string{"arm"}, build: EFIARM64},
+		{packet: "efi-arm64-pxe"},
+		{packet: "bios-pxe", tags: []string{"k16"}},
+	}
+
+	for _, test := range tt {
+		t.Run(test.packet, func(t *testing.T) {
+			c := NewClient4(loadTestPacket(t, test.packet))
+			c.Tags = test.tags
+			assert.Equal(t, test.build, MatchOverride(c))
+		})
+	}
+}
@@ -20,6 +20,7 @@ package firmware
 import (
 	_ "embed"
 	"fmt"
+	"strings"
 
 	"github.com/insomniacslk/dhcp/iana"
 )
@@ -54,6 +55,29 @@ var undiBin []byte
 //go:embed bin/ipxe-arm64.efi
 var efiArm64Bin []byte
 
+// Platform is the firmware interface and CPU architecture a build runs on
+type Platform string
+
+const (
+	PlatformPCBIOS     Platform = "pcbios"
+	PlatformEFIi386    Platform = "efi-i386"
+	PlatformEFIx86_64  Platform = "efi-x86_64"
+	PlatformEFIARM32   Platform = "efi-arm32"
+	PlatformEFIARM64   Platform = "efi-arm64"
+	PlatformEFIRISCV32 Platform = "efi-riscv32"
+	PlatformEFIRISCV64 Platform = "efi-riscv64"
+)
+
+// buildToPlatformMap maps an embedded Build to the platform it runs on
+var buildToPlatformMap = map[Build]Platform{
+	IPXE:     PlatformPCBIOS,
+	EFI386:   PlatformEFIi386,
+	EFI64:    PlatformEFIx86_64,
+	SNPONLY:  PlatformEFIx86_64,
+	UNDI:     PlatformPCBIOS,
+	EFIARM64: PlatformEFIARM64,
+}
+
 // buildToStringMap maps a Build to a binary build name
 var buildToStringMap = map[Build]string{
 	IPXE:     "ipxe.pxe",
@@ -64,14 +88,26 @@ var buildToStringMap = map[Build]string{
 	EFIARM64: "ipxe-arm64.efi",
 }
 
-func NewFromString(b string) Build {
+// builtinBuild returns the embedded build with the given name
+func builtinBuild(name string) (Build, bool) {
 	for k, v := range buildToStringMap {
-		if v == b {
-			return k
+		if v == name {
+			return k, true
 		}
 	}
 
-	return Build(0)
+	return Build(0), false
+}
+
+// NewFromString returns the build with the given name. Names which are not
+// embedded builds refer to custom builds loaded with Register or LoadDir.
+// Unknown names return 0.
+func NewFromString(b string) Build {
+	if build, ok := builtinBuild(b); ok {
+		return build
+	}
+
+	return lookupCustom(b)
 }
 
 // String returns a name for a given build.
@@ -79,6 +115,9 @@ func (b Build) String() string {
 	if bt, ok := buildToStringMap[b]; ok {
 		return bt
 	}
+	if b >= customBuildBase {
+		return customName(b)
+	}
 	return ""
 }
 
@@ -86,7 +125,52 @@ func (b Build) IsNil() bool {
 	return int(b) == 0
 }
 
+// IsCustom returns true if the build is not embedded in grendel
+func (b Build) IsCustom() bool {
+	return b >= customBuildBase
+}
+
+// Platform returns the platform the build runs on. The platform of custom
+// builds is read from their binary when they are registered.
+func (b Build) Platform() Platform {
+	if b.IsCustom() {
+		return customPlatform(b)
+	}
+
+	return buildToPlatformMap[b]
+}
+
+// IsEFI returns true if the build is a UEFI application
+func (b Build) IsEFI() bool {
+	return strings.HasPrefix(string(b.Platform()), "efi-")
+}
+
+// Available returns true if the binary of the build can be served
+func (b Build) Available() bool {
+	return b.ToBytes() != nil
+}
+
+func (b Build) MarshalText() ([]byte, error) {
+	return []byte(b.String()), nil
+}
+
+// UnmarshalText parses the build name with Parse so custom builds which are
+// not loaded keep their name
+func (b *Build) UnmarshalText(text []byte) error {
+	build, err := Parse(string(text))
+	if err != nil {
+		return fmt.Errorf("Invalid firmware build: %s", text)
+	}
+	*b = build
+
+	return nil
+}
+
 func (b Build) ToBytes() []byte {
+	if b.IsCustom() {
+		return customBytes(b)
+	}
+
 	switch b {
 	case IPXE:
 		return ipxeBin
@@ -105,37 +189,14 @@ func (b Build) ToBytes() []byte {
 	return nil
 }
 
-func DetectBuild(archs iana.Archs, userClass string) (Build, error) {
-	var build Build
-
-	if archs == nil || len(archs) == 0 {
-		return build, fmt.Errorf("No Client System Architecture Types provided")
-	}
-
-	//XXX TODO use first arch? what to do if there's more than one??
-	arch := archs[0]
-
+// IsHTTPArch returns true if the client system architecture type is one of the
+// UEFI HTTP boot types
+func IsHTTPArch(arch iana.Arch) bool {
 	switch arch {
-	case iana.INTEL_X86PC:
-		build = UNDI
-	case iana.EFI_IA32:
-		build = EFI386
-	case iana.EFI_BC, iana.EFI_X86_64:
-		build = EFI64
-	case iana.EFI_ARM64:
-		build = EFIARM64
-	default:
-		return build, fmt.Errorf("Unsupported Client System Architecture Type: %d", arch)
-	}
-
-	if userClass != "" {
-		if userClass == "iPXE" && arch == iana.INTEL_X86PC {
-			build = IPXE
-		}
-		if userClass == "grendel" {
-			build = GRENDEL
-		}
+	case iana.EFI_X86_HTTP, iana.EFI_X86_64_HTTP, iana.EFI_BC_HTTP, iana.EFI_ARM32_HTTP,
+		iana.EFI_ARM64_HTTP, iana.EFI_RISCV32_HTTP, iana.EFI_RISCV64_HTTP:
+		return true
 	}
 
-	return build, nil
+	return false
 }
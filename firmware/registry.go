@@ -0,0 +1,283 @@
+// Copyright 2019 Grendel Authors. All rights reserved.
+//
+// This file is part of Grendel.
+//
+// Grendel is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Grendel is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with Grendel. If not, see <https://www.gnu.org/licenses/>.
+
+package firmware
+
+import (
+	"bytes"
+	"debug/pe"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// customBuildBase is the first Build assigned to named builds which are not
+// embedded in grendel
+const customBuildBase Build = 1000
+
+// maxPXESize is the largest legacy PXE binary which fits in base memory
+const maxPXESize = 512 * 1024
+
+// MaxNameLength is the longest name of a custom build. Firmware tokens carry
+// the build name and the client MAC and are sent in the 128 byte BOOTP file
+// field, which fits names of at most 31 bytes along with a 6 byte MAC.
+const MaxNameLength = 31
+
+// buildNameRegexp matches valid names of custom builds. Names end up in TFTP
+// boot file names and URLs so they are restricted to a safe character set.
+var buildNameRegexp = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+
+// registry holds the named builds loaded at runtime with Register
+var registry = struct {
+	sync.RWMutex
+	names     map[string]Build
+	data      map[Build][]byte
+	platforms map[Build]Platform
+}{
+	names:     make(map[string]Build),
+	data:      make(map[Build][]byte),
+	platforms: make(map[Build]Platform),
+}
+
+// peMachinePlatforms maps the machine type of EFI images to their platform
+var peMachinePlatforms = map[uint16]Platform{
+	pe.IMAGE_FILE_MACHINE_I386:    PlatformEFIi386,
+	pe.IMAGE_FILE_MACHINE_AMD64:   PlatformEFIx86_64,
+	pe.IMAGE_FILE_MACHINE_ARMNT:   PlatformEFIARM32,
+	pe.IMAGE_FILE_MACHINE_ARM64:   PlatformEFIARM64,
+	pe.IMAGE_FILE_MACHINE_RISCV32: PlatformEFIRISCV32,
+	pe.IMAGE_FILE_MACHINE_RISCV64: PlatformEFIRISCV64,
+}
+
+// lookupCustom returns the registered custom build with the given name or 0
+// if the name is unknown
+func lookupCustom(name string) Build {
+	registry.RLock()
+	defer registry.RUnlock()
+
+	return registry.names[name]
+}
+
+// customName returns the name of a custom build
+func customName(b Build) string {
+	registry.RLock()
+	defer registry.RUnlock()
+
+	for name, cb := range registry.names {
+		if cb == b {
+			return name
+		}
+	}
+
+	return ""
+}
+
+// customBytes returns the binary of a custom build or nil if it hasn't been
+// loaded
+func customBytes(b Build) []byte {
+	registry.RLock()
+	defer registry.RUnlock()
+
+	return registry.data[b]
+}
+
+// customPlatform returns the platform of a custom build
+func customPlatform(b Build) Platform {
+	registry.RLock()
+	defer registry.RUnlock()
+
+	return registry.platforms[b]
+}
+
+// Validate checks the binary of a firmware build is usable based on the file
+// extension of its name. EFI builds must be PE images for a supported machine
+// type and legacy PXE builds must fit in base memory.
+func Validate(name string, data []byte) error {
+	_, err := platform(name, data)
+	return err
+}
+
+// platform validates the binary of a firmware build and returns the platform
+// it runs on
+func platform(name string, data []byte) (Platform, error) {
+	if len(data) == 0 {
+		return "", fmt.Errorf("firmware build %s is empty", name)
+	}
+
+	switch filepath.Ext(name) {
+	case ".efi":
+		f, err := pe.NewFile(bytes.NewReader(data))
+		if err != nil {
+			return "", fmt.Errorf("firmware build %s is not a valid EFI image: %w", name, err)
+		}
+		defer f.Close()
+
+		p, ok := peMachinePlatforms[f.Machine]
+		if !ok {
+			return "", fmt.Errorf("firmware build %s has unsupported machine type 0x%x", name, f.Machine)
+		}
+
+		return p, nil
+	case ".pxe", ".kpxe", ".kkpxe":
+		if len(data) > maxPXESize {
+			return "", fmt.Errorf("firmware build %s is too large for PXE: %d bytes", name, len(data))
+		}
+
+		return PlatformPCBIOS, nil
+	}
+
+	return "", fmt.Errorf("firmware build %s has unsupported extension, expected .efi, .pxe, .kpxe or .kkpxe", name)
+}
+
+// Register adds a named firmware build after validating it. Registering a name
+// again replaces its binary. The names of the embedded builds can't be
+// registered.
+func Register(name string, data []byte) (Build, error) {
+	if _, ok := builtinBuild(name); ok {
+		return Build(0), fmt.Errorf("firmware build %s is built in", name)
+	}
+
+	if err := validateName(name); err != nil {
+		return Build(0), err
+	}
+
+	p, err := platform(name, data)
+	if err != nil {
+		return Build(0), err
+	}
+
+	registry.Lock()
+	defer registry.Unlock()
+
+	b := allocate(name)
+	registry.data[b] = data
+	registry.platforms[b] = p
+
+	return b, nil
+}
+
+// Parse returns the build with the given name. Valid names of custom builds
+// which are not loaded in this process return an unresolved build. It keeps
+// the name so hosts and images referring to it can be decoded and stored again
+// unchanged, but it is not Available and is never served. Empty names return
+// 0.
+func Parse(name string) (Build, error) {
+	if name == "" {
+		return Build(0), nil
+	}
+
+	if b := NewFromString(name); !b.IsNil() {
+		return b, nil
+	}
+
+	if err := validateName(name); err != nil {
+		return Build(0), err
+	}
+
+	switch filepath.Ext(name) {
+	case ".efi", ".pxe", ".kpxe", ".kkpxe":
+	default:
+		return Build(0), fmt.Errorf("invalid firmware build: %s", name)
+	}
+
+	registry.Lock()
+	defer registry.Unlock()
+
+	return allocate(name), nil
+}
+
+// validateName checks the name of a custom build
+func validateName(name string) error {
+	if !buildNameRegexp.MatchString(name) {
+		return fmt.Errorf("invalid firmware build name: %s", name)
+	}
+
+	if len(name) > MaxNameLength {
+		return fmt.Errorf("firmware build name %s is longer than %d characters", name, MaxNameLength)
+	}
+
+	return nil
+}
+
+// allocate returns the custom build with the given name, assigning it the next
+// Build if it's new. The registry must be locked.
+func allocate(name string) Build {
+	b, ok := registry.names[name]
+	if !ok {
+		b = customBuildBase + Build(len(registry.names))
+		registry.names[name] = b
+	}
+
+	return b
+}
+
+// LoadDir registers every file in dir as a firmware build named after the
+// file. It returns the names of the loaded builds.
+func LoadDir(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	names := make([]string, 0)
+	for _, e := range entries {
+		if e.IsDir() || strings.HasPrefix(e.Name(), ".") {
+			continue
+		}
+
+		data, err := os.ReadFile(filepath.Join(dir, e.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		if _, err := Register(e.Name(), data); err != nil {
+			return nil, err
+		}
+
+		names = append(names, e.Name())
+	}
+
+	sort.Strings(names)
+
+	return names, nil
+}
+
+// Builds returns the names of all firmware builds which can be served, the
+// embedded ones followed by the loaded custom builds
+func Builds() []string {
+	names := make([]string, 0, len(buildToStringMap))
+	for _, name := range buildToStringMap {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	custom := make([]string, 0)
+	registry.RLock()
+	for name, b := range registry.names {
+		if registry.data[b] != nil {
+			custom = append(custom, name)
+		}
+	}
+	registry.RUnlock()
+	sort.Strings(custom)
+
+	return append(names, custom...)
+}
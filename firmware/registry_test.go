@@ -0,0 +1,130 @@
+// Copyright 2019 Grendel Authors. All rights reserved.
+//
+// This file is part of Grendel.
+//
+// Grendel is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Grendel is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with Grendel. If not, see <https://www.gnu.org/licenses/>.
+
+package firmware
+
+import (
+	"bytes"
+	"debug/pe"
+	"encoding/binary"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// testEFIImage returns a minimal PE image with no sections
+func testEFIImage() []byte {
+	var b bytes.Buffer
+	dos := make([]byte, 0x40)
+	copy(dos, "MZ")
+	binary.LittleEndian.PutUint32(dos[0x3c:], 0x40)
+	b.Write(dos)
+	b.WriteString("PE\x00\x00")
+	binary.Write(&b, binary.LittleEndian, pe.FileHeader{Machine: pe.IMAGE_FILE_MACHINE_AMD64})
+	b.Write(make([]byte, 0x200-b.Len()))
+
+	return b.Bytes()
+}
+
+func TestRegistry(t *testing.T) {
+	assert := assert.New(t)
+
+	efiBin := testEFIImage()
+
+	dir := t.TempDir()
+	assert.NoError(os.WriteFile(filepath.Join(dir, "ipxe-ca-x86_64.efi"), efiBin, 0644))
+	assert.NoError(os.WriteFile(filepath.Join(dir, "undionly-serial.kpxe"), undiBin, 0644))
+
+	names, err := LoadDir(dir)
+	if assert.NoError(err) {
+		assert.Equal([]string{"ipxe-ca-x86_64.efi", "undionly-serial.kpxe"}, names)
+	}
+
+	b := NewFromString("ipxe-ca-x86_64.efi")
+	assert.True(b.IsCustom())
+	assert.True(b.Available())
+	assert.True(b.IsEFI())
+	assert.Equal(PlatformEFIx86_64, b.Platform())
+	assert.False(NewFromString("undionly-serial.kpxe").IsEFI())
+	assert.Equal(PlatformPCBIOS, NewFromString("undionly-serial.kpxe").Platform())
+	assert.Equal(PlatformEFIx86_64, SNPONLY.Platform())
+	assert.Equal("ipxe-ca-x86_64.efi", b.String())
+	assert.Equal(efiBin, b.ToBytes())
+	assert.Contains(Builds(), "ipxe-ca-x86_64.efi")
+	assert.Contains(Builds(), "ipxe-x86_64.efi")
+
+	// Unknown names are never registered by parsing them
+	assert.True(NewFromString("ipxe-missing.efi").IsNil())
+	assert.True(NewFromString("ipxe-x86-64.efi").IsNil())
+	assert.NotContains(Builds(), "ipxe-missing.efi")
+
+	var image struct {
+		Firmware Build `json:"firmware"`
+	}
+	assert.NoError(json.Unmarshal([]byte(`{"firmware": "undionly-serial.kpxe"}`), &image))
+	assert.Equal(NewFromString("undionly-serial.kpxe"), image.Firmware)
+	data, err := json.Marshal(image)
+	if assert.NoError(err) {
+		assert.JSONEq(`{"firmware": "undionly-serial.kpxe"}`, string(data))
+	}
+	assert.Error(json.Unmarshal([]byte(`{"firmware": "../ipxe.efi"}`), &image))
+	assert.Error(json.Unmarshal([]byte(`{"firmware": "ipxe.iso"}`), &image))
+
+	// Builds which are not loaded keep their name but can't be served
+	assert.NoError(json.Unmarshal([]byte(`{"firmware": "ipxe-x86-64.efi"}`), &image))
+	assert.True(image.Firmware.IsCustom())
+	assert.False(image.Firmware.Available())
+	assert.False(image.Firmware.IsEFI())
+	assert.NotContains(Builds(), "ipxe-x86-64.efi")
+	data, err = json.Marshal(image)
+	if assert.NoError(err) {
+		assert.JSONEq(`{"firmware": "ipxe-x86-64.efi"}`, string(data))
+	}
+
+	// Loading the build later resolves it
+	b, err = Register("ipxe-x86-64.efi", efiBin)
+	if assert.NoError(err) {
+		assert.Equal(image.Firmware, b)
+		assert.True(image.Firmware.Available())
+	}
+
+	// EFI byte code images don't run on any platform detected from DHCP
+	ebcBin := testEFIImage()
+	binary.LittleEndian.PutUint16(ebcBin[0x44:], 0xebc)
+
+	tt := []struct {
+		name string
+		data []byte
+	}{
+		{"ipxe-ebc.efi", ebcBin},
+		{"ipxe.pxe", ipxeBin},
+		{"bad name.efi", efiBin},
+		{"empty.efi", []byte{}},
+		{"broken.efi", undiBin},
+		{"huge.kpxe", make([]byte, maxPXESize+1)},
+		{"ipxe.iso", efiBin},
+		{"ipxe-x86_64-mellanox-trustca-serial.efi", efiBin},
+	}
+
+	for _, test := range tt {
+		_, err := Register(test.name, test.data)
+		assert.Error(err, test.name)
+	}
+}
@@ -0,0 +1,186 @@
+// Copyright 2019 Grendel Authors. All rights reserved.
+//
+// This file is part of Grendel.
+//
+// Grendel is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Grendel is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with Grendel. If not, see <https://www.gnu.org/licenses/>.
+
+package logger
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+
+	"github.com/rifflock/lfshook"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+	"gopkg.in/natefinch/lumberjack.v2"
+)
+
+const (
+	FormatText = "text"
+	FormatJSON = "json"
+
+	// levelOff disables all logging for a service
+	levelOff = "off"
+
+	// levelOn logs a service at the default level
+	levelOn = "on"
+)
+
+var (
+	levelsMutex   sync.RWMutex
+	defaultLevel  = logrus.TraceLevel
+	serviceLevels map[string]*serviceLevel
+	fileWriter    io.Closer
+)
+
+type serviceLevel struct {
+	level logrus.Level
+	off   bool
+}
+
+func init() {
+	viper.SetDefault("log.format", FormatText)
+	viper.SetDefault("log.file_format", FormatJSON)
+	viper.SetDefault("log.max_size", 100)
+	viper.SetDefault("log.max_backups", 5)
+	viper.SetDefault("log.max_age", 30)
+	viper.SetDefault("log.compress", false)
+}
+
+// Configure sets up the global logger from the log and loggers sections of
+// the grendel config. Services without a level in loggers log at level.
+func Configure(level logrus.Level) error {
+	levels, err := parseServiceLevels(viper.GetStringMapString("loggers"), level)
+	if err != nil {
+		return err
+	}
+
+	formatter, err := NewFormatter(viper.GetString("log.format"), false)
+	if err != nil {
+		return err
+	}
+
+	logger := getGlobalLogger()
+
+	// The logger level must allow the most verbose service, entries for
+	// other services are dropped by the formatters
+	maxLevel := level
+	for _, l := range levels {
+		if !l.off && l.level > maxLevel {
+			maxLevel = l.level
+		}
+	}
+
+	levelsMutex.Lock()
+	defaultLevel = level
+	serviceLevels = levels
+	levelsMutex.Unlock()
+
+	logger.SetLevel(maxLevel)
+	logger.SetFormatter(formatter)
+
+	return configureFile(logger)
+}
+
+// NewFormatter returns the log formatter for the given format name. File
+// formatters never use colors.
+func NewFormatter(format string, file bool) (logrus.Formatter, error) {
+	switch strings.ToLower(format) {
+	case "", FormatText:
+		return &TextFormatter{FullTimestamp: true, DisableColors: file}, nil
+	case FormatJSON:
+		return &JSONFormatter{}, nil
+	}
+
+	return nil, fmt.Errorf("invalid log format: %s", format)
+}
+
+// Enabled returns true if the entry should be logged given the level of the
+// service it was logged for
+func Enabled(entry *logrus.Entry) bool {
+	prefix, ok := entry.Data["prefix"].(string)
+	if !ok {
+		return true
+	}
+
+	levelsMutex.RLock()
+	defer levelsMutex.RUnlock()
+
+	l, ok := serviceLevels[strings.ToLower(prefix)]
+	if !ok {
+		return entry.Level <= defaultLevel
+	}
+
+	return !l.off && entry.Level <= l.level
+}
+
+// parseServiceLevels parses the per service levels from the loggers config.
+// Along with the logrus level names, "on" logs at the default level and "off"
+// disables logging for the service.
+func parseServiceLevels(loggers map[string]string, level logrus.Level) (map[string]*serviceLevel, error) {
+	levels := make(map[string]*serviceLevel, len(loggers))
+	for service, value := range loggers {
+		service = strings.ToLower(service)
+		switch strings.ToLower(value) {
+		case levelOff:
+			levels[service] = &serviceLevel{off: true}
+		case levelOn, "":
+			levels[service] = &serviceLevel{level: level}
+		default:
+			l, err := logrus.ParseLevel(value)
+			if err != nil {
+				return nil, fmt.Errorf("invalid log level for %s: %w", service, err)
+			}
+			levels[service] = &serviceLevel{level: l}
+		}
+	}
+
+	return levels, nil
+}
+
+// configureFile adds a hook writing entries to the rotated log file set in
+// log.file
+func configureFile(logger *logrus.Logger) error {
+	logger.ReplaceHooks(make(logrus.LevelHooks))
+	if fileWriter != nil {
+		fileWriter.Close()
+		fileWriter = nil
+	}
+
+	path := viper.GetString("log.file")
+	if path == "" {
+		return nil
+	}
+
+	formatter, err := NewFormatter(viper.GetString("log.file_format"), true)
+	if err != nil {
+		return err
+	}
+
+	writer := &lumberjack.Logger{
+		Filename:   path,
+		MaxSize:    viper.GetInt("log.max_size"),
+		MaxBackups: viper.GetInt("log.max_backups"),
+		MaxAge:     viper.GetInt("log.max_age"),
+		Compress:   viper.GetBool("log.compress"),
+	}
+
+	fileWriter = writer
+	logger.AddHook(lfshook.NewHook(writer, formatter))
+
+	return nil
+}
@@ -39,7 +39,6 @@ import (
 
 	"github.com/mgutz/ansi"
 	"github.com/sirupsen/logrus"
-	"github.com/spf13/viper"
 	"golang.org/x/crypto/ssh/terminal"
 )
 
@@ -192,12 +191,8 @@ func (f *TextFormatter) SetColorScheme(colorScheme *ColorScheme) {
 }
 
 func (f *TextFormatter) Format(entry *logrus.Entry) ([]byte, error) {
-	if prefixValue, ok := entry.Data["prefix"]; ok {
-		loggers := viper.GetStringMapString("loggers")
-		status, ok := loggers[strings.ToLower(prefixValue.(string))]
-		if ok && "off" == strings.ToLower(status) {
-			return nil, nil
-		}
+	if !Enabled(entry) {
+		return nil, nil
 	}
 
 	var b *bytes.Buffer
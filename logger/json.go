@@ -0,0 +1,94 @@
+// Copyright 2019 Grendel Authors. All rights reserved.
+//
+// This file is part of Grendel.
+//
+// Grendel is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Grendel is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with Grendel. If not, see <https://www.gnu.org/licenses/>.
+
+package logger
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Stable field names used in structured log output. Services should use these
+// names when logging the corresponding values so log pipelines can index them.
+const (
+	FieldService   = "service"
+	FieldMAC       = "mac"
+	FieldHost      = "host"
+	FieldHostID    = "host_id"
+	FieldIP        = "ip"
+	FieldTokenID   = "token-id"
+	FieldTokenName = "token-name"
+	FieldTime      = "time"
+	FieldLevel     = "level"
+	FieldMessage   = "msg"
+)
+
+// JSONFormatter formats log entries as a single JSON object per line. The
+// logger prefix is written as the service field in lower case.
+type JSONFormatter struct {
+	// Timestamp format to use, defaults to RFC3339 with nanoseconds.
+	TimestampFormat string
+}
+
+func (f *JSONFormatter) Format(entry *logrus.Entry) ([]byte, error) {
+	if !Enabled(entry) {
+		return nil, nil
+	}
+
+	data := make(logrus.Fields, len(entry.Data)+4)
+	for k, v := range entry.Data {
+		switch k {
+		case "prefix":
+			data[FieldService] = strings.ToLower(fmt.Sprint(v))
+			continue
+		case FieldTime, FieldLevel, FieldMessage:
+			k = "fields." + k
+		}
+
+		switch v := v.(type) {
+		case error:
+			// Otherwise errors with no exported fields are marshalled as {}
+			data[k] = v.Error()
+		case json.Marshaler:
+			data[k] = v
+		case fmt.Stringer:
+			data[k] = v.String()
+		default:
+			data[k] = v
+		}
+	}
+
+	timestampFormat := f.TimestampFormat
+	if timestampFormat == "" {
+		timestampFormat = time.RFC3339Nano
+	}
+
+	data[FieldTime] = entry.Time.Format(timestampFormat)
+	data[FieldLevel] = entry.Level.String()
+	data[FieldMessage] = entry.Message
+
+	b, err := json.Marshal(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal log entry to JSON: %w", err)
+	}
+
+	return append(b, '\n'), nil
+}
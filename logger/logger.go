@@ -41,16 +41,20 @@ func GetLogger(prefix string) *logrus.Entry {
 	if prefix == "" {
 		prefix = "<no prefix>"
 	}
+	return getGlobalLogger().WithField("prefix", prefix)
+}
+
+func getGlobalLogger() *logrus.Logger {
+	getLoggerMutex.Lock()
+	defer getLoggerMutex.Unlock()
 	if globalLogger == nil {
-		getLoggerMutex.Lock()
-		defer getLoggerMutex.Unlock()
 		logger := logrus.New()
 		logger.SetFormatter(&TextFormatter{
 			FullTimestamp: true,
 		})
 		globalLogger = logger
 	}
-	return globalLogger.WithField("prefix", prefix)
+	return globalLogger
 }
 
 // WithFile logs to the specified file in addition to the existing output.
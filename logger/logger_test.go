@@ -0,0 +1,144 @@
+// Copyright 2019 Grendel Authors. All rights reserved.
+//
+// This file is part of Grendel.
+//
+// Grendel is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Grendel is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with Grendel. If not, see <https://www.gnu.org/licenses/>.
+
+package logger
+
+import (
+	"bytes"
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/tidwall/gjson"
+)
+
+func newTestLogger(formatter logrus.Formatter) (*logrus.Logger, *bytes.Buffer) {
+	var buf bytes.Buffer
+	logger := logrus.New()
+	logger.SetOutput(&buf)
+	logger.SetLevel(logrus.TraceLevel)
+	logger.SetFormatter(formatter)
+
+	return logger, &buf
+}
+
+func TestJSONFormatter(t *testing.T) {
+	assert := assert.New(t)
+
+	logger, buf := newTestLogger(&JSONFormatter{})
+	mac, _ := net.ParseMAC("00:11:22:33:44:55")
+
+	logger.WithFields(logrus.Fields{
+		"prefix":     "DHCP",
+		FieldMAC:     mac,
+		FieldHost:    "cpn-d13-01",
+		FieldIP:      net.ParseIP("10.0.0.1"),
+		"err":        errors.New("boom"),
+		FieldMessage: "clash",
+	}).Warn("Found host")
+
+	out := buf.String()
+	assert.True(gjson.Valid(out))
+	assert.Equal("dhcp", gjson.Get(out, "service").String())
+	assert.Equal("00:11:22:33:44:55", gjson.Get(out, "mac").String())
+	assert.Equal("cpn-d13-01", gjson.Get(out, "host").String())
+	assert.Equal("10.0.0.1", gjson.Get(out, "ip").String())
+	assert.Equal("boom", gjson.Get(out, "err").String())
+	assert.Equal("warning", gjson.Get(out, "level").String())
+	assert.Equal("Found host", gjson.Get(out, "msg").String())
+	assert.Equal("clash", gjson.Get(out, "fields\\.msg").String())
+	assert.False(gjson.Get(out, "prefix").Exists())
+}
+
+func TestServiceLevels(t *testing.T) {
+	assert := assert.New(t)
+
+	_, err := parseServiceLevels(map[string]string{"dhcp": "loud"}, logrus.InfoLevel)
+	assert.Error(err)
+
+	levels, err := parseServiceLevels(map[string]string{
+		"DHCP": "debug",
+		"tftp": "off",
+		"dns":  "on",
+		"api":  "error",
+	}, logrus.InfoLevel)
+	if !assert.NoError(err) {
+		return
+	}
+
+	levelsMutex.Lock()
+	defaultLevel, serviceLevels = logrus.InfoLevel, levels
+	levelsMutex.Unlock()
+	defer func() {
+		levelsMutex.Lock()
+		defaultLevel, serviceLevels = logrus.TraceLevel, nil
+		levelsMutex.Unlock()
+	}()
+
+	logger, buf := newTestLogger(&JSONFormatter{})
+	tests := []struct {
+		prefix string
+		level  logrus.Level
+		logged bool
+	}{
+		{"DHCP", logrus.DebugLevel, true},
+		{"DHCP", logrus.TraceLevel, false},
+		{"TFTP", logrus.ErrorLevel, false},
+		{"DNS", logrus.InfoLevel, true},
+		{"DNS", logrus.DebugLevel, false},
+		{"API", logrus.WarnLevel, false},
+		{"API", logrus.ErrorLevel, true},
+		{"PXE", logrus.InfoLevel, true},
+		{"PXE", logrus.DebugLevel, false},
+	}
+
+	for _, test := range tests {
+		buf.Reset()
+		logger.WithField("prefix", test.prefix).Log(test.level, "test")
+		assert.Equalf(test.logged, buf.Len() > 0, "%s at %s", test.prefix, test.level)
+	}
+}
+
+func TestLogFile(t *testing.T) {
+	assert := assert.New(t)
+
+	path := filepath.Join(t.TempDir(), "grendel.log")
+	viper.Set("log.file", path)
+
+	logger, _ := newTestLogger(&TextFormatter{})
+	defer func() {
+		viper.Set("log.file", "")
+		configureFile(logger)
+	}()
+
+	if !assert.NoError(configureFile(logger)) {
+		return
+	}
+
+	logger.WithFields(logrus.Fields{"prefix": "PROVISION", FieldHost: "cpn-d13-01"}).Info("Provisioned host")
+
+	data, err := os.ReadFile(path)
+	if assert.NoError(err) {
+		assert.Equal("provision", gjson.GetBytes(data, "service").String())
+		assert.Equal("cpn-d13-01", gjson.GetBytes(data, "host").String())
+	}
+}
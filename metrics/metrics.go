@@ -0,0 +1,101 @@
+// Copyright 2019 Grendel Authors. All rights reserved.
+//
+// This file is part of Grendel.
+//
+// Grendel is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Grendel is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with Grendel. If not, see <https://www.gnu.org/licenses/>.
+
+// Package metrics provides the prometheus registry shared by the grendel
+// services and a server exposing it on /metrics
+package metrics
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/ubccr/grendel/logger"
+)
+
+const (
+	// Namespace is the prefix of all grendel metric names
+	Namespace = "grendel"
+)
+
+var (
+	log = logger.GetLogger("METRICS")
+
+	// Registry holds all metrics exported by grendel
+	Registry = prometheus.NewRegistry()
+
+	// Factory registers new metrics with Registry
+	Factory = promauto.With(Registry)
+)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+}
+
+// Handler returns a http.Handler serving the metrics in Registry
+func Handler() http.Handler {
+	return promhttp.HandlerFor(Registry, promhttp.HandlerOpts{})
+}
+
+// Server serves metrics on a dedicated listener for prometheus to scrape
+type Server struct {
+	Address    string
+	httpServer *http.Server
+}
+
+func NewServer(address string) (*Server, error) {
+	_, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return nil, err
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", Handler())
+
+	s := &Server{
+		Address: address,
+		httpServer: &http.Server{
+			Addr:         address,
+			Handler:      mux,
+			ReadTimeout:  10 * time.Second,
+			WriteTimeout: 30 * time.Second,
+		},
+	}
+
+	return s, nil
+}
+
+func (s *Server) Serve() error {
+	log.Infof("Server listening on: %s", s.Address)
+	if err := s.httpServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		return err
+	}
+
+	return nil
+}
+
+func (s *Server) Shutdown(ctx context.Context) error {
+	return s.httpServer.Shutdown(ctx)
+}
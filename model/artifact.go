@@ -0,0 +1,94 @@
+// Copyright 2019 Grendel Authors. All rights reserved.
+//
+// This file is part of Grendel.
+//
+// Grendel is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Grendel is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with Grendel. If not, see <https://www.gnu.org/licenses/>.
+
+package model
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+)
+
+const (
+	ArtifactKernel  = "kernel"
+	ArtifactInitrd  = "initrd"
+	ArtifactLiveImg = "liveimg"
+	ArtifactRootFS  = "rootfs"
+)
+
+// Artifact is a boot image file uploaded to the artifact store. Artifacts are
+// stored by the sha256 checksum of their content.
+type Artifact struct {
+	Kind   string `json:"kind"`
+	Name   string `json:"name"`
+	SHA256 string `json:"sha256"`
+	Size   int64  `json:"size"`
+}
+
+var sha256Regexp = regexp.MustCompile(`^[0-9a-f]{64}$`)
+
+// Source is a remote boot image file fetched into the artifact store. The URL
+// is either an HTTP(S) URL, which requires the expected sha256 checksum, or an
+// OCI artifact reference oci://registry/repository[:tag|@sha256:digest] of a
+// single layer artifact. Initrds are set in the order of their sources.
+type Source struct {
+	Kind   string `json:"kind"`
+	URL    string `json:"url"`
+	SHA256 string `json:"sha256,omitempty"`
+}
+
+// Validate returns an error if the source is not a valid kind, URL and
+// checksum
+func (s *Source) Validate() error {
+	if !IsArtifactKind(s.Kind) {
+		return fmt.Errorf("invalid source kind %q", s.Kind)
+	}
+
+	u, err := url.Parse(s.URL)
+	if err != nil {
+		return fmt.Errorf("invalid source url %q: %w", s.URL, err)
+	}
+
+	if s.SHA256 != "" && !sha256Regexp.MatchString(s.SHA256) {
+		return fmt.Errorf("invalid sha256 for source %s", s.URL)
+	}
+
+	switch u.Scheme {
+	case "http", "https":
+		if s.SHA256 == "" {
+			return fmt.Errorf("sha256 required for source %s", s.URL)
+		}
+	case "oci":
+		if u.Host == "" || u.Path == "" {
+			return fmt.Errorf("invalid oci reference %s", s.URL)
+		}
+	default:
+		return fmt.Errorf("unsupported scheme for source %s", s.URL)
+	}
+
+	return nil
+}
+
+// IsArtifactKind returns true if kind is a valid artifact kind
+func IsArtifactKind(kind string) bool {
+	switch kind {
+	case ArtifactKernel, ArtifactInitrd, ArtifactLiveImg, ArtifactRootFS:
+		return true
+	}
+
+	return false
+}
@@ -0,0 +1,652 @@
+// Copyright 2019 Grendel Authors. All rights reserved.
+//
+// This file is part of Grendel.
+//
+// Grendel is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Grendel is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with Grendel. If not, see <https://www.gnu.org/licenses/>.
+
+package model
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/ubccr/grendel/events"
+	"github.com/ubccr/grendel/nodeset"
+)
+
+const (
+	AuditKindHost  = "host"
+	AuditKindImage = "image"
+
+	AuditOpHostStore       = "host.store"
+	AuditOpHostDelete      = "host.delete"
+	AuditOpHostTag         = "host.tag"
+	AuditOpHostUntag       = "host.untag"
+	AuditOpHostProvision   = "host.provision"
+	AuditOpHostUnprovision = "host.unprovision"
+	AuditOpHostBootImage   = "host.bootimage"
+	AuditOpHostRevert      = "host.revert"
+	AuditOpImageStore      = "image.store"
+	AuditOpImageDelete     = "image.delete"
+)
+
+type AuditEntryList []*AuditEntry
+
+// AuditEntry records a single mutation of the data store. The entry ID is an
+// increasing revision number used when reverting a host.
+type AuditEntry struct {
+	ID        int64          `json:"id"`
+	Timestamp time.Time      `json:"timestamp"`
+	Caller    string         `json:"caller"`
+	TokenID   string         `json:"token_id,omitempty"`
+	Operation string         `json:"operation"`
+	NodeSet   string         `json:"nodeset"`
+	Changes   []*AuditChange `json:"changes"`
+}
+
+// AuditChange records the state of a single host or boot image before and
+// after a mutation. Before is empty for new records and After is empty for
+// deleted records.
+type AuditChange struct {
+	Kind   string          `json:"kind"`
+	Name   string          `json:"name"`
+	Before json.RawMessage `json:"before,omitempty"`
+	After  json.RawMessage `json:"after,omitempty"`
+	Diff   []*AuditDiff    `json:"diff"`
+}
+
+// AuditDiff is a single changed field given by its JSON path
+type AuditDiff struct {
+	Path   string      `json:"path"`
+	Before interface{} `json:"before"`
+	After  interface{} `json:"after"`
+}
+
+// AuditFilter selects audit log entries. Zero values match all entries.
+type AuditFilter struct {
+	NodeSet   *nodeset.NodeSet
+	Kind      string
+	Operation string
+	Caller    string
+	Since     time.Time
+	Until     time.Time
+	Limit     int
+}
+
+// Names returns the names of all hosts or boot images changed by the entry
+func (e *AuditEntry) Names() []string {
+	names := make([]string, 0, len(e.Changes))
+	for _, c := range e.Changes {
+		names = append(names, c.Name)
+	}
+
+	return names
+}
+
+// Change returns the change to the host or boot image with the given name
+func (e *AuditEntry) Change(kind, name string) *AuditChange {
+	for _, c := range e.Changes {
+		if c.Kind == kind && c.Name == name {
+			return c
+		}
+	}
+
+	return nil
+}
+
+// Match returns true if the audit entry matches all fields of the filter
+// except Limit
+func (f *AuditFilter) Match(e *AuditEntry) bool {
+	if f.Operation != "" && f.Operation != e.Operation {
+		return false
+	}
+
+	if f.Caller != "" && f.Caller != e.Caller {
+		return false
+	}
+
+	if !f.Since.IsZero() && e.Timestamp.Before(f.Since) {
+		return false
+	}
+
+	if !f.Until.IsZero() && e.Timestamp.After(f.Until) {
+		return false
+	}
+
+	if f.Kind == "" && f.NodeSet == nil {
+		return true
+	}
+
+	names := make(map[string]bool)
+	if f.NodeSet != nil {
+		it := f.NodeSet.Iterator()
+		for it.Next() {
+			names[it.Value()] = true
+		}
+	}
+
+	for _, c := range e.Changes {
+		if f.Kind != "" && f.Kind != c.Kind {
+			continue
+		}
+
+		if f.NodeSet == nil || names[c.Name] {
+			return true
+		}
+	}
+
+	return false
+}
+
+// NewAuditChange returns the change between the JSON encoded before and after
+// states of a host or boot image. It returns nil if nothing changed.
+func NewAuditChange(kind, name string, before, after []byte) *AuditChange {
+	diff := DiffJSON(before, after)
+	if len(diff) == 0 {
+		return nil
+	}
+
+	return &AuditChange{
+		Kind:   kind,
+		Name:   name,
+		Before: before,
+		After:  after,
+		Diff:   diff,
+	}
+}
+
+// DiffJSON returns the fields which differ between two JSON documents
+func DiffJSON(before, after []byte) []*AuditDiff {
+	a := make(map[string]interface{})
+	b := make(map[string]interface{})
+	flattenJSON(before, a)
+	flattenJSON(after, b)
+
+	paths := make([]string, 0, len(a)+len(b))
+	for p := range a {
+		paths = append(paths, p)
+	}
+	for p := range b {
+		if _, ok := a[p]; !ok {
+			paths = append(paths, p)
+		}
+	}
+	sort.Strings(paths)
+
+	diff := make([]*AuditDiff, 0)
+	for _, p := range paths {
+		if !reflect.DeepEqual(a[p], b[p]) {
+			diff = append(diff, &AuditDiff{Path: p, Before: a[p], After: b[p]})
+		}
+	}
+
+	return diff
+}
+
+func flattenJSON(data []byte, fields map[string]interface{}) {
+	if len(data) == 0 {
+		return
+	}
+
+	var v interface{}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return
+	}
+
+	flatten("", v, fields)
+}
+
+func flatten(prefix string, v interface{}, fields map[string]interface{}) {
+	join := func(k string) string {
+		if prefix == "" {
+			return k
+		}
+		return prefix + "." + k
+	}
+
+	switch val := v.(type) {
+	case map[string]interface{}:
+		for k, child := range val {
+			flatten(join(k), child, fields)
+		}
+	case []interface{}:
+		for i, child := range val {
+			flatten(join(strconv.Itoa(i)), child, fields)
+		}
+	default:
+		fields[prefix] = val
+	}
+}
+
+// AuditedStore wraps a DataStore and records every mutation of hosts and boot
+// images in the audit log on behalf of the given caller
+type AuditedStore struct {
+	DataStore
+	Caller  string
+	TokenID string
+}
+
+// NewAuditedStore returns a DataStore which records mutations made by caller
+func NewAuditedStore(store DataStore, caller, tokenID string) *AuditedStore {
+	return &AuditedStore{DataStore: store, Caller: caller, TokenID: tokenID}
+}
+
+// hostSnapshot returns the JSON encoded state of the given hosts keyed by name
+func (s *AuditedStore) hostSnapshot(ns *nodeset.NodeSet) (map[string][]byte, error) {
+	snap := make(map[string][]byte)
+
+	hosts, err := s.DataStore.FindHosts(ns)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, host := range hosts {
+		data, err := json.Marshal(host)
+		if err != nil {
+			return nil, err
+		}
+		snap[host.Name] = data
+	}
+
+	return snap, nil
+}
+
+// imageSnapshot returns the JSON encoded state of the given boot images keyed
+// by name
+func (s *AuditedStore) imageSnapshot(names []string) (map[string][]byte, error) {
+	snap := make(map[string][]byte)
+
+	for _, name := range names {
+		image, err := s.DataStore.LoadBootImage(strings.ToLower(name))
+		if err != nil {
+			continue
+		}
+
+		data, err := json.Marshal(image)
+		if err != nil {
+			return nil, err
+		}
+		snap[image.Name] = data
+	}
+
+	return snap, nil
+}
+
+// record stores an audit entry with the changes between the before and after
+// snapshots and prunes entries past the retention limits. Mutations are not
+// allowed to go unaudited, callers revert the mutation if storing the entry
+// fails. Pruning failures are only logged.
+func (s *AuditedStore) record(op, kind, set string, names []string, before, after map[string][]byte) error {
+	entry := &AuditEntry{
+		Timestamp: time.Now(),
+		Caller:    s.Caller,
+		TokenID:   s.TokenID,
+		Operation: op,
+		NodeSet:   set,
+		Changes:   make([]*AuditChange, 0),
+	}
+
+	for _, name := range names {
+		if c := NewAuditChange(kind, name, before[name], after[name]); c != nil {
+			entry.Changes = append(entry.Changes, c)
+		}
+	}
+
+	if len(entry.Changes) == 0 {
+		return nil
+	}
+
+	if err := s.DataStore.StoreAuditEntry(entry); err != nil {
+		return fmt.Errorf("Failed to store audit log entry for %s on %s: %w", op, set, err)
+	}
+
+	if AuditMaxAge > 0 || AuditMaxEntries > 0 {
+		n, err := s.DataStore.PruneAuditEntries(AuditMaxAge, AuditMaxEntries)
+		if err != nil {
+			log.WithFields(logrus.Fields{
+				"err":       err,
+				"operation": op,
+			}).Error("Failed to prune audit log")
+		} else if n > 0 {
+			log.Debugf("Pruned %d audit log entries", n)
+		}
+	}
+
+	if kind == AuditKindHost {
+		s.publishHostChanges(entry)
+	}
+
+	return nil
+}
+
+// restoreHosts reverts the hosts changed between the before and after
+// snapshots to their before state. Restored hosts which had been deleted are
+// given a new ID.
+func (s *AuditedStore) restoreHosts(before, after map[string][]byte) error {
+	hosts := make(HostList, 0)
+	for name, data := range before {
+		if bytes.Equal(data, after[name]) {
+			continue
+		}
+
+		host := &Host{}
+		if err := json.Unmarshal(data, host); err != nil {
+			return err
+		}
+		hosts = append(hosts, host)
+	}
+
+	added := make([]string, 0)
+	for name := range after {
+		if _, ok := before[name]; !ok {
+			added = append(added, name)
+		}
+	}
+
+	if len(added) > 0 {
+		ns, err := nodeset.NewNodeSet(strings.Join(added, ","))
+		if err != nil {
+			return err
+		}
+
+		if err := s.DataStore.DeleteHosts(ns); err != nil {
+			return err
+		}
+	}
+
+	if len(hosts) == 0 {
+		return nil
+	}
+
+	return s.DataStore.StoreHosts(hosts)
+}
+
+// restoreImages reverts the boot images changed between the before and after
+// snapshots to their before state
+func (s *AuditedStore) restoreImages(before, after map[string][]byte) error {
+	images := make(BootImageList, 0)
+	for name, data := range before {
+		if bytes.Equal(data, after[name]) {
+			continue
+		}
+
+		image := &BootImage{}
+		if err := json.Unmarshal(data, image); err != nil {
+			return err
+		}
+		images = append(images, image)
+	}
+
+	added := make([]string, 0)
+	for name := range after {
+		if _, ok := before[name]; !ok {
+			added = append(added, name)
+		}
+	}
+
+	if len(added) > 0 {
+		if err := s.DataStore.DeleteBootImages(added); err != nil {
+			return err
+		}
+	}
+
+	if len(images) == 0 {
+		return nil
+	}
+
+	return s.DataStore.StoreBootImages(images)
+}
+
+// revert undoes a mutation whose audit entry could not be stored and returns
+// the audit error
+func (s *AuditedStore) revert(op, set string, auditErr error, restore func() error) error {
+	if err := restore(); err != nil {
+		log.WithFields(logrus.Fields{
+			"err":       err,
+			"operation": op,
+			"nodeset":   set,
+		}).Error("Failed to revert unaudited change")
+		return fmt.Errorf("%w (change not reverted: %v)", auditErr, err)
+	}
+
+	return auditErr
+}
+
+// publishHostChanges publishes an event on the event bus for each host changed
+// by the audit entry
+func (s *AuditedStore) publishHostChanges(entry *AuditEntry) {
+	for _, c := range entry.Changes {
+		data := c.After
+		if len(data) == 0 {
+			data = c.Before
+		}
+
+		var host Host
+		if err := json.Unmarshal(data, &host); err != nil {
+			continue
+		}
+
+		events.Publish(&events.Event{
+			Time:    entry.Timestamp,
+			Service: "db",
+			Type:    events.TypeHostChanged,
+			Host:    c.Name,
+			Tags:    host.Tags,
+			Message: fmt.Sprintf("%s by %s", entry.Operation, entry.Caller),
+		})
+	}
+}
+
+// mutateHosts records the changes made to the hosts in ns by fn. The changes
+// are reverted if they can't be recorded.
+func (s *AuditedStore) mutateHosts(op string, ns *nodeset.NodeSet, fn func() error) error {
+	before, err := s.hostSnapshot(ns)
+	if err != nil {
+		return err
+	}
+
+	err = fn()
+	if err != nil {
+		return err
+	}
+
+	after, err := s.hostSnapshot(ns)
+	if err != nil {
+		return err
+	}
+
+	names := make([]string, 0, ns.Len())
+	it := ns.Iterator()
+	for it.Next() {
+		names = append(names, it.Value())
+	}
+
+	err = s.record(op, AuditKindHost, ns.String(), names, before, after)
+	if err != nil {
+		return s.revert(op, ns.String(), err, func() error {
+			return s.restoreHosts(before, after)
+		})
+	}
+
+	return nil
+}
+
+// StoreHost stores a host and records the change in the audit log
+func (s *AuditedStore) StoreHost(host *Host) error {
+	return s.StoreHosts(HostList{host})
+}
+
+// StoreHosts stores hosts and records the changes in the audit log
+func (s *AuditedStore) StoreHosts(hosts HostList) error {
+	return s.storeHosts(AuditOpHostStore, hosts)
+}
+
+func (s *AuditedStore) storeHosts(op string, hosts HostList) error {
+	names := make([]string, 0, len(hosts))
+	for _, host := range hosts {
+		names = append(names, strings.ToLower(host.Name))
+	}
+
+	ns, err := nodeset.NewNodeSet(strings.Join(names, ","))
+	if err != nil {
+		// Host names which are not valid nodesets are still stored but
+		// can't be audited
+		return s.DataStore.StoreHosts(hosts)
+	}
+
+	return s.mutateHosts(op, ns, func() error {
+		return s.DataStore.StoreHosts(hosts)
+	})
+}
+
+// DeleteHosts deletes hosts and records the changes in the audit log
+func (s *AuditedStore) DeleteHosts(ns *nodeset.NodeSet) error {
+	return s.mutateHosts(AuditOpHostDelete, ns, func() error {
+		return s.DataStore.DeleteHosts(ns)
+	})
+}
+
+// ProvisionHosts sets the provision flag and records the changes in the audit log
+func (s *AuditedStore) ProvisionHosts(ns *nodeset.NodeSet, provision bool) error {
+	op := AuditOpHostProvision
+	if !provision {
+		op = AuditOpHostUnprovision
+	}
+
+	return s.mutateHosts(op, ns, func() error {
+		return s.DataStore.ProvisionHosts(ns, provision)
+	})
+}
+
+// TagHosts adds tags and records the changes in the audit log
+func (s *AuditedStore) TagHosts(ns *nodeset.NodeSet, tags []string) error {
+	return s.mutateHosts(AuditOpHostTag, ns, func() error {
+		return s.DataStore.TagHosts(ns, tags)
+	})
+}
+
+// UntagHosts removes tags and records the changes in the audit log
+func (s *AuditedStore) UntagHosts(ns *nodeset.NodeSet, tags []string) error {
+	return s.mutateHosts(AuditOpHostUntag, ns, func() error {
+		return s.DataStore.UntagHosts(ns, tags)
+	})
+}
+
+// SetBootImage sets the boot image and records the changes in the audit log
+func (s *AuditedStore) SetBootImage(ns *nodeset.NodeSet, name string) error {
+	return s.mutateHosts(AuditOpHostBootImage, ns, func() error {
+		return s.DataStore.SetBootImage(ns, name)
+	})
+}
+
+// StoreBootImage stores a boot image and records the change in the audit log
+func (s *AuditedStore) StoreBootImage(image *BootImage) error {
+	return s.StoreBootImages(BootImageList{image})
+}
+
+// StoreBootImages stores boot images and records the changes in the audit log
+func (s *AuditedStore) StoreBootImages(images BootImageList) error {
+	names := make([]string, 0, len(images))
+	for _, image := range images {
+		names = append(names, strings.ToLower(image.Name))
+	}
+
+	return s.mutateImages(AuditOpImageStore, names, func() error {
+		return s.DataStore.StoreBootImages(images)
+	})
+}
+
+// DeleteBootImages deletes boot images and records the changes in the audit log
+func (s *AuditedStore) DeleteBootImages(names []string) error {
+	return s.mutateImages(AuditOpImageDelete, names, func() error {
+		return s.DataStore.DeleteBootImages(names)
+	})
+}
+
+// mutateImages records the changes made to the named boot images by fn. The
+// changes are reverted if they can't be recorded.
+func (s *AuditedStore) mutateImages(op string, names []string, fn func() error) error {
+	before, err := s.imageSnapshot(names)
+	if err != nil {
+		return err
+	}
+
+	err = fn()
+	if err != nil {
+		return err
+	}
+
+	after, err := s.imageSnapshot(names)
+	if err != nil {
+		return err
+	}
+
+	keys := make([]string, 0, len(names))
+	for _, name := range names {
+		keys = append(keys, strings.ToLower(name))
+	}
+
+	set := strings.Join(keys, ",")
+	err = s.record(op, AuditKindImage, set, keys, before, after)
+	if err != nil {
+		return s.revert(op, set, err, func() error {
+			return s.restoreImages(before, after)
+		})
+	}
+
+	return nil
+}
+
+// RevertHost restores a host to its state in the given audit log revision. If
+// before is true the host is restored to its state prior to the revision.
+func (s *AuditedStore) RevertHost(name string, revision int64, before bool) (*Host, error) {
+	entry, err := s.DataStore.LoadAuditEntry(revision)
+	if err != nil {
+		return nil, err
+	}
+
+	change := entry.Change(AuditKindHost, strings.ToLower(name))
+	if change == nil {
+		return nil, fmt.Errorf("host %s not changed in revision %d: %w", name, revision, ErrNotFound)
+	}
+
+	state := change.After
+	if before {
+		state = change.Before
+	}
+
+	if len(state) == 0 {
+		return nil, fmt.Errorf("host %s did not exist at revision %d: %w", name, revision, ErrInvalidData)
+	}
+
+	host := &Host{}
+	err = json.Unmarshal(state, host)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.storeHosts(AuditOpHostRevert, HostList{host})
+	if err != nil {
+		return nil, err
+	}
+
+	return host, nil
+}
@@ -0,0 +1,272 @@
+// Copyright 2019 Grendel Authors. All rights reserved.
+//
+// This file is part of Grendel.
+//
+// Grendel is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Grendel is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with Grendel. If not, see <https://www.gnu.org/licenses/>.
+
+package model_test
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/ubccr/grendel/internal/tests"
+	"github.com/ubccr/grendel/model"
+	"github.com/ubccr/grendel/nodeset"
+)
+
+func TestStoreAudit(t *testing.T) {
+	testStores(t, func(t *testing.T, store model.DataStore) {
+		assert := assert.New(t)
+
+		audited := model.NewAuditedStore(store, "alice", "token1")
+
+		for i := 0; i < 5; i++ {
+			host := tests.HostFactory.MustCreate().(*model.Host)
+			host.Name = fmt.Sprintf("tux-%02d", i)
+			assert.NoError(audited.StoreHost(host))
+		}
+
+		ns, err := nodeset.NewNodeSet("tux-[00-02]")
+		assert.NoError(err)
+		assert.NoError(model.NewAuditedStore(store, "bob", "").TagHosts(ns, []string{"k16"}))
+
+		// No changes are not recorded
+		assert.NoError(audited.TagHosts(ns, []string{"k16"}))
+
+		entries, err := store.AuditEntries(nil)
+		if assert.NoError(err) && assert.Equal(6, len(entries)) {
+			assert.Equal(int64(6), entries[0].ID)
+			assert.Equal(model.AuditOpHostTag, entries[0].Operation)
+			assert.Equal("bob", entries[0].Caller)
+			assert.Equal("tux-[00-02]", entries[0].NodeSet)
+			if assert.Equal(3, len(entries[0].Changes)) {
+				c := entries[0].Changes[0]
+				assert.Equal("tux-00", c.Name)
+				if assert.Equal(1, len(c.Diff)) {
+					assert.Equal("tags.0", c.Diff[0].Path)
+					assert.Nil(c.Diff[0].Before)
+					assert.Equal("k16", c.Diff[0].After)
+				}
+			}
+
+			assert.Equal(model.AuditOpHostStore, entries[5].Operation)
+			assert.Equal("token1", entries[5].TokenID)
+			assert.Nil(entries[5].Changes[0].Before)
+		}
+
+		entries, err = store.AuditEntries(&model.AuditFilter{Caller: "alice"})
+		if assert.NoError(err) {
+			assert.Equal(5, len(entries))
+		}
+
+		entries, err = store.AuditEntries(&model.AuditFilter{NodeSet: ns, Limit: 2})
+		if assert.NoError(err) && assert.Equal(2, len(entries)) {
+			assert.Equal(model.AuditOpHostTag, entries[0].Operation)
+			assert.Equal(model.AuditOpHostStore, entries[1].Operation)
+			assert.Equal("tux-02", entries[1].NodeSet)
+		}
+
+		entries, err = store.AuditEntries(&model.AuditFilter{Operation: model.AuditOpHostTag, Kind: model.AuditKindImage})
+		if assert.NoError(err) {
+			assert.Equal(0, len(entries))
+		}
+
+		entries, err = store.AuditEntries(&model.AuditFilter{Since: time.Now().Add(time.Hour)})
+		if assert.NoError(err) {
+			assert.Equal(0, len(entries))
+		}
+
+		entry, err := store.LoadAuditEntry(6)
+		if assert.NoError(err) {
+			assert.Equal("bob", entry.Caller)
+		}
+
+		_, err = store.LoadAuditEntry(100)
+		if assert.Error(err) {
+			assert.True(errors.Is(err, model.ErrNotFound))
+		}
+	})
+}
+
+func TestStoreAuditRevert(t *testing.T) {
+	testStores(t, func(t *testing.T, store model.DataStore) {
+		assert := assert.New(t)
+
+		audited := model.NewAuditedStore(store, "alice", "")
+
+		host := tests.HostFactory.MustCreate().(*model.Host)
+		host.Name = "tux-01"
+		host.BootImage = "centos7"
+		assert.NoError(audited.StoreHost(host))
+
+		ns, err := nodeset.NewNodeSet(host.Name)
+		assert.NoError(err)
+		assert.NoError(audited.SetBootImage(ns, "rocky9"))
+		assert.NoError(audited.DeleteHosts(ns))
+
+		entries, err := store.AuditEntries(&model.AuditFilter{NodeSet: ns})
+		if !assert.NoError(err) || !assert.Equal(3, len(entries)) {
+			return
+		}
+
+		deleted := entries[0]
+		assert.Equal(model.AuditOpHostDelete, deleted.Operation)
+		assert.Nil(deleted.Changes[0].After)
+
+		_, err = audited.RevertHost(host.Name, deleted.ID, false)
+		if assert.Error(err) {
+			assert.True(errors.Is(err, model.ErrInvalidData))
+		}
+
+		testHost, err := audited.RevertHost(host.Name, deleted.ID, true)
+		if assert.NoError(err) {
+			assert.Equal("rocky9", testHost.BootImage)
+		}
+
+		testHost, err = audited.RevertHost(host.Name, entries[2].ID, false)
+		if assert.NoError(err) {
+			assert.Equal("centos7", testHost.BootImage)
+		}
+
+		testHost, err = store.LoadHostFromName(host.Name)
+		if assert.NoError(err) {
+			assert.Equal("centos7", testHost.BootImage)
+			assert.Equal(host.Interfaces[0].MAC.String(), testHost.Interfaces[0].MAC.String())
+		}
+
+		entries, err = store.AuditEntries(&model.AuditFilter{Operation: model.AuditOpHostRevert})
+		if assert.NoError(err) {
+			assert.Equal(2, len(entries))
+		}
+
+		_, err = audited.RevertHost("notfound", deleted.ID, false)
+		if assert.Error(err) {
+			assert.True(errors.Is(err, model.ErrNotFound))
+		}
+	})
+}
+
+func TestStoreAuditPrune(t *testing.T) {
+	testStores(t, func(t *testing.T, store model.DataStore) {
+		assert := assert.New(t)
+
+		for i := 0; i < 6; i++ {
+			entry := &model.AuditEntry{
+				Timestamp: time.Now().Add(time.Duration(i-6) * time.Hour),
+				Caller:    "alice",
+				Operation: model.AuditOpHostStore,
+				NodeSet:   fmt.Sprintf("tux-%02d", i),
+				Changes: []*model.AuditChange{
+					model.NewAuditChange(model.AuditKindHost, fmt.Sprintf("tux-%02d", i), nil, []byte(`{"provision":true}`)),
+				},
+			}
+			assert.NoError(store.StoreAuditEntry(entry))
+		}
+
+		n, err := store.PruneAuditEntries(0, 0)
+		if assert.NoError(err) {
+			assert.Equal(0, n)
+		}
+
+		n, err = store.PruneAuditEntries(0, 4)
+		if assert.NoError(err) {
+			assert.Equal(2, n)
+		}
+
+		n, err = store.PruneAuditEntries(150*time.Minute, 4)
+		if assert.NoError(err) {
+			assert.Equal(2, n)
+		}
+
+		entries, err := store.AuditEntries(nil)
+		if assert.NoError(err) && assert.Equal(2, len(entries)) {
+			assert.Equal(int64(6), entries[0].ID)
+			assert.Equal(int64(5), entries[1].ID)
+		}
+
+		ns, err := nodeset.NewNodeSet("tux-00")
+		assert.NoError(err)
+
+		entries, err = store.AuditEntries(&model.AuditFilter{NodeSet: ns})
+		if assert.NoError(err) {
+			assert.Equal(0, len(entries))
+		}
+
+		_, err = store.LoadAuditEntry(1)
+		assert.True(errors.Is(err, model.ErrNotFound))
+	})
+}
+
+// failingAuditStore fails to store audit entries
+type failingAuditStore struct {
+	model.DataStore
+}
+
+func (s *failingAuditStore) StoreAuditEntry(entry *model.AuditEntry) error {
+	return errors.New("disk full")
+}
+
+func TestStoreAuditFailure(t *testing.T) {
+	testStores(t, func(t *testing.T, store model.DataStore) {
+		assert := assert.New(t)
+
+		host := tests.HostFactory.MustCreate().(*model.Host)
+		host.Name = "tux-01"
+		host.BootImage = "centos7"
+		assert.NoError(model.NewAuditedStore(store, "alice", "").StoreHost(host))
+
+		audited := model.NewAuditedStore(&failingAuditStore{store}, "alice", "")
+
+		ns, err := nodeset.NewNodeSet(host.Name)
+		assert.NoError(err)
+		assert.Error(audited.SetBootImage(ns, "rocky9"))
+		assert.Error(audited.DeleteHosts(ns))
+
+		testHost, err := store.LoadHostFromName(host.Name)
+		if assert.NoError(err) {
+			assert.Equal("centos7", testHost.BootImage)
+		}
+
+		newHost := tests.HostFactory.MustCreate().(*model.Host)
+		newHost.Name = "tux-02"
+		assert.Error(audited.StoreHost(newHost))
+
+		_, err = store.LoadHostFromName(newHost.Name)
+		assert.True(errors.Is(err, model.ErrNotFound))
+
+		image := tests.BootImageFactory.MustCreate().(*model.BootImage)
+		assert.Error(audited.StoreBootImage(image))
+
+		_, err = store.LoadBootImage(image.Name)
+		assert.True(errors.Is(err, model.ErrNotFound))
+	})
+}
+
+func TestDiffJSON(t *testing.T) {
+	assert := assert.New(t)
+
+	diff := model.DiffJSON([]byte(`{"name":"tux","tags":["a"],"interfaces":[{"ip":"10.0.0.1/24"}]}`), []byte(`{"name":"tux","tags":["a","b"],"interfaces":[{"ip":"10.0.0.2/24"}]}`))
+	if assert.Equal(2, len(diff)) {
+		assert.Equal("interfaces.0.ip", diff[0].Path)
+		assert.Equal("10.0.0.1/24", diff[0].Before)
+		assert.Equal("10.0.0.2/24", diff[0].After)
+		assert.Equal("tags.1", diff[1].Path)
+	}
+
+	assert.Equal(0, len(model.DiffJSON([]byte(`{"a":1}`), []byte(`{"a":1}`))))
+}
@@ -22,6 +22,13 @@ import (
 	"path/filepath"
 
 	"github.com/segmentio/ksuid"
+	"github.com/ubccr/grendel/firmware"
+)
+
+const (
+	BootImageStatusPending = "pending"
+	BootImageStatusReady   = "ready"
+	BootImageStatusFailed  = "failed"
 )
 
 type BootImageList []*BootImage
@@ -29,7 +36,7 @@ type BootImageList []*BootImage
 type BootImage struct {
 	ID                 ksuid.KSUID       `json:"id"`
 	Name               string            `json:"name" validate:"required"`
-	KernelPath         string            `json:"kernel" validate:"required"`
+	KernelPath         string            `json:"kernel" validate:"required_without=Sources"`
 	InitrdPaths        []string          `json:"initrd"`
 	LiveImage          string            `json:"liveimg"`
 	CommandLine        string            `json:"cmdline"`
@@ -38,12 +45,59 @@ type BootImage struct {
 	ProvisionTemplates map[string]string `json:"provision_templates"`
 	UserData           string            `json:"user_data"`
 	Butane             string            `json:"butane"`
+
+	// RootFS is the path of a root filesystem (squashfs or tarball) for
+	// stateless hosts. It's served at the rootfs endpoint and passed to
+	// dracut as root=live:<url>
+	RootFS string `json:"rootfs,omitempty"`
+
+	// Artifacts are the files of the image uploaded to the artifact store
+	Artifacts []*Artifact `json:"artifacts,omitempty"`
+
+	// Firmware is the firmware build served to hosts using the image which
+	// don't set one themselves
+	Firmware firmware.Build `json:"firmware,omitempty"`
+
+	// Sources are remote files fetched into the artifact store in the
+	// background. Images with sources are only served once Status is ready.
+	Sources       []*Source `json:"sources,omitempty"`
+	Status        string    `json:"status,omitempty"`
+	StatusMessage string    `json:"status_message,omitempty"`
 }
 
 func NewBootImageList() BootImageList {
 	return make(BootImageList, 0)
 }
 
+// Ready returns true if the files of the image can be served. Images without
+// sources are always ready.
+func (b *BootImage) Ready() bool {
+	return len(b.Sources) == 0 || b.Status == BootImageStatusReady
+}
+
+// ResetStatus marks an image with sources pending until the fetcher has
+// verified its files
+func (b *BootImage) ResetStatus() {
+	if len(b.Sources) > 0 {
+		b.Status = BootImageStatusPending
+		b.StatusMessage = ""
+	}
+}
+
+// Paths returns the kernel, initrd, live image and rootfs paths of the image
+func (b *BootImage) Paths() []string {
+	paths := []string{b.KernelPath}
+	paths = append(paths, b.InitrdPaths...)
+	if b.LiveImage != "" {
+		paths = append(paths, b.LiveImage)
+	}
+	if b.RootFS != "" {
+		paths = append(paths, b.RootFS)
+	}
+
+	return paths
+}
+
 func (b *BootImage) CheckPathsExist() error {
 	if _, err := os.Stat(b.KernelPath); err != nil {
 		return err
@@ -61,6 +115,12 @@ func (b *BootImage) CheckPathsExist() error {
 		}
 	}
 
+	if b.RootFS != "" {
+		if _, err := os.Stat(b.RootFS); err != nil {
+			return err
+		}
+	}
+
 	if b.ProvisionTemplate != "" {
 		if _, err := os.Stat(filepath.Join("/var/lib/grendel/templates", b.ProvisionTemplate)); err != nil {
 			return err
@@ -23,7 +23,9 @@ import (
 	"fmt"
 	"net"
 	"net/netip"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/segmentio/ksuid"
 	"github.com/sirupsen/logrus"
@@ -35,8 +37,21 @@ import (
 )
 
 const (
-	HostKeyPrefix      = "host"
-	BootImageKeyPrefix = "image"
+	HostKeyPrefix         = "host"
+	BootImageKeyPrefix    = "image"
+	MACIndexPrefix        = "idx:mac"
+	FQDNIndexPrefix       = "idx:fqdn"
+	IPIndexPrefix         = "idx:ip"
+	AliasIndexPrefix      = "idx:alias"
+	CircuitIndexPrefix    = "idx:circuit"
+	UUIDIndexPrefix       = "idx:uuid"
+	AuditKeyPrefix        = "audit"
+	ProvisionKeyPrefix    = "provision"
+	LeaseKeyPrefix        = "lease"
+	LeaseIPKeyPrefix      = "leaseip"
+	UnregisteredKeyPrefix = "unregistered"
+	SchemaVersionKey      = "meta:schema_version"
+	AuditSequenceKey      = "meta:audit_sequence"
 )
 
 // BuntStore implements a Grendel Datastore using BuntDB
@@ -56,7 +71,274 @@ func NewBuntStore(filename string) (*BuntStore, error) {
 		return nil, err
 	}
 
-	return &BuntStore{db: db}, nil
+	s := &BuntStore{db: db}
+
+	err = s.reindex()
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	err = s.initSchemaVersion()
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// initSchemaVersion records the current schema version in a new database.
+// Databases created by older releases have no schema version and are left
+// as is until migrated.
+func (s *BuntStore) initSchemaVersion() error {
+	return s.db.Update(func(tx *buntdb.Tx) error {
+		_, err := tx.Get(SchemaVersionKey)
+		if err != buntdb.ErrNotFound {
+			return err
+		}
+
+		empty := true
+		err = tx.AscendKeys("*", func(key, value string) bool {
+			if strings.HasPrefix(key, HostKeyPrefix+":") || strings.HasPrefix(key, BootImageKeyPrefix+":") {
+				empty = false
+				return false
+			}
+			return true
+		})
+		if err != nil || !empty {
+			return err
+		}
+
+		_, _, err = tx.Set(SchemaVersionKey, strconv.Itoa(SchemaVersion), nil)
+		return err
+	})
+}
+
+// SchemaVersion returns the schema version of the records in the data store.
+// Databases created before schema versions were recorded are version 1.
+func (s *BuntStore) SchemaVersion() (int, error) {
+	version := 1
+	err := s.db.View(func(tx *buntdb.Tx) error {
+		val, err := tx.Get(SchemaVersionKey)
+		if err == buntdb.ErrNotFound {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		version, err = strconv.Atoi(val)
+		return err
+	})
+
+	return version, err
+}
+
+// Migrate upgrades all host records to the current SchemaVersion. Host
+// records are upgraded when parsed so this rewrites each host in the current
+// format.
+func (s *BuntStore) Migrate() error {
+	version, err := s.SchemaVersion()
+	if err != nil {
+		return err
+	}
+
+	if version > SchemaVersion {
+		return fmt.Errorf("database schema version %d is newer than supported version %d", version, SchemaVersion)
+	}
+
+	if version == SchemaVersion {
+		return nil
+	}
+
+	hosts, err := s.Hosts()
+	if err != nil {
+		return err
+	}
+
+	err = s.db.Update(func(tx *buntdb.Tx) error {
+		for _, host := range hosts {
+			key := HostKeyPrefix + ":" + host.Name
+			err := deleteHostIndex(tx, key)
+			if err != nil {
+				return err
+			}
+
+			_, _, err = tx.Set(key, host.ToJSON(), nil)
+			if err != nil {
+				return err
+			}
+
+			err = setHostIndex(tx, host)
+			if err != nil {
+				return err
+			}
+		}
+
+		_, _, err := tx.Set(SchemaVersionKey, strconv.Itoa(SchemaVersion), nil)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	log.Infof("Migrated %d hosts from schema version %d to %d", len(hosts), version, SchemaVersion)
+
+	return nil
+}
+
+// hostIndexKeys returns the secondary index keys for the given host. Each key
+// is suffixed with the host name so multiple hosts can share the same indexed
+// value. The value stored at each key is the host name.
+func hostIndexKeys(host *Host) []string {
+	keys := make([]string, 0)
+	for _, nic := range host.Interfaces {
+		if len(nic.MAC) > 0 {
+			keys = append(keys, MACIndexPrefix+":"+nic.MAC.String()+":"+host.Name)
+		}
+		if nic.FQDN != "" {
+			keys = append(keys, FQDNIndexPrefix+":"+util.Normalize(nic.FQDN)+":"+host.Name)
+		}
+		for _, p := range nic.Prefixes() {
+			keys = append(keys, IPIndexPrefix+":"+p.Addr().Unmap().String()+":"+host.Name)
+		}
+		for _, a := range nic.Aliases {
+			keys = append(keys, AliasIndexPrefix+":"+util.Normalize(a)+":"+host.Name)
+		}
+		if nic.CircuitID != "" {
+			keys = append(keys, CircuitIndexPrefix+":"+nic.CircuitID+":"+host.Name)
+		}
+	}
+
+	for _, a := range host.Aliases {
+		keys = append(keys, AliasIndexPrefix+":"+util.Normalize(a)+":"+host.Name)
+	}
+
+	if host.UUID != "" {
+		keys = append(keys, UUIDIndexPrefix+":"+strings.ToLower(host.UUID)+":"+host.Name)
+	}
+
+	return keys
+}
+
+// setHostIndex adds the secondary index keys for the given host
+func setHostIndex(tx *buntdb.Tx, host *Host) error {
+	for _, key := range hostIndexKeys(host) {
+		_, _, err := tx.Set(key, host.Name, nil)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// deleteHostIndex removes the secondary index keys for the host stored at the
+// given key, if it exists
+func deleteHostIndex(tx *buntdb.Tx, key string) error {
+	val, err := tx.Get(key, false)
+	if err != nil {
+		if err != buntdb.ErrNotFound {
+			return err
+		}
+		return nil
+	}
+
+	host := &Host{}
+	host.FromJSON(val)
+	for _, idx := range hostIndexKeys(host) {
+		_, err := tx.Delete(idx)
+		if err != nil && err != buntdb.ErrNotFound {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// ascendIndex iterates over the host names stored in a secondary index with
+// the given prefix
+func ascendIndex(tx *buntdb.Tx, prefix string, iterator func(name string) bool) error {
+	prefix += ":"
+	return tx.AscendGreaterOrEqual("", prefix, func(key, value string) bool {
+		if !strings.HasPrefix(key, prefix) {
+			return false
+		}
+
+		return iterator(value)
+	})
+}
+
+// reindex rebuilds all secondary indexes from the stored host records
+func (s *BuntStore) reindex() error {
+	return s.db.Update(func(tx *buntdb.Tx) error {
+		stale := make([]string, 0)
+		err := tx.AscendKeys("idx:*", func(key, value string) bool {
+			stale = append(stale, key)
+			return true
+		})
+		if err != nil {
+			return err
+		}
+
+		for _, key := range stale {
+			_, err := tx.Delete(key)
+			if err != nil && err != buntdb.ErrNotFound {
+				return err
+			}
+		}
+
+		hosts := make([]*Host, 0)
+		err = tx.AscendKeys(HostKeyPrefix+":*", func(key, value string) bool {
+			h := &Host{}
+			h.FromJSON(value)
+			hosts = append(hosts, h)
+			return true
+		})
+		if err != nil {
+			return err
+		}
+
+		for _, h := range hosts {
+			err := setHostIndex(tx, h)
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+// loadIndexedHosts returns the hosts referenced by a secondary index with the
+// given prefix
+func loadIndexedHosts(tx *buntdb.Tx, prefix string) (HostList, error) {
+	names := make([]string, 0)
+	err := ascendIndex(tx, prefix, func(name string) bool {
+		names = append(names, name)
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	hosts := make(HostList, 0, len(names))
+	for _, name := range names {
+		val, err := tx.Get(HostKeyPrefix+":"+name, false)
+		if err != nil {
+			if err != buntdb.ErrNotFound {
+				return nil, err
+			}
+			continue
+		}
+
+		h := &Host{}
+		h.FromJSON(val)
+		hosts = append(hosts, h)
+	}
+
+	return hosts, nil
 }
 
 // Close closes the BuntStore database
@@ -98,14 +380,56 @@ func (s *BuntStore) StoreHosts(hosts HostList) error {
 		host.ID = checkHost.ID
 	}
 
+	if err := checkUUIDs(hosts); err != nil {
+		return err
+	}
+
+	names := make(map[string]bool, len(hosts))
+	for _, host := range hosts {
+		names[host.Name] = true
+	}
+
 	err := s.db.Update(func(tx *buntdb.Tx) error {
+		for _, host := range hosts {
+			if host.UUID == "" {
+				continue
+			}
+
+			var owner string
+			err := ascendIndex(tx, UUIDIndexPrefix+":"+strings.ToLower(host.UUID), func(name string) bool {
+				if names[name] {
+					return true
+				}
+				owner = name
+				return false
+			})
+			if err != nil {
+				return err
+			}
+
+			if owner != "" {
+				return fmt.Errorf("uuid %s is already set on host %s:  %w", host.UUID, owner, ErrDuplicateEntry)
+			}
+		}
+
 		for _, host := range hosts {
 			val, err := json.Marshal(host)
 			if err != nil {
 				return err
 			}
 
-			_, _, err = tx.Set(HostKeyPrefix+":"+host.Name, string(val), nil)
+			key := HostKeyPrefix + ":" + host.Name
+			err = deleteHostIndex(tx, key)
+			if err != nil {
+				return err
+			}
+
+			_, _, err = tx.Set(key, string(val), nil)
+			if err != nil {
+				return err
+			}
+
+			err = setHostIndex(tx, host)
 			if err != nil {
 				return err
 			}
@@ -123,10 +447,24 @@ func (s *BuntStore) DeleteHosts(ns *nodeset.NodeSet) error {
 
 	err := s.db.Update(func(tx *buntdb.Tx) error {
 		for it.Next() {
-			_, err := tx.Delete(HostKeyPrefix + ":" + it.Value())
+			key := HostKeyPrefix + ":" + it.Value()
+			err := deleteHostIndex(tx, key)
+			if err != nil {
+				return err
+			}
+
+			_, err = tx.Delete(key)
+			if err == buntdb.ErrNotFound {
+				return fmt.Errorf("host with name %s:  %w", it.Value(), ErrNotFound)
+			}
 			if err != nil {
 				return err
 			}
+
+			_, err = tx.Delete(ProvisionKeyPrefix + ":" + it.Value())
+			if err != nil && err != buntdb.ErrNotFound {
+				return err
+			}
 		}
 
 		return nil
@@ -196,28 +534,40 @@ func (s *BuntStore) LoadHostFromID(id string) (*Host, error) {
 
 // ResolveIPv4 returns the list of IPv4 addresses with the given FQDN
 func (s *BuntStore) ResolveIPv4(fqdn string) ([]net.IP, error) {
+	return s.resolve(fqdn, true)
+}
+
+// ResolveIPv6 returns the list of IPv6 addresses with the given FQDN
+func (s *BuntStore) ResolveIPv6(fqdn string) ([]net.IP, error) {
+	return s.resolve(fqdn, false)
+}
+
+// resolve returns the list of IPv4 or IPv6 addresses with the given FQDN
+func (s *BuntStore) resolve(fqdn string, v4 bool) ([]net.IP, error) {
 	fqdn = util.Normalize(fqdn)
 	ips := make([]net.IP, 0)
 
 	err := s.db.View(func(tx *buntdb.Tx) error {
-		err := tx.AscendKeys(HostKeyPrefix+":*", func(key, value string) bool {
-			res := gjson.Get(value, "interfaces")
-			for _, i := range res.Array() {
-				if util.Normalize(i.Get("fqdn").String()) == fqdn {
-					ip, _ := netip.ParsePrefix(i.Get("ip").String())
-					if ip.IsValid() {
-						ips = append(ips, net.IP(ip.Addr().AsSlice()))
-					}
+		hosts, err := loadIndexedHosts(tx, FQDNIndexPrefix+":"+fqdn)
+		if err != nil {
+			return err
+		}
 
-					// XXX stop after first match. consider changing this
-					return false
+		for _, host := range hosts {
+			for _, nic := range host.Interfaces {
+				if util.Normalize(nic.FQDN) != fqdn {
+					continue
 				}
-			}
 
-			return true
-		})
+				for _, p := range nic.Prefixes() {
+					if p.Addr().Unmap().Is4() == v4 {
+						ips = append(ips, net.IP(p.Addr().Unmap().AsSlice()))
+					}
+				}
+			}
+		}
 
-		return err
+		return nil
 	})
 
 	if err != nil {
@@ -227,68 +577,181 @@ func (s *BuntStore) ResolveIPv4(fqdn string) ([]net.IP, error) {
 	return ips, nil
 }
 
-// ReverseResolve returns the list of FQDNs for the given IP
-func (s *BuntStore) ReverseResolve(ip string) ([]string, error) {
-	fqdn := make([]string, 0)
+// ResolveAlias returns the FQDN the given alias points to or an empty string
+// if there is no such alias
+func (s *BuntStore) ResolveAlias(name string) (string, error) {
+	name = util.Normalize(name)
+	target := ""
 
 	err := s.db.View(func(tx *buntdb.Tx) error {
-		err := tx.AscendKeys(HostKeyPrefix+":*", func(key, value string) bool {
-			res := gjson.Get(value, "interfaces")
-			for _, i := range res.Array() {
-				if strings.Contains(i.Get("ip").String(), ip) {
-					name := i.Get("fqdn").String()
-					if name != "" {
-						fqdn = append(fqdn, name)
-					}
+		hosts, err := loadIndexedHosts(tx, AliasIndexPrefix+":"+name)
+		if err != nil {
+			return err
+		}
 
-					// XXX stop after first match. consider changing this
-					return false
-				}
+		for _, host := range hosts {
+			if target = host.AliasTarget(name); target != "" {
+				break
 			}
+		}
 
-			return true
-		})
-
-		return err
+		return nil
 	})
 
 	if err != nil {
-		return nil, err
+		return "", err
 	}
 
-	return fqdn, nil
+	return target, nil
 }
 
-// LoadHostFromMAC returns the Host that has a network interface with the give MAC address
-func (s *BuntStore) LoadHostFromMAC(mac string) (*Host, error) {
-	hostJSON := ""
+// ReverseResolve returns the list of FQDNs for the given IP. Addresses are
+// compared exactly and the FQDN of every matching interface is returned.
+func (s *BuntStore) ReverseResolve(ip string) ([]string, error) {
+	fqdn := make([]string, 0)
 
-	err := s.db.View(func(tx *buntdb.Tx) error {
-		err := tx.AscendKeys(HostKeyPrefix+":*", func(key, value string) bool {
-			res := gjson.Get(value, "interfaces")
-			for _, i := range res.Array() {
-				if i.Get("mac").String() == mac {
-					hostJSON = value
-					return false
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return fqdn, nil
+	}
+	addr = addr.Unmap()
+
+	err = s.db.View(func(tx *buntdb.Tx) error {
+		hosts, err := loadIndexedHosts(tx, IPIndexPrefix+":"+addr.String())
+		if err != nil {
+			return err
+		}
+
+		for _, host := range hosts {
+			for _, nic := range host.Interfaces {
+				if nic.FQDN == "" {
+					continue
 				}
-			}
 
-			return true
-		})
+				if nic.HasAddr(addr) {
+					fqdn = append(fqdn, nic.FQDN)
+				}
+			}
+		}
 
-		return err
+		return nil
 	})
 
 	if err != nil {
 		return nil, err
 	}
 
-	if hostJSON == "" {
-		return nil, fmt.Errorf("no host found with mac address %s:  %w", mac, ErrNotFound)
-	}
+	return fqdn, nil
+}
+
+// LoadHostFromMAC returns the Host that has a network interface with the give MAC address
+func (s *BuntStore) LoadHostFromMAC(mac string) (*Host, error) {
+	var host *Host
+
+	hwaddr, err := net.ParseMAC(mac)
+	if err != nil {
+		return nil, fmt.Errorf("no host found with mac address %s:  %w", mac, ErrNotFound)
+	}
+
+	err = s.db.View(func(tx *buntdb.Tx) error {
+		hosts, err := loadIndexedHosts(tx, MACIndexPrefix+":"+hwaddr.String())
+		if err != nil {
+			return err
+		}
+
+		// The index prefix also matches longer hardware addresses starting
+		// with the same bytes so check the interface actually matches.
+		// XXX What to about dups? We only fetch first one.
+		for _, h := range hosts {
+			if h.Interface(hwaddr) != nil {
+				host = h
+				break
+			}
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	if host == nil {
+		return nil, fmt.Errorf("no host found with mac address %s:  %w", mac, ErrNotFound)
+	}
+
+	return host, nil
+}
+
+// LoadHostFromRelay returns the Host that has a network interface identified by
+// the given relay agent circuit id and remote id
+func (s *BuntStore) LoadHostFromRelay(circuitID, remoteID string) (*Host, error) {
+	var host *Host
+
+	if circuitID == "" {
+		return nil, fmt.Errorf("no host found with circuit id %s:  %w", circuitID, ErrNotFound)
+	}
+
+	err := s.db.View(func(tx *buntdb.Tx) error {
+		hosts, err := loadIndexedHosts(tx, CircuitIndexPrefix+":"+circuitID)
+		if err != nil {
+			return err
+		}
+
+		for _, h := range hosts {
+			if h.InterfaceFromRelay(circuitID, remoteID) != nil {
+				host = h
+				break
+			}
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	if host == nil {
+		return nil, fmt.Errorf("no host found with circuit id %s:  %w", circuitID, ErrNotFound)
+	}
+
+	return host, nil
+}
+
+// LoadHostFromUUID returns the Host with the given system UUID
+func (s *BuntStore) LoadHostFromUUID(uuid string) (*Host, error) {
+	var host *Host
+
+	if uuid == "" || PlaceholderUUID(uuid) {
+		return nil, fmt.Errorf("no host found with uuid %s:  %w", uuid, ErrNotFound)
+	}
+
+	err := s.db.View(func(tx *buntdb.Tx) error {
+		hosts, err := loadIndexedHosts(tx, UUIDIndexPrefix+":"+strings.ToLower(uuid))
+		if err != nil {
+			return err
+		}
+
+		if len(hosts) > 1 {
+			return fmt.Errorf("%d hosts found with uuid %s:  %w", len(hosts), uuid, ErrDuplicateEntry)
+		}
+
+		if len(hosts) > 0 {
+			host = hosts[0]
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	if host == nil {
+		return nil, fmt.Errorf("no host found with uuid %s:  %w", uuid, ErrNotFound)
+	}
 
-	host := &Host{}
-	host.FromJSON(hostJSON)
 	return host, nil
 }
 
@@ -622,6 +1085,9 @@ func (s *BuntStore) DeleteBootImages(names []string) error {
 	err := s.db.Update(func(tx *buntdb.Tx) error {
 		for _, name := range names {
 			_, err := tx.Delete(BootImageKeyPrefix + ":" + name)
+			if err == buntdb.ErrNotFound {
+				return fmt.Errorf("boot image with name %s:  %w", name, ErrNotFound)
+			}
 			if err != nil {
 				return err
 			}
@@ -695,3 +1161,500 @@ func (s *BuntStore) BootImages() (BootImageList, error) {
 
 	return images, nil
 }
+
+// auditKey returns the key for the audit entry with the given ID. IDs are zero
+// padded so keys sort in revision order.
+func auditKey(id int64) string {
+	return fmt.Sprintf("%s:%020d", AuditKeyPrefix, id)
+}
+
+// StoreAuditEntry stores an entry in the audit log and sets its ID
+func (s *BuntStore) StoreAuditEntry(entry *AuditEntry) error {
+	return s.db.Update(func(tx *buntdb.Tx) error {
+		var seq int64
+		val, err := tx.Get(AuditSequenceKey)
+		if err != nil && err != buntdb.ErrNotFound {
+			return err
+		}
+
+		if err == nil {
+			seq, err = strconv.ParseInt(val, 10, 64)
+			if err != nil {
+				return err
+			}
+		}
+
+		entry.ID = seq + 1
+
+		data, err := json.Marshal(entry)
+		if err != nil {
+			return err
+		}
+
+		_, _, err = tx.Set(auditKey(entry.ID), string(data), nil)
+		if err != nil {
+			return err
+		}
+
+		_, _, err = tx.Set(AuditSequenceKey, strconv.FormatInt(entry.ID, 10), nil)
+		return err
+	})
+}
+
+// AuditEntries returns the audit log entries matching the filter, newest first
+func (s *BuntStore) AuditEntries(filter *AuditFilter) (AuditEntryList, error) {
+	entries := make(AuditEntryList, 0)
+
+	err := s.db.View(func(tx *buntdb.Tx) error {
+		return tx.DescendKeys(AuditKeyPrefix+":*", func(key, value string) bool {
+			var entry AuditEntry
+			err := json.Unmarshal([]byte(value), &entry)
+			if err != nil {
+				log.WithFields(logrus.Fields{
+					"err": err,
+					"key": key,
+				}).Warn("Invalid audit entry json stored in db")
+				return true
+			}
+
+			if filter != nil && !filter.Match(&entry) {
+				return true
+			}
+
+			entries = append(entries, &entry)
+
+			return filter == nil || filter.Limit <= 0 || len(entries) < filter.Limit
+		})
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return entries, nil
+}
+
+// LoadAuditEntry returns the audit log entry with the given ID
+func (s *BuntStore) LoadAuditEntry(id int64) (*AuditEntry, error) {
+	var entry AuditEntry
+
+	err := s.db.View(func(tx *buntdb.Tx) error {
+		val, err := tx.Get(auditKey(id))
+		if err != nil {
+			return err
+		}
+
+		return json.Unmarshal([]byte(val), &entry)
+	})
+
+	if err == buntdb.ErrNotFound {
+		return nil, fmt.Errorf("audit entry with id %d:  %w", id, ErrNotFound)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &entry, nil
+}
+
+// PruneAuditEntries deletes audit log entries older than maxAge and all but
+// the newest maxEntries entries
+func (s *BuntStore) PruneAuditEntries(maxAge time.Duration, maxEntries int) (int, error) {
+	var cutoff time.Time
+	if maxAge > 0 {
+		cutoff = time.Now().Add(-maxAge)
+	}
+
+	count := 0
+	err := s.db.Update(func(tx *buntdb.Tx) error {
+		var seq int64
+		val, err := tx.Get(AuditSequenceKey)
+		if err == buntdb.ErrNotFound {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		seq, err = strconv.ParseInt(val, 10, 64)
+		if err != nil {
+			return err
+		}
+
+		// Entries are keyed in ID order so stop at the first entry kept
+		keys := make([]string, 0)
+		err = tx.AscendKeys(AuditKeyPrefix+":*", func(key, value string) bool {
+			var entry AuditEntry
+			if err := json.Unmarshal([]byte(value), &entry); err != nil {
+				keys = append(keys, key)
+				return true
+			}
+
+			if maxEntries > 0 && entry.ID <= seq-int64(maxEntries) {
+				keys = append(keys, key)
+				return true
+			}
+
+			if !cutoff.IsZero() && entry.Timestamp.Before(cutoff) {
+				keys = append(keys, key)
+				return true
+			}
+
+			return false
+		})
+		if err != nil {
+			return err
+		}
+
+		for _, key := range keys {
+			if _, err := tx.Delete(key); err != nil {
+				return err
+			}
+		}
+
+		count = len(keys)
+		return nil
+	})
+
+	return count, err
+}
+
+// StoreProvisionRecord stores the provisioning record of a host
+func (s *BuntStore) StoreProvisionRecord(record *ProvisionRecord) error {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+
+	return s.db.Update(func(tx *buntdb.Tx) error {
+		_, err := tx.Get(HostKeyPrefix + ":" + record.Name)
+		if err == buntdb.ErrNotFound {
+			return fmt.Errorf("host with name %s:  %w", record.Name, ErrNotFound)
+		}
+		if err != nil {
+			return err
+		}
+
+		_, _, err = tx.Set(ProvisionKeyPrefix+":"+record.Name, string(data), nil)
+		return err
+	})
+}
+
+// LoadProvisionRecord returns the provisioning record of the host with the given name
+func (s *BuntStore) LoadProvisionRecord(name string) (*ProvisionRecord, error) {
+	var record ProvisionRecord
+
+	err := s.db.View(func(tx *buntdb.Tx) error {
+		val, err := tx.Get(ProvisionKeyPrefix + ":" + name)
+		if err != nil {
+			return err
+		}
+
+		return json.Unmarshal([]byte(val), &record)
+	})
+
+	if err == buntdb.ErrNotFound {
+		return nil, fmt.Errorf("provision record for host %s:  %w", name, ErrNotFound)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &record, nil
+}
+
+// ProvisionRecords returns the provisioning records of the hosts in the
+// given NodeSet, or of all hosts if ns is nil
+func (s *BuntStore) ProvisionRecords(ns *nodeset.NodeSet) (ProvisionRecordList, error) {
+	records := make(ProvisionRecordList, 0)
+
+	err := s.db.View(func(tx *buntdb.Tx) error {
+		load := func(key, value string) bool {
+			var record ProvisionRecord
+			err := json.Unmarshal([]byte(value), &record)
+			if err != nil {
+				log.WithFields(logrus.Fields{
+					"err": err,
+					"key": key,
+				}).Warn("Invalid provision record json stored in db")
+				return true
+			}
+
+			records = append(records, &record)
+			return true
+		}
+
+		if ns == nil {
+			return tx.AscendKeys(ProvisionKeyPrefix+":*", load)
+		}
+
+		it := ns.Iterator()
+		for it.Next() {
+			key := ProvisionKeyPrefix + ":" + it.Value()
+			val, err := tx.Get(key)
+			if err == buntdb.ErrNotFound {
+				continue
+			}
+			if err != nil {
+				return err
+			}
+
+			load(key, val)
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return records, nil
+}
+
+// loadLease returns the lease of the client with the given MAC address
+func loadLease(tx *buntdb.Tx, mac string) (*Lease, error) {
+	val, err := tx.Get(LeaseKeyPrefix + ":" + mac)
+	if err != nil {
+		return nil, err
+	}
+
+	var lease Lease
+	if err := json.Unmarshal([]byte(val), &lease); err != nil {
+		return nil, err
+	}
+
+	return &lease, nil
+}
+
+// StoreLease stores the dynamic DHCP lease of a client
+func (s *BuntStore) StoreLease(lease *Lease) error {
+	data, err := json.Marshal(lease)
+	if err != nil {
+		return err
+	}
+
+	mac := lease.key()
+	ip := lease.IP.Unmap().String()
+	now := time.Now()
+
+	return s.db.Update(func(tx *buntdb.Tx) error {
+		hosts, err := loadIndexedHosts(tx, IPIndexPrefix+":"+ip)
+		if err != nil {
+			return err
+		}
+		if len(hosts) > 0 {
+			return fmt.Errorf("address %s is assigned to host %s:  %w", ip, hosts[0].Name, ErrDuplicateEntry)
+		}
+
+		holder, err := tx.Get(LeaseIPKeyPrefix + ":" + ip)
+		if err != nil && err != buntdb.ErrNotFound {
+			return err
+		}
+		if err == nil && holder != mac {
+			other, err := loadLease(tx, holder)
+			if err != nil && err != buntdb.ErrNotFound {
+				return err
+			}
+			if other != nil && !other.Expired(now) {
+				return fmt.Errorf("address %s is leased to %s:  %w", ip, holder, ErrDuplicateEntry)
+			}
+
+			_, err = tx.Delete(LeaseKeyPrefix + ":" + holder)
+			if err != nil && err != buntdb.ErrNotFound {
+				return err
+			}
+		}
+
+		prev, err := loadLease(tx, mac)
+		if err != nil && err != buntdb.ErrNotFound {
+			return err
+		}
+		if prev != nil && prev.IP.Unmap().String() != ip {
+			_, err = tx.Delete(LeaseIPKeyPrefix + ":" + prev.IP.Unmap().String())
+			if err != nil && err != buntdb.ErrNotFound {
+				return err
+			}
+		}
+
+		if _, _, err := tx.Set(LeaseKeyPrefix+":"+mac, string(data), nil); err != nil {
+			return err
+		}
+
+		_, _, err = tx.Set(LeaseIPKeyPrefix+":"+ip, mac, nil)
+		return err
+	})
+}
+
+// LoadLease returns the lease of the client with the given MAC address
+func (s *BuntStore) LoadLease(mac string) (*Lease, error) {
+	var lease *Lease
+
+	hwaddr, err := net.ParseMAC(mac)
+	if err != nil {
+		return nil, fmt.Errorf("lease for mac %s:  %w", mac, ErrNotFound)
+	}
+
+	err = s.db.View(func(tx *buntdb.Tx) error {
+		var err error
+		lease, err = loadLease(tx, hwaddr.String())
+		return err
+	})
+
+	if err == buntdb.ErrNotFound {
+		return nil, fmt.Errorf("lease for mac %s:  %w", mac, ErrNotFound)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return lease, nil
+}
+
+// DeleteLease deletes the lease of the client with the given MAC address
+func (s *BuntStore) DeleteLease(mac string) error {
+	hwaddr, err := net.ParseMAC(mac)
+	if err != nil {
+		return fmt.Errorf("lease for mac %s:  %w", mac, ErrNotFound)
+	}
+	mac = hwaddr.String()
+
+	return s.db.Update(func(tx *buntdb.Tx) error {
+		lease, err := loadLease(tx, mac)
+		if err == buntdb.ErrNotFound {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		if _, err := tx.Delete(LeaseKeyPrefix + ":" + mac); err != nil {
+			return err
+		}
+
+		_, err = tx.Delete(LeaseIPKeyPrefix + ":" + lease.IP.Unmap().String())
+		if err != nil && err != buntdb.ErrNotFound {
+			return err
+		}
+
+		return nil
+	})
+}
+
+// Leases returns all dynamic DHCP leases
+func (s *BuntStore) Leases() (LeaseList, error) {
+	leases := make(LeaseList, 0)
+
+	err := s.db.View(func(tx *buntdb.Tx) error {
+		return tx.AscendKeys(LeaseKeyPrefix+":*", func(key, value string) bool {
+			var lease Lease
+			err := json.Unmarshal([]byte(value), &lease)
+			if err != nil {
+				log.WithFields(logrus.Fields{
+					"err": err,
+					"key": key,
+				}).Warn("Invalid lease json stored in db")
+				return true
+			}
+
+			leases = append(leases, &lease)
+			return true
+		})
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	sortLeases(leases)
+	return leases, nil
+}
+
+// StoreUnregistered stores an unknown PXE client seen by the DHCP server
+func (s *BuntStore) StoreUnregistered(host *UnregisteredHost) error {
+	data, err := json.Marshal(host)
+	if err != nil {
+		return err
+	}
+
+	return s.db.Update(func(tx *buntdb.Tx) error {
+		_, _, err := tx.Set(UnregisteredKeyPrefix+":"+host.MAC.String(), string(data), nil)
+		return err
+	})
+}
+
+// LoadUnregistered returns the unregistered host with the given MAC address
+func (s *BuntStore) LoadUnregistered(mac string) (*UnregisteredHost, error) {
+	hwaddr, err := net.ParseMAC(mac)
+	if err != nil {
+		return nil, fmt.Errorf("unregistered host with mac %s:  %w", mac, ErrNotFound)
+	}
+
+	var val string
+	err = s.db.View(func(tx *buntdb.Tx) error {
+		var err error
+		val, err = tx.Get(UnregisteredKeyPrefix + ":" + hwaddr.String())
+		return err
+	})
+
+	if err == buntdb.ErrNotFound {
+		return nil, fmt.Errorf("unregistered host with mac %s:  %w", mac, ErrNotFound)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var host UnregisteredHost
+	if err := json.Unmarshal([]byte(val), &host); err != nil {
+		return nil, err
+	}
+
+	return &host, nil
+}
+
+// DeleteUnregistered deletes the unregistered host with the given MAC address
+func (s *BuntStore) DeleteUnregistered(mac string) error {
+	hwaddr, err := net.ParseMAC(mac)
+	if err != nil {
+		return fmt.Errorf("unregistered host with mac %s:  %w", mac, ErrNotFound)
+	}
+
+	return s.db.Update(func(tx *buntdb.Tx) error {
+		_, err := tx.Delete(UnregisteredKeyPrefix + ":" + hwaddr.String())
+		if err != nil && err != buntdb.ErrNotFound {
+			return err
+		}
+
+		return nil
+	})
+}
+
+// UnregisteredHosts returns all unregistered hosts in the order they were
+// first seen
+func (s *BuntStore) UnregisteredHosts() (UnregisteredHostList, error) {
+	hosts := make(UnregisteredHostList, 0)
+
+	err := s.db.View(func(tx *buntdb.Tx) error {
+		return tx.AscendKeys(UnregisteredKeyPrefix+":*", func(key, value string) bool {
+			var host UnregisteredHost
+			err := json.Unmarshal([]byte(value), &host)
+			if err != nil {
+				log.WithFields(logrus.Fields{
+					"err": err,
+					"key": key,
+				}).Warn("Invalid unregistered host json stored in db")
+				return true
+			}
+
+			hosts = append(hosts, &host)
+			return true
+		})
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	sortUnregistered(hosts)
+	return hosts, nil
+}
@@ -18,7 +18,6 @@
 package model_test
 
 import (
-	"errors"
 	"fmt"
 	"io/ioutil"
 	"math/rand"
@@ -40,410 +39,27 @@ func tempfile() string {
 	return name.Name()
 }
 
-func TestBuntStoreHost(t *testing.T) {
+func TestBuntStoreReindex(t *testing.T) {
 	assert := assert.New(t)
 
-	store, err := model.NewBuntStore(":memory:")
-	defer store.Close()
-	assert.NoError(err)
-
-	host := tests.HostFactory.MustCreate().(*model.Host)
-
-	err = store.StoreHost(host)
-	assert.NoError(err)
-
-	testHost, err := store.LoadHostFromID(host.ID.String())
-	if assert.NoError(err) {
-		assert.Equal(2, len(testHost.Interfaces))
-	}
-
-	testHost2, err := store.LoadHostFromName(host.Name)
-	if assert.NoError(err) {
-		assert.Equal(host.Name, testHost2.Name)
-		assert.Equal(0, host.Interfaces[0].Addr().Compare(testHost2.Interfaces[0].Addr()))
-	}
-
-	testHost3, err := store.LoadHostFromMAC(host.Interfaces[0].MAC.String())
-	if assert.NoError(err) {
-		assert.Equal(host.Name, testHost3.Name)
-		assert.Equal(host.Interfaces[0].MAC.String(), testHost3.Interfaces[0].MAC.String())
-	}
-
-	testIPs, err := store.ResolveIPv4(host.Interfaces[0].FQDN)
-	if assert.NoError(err) {
-		if assert.Equal(1, len(testIPs)) {
-			assert.Equal(host.Interfaces[0].AddrString(), testIPs[0].String())
-		}
-	}
-
-	testNames, err := store.ReverseResolve(host.Interfaces[0].AddrString())
-	if assert.NoError(err) {
-		if assert.Equal(1, len(testNames)) {
-			assert.Equal(host.Interfaces[0].FQDN, testNames[0])
-		}
-	}
-
-	badhost := &model.Host{}
-	err = store.StoreHost(badhost)
-	if assert.Error(err) {
-		assert.True(errors.Is(err, model.ErrInvalidData))
-	}
-
-	_, err = store.LoadHostFromID("notfound")
-	if assert.Error(err) {
-		assert.True(errors.Is(err, model.ErrNotFound))
-	}
-
-	_, err = store.LoadHostFromName("notfound")
-	if assert.Error(err) {
-		assert.True(errors.Is(err, model.ErrNotFound))
-	}
-
-	_, err = store.LoadHostFromMAC("notfound")
-	if assert.Error(err) {
-		assert.True(errors.Is(err, model.ErrNotFound))
-	}
-}
-
-func TestBuntStoreIfname(t *testing.T) {
-	assert := assert.New(t)
-
-	store, err := model.NewBuntStore(":memory:")
-	defer store.Close()
-	assert.NoError(err)
-
-	host := tests.HostFactory.MustCreate().(*model.Host)
-
-	err = store.StoreHost(host)
-	assert.NoError(err)
-
-	testHost, err := store.LoadHostFromName(host.Name)
-	if assert.NoError(err) {
-		assert.Equal(host.Interfaces[0].Name, testHost.Interfaces[0].Name)
-	}
-}
-
-func TestBuntStoreHostList(t *testing.T) {
-	assert := assert.New(t)
-
-	store, err := model.NewBuntStore(":memory:")
-	defer store.Close()
-	assert.NoError(err)
-
-	size := 10
-	for i := 0; i < size; i++ {
-		host := tests.HostFactory.MustCreate().(*model.Host)
-		err := store.StoreHost(host)
-		assert.NoError(err)
-	}
-
-	hosts, err := store.Hosts()
-	assert.NoError(err)
-	assert.Equal(10, len(hosts))
-}
-
-func TestBuntStoreHostFind(t *testing.T) {
-	assert := assert.New(t)
-
-	store, err := model.NewBuntStore(":memory:")
-	defer store.Close()
-	assert.NoError(err)
-
-	size := 20
-	for i := 0; i < size; i++ {
-		host := tests.HostFactory.MustCreate().(*model.Host)
-		host.Name = fmt.Sprintf("tux-%02d", i)
-		err := store.StoreHost(host)
-		assert.NoError(err)
-	}
-
-	ns, err := nodeset.NewNodeSet("tux-[05-14]")
-	if assert.NoError(err) {
-		hosts, err := store.FindHosts(ns)
-		assert.NoError(err)
-		assert.Equal(10, len(hosts))
-	}
-}
-
-func TestBuntStoreFindTags(t *testing.T) {
-	assert := assert.New(t)
-
-	store, err := model.NewBuntStore(":memory:")
-	defer store.Close()
-	assert.NoError(err)
-
-	size := 10
-	for i := 0; i < size; i++ {
-		host := tests.HostFactory.MustCreate().(*model.Host)
-		host.Name = fmt.Sprintf("tux-%02d", i)
-		if (i % 2) == 0 {
-			host.Tags = []string{"k11", "wanda"}
-		} else if (i % 2) != 0 {
-			host.Tags = []string{"k16", "vision"}
-		}
-		err := store.StoreHost(host)
-		assert.NoError(err)
-	}
-
-	ns, err := store.FindTags([]string{"k16"})
-	if assert.NoError(err) {
-		assert.Equal(5, ns.Len())
-	}
-
-	ns, err = store.FindTags([]string{"vision"})
-	if assert.NoError(err) {
-		assert.Equal(5, ns.Len())
-	}
-
-	ns, err = store.FindTags([]string{"vision", "k11"})
-	if assert.NoError(err) {
-		assert.Equal(10, ns.Len())
-	}
-
-	ns, err = store.FindTags([]string{"harkness", "rambeau"})
-	if assert.Error(err) {
-		assert.True(errors.Is(err, model.ErrNotFound))
-	}
-
-	ns, err = nodeset.NewNodeSet("tux-[05-08]")
-	if assert.NoError(err) {
-		err := store.TagHosts(ns, []string{"harkness"})
-		assert.NoError(err)
-	}
-
-	ns, err = store.FindTags([]string{"harkness"})
-	if assert.NoError(err) {
-		assert.Equal(4, ns.Len())
-	}
-
-	ns, err = nodeset.NewNodeSet("tux-[00-10]")
-	if assert.NoError(err) {
-		err := store.UntagHosts(ns, []string{"vision"})
-		assert.NoError(err)
-	}
-
-	ns, err = store.FindTags([]string{"vision"})
-	if assert.Error(err) {
-		assert.True(errors.Is(err, model.ErrNotFound))
-	}
-}
-
-func TestBuntStoreProvision(t *testing.T) {
-	assert := assert.New(t)
-
-	store, err := model.NewBuntStore(":memory:")
-	defer store.Close()
-	assert.NoError(err)
-
-	size := 20
-	for i := 0; i < size; i++ {
-		host := tests.HostFactory.MustCreate().(*model.Host)
-		host.Name = fmt.Sprintf("tux-%02d", i)
-		err := store.StoreHost(host)
-		assert.NoError(err)
-	}
-
-	ns, err := nodeset.NewNodeSet("tux-[05-14]")
-	if assert.NoError(err) {
-		hosts, err := store.FindHosts(ns)
-		assert.NoError(err)
-		assert.Equal(10, len(hosts))
-		for _, host := range hosts {
-			assert.False(host.Provision)
-		}
-
-		err = store.ProvisionHosts(ns, true)
-		assert.NoError(err)
-
-		hosts, err = store.FindHosts(ns)
-		assert.NoError(err)
-		assert.Equal(10, len(hosts))
-		for _, host := range hosts {
-			assert.True(host.Provision)
-		}
-	}
-}
-
-func TestBuntStoreSetBootImage(t *testing.T) {
-	assert := assert.New(t)
-
-	store, err := model.NewBuntStore(":memory:")
-	defer store.Close()
-	assert.NoError(err)
-
-	size := 20
-	for i := 0; i < size; i++ {
-		host := tests.HostFactory.MustCreate().(*model.Host)
-		host.Name = fmt.Sprintf("tux-%02d", i)
-		err := store.StoreHost(host)
-		assert.NoError(err)
-	}
-
-	ns, err := nodeset.NewNodeSet("tux-[05-14]")
-	if assert.NoError(err) {
-		hosts, err := store.FindHosts(ns)
-		assert.NoError(err)
-		assert.Equal(10, len(hosts))
-		for _, host := range hosts {
-			assert.Equal("", host.BootImage)
-		}
-
-		err = store.SetBootImage(ns, "centos7")
-		assert.NoError(err)
-
-		hosts, err = store.FindHosts(ns)
-		assert.NoError(err)
-		assert.Equal(10, len(hosts))
-		for _, host := range hosts {
-			assert.Equal("centos7", host.BootImage)
-		}
-	}
-}
-
-func TestBuntStoreBootImage(t *testing.T) {
-	assert := assert.New(t)
-
-	store, err := model.NewBuntStore(":memory:")
-	defer store.Close()
-	assert.NoError(err)
-
-	image := tests.BootImageFactory.MustCreate().(*model.BootImage)
-
-	image.ProvisionTemplates = map[string]string{
-		"kickstart":    "kickstart.tmpl",
-		"post-install": "post-install.tmpl",
-	}
-
-	err = store.StoreBootImage(image)
-	assert.NoError(err)
-
-	testImage, err := store.LoadBootImage(image.Name)
-	if assert.NoError(err) {
-		assert.Equal(image.Name, testImage.Name)
-		assert.Contains(testImage.ProvisionTemplates, "post-install")
-		assert.Contains(testImage.ProvisionTemplates, "kickstart")
-	}
-
-	badimage := &model.BootImage{}
-	err = store.StoreBootImage(badimage)
-	if assert.Error(err) {
-		assert.True(errors.Is(err, model.ErrInvalidData))
-	}
-
-	_, err = store.LoadBootImage("notfound")
-	if assert.Error(err) {
-		assert.True(errors.Is(err, model.ErrNotFound))
-	}
-
-	for i := 0; i < 5; i++ {
-		image := tests.BootImageFactory.MustCreate().(*model.BootImage)
-		err := store.StoreBootImage(image)
-		assert.NoError(err)
-	}
-
-	images, err := store.BootImages()
-	if assert.NoError(err) {
-		assert.Equal(6, len(images))
-	}
-}
-
-func TestBuntStoreBootImageDelete(t *testing.T) {
-	assert := assert.New(t)
-
-	store, err := model.NewBuntStore(":memory:")
-	defer store.Close()
-	assert.NoError(err)
-
-	image := tests.BootImageFactory.MustCreate().(*model.BootImage)
-
-	err = store.StoreBootImage(image)
-	assert.NoError(err)
-
-	testImage, err := store.LoadBootImage(image.Name)
-	if assert.NoError(err) {
-		assert.Equal(image.Name, testImage.Name)
-	}
-
-	err = store.DeleteBootImages([]string{testImage.Name})
-	if assert.NoError(err) {
-		_, err = store.LoadBootImage(testImage.Name)
-		if assert.Error(err) {
-			assert.True(errors.Is(err, model.ErrNotFound))
-		}
-	}
-}
-
-func TestBuntStoreUpdate(t *testing.T) {
-	assert := assert.New(t)
+	file := tempfile()
+	defer os.Remove(file)
 
-	store, err := model.NewBuntStore(":memory:")
-	defer store.Close()
+	store, err := model.NewBuntStore(file)
 	assert.NoError(err)
 
 	host := tests.HostFactory.MustCreate().(*model.Host)
-
 	err = store.StoreHost(host)
 	assert.NoError(err)
+	assert.NoError(store.Close())
 
-	testHost, err := store.LoadHostFromID(host.ID.String())
-	if assert.NoError(err) {
-		assert.Equal(2, len(testHost.Interfaces))
-	}
-
-	// Store host with same name is update
-	hostDup := tests.HostFactory.MustCreate().(*model.Host)
-	hostDup.ID = host.ID
-	hostDup.Name = host.Name
-	err = store.StoreHost(hostDup)
-	if assert.NoError(err) {
-		hosts, err := store.Hosts()
-		assert.NoError(err)
-		assert.Equal(1, len(hosts))
-	}
-
-	// Store host with different name gets new ID
-	hostDup = tests.HostFactory.MustCreate().(*model.Host)
-	hostDup.ID = host.ID
-	hostDup.Name = "cpn-new"
-	err = store.StoreHost(hostDup)
-	if assert.NoError(err) {
-		hosts, err := store.Hosts()
-		assert.NoError(err)
-		assert.Equal(2, len(hosts))
-		idCheck := ""
-		for _, h := range hosts {
-			assert.NotEqual(idCheck, h.ID.String())
-			idCheck = h.ID.String()
-		}
-	}
-}
-
-func TestBuntStoreHostDelete(t *testing.T) {
-	assert := assert.New(t)
-
-	store, err := model.NewBuntStore(":memory:")
+	store, err = model.NewBuntStore(file)
 	defer store.Close()
 	assert.NoError(err)
 
-	host := tests.HostFactory.MustCreate().(*model.Host)
-
-	err = store.StoreHost(host)
-	assert.NoError(err)
-
-	testHost, err := store.LoadHostFromID(host.ID.String())
+	testHost, err := store.LoadHostFromMAC(host.Interfaces[0].MAC.String())
 	if assert.NoError(err) {
-		assert.Equal(2, len(testHost.Interfaces))
-	}
-
-	ns, err := nodeset.NewNodeSet(testHost.Name)
-	if assert.NoError(err) {
-		err := store.DeleteHosts(ns)
-		assert.NoError(err)
-
-		_, err = store.LoadHostFromID(host.ID.String())
-		if assert.Error(err) {
-			assert.True(errors.Is(err, model.ErrNotFound))
-		}
+		assert.Equal(host.Name, testHost.Name)
 	}
 }
 
@@ -701,7 +317,7 @@ func BenchmarkBuntStoreReverseResolve(b *testing.B) {
 	b.ResetTimer()
 	for n := 0; n < b.N; n++ {
 		pick := hosts[rand.Intn(size)]
-		names, err := store.ReverseResolve(pick.Interfaces[0].IP.String())
+		names, err := store.ReverseResolve(pick.Interfaces[0].AddrString())
 		if err != nil {
 			b.Fatal(err)
 		}
@@ -710,3 +326,62 @@ func BenchmarkBuntStoreReverseResolve(b *testing.B) {
 		}
 	}
 }
+
+func BenchmarkBuntStoreIndexedLookups(b *testing.B) {
+	for _, size := range []int{100, 1000, 10000} {
+		store, err := model.NewBuntStore(":memory:")
+		if err != nil {
+			b.Fatal(err)
+		}
+
+		hosts := make(model.HostList, size)
+		for i := 0; i < size; i++ {
+			host := tests.HostFactory.MustCreate().(*model.Host)
+			host.Name = fmt.Sprintf("tux-%05d", i)
+			hosts[i] = host
+		}
+
+		err = store.StoreHosts(hosts)
+		if err != nil {
+			b.Fatal(err)
+		}
+
+		b.Run(fmt.Sprintf("LoadHostFromMAC-%d", size), func(b *testing.B) {
+			for n := 0; n < b.N; n++ {
+				pick := hosts[n%size]
+				_, err := store.LoadHostFromMAC(pick.Interfaces[0].MAC.String())
+				if err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+
+		b.Run(fmt.Sprintf("ResolveIPv4-%d", size), func(b *testing.B) {
+			for n := 0; n < b.N; n++ {
+				pick := hosts[n%size]
+				ips, err := store.ResolveIPv4(pick.Interfaces[0].FQDN)
+				if err != nil {
+					b.Fatal(err)
+				}
+				if len(ips) == 0 {
+					b.Fatalf("IPs not found")
+				}
+			}
+		})
+
+		b.Run(fmt.Sprintf("ReverseResolve-%d", size), func(b *testing.B) {
+			for n := 0; n < b.N; n++ {
+				pick := hosts[n%size]
+				names, err := store.ReverseResolve(pick.Interfaces[0].AddrString())
+				if err != nil {
+					b.Fatal(err)
+				}
+				if len(names) == 0 {
+					b.Fatalf("names not found")
+				}
+			}
+		})
+
+		store.Close()
+	}
+}
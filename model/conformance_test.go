@@ -0,0 +1,1004 @@
+// Copyright 2019 Grendel Authors. All rights reserved.
+//
+// This file is part of Grendel.
+//
+// Grendel is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Grendel is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with Grendel. If not, see <https://www.gnu.org/licenses/>.
+
+package model_test
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/netip"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/ubccr/grendel/firmware"
+	"github.com/ubccr/grendel/internal/tests"
+	"github.com/ubccr/grendel/model"
+	"github.com/ubccr/grendel/nodeset"
+)
+
+// testStores runs fn against a new in-memory instance of every DataStore
+// backend. All backends must pass the same conformance tests.
+func testStores(t *testing.T, fn func(t *testing.T, store model.DataStore)) {
+	for _, dbpath := range []string{"buntdb://:memory:", "sqlite://:memory:"} {
+		t.Run(strings.SplitN(dbpath, ":", 2)[0], func(t *testing.T) {
+			store, err := model.NewDataStore(dbpath)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer store.Close()
+
+			fn(t, store)
+		})
+	}
+}
+
+func TestStoreHost(t *testing.T) {
+	testStores(t, func(t *testing.T, store model.DataStore) {
+		assert := assert.New(t)
+
+		host := tests.HostFactory.MustCreate().(*model.Host)
+
+		err := store.StoreHost(host)
+		assert.NoError(err)
+
+		testHost, err := store.LoadHostFromID(host.ID.String())
+		if assert.NoError(err) {
+			assert.Equal(2, len(testHost.Interfaces))
+		}
+
+		testHost2, err := store.LoadHostFromName(host.Name)
+		if assert.NoError(err) {
+			assert.Equal(host.Name, testHost2.Name)
+			assert.Equal(0, host.Interfaces[0].Addr().Compare(testHost2.Interfaces[0].Addr()))
+		}
+
+		testHost3, err := store.LoadHostFromMAC(host.Interfaces[0].MAC.String())
+		if assert.NoError(err) {
+			assert.Equal(host.Name, testHost3.Name)
+			assert.Equal(host.Interfaces[0].MAC.String(), testHost3.Interfaces[0].MAC.String())
+		}
+
+		testIPs, err := store.ResolveIPv4(host.Interfaces[0].FQDN)
+		if assert.NoError(err) {
+			if assert.Equal(1, len(testIPs)) {
+				assert.Equal(host.Interfaces[0].AddrString(), testIPs[0].String())
+			}
+		}
+
+		testNames, err := store.ReverseResolve(host.Interfaces[0].AddrString())
+		if assert.NoError(err) {
+			if assert.Equal(1, len(testNames)) {
+				assert.Equal(host.Interfaces[0].FQDN, testNames[0])
+			}
+		}
+
+		badhost := &model.Host{}
+		err = store.StoreHost(badhost)
+		if assert.Error(err) {
+			assert.True(errors.Is(err, model.ErrInvalidData))
+		}
+
+		_, err = store.LoadHostFromID("notfound")
+		if assert.Error(err) {
+			assert.True(errors.Is(err, model.ErrNotFound))
+		}
+
+		_, err = store.LoadHostFromName("notfound")
+		if assert.Error(err) {
+			assert.True(errors.Is(err, model.ErrNotFound))
+		}
+
+		_, err = store.LoadHostFromMAC("notfound")
+		if assert.Error(err) {
+			assert.True(errors.Is(err, model.ErrNotFound))
+		}
+	})
+}
+
+func TestStoreIfname(t *testing.T) {
+	testStores(t, func(t *testing.T, store model.DataStore) {
+		assert := assert.New(t)
+
+		host := tests.HostFactory.MustCreate().(*model.Host)
+
+		err := store.StoreHost(host)
+		assert.NoError(err)
+
+		testHost, err := store.LoadHostFromName(host.Name)
+		if assert.NoError(err) {
+			assert.Equal(host.Interfaces[0].Name, testHost.Interfaces[0].Name)
+		}
+	})
+}
+
+func TestStoreHostList(t *testing.T) {
+	testStores(t, func(t *testing.T, store model.DataStore) {
+		assert := assert.New(t)
+
+		size := 10
+		for i := 0; i < size; i++ {
+			host := tests.HostFactory.MustCreate().(*model.Host)
+			err := store.StoreHost(host)
+			assert.NoError(err)
+		}
+
+		hosts, err := store.Hosts()
+		assert.NoError(err)
+		assert.Equal(10, len(hosts))
+	})
+}
+
+func TestStoreHostFind(t *testing.T) {
+	testStores(t, func(t *testing.T, store model.DataStore) {
+		assert := assert.New(t)
+
+		size := 20
+		for i := 0; i < size; i++ {
+			host := tests.HostFactory.MustCreate().(*model.Host)
+			host.Name = fmt.Sprintf("tux-%02d", i)
+			err := store.StoreHost(host)
+			assert.NoError(err)
+		}
+
+		ns, err := nodeset.NewNodeSet("tux-[05-14]")
+		if assert.NoError(err) {
+			hosts, err := store.FindHosts(ns)
+			assert.NoError(err)
+			assert.Equal(10, len(hosts))
+		}
+	})
+}
+
+func TestStoreFindTags(t *testing.T) {
+	testStores(t, func(t *testing.T, store model.DataStore) {
+		assert := assert.New(t)
+
+		size := 10
+		for i := 0; i < size; i++ {
+			host := tests.HostFactory.MustCreate().(*model.Host)
+			host.Name = fmt.Sprintf("tux-%02d", i)
+			if (i % 2) == 0 {
+				host.Tags = []string{"k11", "wanda"}
+			} else if (i % 2) != 0 {
+				host.Tags = []string{"k16", "vision"}
+			}
+			err := store.StoreHost(host)
+			assert.NoError(err)
+		}
+
+		ns, err := store.FindTags([]string{"k16"})
+		if assert.NoError(err) {
+			assert.Equal(5, ns.Len())
+		}
+
+		ns, err = store.FindTags([]string{"vision"})
+		if assert.NoError(err) {
+			assert.Equal(5, ns.Len())
+		}
+
+		ns, err = store.FindTags([]string{"vision", "k11"})
+		if assert.NoError(err) {
+			assert.Equal(10, ns.Len())
+		}
+
+		ns, err = store.FindTags([]string{"harkness", "rambeau"})
+		if assert.Error(err) {
+			assert.True(errors.Is(err, model.ErrNotFound))
+		}
+
+		ns, err = nodeset.NewNodeSet("tux-[05-08]")
+		if assert.NoError(err) {
+			err := store.TagHosts(ns, []string{"harkness"})
+			assert.NoError(err)
+		}
+
+		ns, err = store.FindTags([]string{"harkness"})
+		if assert.NoError(err) {
+			assert.Equal(4, ns.Len())
+		}
+
+		ns, err = nodeset.NewNodeSet("tux-[00-10]")
+		if assert.NoError(err) {
+			err := store.UntagHosts(ns, []string{"vision"})
+			assert.NoError(err)
+		}
+
+		ns, err = store.FindTags([]string{"vision"})
+		if assert.Error(err) {
+			assert.True(errors.Is(err, model.ErrNotFound))
+		}
+	})
+}
+
+func TestStoreProvision(t *testing.T) {
+	testStores(t, func(t *testing.T, store model.DataStore) {
+		assert := assert.New(t)
+
+		size := 20
+		for i := 0; i < size; i++ {
+			host := tests.HostFactory.MustCreate().(*model.Host)
+			host.Name = fmt.Sprintf("tux-%02d", i)
+			err := store.StoreHost(host)
+			assert.NoError(err)
+		}
+
+		ns, err := nodeset.NewNodeSet("tux-[05-14]")
+		if assert.NoError(err) {
+			hosts, err := store.FindHosts(ns)
+			assert.NoError(err)
+			assert.Equal(10, len(hosts))
+			for _, host := range hosts {
+				assert.False(host.Provision)
+			}
+
+			err = store.ProvisionHosts(ns, true)
+			assert.NoError(err)
+
+			hosts, err = store.FindHosts(ns)
+			assert.NoError(err)
+			assert.Equal(10, len(hosts))
+			for _, host := range hosts {
+				assert.True(host.Provision)
+			}
+		}
+	})
+}
+
+func TestStoreSetBootImage(t *testing.T) {
+	testStores(t, func(t *testing.T, store model.DataStore) {
+		assert := assert.New(t)
+
+		size := 20
+		for i := 0; i < size; i++ {
+			host := tests.HostFactory.MustCreate().(*model.Host)
+			host.Name = fmt.Sprintf("tux-%02d", i)
+			err := store.StoreHost(host)
+			assert.NoError(err)
+		}
+
+		ns, err := nodeset.NewNodeSet("tux-[05-14]")
+		if assert.NoError(err) {
+			hosts, err := store.FindHosts(ns)
+			assert.NoError(err)
+			assert.Equal(10, len(hosts))
+			for _, host := range hosts {
+				assert.Equal("", host.BootImage)
+			}
+
+			err = store.SetBootImage(ns, "centos7")
+			assert.NoError(err)
+
+			hosts, err = store.FindHosts(ns)
+			assert.NoError(err)
+			assert.Equal(10, len(hosts))
+			for _, host := range hosts {
+				assert.Equal("centos7", host.BootImage)
+			}
+		}
+	})
+}
+
+func TestStoreUnresolvedFirmware(t *testing.T) {
+	testStores(t, func(t *testing.T, store model.DataStore) {
+		assert := assert.New(t)
+
+		// A build that isn't loaded in this process, for example one only
+		// present in the firmware dir of another machine
+		fw, err := firmware.Parse("ipxe-offsite.efi")
+		if !assert.NoError(err) {
+			return
+		}
+
+		host := tests.HostFactory.MustCreate().(*model.Host)
+		host.Firmware = fw
+		assert.NoError(store.StoreHost(host))
+		assert.NoError(store.StoreBootImage(&model.BootImage{Name: "centos", KernelPath: "/vmlinuz", Firmware: fw}))
+
+		ns, err := nodeset.NewNodeSet(host.Name)
+		if assert.NoError(err) {
+			assert.NoError(store.ProvisionHosts(ns, false))
+		}
+
+		testHost, err := store.LoadHostFromName(host.Name)
+		if assert.NoError(err) {
+			assert.Equal("ipxe-offsite.efi", testHost.Firmware.String())
+			assert.False(testHost.Firmware.Available())
+		}
+
+		image, err := store.LoadBootImage("centos")
+		if assert.NoError(err) {
+			assert.Equal("ipxe-offsite.efi", image.Firmware.String())
+		}
+	})
+}
+
+func TestStoreBootImage(t *testing.T) {
+	testStores(t, func(t *testing.T, store model.DataStore) {
+		assert := assert.New(t)
+
+		image := tests.BootImageFactory.MustCreate().(*model.BootImage)
+
+		image.ProvisionTemplates = map[string]string{
+			"kickstart":    "kickstart.tmpl",
+			"post-install": "post-install.tmpl",
+		}
+
+		err := store.StoreBootImage(image)
+		assert.NoError(err)
+
+		testImage, err := store.LoadBootImage(image.Name)
+		if assert.NoError(err) {
+			assert.Equal(image.Name, testImage.Name)
+			assert.Contains(testImage.ProvisionTemplates, "post-install")
+			assert.Contains(testImage.ProvisionTemplates, "kickstart")
+		}
+
+		badimage := &model.BootImage{}
+		err = store.StoreBootImage(badimage)
+		if assert.Error(err) {
+			assert.True(errors.Is(err, model.ErrInvalidData))
+		}
+
+		_, err = store.LoadBootImage("notfound")
+		if assert.Error(err) {
+			assert.True(errors.Is(err, model.ErrNotFound))
+		}
+
+		for i := 0; i < 5; i++ {
+			image := tests.BootImageFactory.MustCreate().(*model.BootImage)
+			err := store.StoreBootImage(image)
+			assert.NoError(err)
+		}
+
+		images, err := store.BootImages()
+		if assert.NoError(err) {
+			assert.Equal(6, len(images))
+		}
+	})
+}
+
+func TestStoreBootImageDelete(t *testing.T) {
+	testStores(t, func(t *testing.T, store model.DataStore) {
+		assert := assert.New(t)
+
+		image := tests.BootImageFactory.MustCreate().(*model.BootImage)
+
+		err := store.StoreBootImage(image)
+		assert.NoError(err)
+
+		testImage, err := store.LoadBootImage(image.Name)
+		if assert.NoError(err) {
+			assert.Equal(image.Name, testImage.Name)
+		}
+
+		err = store.DeleteBootImages([]string{testImage.Name})
+		if assert.NoError(err) {
+			_, err = store.LoadBootImage(testImage.Name)
+			if assert.Error(err) {
+				assert.True(errors.Is(err, model.ErrNotFound))
+			}
+		}
+	})
+}
+
+func TestStoreUpdate(t *testing.T) {
+	testStores(t, func(t *testing.T, store model.DataStore) {
+		assert := assert.New(t)
+
+		host := tests.HostFactory.MustCreate().(*model.Host)
+
+		err := store.StoreHost(host)
+		assert.NoError(err)
+
+		testHost, err := store.LoadHostFromID(host.ID.String())
+		if assert.NoError(err) {
+			assert.Equal(2, len(testHost.Interfaces))
+		}
+
+		// Store host with same name is update
+		hostDup := tests.HostFactory.MustCreate().(*model.Host)
+		hostDup.ID = host.ID
+		hostDup.Name = host.Name
+		err = store.StoreHost(hostDup)
+		if assert.NoError(err) {
+			hosts, err := store.Hosts()
+			assert.NoError(err)
+			assert.Equal(1, len(hosts))
+		}
+
+		// Store host with different name gets new ID
+		hostDup = tests.HostFactory.MustCreate().(*model.Host)
+		hostDup.ID = host.ID
+		hostDup.Name = "cpn-new"
+		err = store.StoreHost(hostDup)
+		if assert.NoError(err) {
+			hosts, err := store.Hosts()
+			assert.NoError(err)
+			assert.Equal(2, len(hosts))
+			idCheck := ""
+			for _, h := range hosts {
+				assert.NotEqual(idCheck, h.ID.String())
+				idCheck = h.ID.String()
+			}
+		}
+	})
+}
+
+func TestStoreHostDelete(t *testing.T) {
+	testStores(t, func(t *testing.T, store model.DataStore) {
+		assert := assert.New(t)
+
+		host := tests.HostFactory.MustCreate().(*model.Host)
+
+		err := store.StoreHost(host)
+		assert.NoError(err)
+
+		testHost, err := store.LoadHostFromID(host.ID.String())
+		if assert.NoError(err) {
+			assert.Equal(2, len(testHost.Interfaces))
+		}
+
+		ns, err := nodeset.NewNodeSet(testHost.Name)
+		if assert.NoError(err) {
+			err := store.DeleteHosts(ns)
+			assert.NoError(err)
+
+			_, err = store.LoadHostFromID(host.ID.String())
+			if assert.Error(err) {
+				assert.True(errors.Is(err, model.ErrNotFound))
+			}
+		}
+	})
+}
+
+func TestStoreProvisionRecord(t *testing.T) {
+	testStores(t, func(t *testing.T, store model.DataStore) {
+		assert := assert.New(t)
+
+		host := tests.HostFactory.MustCreate().(*model.Host)
+		host.Name = "tux-01"
+		err := store.StoreHost(host)
+		assert.NoError(err)
+
+		now := time.Now()
+		record := model.NewProvisionRecord(host.Name, now)
+		record.Transition(model.ProvisionStateDHCP, "10.0.0.1", "", now.Add(time.Second))
+		err = store.StoreProvisionRecord(record)
+		assert.NoError(err)
+
+		err = store.StoreProvisionRecord(model.NewProvisionRecord("missing", now))
+		if assert.Error(err) {
+			assert.True(errors.Is(err, model.ErrNotFound))
+		}
+
+		testRecord, err := store.LoadProvisionRecord(host.Name)
+		if assert.NoError(err) {
+			assert.Equal(model.ProvisionStateDHCP, testRecord.State)
+			assert.Equal("10.0.0.1", testRecord.LastIP)
+			assert.Len(testRecord.History, 2)
+		}
+
+		ns, err := nodeset.NewNodeSet("tux-[01-02]")
+		if assert.NoError(err) {
+			records, err := store.ProvisionRecords(ns)
+			if assert.NoError(err) {
+				assert.Len(records, 1)
+			}
+
+			records, err = store.ProvisionRecords(nil)
+			if assert.NoError(err) {
+				assert.Len(records, 1)
+			}
+		}
+
+		ns, err = nodeset.NewNodeSet(host.Name)
+		if assert.NoError(err) {
+			err = store.DeleteHosts(ns)
+			assert.NoError(err)
+
+			_, err = store.LoadProvisionRecord(host.Name)
+			if assert.Error(err) {
+				assert.True(errors.Is(err, model.ErrNotFound))
+			}
+		}
+	})
+}
+
+func TestStoreIndex(t *testing.T) {
+	testStores(t, func(t *testing.T, store model.DataStore) {
+		assert := assert.New(t)
+
+		host := tests.HostFactory.MustCreate().(*model.Host)
+		host.Name = "tux-01"
+		err := store.StoreHost(host)
+		assert.NoError(err)
+
+		oldMAC := host.Interfaces[0].MAC.String()
+		oldFQDN := host.Interfaces[0].FQDN
+		oldIP := host.Interfaces[0].AddrString()
+
+		// Update host with new interfaces
+		update := tests.HostFactory.MustCreate().(*model.Host)
+		update.Name = host.Name
+		err = store.StoreHost(update)
+		assert.NoError(err)
+
+		_, err = store.LoadHostFromMAC(oldMAC)
+		if assert.Error(err) {
+			assert.True(errors.Is(err, model.ErrNotFound))
+		}
+
+		ips, err := store.ResolveIPv4(oldFQDN)
+		if assert.NoError(err) {
+			assert.Equal(0, len(ips))
+		}
+
+		names, err := store.ReverseResolve(oldIP)
+		if assert.NoError(err) {
+			assert.Equal(0, len(names))
+		}
+
+		testHost, err := store.LoadHostFromMAC(strings.ToUpper(update.Interfaces[0].MAC.String()))
+		if assert.NoError(err) {
+			assert.Equal(update.Name, testHost.Name)
+		}
+
+		// Tag and provision updates keep indexes consistent
+		ns, err := nodeset.NewNodeSet(host.Name)
+		assert.NoError(err)
+		assert.NoError(store.TagHosts(ns, []string{"k16"}))
+		assert.NoError(store.ProvisionHosts(ns, true))
+
+		testHost, err = store.LoadHostFromMAC(update.Interfaces[1].MAC.String())
+		if assert.NoError(err) {
+			assert.Equal(update.Name, testHost.Name)
+			assert.True(testHost.Provision)
+			assert.True(testHost.HasTags("k16"))
+		}
+
+		ips, err = store.ResolveIPv4(update.Interfaces[1].FQDN)
+		if assert.NoError(err) && assert.Equal(1, len(ips)) {
+			assert.Equal(update.Interfaces[1].AddrString(), ips[0].String())
+		}
+
+		// Deleted hosts are removed from indexes
+		assert.NoError(store.DeleteHosts(ns))
+
+		_, err = store.LoadHostFromMAC(update.Interfaces[0].MAC.String())
+		if assert.Error(err) {
+			assert.True(errors.Is(err, model.ErrNotFound))
+		}
+
+		ips, err = store.ResolveIPv4(update.Interfaces[0].FQDN)
+		if assert.NoError(err) {
+			assert.Equal(0, len(ips))
+		}
+
+		names, err = store.ReverseResolve(update.Interfaces[0].AddrString())
+		if assert.NoError(err) {
+			assert.Equal(0, len(names))
+		}
+	})
+}
+
+func TestStoreReverseResolveExact(t *testing.T) {
+	testStores(t, func(t *testing.T, store model.DataStore) {
+		assert := assert.New(t)
+
+		for i, ip := range []string{"10.0.0.1", "10.0.0.10", "10.0.0.100", "10.0.0.1"} {
+			host := tests.HostFactory.MustCreate().(*model.Host)
+			host.Name = fmt.Sprintf("tux-%02d", i)
+			host.Interfaces[0].FQDN = fmt.Sprintf("tux-%02d.compute.local", i)
+			host.Interfaces[0].IP = netip.MustParsePrefix(ip + "/24")
+			err := store.StoreHost(host)
+			assert.NoError(err)
+		}
+
+		names, err := store.ReverseResolve("10.0.0.1")
+		if assert.NoError(err) {
+			assert.ElementsMatch([]string{"tux-00.compute.local", "tux-03.compute.local"}, names)
+		}
+
+		names, err = store.ReverseResolve("10.0.0.10")
+		if assert.NoError(err) {
+			assert.Equal([]string{"tux-01.compute.local"}, names)
+		}
+
+		names, err = store.ReverseResolve("::ffff:10.0.0.100")
+		if assert.NoError(err) {
+			assert.Equal([]string{"tux-02.compute.local"}, names)
+		}
+
+		names, err = store.ReverseResolve("10.0.0")
+		if assert.NoError(err) {
+			assert.Equal(0, len(names))
+		}
+	})
+}
+
+func TestStoreMACExact(t *testing.T) {
+	testStores(t, func(t *testing.T, store model.DataStore) {
+		assert := assert.New(t)
+
+		// IPoIB hardware address starting with the same 6 bytes as mac
+		mac, _ := net.ParseMAC("00:11:22:33:44:55")
+		ipoib, _ := net.ParseMAC("00:11:22:33:44:55:00:00:00:00:fe:80:00:00:00:00:00:00:00:01")
+
+		host := tests.HostFactory.MustCreate().(*model.Host)
+		host.Interfaces[0].MAC = ipoib
+		err := store.StoreHost(host)
+		assert.NoError(err)
+
+		_, err = store.LoadHostFromMAC(mac.String())
+		if assert.Error(err) {
+			assert.True(errors.Is(err, model.ErrNotFound))
+		}
+
+		testHost, err := store.LoadHostFromMAC(ipoib.String())
+		if assert.NoError(err) {
+			assert.Equal(host.Name, testHost.Name)
+		}
+	})
+}
+
+func TestStoreInterfaceAddrs(t *testing.T) {
+	testStores(t, func(t *testing.T, store model.DataStore) {
+		assert := assert.New(t)
+
+		host := tests.HostFactory.MustCreate().(*model.Host)
+		host.Interfaces[0].FQDN = "tux-01.compute.local"
+		host.Interfaces[0].IP = netip.MustParsePrefix("10.0.0.1/24")
+		host.Interfaces[0].Addrs = []netip.Prefix{
+			netip.MustParsePrefix("2001:db8::1/64"),
+			netip.MustParsePrefix("10.1.0.1/16"),
+		}
+		err := store.StoreHost(host)
+		assert.NoError(err)
+
+		testHost, err := store.LoadHostFromName(host.Name)
+		if assert.NoError(err) {
+			assert.Equal(host.Interfaces[0].Addrs, testHost.Interfaces[0].Addrs)
+			assert.Equal("2001:db8::1/64", testHost.Interfaces[0].IPv6().String())
+			assert.Equal("10.0.0.1/24", testHost.Interfaces[0].IPv4().String())
+		}
+
+		names, err := store.ReverseResolve("2001:db8::1")
+		if assert.NoError(err) {
+			assert.Equal([]string{"tux-01.compute.local"}, names)
+		}
+
+		ips, err := store.ResolveIPv4("tux-01.compute.local")
+		if assert.NoError(err) {
+			assert.Equal([]net.IP{net.ParseIP("10.0.0.1").To4(), net.ParseIP("10.1.0.1").To4()}, ips)
+		}
+
+		ips, err = store.ResolveIPv6("tux-01.compute.local")
+		if assert.NoError(err) {
+			assert.Equal([]net.IP{net.ParseIP("2001:db8::1")}, ips)
+		}
+
+		// Stale addresses are removed when the host is updated
+		host.Interfaces[0].Addrs = nil
+		err = store.StoreHost(host)
+		assert.NoError(err)
+
+		names, err = store.ReverseResolve("2001:db8::1")
+		if assert.NoError(err) {
+			assert.Equal(0, len(names))
+		}
+	})
+}
+
+func TestStoreAliases(t *testing.T) {
+	testStores(t, func(t *testing.T, store model.DataStore) {
+		assert := assert.New(t)
+
+		host := tests.HostFactory.MustCreate().(*model.Host)
+		host.Interfaces[0].BMC = false
+		host.Interfaces[0].FQDN = "tux-01.compute.local"
+		host.Interfaces[1].BMC = true
+		host.Interfaces[1].FQDN = "tux-01-bmc.compute.local"
+		host.Interfaces[1].Aliases = []string{"ipmi-01.compute.local"}
+		host.Aliases = []string{"login.compute.local", "Web.compute.local"}
+		err := store.StoreHost(host)
+		assert.NoError(err)
+
+		testHost, err := store.LoadHostFromName(host.Name)
+		if assert.NoError(err) {
+			assert.Equal(host.Aliases, testHost.Aliases)
+			assert.Equal(host.Interfaces[1].Aliases, testHost.Interfaces[1].Aliases)
+			assert.Nil(testHost.Interfaces[0].Aliases)
+		}
+
+		for alias, target := range map[string]string{
+			"login.compute.local":    "tux-01.compute.local",
+			"web.compute.local.":     "tux-01.compute.local",
+			"ipmi-01.compute.local":  "tux-01-bmc.compute.local",
+			"tux-01.compute.local":   "",
+			"missing.compute.local.": "",
+		} {
+			name, err := store.ResolveAlias(alias)
+			if assert.NoError(err) {
+				assert.Equal(target, name, alias)
+			}
+		}
+
+		// Stale aliases are removed when the host is updated
+		host.Aliases = nil
+		err = store.StoreHost(host)
+		assert.NoError(err)
+
+		name, err := store.ResolveAlias("login.compute.local")
+		if assert.NoError(err) {
+			assert.Equal("", name)
+		}
+	})
+}
+
+func TestStoreLease(t *testing.T) {
+	testStores(t, func(t *testing.T, store model.DataStore) {
+		assert := assert.New(t)
+
+		host := tests.HostFactory.MustCreate().(*model.Host)
+		host.Interfaces[0].IP = netip.MustParsePrefix("10.0.0.5/24")
+		assert.NoError(store.StoreHost(host))
+
+		mac1, _ := net.ParseMAC("00:00:5e:00:53:01")
+		mac2, _ := net.ParseMAC("00:00:5e:00:53:02")
+		now := time.Now()
+
+		lease := &model.Lease{MAC: mac1, IP: netip.MustParseAddr("10.0.0.100"), Hostname: "new-01", Started: now, Expires: now.Add(time.Hour)}
+		assert.NoError(store.StoreLease(lease))
+
+		testLease, err := store.LoadLease("00:00:5E:00:53:01")
+		if assert.NoError(err) {
+			assert.Equal(lease.IP, testLease.IP)
+			assert.Equal(lease.MAC, testLease.MAC)
+			assert.Equal("new-01", testLease.Hostname)
+		}
+
+		// Active leases and host addresses can't be leased to another client
+		err = store.StoreLease(&model.Lease{MAC: mac2, IP: netip.MustParseAddr("10.0.0.100"), Expires: now.Add(time.Hour)})
+		assert.ErrorIs(err, model.ErrDuplicateEntry)
+		err = store.StoreLease(&model.Lease{MAC: mac2, IP: netip.MustParseAddr("10.0.0.5"), Expires: now.Add(time.Hour)})
+		assert.ErrorIs(err, model.ErrDuplicateEntry)
+
+		// Expired leases are replaced
+		lease.Expires = now.Add(-time.Minute)
+		assert.NoError(store.StoreLease(lease))
+		assert.NoError(store.StoreLease(&model.Lease{MAC: mac2, IP: netip.MustParseAddr("10.0.0.100"), Expires: now.Add(time.Hour)}))
+
+		_, err = store.LoadLease(mac1.String())
+		assert.ErrorIs(err, model.ErrNotFound)
+
+		// Moving a client frees its previous address
+		assert.NoError(store.StoreLease(&model.Lease{MAC: mac2, IP: netip.MustParseAddr("10.0.0.101"), Expires: now.Add(time.Hour)}))
+		assert.NoError(store.StoreLease(&model.Lease{MAC: mac1, IP: netip.MustParseAddr("10.0.0.100"), Expires: now.Add(time.Hour)}))
+
+		leases, err := store.Leases()
+		if assert.NoError(err) && assert.Equal(2, len(leases)) {
+			assert.Equal("10.0.0.100", leases[0].IP.String())
+			assert.Equal("10.0.0.101", leases[1].IP.String())
+		}
+
+		assert.NoError(store.DeleteLease(mac1.String()))
+		_, err = store.LoadLease(mac1.String())
+		assert.ErrorIs(err, model.ErrNotFound)
+		assert.NoError(store.StoreLease(&model.Lease{MAC: mac2, IP: netip.MustParseAddr("10.0.0.100"), Expires: now.Add(time.Hour)}))
+
+		// Declined leases have no MAC and hold the address until they expire
+		declined := &model.Lease{IP: netip.MustParseAddr("10.0.0.102"), Expires: now.Add(time.Hour)}
+		assert.NoError(store.StoreLease(declined))
+		assert.NoError(store.StoreLease(&model.Lease{IP: netip.MustParseAddr("10.0.0.103"), Expires: now.Add(time.Hour)}))
+		err = store.StoreLease(&model.Lease{MAC: mac1, IP: netip.MustParseAddr("10.0.0.102"), Expires: now.Add(time.Hour)})
+		assert.ErrorIs(err, model.ErrDuplicateEntry)
+
+		leases, err = store.Leases()
+		if assert.NoError(err) && assert.Equal(3, len(leases)) {
+			assert.True(leases[1].Declined())
+			assert.Equal("10.0.0.102", leases[1].IP.String())
+		}
+
+		declined.Expires = now.Add(-time.Minute)
+		assert.NoError(store.StoreLease(declined))
+		assert.NoError(store.StoreLease(&model.Lease{MAC: mac1, IP: netip.MustParseAddr("10.0.0.102"), Expires: now.Add(time.Hour)}))
+
+		leases, err = store.Leases()
+		if assert.NoError(err) && assert.Equal(3, len(leases)) {
+			assert.Equal(mac1, leases[1].MAC)
+		}
+	})
+}
+
+func TestStoreUnregistered(t *testing.T) {
+	testStores(t, func(t *testing.T, store model.DataStore) {
+		assert := assert.New(t)
+
+		mac1, _ := net.ParseMAC("00:00:5e:00:53:01")
+		mac2, _ := net.ParseMAC("00:00:5e:00:53:02")
+		now := time.Now()
+
+		assert.NoError(store.StoreUnregistered(&model.UnregisteredHost{MAC: mac2, FirstSeen: now, LastSeen: now, Count: 1}))
+
+		host := &model.UnregisteredHost{
+			MAC:         mac1,
+			Arch:        "EFI x86-64",
+			VendorClass: "PXEClient:Arch:00007:UNDI:003016",
+			UUID:        "4c4c4544-0042-3510-8052-b4c04f4a4d32",
+			RelayAddr:   netip.MustParseAddr("10.0.0.254"),
+			CircuitID:   "Ethernet1/1",
+			FirstSeen:   now.Add(-time.Hour),
+			LastSeen:    now,
+			Count:       3,
+		}
+		assert.NoError(store.StoreUnregistered(host))
+
+		testHost, err := store.LoadUnregistered("00:00:5E:00:53:01")
+		if assert.NoError(err) {
+			assert.Equal(host.MAC, testHost.MAC)
+			assert.Equal(host.UUID, testHost.UUID)
+			assert.Equal(host.RelayAddr, testHost.RelayAddr)
+			assert.Equal("Ethernet1/1", testHost.CircuitID)
+			assert.Equal(3, testHost.Count)
+		}
+
+		hosts, err := store.UnregisteredHosts()
+		if assert.NoError(err) && assert.Equal(2, len(hosts)) {
+			assert.Equal(mac1, hosts[0].MAC)
+			assert.Equal(mac2, hosts[1].MAC)
+		}
+
+		assert.NoError(store.DeleteUnregistered(mac1.String()))
+		_, err = store.LoadUnregistered(mac1.String())
+		assert.ErrorIs(err, model.ErrNotFound)
+
+		hosts, err = store.UnregisteredHosts()
+		if assert.NoError(err) {
+			assert.Equal(1, len(hosts))
+		}
+	})
+}
+
+func TestStoreRelay(t *testing.T) {
+	testStores(t, func(t *testing.T, store model.DataStore) {
+		assert := assert.New(t)
+
+		host := tests.HostFactory.MustCreate().(*model.Host)
+		host.Interfaces[0].MAC = nil
+		host.Interfaces[0].CircuitID = "Eth1/12"
+		host.Interfaces[0].RemoteID = "leaf-01"
+		assert.NoError(store.StoreHost(host))
+
+		other := tests.HostFactory.MustCreate().(*model.Host)
+		other.Interfaces[0].CircuitID = "Eth1/1"
+		assert.NoError(store.StoreHost(other))
+
+		testHost, err := store.LoadHostFromRelay("Eth1/12", "leaf-01")
+		if assert.NoError(err) {
+			assert.Equal(host.Name, testHost.Name)
+			assert.Equal("Eth1/12", testHost.Interfaces[0].CircuitID)
+			assert.Equal("leaf-01", testHost.Interfaces[0].RemoteID)
+		}
+
+		// Remote id only matters when set on the interface
+		testHost, err = store.LoadHostFromRelay("Eth1/1", "leaf-07")
+		if assert.NoError(err) {
+			assert.Equal(other.Name, testHost.Name)
+		}
+
+		for _, relay := range [][2]string{{"Eth1/12", "leaf-02"}, {"Eth1", "leaf-01"}, {"", ""}} {
+			_, err = store.LoadHostFromRelay(relay[0], relay[1])
+			if assert.Error(err) {
+				assert.True(errors.Is(err, model.ErrNotFound))
+			}
+		}
+	})
+}
+
+func TestStoreUUID(t *testing.T) {
+	testStores(t, func(t *testing.T, store model.DataStore) {
+		assert := assert.New(t)
+
+		host := tests.HostFactory.MustCreate().(*model.Host)
+		host.UUID = "4C4C4544-0042-3510-8052-B4C04F4A4D32"
+		host.Serial = "B5RJM32"
+		assert.NoError(store.StoreHost(host))
+
+		testHost, err := store.LoadHostFromUUID("4c4c4544-0042-3510-8052-b4c04f4a4d32")
+		if assert.NoError(err) {
+			assert.Equal(host.Name, testHost.Name)
+			assert.Equal(host.UUID, testHost.UUID)
+			assert.Equal("B5RJM32", testHost.Serial)
+		}
+
+		// Changing the UUID updates the index
+		testHost.UUID = "4c4c4544-0042-3510-8052-b4c04f4a4d33"
+		assert.NoError(store.StoreHost(testHost))
+
+		_, err = store.LoadHostFromUUID(host.UUID)
+		if assert.Error(err) {
+			assert.True(errors.Is(err, model.ErrNotFound))
+		}
+
+		testHost, err = store.LoadHostFromUUID("4C4C4544-0042-3510-8052-B4C04F4A4D33")
+		if assert.NoError(err) {
+			assert.Equal(host.Name, testHost.Name)
+		}
+
+		_, err = store.LoadHostFromUUID("")
+		if assert.Error(err) {
+			assert.True(errors.Is(err, model.ErrNotFound))
+		}
+	})
+}
+
+func TestStoreUUIDUnique(t *testing.T) {
+	testStores(t, func(t *testing.T, store model.DataStore) {
+		assert := assert.New(t)
+
+		host := tests.HostFactory.MustCreate().(*model.Host)
+		host.UUID = "4c4c4544-0042-3510-8052-b4c04f4a4d32"
+		assert.NoError(store.StoreHost(host))
+
+		// Storing the same host again keeps its UUID
+		assert.NoError(store.StoreHost(host))
+
+		other := tests.HostFactory.MustCreate().(*model.Host)
+		other.UUID = "4C4C4544-0042-3510-8052-B4C04F4A4D32"
+		err := store.StoreHost(other)
+		if assert.Error(err) {
+			assert.True(errors.Is(err, model.ErrDuplicateEntry))
+		}
+
+		// Two hosts in the same batch
+		third := tests.HostFactory.MustCreate().(*model.Host)
+		third.UUID = "4c4c4544-0042-3510-8052-b4c04f4a4d33"
+		other.UUID = third.UUID
+		err = store.StoreHosts(model.HostList{other, third})
+		if assert.Error(err) {
+			assert.True(errors.Is(err, model.ErrDuplicateEntry))
+		}
+
+		// Hosts in the same batch can swap UUIDs
+		other.UUID = host.UUID
+		host.UUID = third.UUID
+		assert.NoError(store.StoreHosts(model.HostList{host, other}))
+
+		testHost, err := store.LoadHostFromUUID(other.UUID)
+		if assert.NoError(err) {
+			assert.Equal(other.Name, testHost.Name)
+		}
+
+		for _, uuid := range []string{
+			"00000000-0000-0000-0000-000000000000",
+			"FFFFFFFF-FFFF-FFFF-FFFF-FFFFFFFFFFFF",
+			"03000200-0400-0500-0006-000700080009",
+		} {
+			third.UUID = uuid
+			err = store.StoreHost(third)
+			if assert.Error(err) {
+				assert.True(errors.Is(err, model.ErrInvalidData))
+			}
+
+			_, err = store.LoadHostFromUUID(uuid)
+			assert.True(errors.Is(err, model.ErrNotFound))
+		}
+	})
+}
@@ -4,9 +4,13 @@ import (
 	"fmt"
 	"net"
 	"net/netip"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/spf13/viper"
+	"github.com/ubccr/grendel/firmware"
+	"go4.org/netipx"
 )
 
 var (
@@ -17,7 +21,11 @@ var (
 	DefaultDNS          []net.IP       = []net.IP{}
 	DefaultDomainSearch []string       = []string{}
 	DefaultMTU          uint16         = 1500
+	DefaultPrefixBits   int            = 24
+	DefaultPrefixBits6  int            = 64
 	DefaultGateway      netip.Addr
+	AuditMaxAge         time.Duration
+	AuditMaxEntries     int = 100000
 )
 
 func ParseConfigs() error {
@@ -26,6 +34,7 @@ func ParseConfigs() error {
 		DNS          string
 		DomainSearch string
 		MTU          uint16
+		Range        string
 	}
 	var subnetConfigs []SubnetConfig
 
@@ -61,7 +70,23 @@ func ParseConfigs() error {
 			domainSearch = append(domainSearch, domain)
 		}
 
-		Subnets = append(Subnets, Subnet{Gateway: gw, DNS: dnsServers, DomainSearch: domainSearch, MTU: sc.MTU})
+		var pool netipx.IPRange
+		if sc.Range != "" {
+			pool, err = netipx.ParseIPRange(sc.Range)
+			if err != nil {
+				return fmt.Errorf("Failed parsing dhcp.subnets config. Invalid range: %s", sc.Range)
+			}
+
+			if !gw.Contains(pool.From()) || !gw.Contains(pool.To()) {
+				return fmt.Errorf("Failed parsing dhcp.subnets config. Range %s is outside of subnet %s", sc.Range, gw.Masked())
+			}
+		}
+
+		Subnets = append(Subnets, Subnet{Gateway: gw, DNS: dnsServers, DomainSearch: domainSearch, MTU: sc.MTU, Pool: pool})
+	}
+
+	if err := parseFirmwareOverrides(); err != nil {
+		return err
 	}
 
 	DefaultDNS = make([]net.IP, 0)
@@ -88,6 +113,17 @@ func ParseConfigs() error {
 		}
 	}
 
+	if viper.IsSet("audit.max_age") {
+		AuditMaxAge, err = time.ParseDuration(viper.GetString("audit.max_age"))
+		if err != nil {
+			return fmt.Errorf("Failed parsing audit.max_age %s: %w", viper.GetString("audit.max_age"), err)
+		}
+	}
+
+	if viper.IsSet("audit.max_entries") {
+		AuditMaxEntries = viper.GetInt("audit.max_entries")
+	}
+
 	ProvisionHostname = viper.GetString("provision.hostname")
 	ProvisionAddr = addrPort
 
@@ -97,3 +133,50 @@ func ParseConfigs() error {
 
 	return nil
 }
+
+// parseFirmwareOverrides sets firmware.Overrides from the firmware.overrides
+// config
+func parseFirmwareOverrides() error {
+	type OverrideConfig struct {
+		MACPrefix   string `mapstructure:"mac_prefix"`
+		VendorClass string `mapstructure:"vendor_class"`
+		Tag         string
+		Build       string
+	}
+	var overrideConfigs []OverrideConfig
+
+	err := viper.UnmarshalKey("firmware.overrides", &overrideConfigs)
+	if err != nil {
+		return err
+	}
+
+	firmware.Overrides = make([]firmware.Override, 0)
+	for _, oc := range overrideConfigs {
+		if oc.MACPrefix == "" && oc.VendorClass == "" && oc.Tag == "" {
+			return fmt.Errorf("Failed parsing firmware.overrides config. Override for build %s does not match on mac_prefix, vendor_class or tag", oc.Build)
+		}
+
+		build, err := firmware.Parse(oc.Build)
+		if err != nil || build.IsNil() {
+			return fmt.Errorf("Failed parsing firmware.overrides config. Invalid build: %s", oc.Build)
+		}
+
+		prefix := strings.ToLower(strings.ReplaceAll(oc.MACPrefix, "-", ":"))
+		if prefix != "" {
+			for _, octet := range strings.Split(prefix, ":") {
+				if _, err := strconv.ParseUint(octet, 16, 8); err != nil || len(octet) != 2 {
+					return fmt.Errorf("Failed parsing firmware.overrides config. Invalid mac_prefix: %s", oc.MACPrefix)
+				}
+			}
+		}
+
+		firmware.Overrides = append(firmware.Overrides, firmware.Override{
+			MACPrefix:   prefix,
+			VendorClass: oc.VendorClass,
+			Tag:         oc.Tag,
+			Build:       build,
+		})
+	}
+
+	return nil
+}
@@ -21,11 +21,18 @@ package model
 import (
 	"errors"
 	"net"
+	"strings"
+	"time"
 
 	"github.com/ubccr/grendel/logger"
 	"github.com/ubccr/grendel/nodeset"
 )
 
+// SchemaVersion is the current version of the records in the data store.
+// Version 1 stored bare IP addresses on network interfaces, version 2 stores
+// IP addresses with a network prefix.
+const SchemaVersion = 2
+
 var (
 	// Global logger for DB package
 	log = logger.GetLogger("DB")
@@ -96,16 +103,99 @@ type DataStore interface {
 	// LoadHostFromMAC returns the Host that has a network interface with the give MAC address
 	LoadHostFromMAC(mac string) (*Host, error)
 
+	// LoadHostFromRelay returns the Host that has a network interface
+	// identified by the given relay agent circuit id and remote id
+	LoadHostFromRelay(circuitID, remoteID string) (*Host, error)
+
+	// LoadHostFromUUID returns the Host with the given system UUID
+	LoadHostFromUUID(uuid string) (*Host, error)
+
 	// ResolveIPv4 returns the list of IPv4 addresses with the given FQDN
 	ResolveIPv4(fqdn string) ([]net.IP, error)
 
+	// ResolveIPv6 returns the list of IPv6 addresses with the given FQDN
+	ResolveIPv6(fqdn string) ([]net.IP, error)
+
+	// ResolveAlias returns the FQDN the given alias points to or an empty
+	// string if there is no such alias
+	ResolveAlias(name string) (string, error)
+
 	// ReverseResolve returns the list of FQDNs for the given IP
 	ReverseResolve(ip string) ([]string, error)
 
+	// StoreAuditEntry stores an entry in the audit log and sets its ID
+	StoreAuditEntry(entry *AuditEntry) error
+
+	// AuditEntries returns the audit log entries matching the filter, newest first
+	AuditEntries(filter *AuditFilter) (AuditEntryList, error)
+
+	// LoadAuditEntry returns the audit log entry with the given ID
+	LoadAuditEntry(id int64) (*AuditEntry, error)
+
+	// PruneAuditEntries deletes audit log entries older than maxAge and all
+	// but the newest maxEntries entries. A zero limit is not applied. Returns
+	// the number of entries deleted.
+	PruneAuditEntries(maxAge time.Duration, maxEntries int) (int, error)
+
+	// StoreProvisionRecord stores the provisioning record of a host
+	StoreProvisionRecord(record *ProvisionRecord) error
+
+	// LoadProvisionRecord returns the provisioning record of the host with the given name
+	LoadProvisionRecord(name string) (*ProvisionRecord, error)
+
+	// ProvisionRecords returns the provisioning records of the hosts in the
+	// given NodeSet, or of all hosts if ns is nil
+	ProvisionRecords(ns *nodeset.NodeSet) (ProvisionRecordList, error)
+
+	// StoreLease stores the dynamic DHCP lease of a client, replacing any
+	// previous lease of the client and any expired lease of the address. It
+	// returns ErrDuplicateEntry if the address is assigned to a host or leased
+	// to another client. Leases without a MAC are stored by address.
+	StoreLease(lease *Lease) error
+
+	// LoadLease returns the lease of the client with the given MAC address
+	LoadLease(mac string) (*Lease, error)
+
+	// DeleteLease deletes the lease of the client with the given MAC address
+	DeleteLease(mac string) error
+
+	// Leases returns all dynamic DHCP leases including expired leases
+	Leases() (LeaseList, error)
+
+	// StoreUnregistered stores an unknown PXE client seen by the DHCP server
+	StoreUnregistered(host *UnregisteredHost) error
+
+	// LoadUnregistered returns the unregistered host with the given MAC address
+	LoadUnregistered(mac string) (*UnregisteredHost, error)
+
+	// DeleteUnregistered deletes the unregistered host with the given MAC address
+	DeleteUnregistered(mac string) error
+
+	// UnregisteredHosts returns all unregistered hosts in the order they were
+	// first seen
+	UnregisteredHosts() (UnregisteredHostList, error)
+
+	// SchemaVersion returns the schema version of the records in the data store
+	SchemaVersion() (int, error)
+
+	// Migrate upgrades records in the data store to the current SchemaVersion
+	Migrate() error
+
 	// Close data store
 	Close() error
 }
 
+// NewDataStore returns a DataStore for the given database path. The backend is
+// selected by the URL scheme, for example sqlite:///var/lib/grendel/grendel.db
+// or buntdb:///var/lib/grendel/grendel.db. A path without a scheme uses
+// BuntStore. Use :memory: as the path for an in-memory database.
 func NewDataStore(path string) (DataStore, error) {
+	switch {
+	case strings.HasPrefix(path, "sqlite://"):
+		return NewSQLStore(strings.TrimPrefix(path, "sqlite://"))
+	case strings.HasPrefix(path, "buntdb://"):
+		return NewBuntStore(strings.TrimPrefix(path, "buntdb://"))
+	}
+
 	return NewBuntStore(path)
 }
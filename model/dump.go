@@ -0,0 +1,102 @@
+// Copyright 2019 Grendel Authors. All rights reserved.
+//
+// This file is part of Grendel.
+//
+// Grendel is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Grendel is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with Grendel. If not, see <https://www.gnu.org/licenses/>.
+
+package model
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// Dump is a versioned export of all hosts and boot images in a data store
+type Dump struct {
+	Version int           `json:"version"`
+	Created time.Time     `json:"created"`
+	Hosts   HostList      `json:"hosts"`
+	Images  BootImageList `json:"images"`
+}
+
+// NewDump returns a Dump of all hosts and boot images in the data store
+func NewDump(store DataStore) (*Dump, error) {
+	hosts, err := store.Hosts()
+	if err != nil {
+		return nil, err
+	}
+
+	images, err := store.BootImages()
+	if err != nil {
+		return nil, err
+	}
+
+	return &Dump{
+		Version: SchemaVersion,
+		Created: time.Now(),
+		Hosts:   hosts,
+		Images:  images,
+	}, nil
+}
+
+// ParseDump parses a Dump from JSON. A plain JSON list of hosts, as output by
+// `grendel host show` in older releases, is also accepted. Records from older
+// schema versions are upgraded as they are parsed.
+func ParseDump(data []byte) (*Dump, error) {
+	dump := &Dump{}
+
+	data = bytes.TrimSpace(data)
+	if bytes.HasPrefix(data, []byte("[")) {
+		dump.Version = 1
+		err := json.Unmarshal(data, &dump.Hosts)
+		if err != nil {
+			return nil, err
+		}
+
+		return dump, nil
+	}
+
+	err := json.Unmarshal(data, dump)
+	if err != nil {
+		return nil, err
+	}
+
+	if dump.Version < 1 || dump.Version > SchemaVersion {
+		return nil, fmt.Errorf("unsupported dump version %d, expected 1 to %d: %w", dump.Version, SchemaVersion, ErrInvalidData)
+	}
+
+	return dump, nil
+}
+
+// Restore stores all hosts and boot images in the dump to the data store.
+// Existing hosts and boot images with the same name are overwritten.
+func (d *Dump) Restore(store DataStore) error {
+	if len(d.Hosts) > 0 {
+		err := store.StoreHosts(d.Hosts)
+		if err != nil {
+			return err
+		}
+	}
+
+	if len(d.Images) > 0 {
+		err := store.StoreBootImages(d.Images)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
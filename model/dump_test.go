@@ -0,0 +1,153 @@
+// Copyright 2019 Grendel Authors. All rights reserved.
+//
+// This file is part of Grendel.
+//
+// Grendel is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Grendel is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with Grendel. If not, see <https://www.gnu.org/licenses/>.
+
+package model_test
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tidwall/buntdb"
+	"github.com/ubccr/grendel/internal/tests"
+	"github.com/ubccr/grendel/model"
+)
+
+const legacyHostJSON = `{"id":"1VCnR6qevU5BbihTIvZEhX002CI","name":"tux01","provision":true,"firmware":"","boot_image":"centos7","tags":["k16"],"interfaces":[{"mac":"00:11:22:33:44:55","ifname":"eno1","ip":"10.10.1.2","fqdn":"tux01.compute.local","bmc":false}]}`
+
+func TestDumpRestore(t *testing.T) {
+	testStores(t, func(t *testing.T, store model.DataStore) {
+		assert := assert.New(t)
+
+		hosts := make(model.HostList, 0)
+		for i := 0; i < 5; i++ {
+			hosts = append(hosts, tests.HostFactory.MustCreate().(*model.Host))
+		}
+		assert.NoError(store.StoreHosts(hosts))
+		assert.NoError(store.StoreBootImage(tests.BootImageFactory.MustCreate().(*model.BootImage)))
+
+		dump, err := model.NewDump(store)
+		if !assert.NoError(err) {
+			return
+		}
+		assert.Equal(model.SchemaVersion, dump.Version)
+
+		data, err := json.Marshal(dump)
+		assert.NoError(err)
+
+		restore, err := model.NewDataStore("sqlite://:memory:")
+		if !assert.NoError(err) {
+			return
+		}
+		defer restore.Close()
+
+		parsed, err := model.ParseDump(data)
+		if assert.NoError(err) {
+			assert.NoError(parsed.Restore(restore))
+		}
+
+		testHosts, err := restore.Hosts()
+		if assert.NoError(err) {
+			assert.Equal(5, len(testHosts))
+		}
+
+		testHost, err := restore.LoadHostFromMAC(hosts[0].Interfaces[0].MAC.String())
+		if assert.NoError(err) {
+			assert.Equal(hosts[0].Name, testHost.Name)
+			assert.Equal(hosts[0].Interfaces[0].CIDR(), testHost.Interfaces[0].CIDR())
+		}
+
+		images, err := restore.BootImages()
+		if assert.NoError(err) {
+			assert.Equal(1, len(images))
+		}
+	})
+}
+
+func TestParseDumpLegacy(t *testing.T) {
+	assert := assert.New(t)
+
+	dump, err := model.ParseDump([]byte("[" + legacyHostJSON + "]"))
+	if assert.NoError(err) && assert.Equal(1, len(dump.Hosts)) {
+		assert.Equal(1, dump.Version)
+		assert.Equal("10.10.1.2/24", dump.Hosts[0].Interfaces[0].CIDR())
+	}
+
+	_, err = model.ParseDump([]byte(`{"version": 99, "hosts": []}`))
+	if assert.Error(err) {
+		assert.True(errors.Is(err, model.ErrInvalidData))
+	}
+}
+
+func TestBuntStoreMigrate(t *testing.T) {
+	assert := assert.New(t)
+
+	file := tempfile()
+	defer os.Remove(file)
+
+	// Write a host record as stored by releases prior to 0.0.8
+	db, err := buntdb.Open(file)
+	if assert.NoError(err) {
+		err = db.Update(func(tx *buntdb.Tx) error {
+			_, _, err := tx.Set(model.HostKeyPrefix+":tux01", legacyHostJSON, nil)
+			return err
+		})
+		assert.NoError(err)
+		assert.NoError(db.Close())
+	}
+
+	store, err := model.NewBuntStore(file)
+	if !assert.NoError(err) {
+		return
+	}
+	defer store.Close()
+
+	version, err := store.SchemaVersion()
+	if assert.NoError(err) {
+		assert.Equal(1, version)
+	}
+
+	assert.NoError(store.Migrate())
+
+	version, err = store.SchemaVersion()
+	if assert.NoError(err) {
+		assert.Equal(model.SchemaVersion, version)
+	}
+
+	names, err := store.ReverseResolve("10.10.1.2")
+	if assert.NoError(err) {
+		assert.Equal([]string{"tux01.compute.local"}, names)
+	}
+
+	host, err := store.LoadHostFromName("tux01")
+	if assert.NoError(err) {
+		assert.Equal("10.10.1.2/24", host.Interfaces[0].CIDR())
+		assert.Equal("centos7", host.BootImage)
+	}
+
+	// New databases are created with the current schema version
+	mem, err := model.NewBuntStore(":memory:")
+	if assert.NoError(err) {
+		defer mem.Close()
+		version, err := mem.SchemaVersion()
+		if assert.NoError(err) {
+			assert.Equal(model.SchemaVersion, version)
+		}
+	}
+}
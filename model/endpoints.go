@@ -2,10 +2,14 @@ package model
 
 import (
 	"fmt"
+	"net/netip"
+
+	"github.com/ubccr/grendel/firmware"
 )
 
 const (
 	endpointPrefix     string = "boot"
+	endpointFirmware          = "firmware"
 	endpointRepo              = "repo"
 	endpointComplete          = "complete"
 	endpointIPXE              = "ipxe"
@@ -31,8 +35,24 @@ func NewEndpoints(host, token string) *Endpoints {
 	return &Endpoints{host: host, token: token}
 }
 
+// urlHost returns the host enclosed in brackets if it's an IPv6 address
+func urlHost(host string) string {
+	addr, err := netip.ParseAddr(host)
+	if err == nil && addr.Is6() && !addr.Is4In6() {
+		return "[" + host + "]"
+	}
+
+	return host
+}
+
 func (e *Endpoints) BootFileURL() string {
-	return fmt.Sprintf("tftp://%s/%s", e.host, e.token)
+	return fmt.Sprintf("tftp://%s/%s", urlHost(e.host), e.token)
+}
+
+// FirmwareURL returns the HTTP URL of the firmware build for HTTP boot
+// clients. The token must be a firmware token.
+func (e *Endpoints) FirmwareURL(build firmware.Build) string {
+	return fmt.Sprintf("%s/%s/%s/%s", e.BaseURL(), endpointFirmware, e.token, build)
 }
 
 func (e *Endpoints) RepoURL() string {
@@ -40,7 +60,7 @@ func (e *Endpoints) RepoURL() string {
 }
 
 func (e *Endpoints) BaseURL() string {
-	host := e.host
+	host := urlHost(e.host)
 	if ProvisionHostname != "" {
 		host = ProvisionHostname
 	}
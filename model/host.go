@@ -22,12 +22,13 @@ import (
 	"encoding/json"
 	"fmt"
 	"net"
-	"net/netip"
+	"strings"
 
 	"github.com/segmentio/ksuid"
 	"github.com/tidwall/gjson"
 	"github.com/tidwall/sjson"
 	"github.com/ubccr/grendel/firmware"
+	"github.com/ubccr/grendel/util"
 )
 
 type Host struct {
@@ -38,6 +39,18 @@ type Host struct {
 	Firmware   firmware.Build  `json:"firmware"`
 	BootImage  string          `json:"boot_image"`
 	Tags       []string        `json:"tags"`
+
+	// Aliases are DNS names served as CNAME records pointing at the FQDN
+	// of the boot interface
+	Aliases []string `json:"aliases,omitempty"`
+
+	// UUID is the SMBIOS system UUID sent by PXE clients in DHCP option 97.
+	// Hosts are looked up by UUID when the MAC address is unknown, for
+	// example after a NIC swap. It is learned the first time the host boots.
+	UUID string `json:"uuid,omitempty"`
+
+	// Serial is the system serial number
+	Serial string `json:"serial,omitempty"`
 }
 
 func (h *Host) HasTags(tags ...string) bool {
@@ -80,6 +93,18 @@ func (h *Host) Interface(mac net.HardwareAddr) *NetInterface {
 	return nil
 }
 
+// InterfaceFromRelay returns the interface identified by the given relay agent
+// circuit id and remote id or nil if none matches
+func (h *Host) InterfaceFromRelay(circuitID, remoteID string) *NetInterface {
+	for _, nic := range h.Interfaces {
+		if nic.MatchRelay(circuitID, remoteID) {
+			return nic
+		}
+	}
+
+	return nil
+}
+
 func (h *Host) InterfaceBMC() *NetInterface {
 	for _, nic := range h.Interfaces {
 		if nic.BMC {
@@ -100,12 +125,41 @@ func (h *Host) BootInterface() *NetInterface {
 	return nil
 }
 
+// AliasTarget returns the FQDN the given alias points to or an empty string if
+// the alias is not defined on the host. Aliases of an interface point to the
+// interface FQDN and aliases of the host point to the boot interface FQDN.
+func (h *Host) AliasTarget(alias string) string {
+	alias = util.Normalize(alias)
+
+	for _, nic := range h.Interfaces {
+		for _, a := range nic.Aliases {
+			if util.Normalize(a) == alias && nic.FQDN != "" {
+				return nic.FQDN
+			}
+		}
+	}
+
+	for _, a := range h.Aliases {
+		if util.Normalize(a) != alias {
+			continue
+		}
+
+		if nic := h.BootInterface(); nic != nil && nic.FQDN != "" {
+			return nic.FQDN
+		}
+	}
+
+	return ""
+}
+
 func (h *Host) FromJSON(hostJSON string) {
 	h.Name = gjson.Get(hostJSON, "name").String()
 	h.BootImage = gjson.Get(hostJSON, "boot_image").String()
 	h.Provision = gjson.Get(hostJSON, "provision").Bool()
 	h.ID, _ = ksuid.Parse(gjson.Get(hostJSON, "id").String())
-	h.Firmware = firmware.NewFromString(gjson.Get(hostJSON, "firmware").String())
+	h.Firmware, _ = firmware.Parse(gjson.Get(hostJSON, "firmware").String())
+	h.UUID = gjson.Get(hostJSON, "uuid").String()
+	h.Serial = gjson.Get(hostJSON, "serial").String()
 
 	h.Interfaces = make([]*NetInterface, 0)
 	res := gjson.Get(hostJSON, "interfaces")
@@ -116,8 +170,18 @@ func (h *Host) FromJSON(hostJSON string) {
 		nic.BMC = i.Get("bmc").Bool()
 		nic.VLAN = i.Get("vlan").String()
 		nic.MTU = uint16(i.Get("mtu").Int())
-		nic.IP, _ = netip.ParsePrefix(i.Get("ip").String())
+		nic.IP, _ = ParseIPPrefix(i.Get("ip").String())
 		nic.MAC, _ = net.ParseMAC(i.Get("mac").String())
+		nic.CircuitID = i.Get("circuit_id").String()
+		nic.RemoteID = i.Get("remote_id").String()
+		for _, a := range i.Get("addrs").Array() {
+			if ip, err := ParseIPPrefix(a.String()); err == nil {
+				nic.Addrs = append(nic.Addrs, ip)
+			}
+		}
+		for _, a := range i.Get("aliases").Array() {
+			nic.Aliases = append(nic.Aliases, a.String())
+		}
 		h.Interfaces = append(h.Interfaces, nic)
 	}
 
@@ -125,6 +189,10 @@ func (h *Host) FromJSON(hostJSON string) {
 	for _, i := range tres.Array() {
 		h.Tags = append(h.Tags, i.String())
 	}
+
+	for _, a := range gjson.Get(hostJSON, "aliases").Array() {
+		h.Aliases = append(h.Aliases, a.String())
+	}
 }
 
 func (h *Host) ToJSON() string {
@@ -148,6 +216,22 @@ func (h *Host) ToJSON() string {
 			"vlan":   nic.VLAN,
 			"mtu":    nic.MTU,
 		}
+		if len(nic.Addrs) > 0 {
+			addrs := make([]string, len(nic.Addrs))
+			for i, a := range nic.Addrs {
+				addrs[i] = a.String()
+			}
+			n["addrs"] = addrs
+		}
+		if len(nic.Aliases) > 0 {
+			n["aliases"] = nic.Aliases
+		}
+		if nic.CircuitID != "" {
+			n["circuit_id"] = nic.CircuitID
+		}
+		if nic.RemoteID != "" {
+			n["remote_id"] = nic.RemoteID
+		}
 		hostJSON, _ = sjson.Set(hostJSON, "interfaces.-1", n)
 	}
 
@@ -155,6 +239,18 @@ func (h *Host) ToJSON() string {
 		hostJSON, _ = sjson.Set(hostJSON, "tags.-1", t)
 	}
 
+	if len(h.Aliases) > 0 {
+		hostJSON, _ = sjson.Set(hostJSON, "aliases", h.Aliases)
+	}
+
+	if h.UUID != "" {
+		hostJSON, _ = sjson.Set(hostJSON, "uuid", h.UUID)
+	}
+
+	if h.Serial != "" {
+		hostJSON, _ = sjson.Set(hostJSON, "serial", h.Serial)
+	}
+
 	return hostJSON
 }
 
@@ -195,10 +291,48 @@ func (h *Host) UnmarshalJSON(data []byte) error {
 		return err
 	}
 
-	h.Firmware = firmware.NewFromString(aux.Firmware)
-	if len(aux.Firmware) != 0 && h.Firmware.IsNil() {
+	fw, err := firmware.Parse(aux.Firmware)
+	if err != nil {
 		return fmt.Errorf("Invalid firmware build: %s", aux.Firmware)
 	}
+	h.Firmware = fw
+
+	return nil
+}
+
+// placeholderUUIDs are system UUIDs left unset by the board vendor. They are
+// shared by many machines so they never identify a host.
+var placeholderUUIDs = map[string]bool{
+	"00000000-0000-0000-0000-000000000000": true,
+	"ffffffff-ffff-ffff-ffff-ffffffffffff": true,
+	"03000200-0400-0500-0006-000700080009": true,
+}
+
+// PlaceholderUUID returns true if the given system UUID is a vendor
+// placeholder
+func PlaceholderUUID(uuid string) bool {
+	return placeholderUUIDs[strings.ToLower(uuid)]
+}
+
+// checkUUIDs returns an error if a host has a placeholder system UUID or if
+// two hosts in the list share the same UUID
+func checkUUIDs(hosts HostList) error {
+	seen := make(map[string]string)
+	for _, host := range hosts {
+		if host.UUID == "" {
+			continue
+		}
+
+		if PlaceholderUUID(host.UUID) {
+			return fmt.Errorf("placeholder uuid %s for host %s:  %w", host.UUID, host.Name, ErrInvalidData)
+		}
+
+		key := strings.ToLower(host.UUID)
+		if name, ok := seen[key]; ok && name != host.Name {
+			return fmt.Errorf("uuid %s is set on hosts %s and %s:  %w", host.UUID, name, host.Name, ErrDuplicateEntry)
+		}
+		seen[key] = host.Name
+	}
 
 	return nil
 }
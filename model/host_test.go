@@ -18,9 +18,13 @@
 package model_test
 
 import (
+	"encoding/json"
+	"net/netip"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/tidwall/gjson"
+	"github.com/ubccr/grendel/firmware"
 	"github.com/ubccr/grendel/internal/tests"
 	"github.com/ubccr/grendel/model"
 )
@@ -38,3 +42,48 @@ func TestHostTags(t *testing.T) {
 	assert.False(host.HasAnyTags())
 	assert.False(host.HasTags())
 }
+
+func TestNetInterfaceAddrs(t *testing.T) {
+	assert := assert.New(t)
+
+	nic := &model.NetInterface{}
+	err := json.Unmarshal([]byte(`{"mac":"00:11:22:33:44:55","ip":"10.0.0.1/24","addrs":["2001:db8::1","10.1.0.1/16"]}`), nic)
+	if assert.NoError(err) {
+		assert.Equal("10.0.0.1/24", nic.IPv4().String())
+		assert.Equal("2001:db8::1/64", nic.IPv6().String())
+		assert.Equal(3, len(nic.Prefixes()))
+		assert.True(nic.HasAddr(netip.MustParseAddr("10.1.0.1")))
+		assert.False(nic.HasAddr(netip.MustParseAddr("10.1.0.2")))
+	}
+
+	data, err := json.Marshal(nic)
+	if assert.NoError(err) {
+		assert.Equal([]interface{}{"2001:db8::1/64", "10.1.0.1/16"}, gjson.GetBytes(data, "addrs").Value())
+	}
+
+	v6 := &model.NetInterface{IP: netip.MustParsePrefix("2001:db8::1/64")}
+	assert.False(v6.IPv4().IsValid())
+	assert.Equal("ffff:ffff:ffff:ffff::", v6.NetmaskString())
+}
+
+func TestHostFirmware(t *testing.T) {
+	assert := assert.New(t)
+
+	host := &model.Host{}
+	assert.NoError(json.Unmarshal([]byte(`{"name":"tux01","firmware":"ipxe-x86_64.efi"}`), host))
+	assert.Equal(firmware.EFI64, host.Firmware)
+
+	// Builds which are not loaded in this process keep their name so the host
+	// can be stored again unchanged, but are not served
+	assert.NoError(json.Unmarshal([]byte(`{"name":"tux01","firmware":"ipxe-missing.efi"}`), host))
+	assert.Equal("ipxe-missing.efi", host.Firmware.String())
+	assert.False(host.Firmware.Available())
+	assert.NotContains(firmware.Builds(), "ipxe-missing.efi")
+
+	data, err := json.Marshal(host)
+	if assert.NoError(err) {
+		assert.Contains(string(data), `"firmware":"ipxe-missing.efi"`)
+	}
+
+	assert.Error(json.Unmarshal([]byte(`{"name":"tux01","firmware":"../ipxe.efi"}`), host))
+}
@@ -0,0 +1,245 @@
+// Copyright 2019 Grendel Authors. All rights reserved.
+//
+// This file is part of Grendel.
+//
+// Grendel is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Grendel is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with Grendel. If not, see <https://www.gnu.org/licenses/>.
+
+package model
+
+import (
+	"net"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/ubccr/grendel/metrics"
+	"github.com/ubccr/grendel/nodeset"
+)
+
+var operationDuration = metrics.Factory.NewHistogramVec(prometheus.HistogramOpts{
+	Namespace: metrics.Namespace,
+	Subsystem: "datastore",
+	Name:      "operation_duration_seconds",
+	Help:      "Latency of data store operations",
+	Buckets:   []float64{.0001, .00025, .0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1},
+}, []string{"operation"})
+
+// InstrumentedStore wraps a DataStore and records the latency of each
+// operation in the datastore metrics
+type InstrumentedStore struct {
+	DataStore
+}
+
+// NewInstrumentedStore returns a DataStore which records operation latency
+func NewInstrumentedStore(store DataStore) *InstrumentedStore {
+	return &InstrumentedStore{DataStore: store}
+}
+
+func observe(op string, start time.Time) {
+	operationDuration.WithLabelValues(op).Observe(time.Since(start).Seconds())
+}
+
+func (s *InstrumentedStore) BootImages() (BootImageList, error) {
+	defer observe("BootImages", time.Now())
+	return s.DataStore.BootImages()
+}
+
+func (s *InstrumentedStore) LoadBootImage(name string) (*BootImage, error) {
+	defer observe("LoadBootImage", time.Now())
+	return s.DataStore.LoadBootImage(name)
+}
+
+func (s *InstrumentedStore) StoreBootImage(image *BootImage) error {
+	defer observe("StoreBootImage", time.Now())
+	return s.DataStore.StoreBootImage(image)
+}
+
+func (s *InstrumentedStore) StoreBootImages(images BootImageList) error {
+	defer observe("StoreBootImages", time.Now())
+	return s.DataStore.StoreBootImages(images)
+}
+
+func (s *InstrumentedStore) DeleteBootImages(names []string) error {
+	defer observe("DeleteBootImages", time.Now())
+	return s.DataStore.DeleteBootImages(names)
+}
+
+func (s *InstrumentedStore) SetBootImage(ns *nodeset.NodeSet, name string) error {
+	defer observe("SetBootImage", time.Now())
+	return s.DataStore.SetBootImage(ns, name)
+}
+
+func (s *InstrumentedStore) Hosts() (HostList, error) {
+	defer observe("Hosts", time.Now())
+	return s.DataStore.Hosts()
+}
+
+func (s *InstrumentedStore) FindHosts(ns *nodeset.NodeSet) (HostList, error) {
+	defer observe("FindHosts", time.Now())
+	return s.DataStore.FindHosts(ns)
+}
+
+func (s *InstrumentedStore) FindTags(tags []string) (*nodeset.NodeSet, error) {
+	defer observe("FindTags", time.Now())
+	return s.DataStore.FindTags(tags)
+}
+
+func (s *InstrumentedStore) ProvisionHosts(ns *nodeset.NodeSet, provision bool) error {
+	defer observe("ProvisionHosts", time.Now())
+	return s.DataStore.ProvisionHosts(ns, provision)
+}
+
+func (s *InstrumentedStore) TagHosts(ns *nodeset.NodeSet, tags []string) error {
+	defer observe("TagHosts", time.Now())
+	return s.DataStore.TagHosts(ns, tags)
+}
+
+func (s *InstrumentedStore) UntagHosts(ns *nodeset.NodeSet, tags []string) error {
+	defer observe("UntagHosts", time.Now())
+	return s.DataStore.UntagHosts(ns, tags)
+}
+
+func (s *InstrumentedStore) StoreHost(host *Host) error {
+	defer observe("StoreHost", time.Now())
+	return s.DataStore.StoreHost(host)
+}
+
+func (s *InstrumentedStore) StoreHosts(hosts HostList) error {
+	defer observe("StoreHosts", time.Now())
+	return s.DataStore.StoreHosts(hosts)
+}
+
+func (s *InstrumentedStore) DeleteHosts(ns *nodeset.NodeSet) error {
+	defer observe("DeleteHosts", time.Now())
+	return s.DataStore.DeleteHosts(ns)
+}
+
+func (s *InstrumentedStore) LoadHostFromID(id string) (*Host, error) {
+	defer observe("LoadHostFromID", time.Now())
+	return s.DataStore.LoadHostFromID(id)
+}
+
+func (s *InstrumentedStore) LoadHostFromName(name string) (*Host, error) {
+	defer observe("LoadHostFromName", time.Now())
+	return s.DataStore.LoadHostFromName(name)
+}
+
+func (s *InstrumentedStore) LoadHostFromMAC(mac string) (*Host, error) {
+	defer observe("LoadHostFromMAC", time.Now())
+	return s.DataStore.LoadHostFromMAC(mac)
+}
+
+func (s *InstrumentedStore) LoadHostFromRelay(circuitID, remoteID string) (*Host, error) {
+	defer observe("LoadHostFromRelay", time.Now())
+	return s.DataStore.LoadHostFromRelay(circuitID, remoteID)
+}
+
+func (s *InstrumentedStore) LoadHostFromUUID(uuid string) (*Host, error) {
+	defer observe("LoadHostFromUUID", time.Now())
+	return s.DataStore.LoadHostFromUUID(uuid)
+}
+
+func (s *InstrumentedStore) ResolveIPv4(fqdn string) ([]net.IP, error) {
+	defer observe("ResolveIPv4", time.Now())
+	return s.DataStore.ResolveIPv4(fqdn)
+}
+
+func (s *InstrumentedStore) ResolveIPv6(fqdn string) ([]net.IP, error) {
+	defer observe("ResolveIPv6", time.Now())
+	return s.DataStore.ResolveIPv6(fqdn)
+}
+
+func (s *InstrumentedStore) ResolveAlias(name string) (string, error) {
+	defer observe("ResolveAlias", time.Now())
+	return s.DataStore.ResolveAlias(name)
+}
+
+func (s *InstrumentedStore) ReverseResolve(ip string) ([]string, error) {
+	defer observe("ReverseResolve", time.Now())
+	return s.DataStore.ReverseResolve(ip)
+}
+
+func (s *InstrumentedStore) StoreAuditEntry(entry *AuditEntry) error {
+	defer observe("StoreAuditEntry", time.Now())
+	return s.DataStore.StoreAuditEntry(entry)
+}
+
+func (s *InstrumentedStore) AuditEntries(filter *AuditFilter) (AuditEntryList, error) {
+	defer observe("AuditEntries", time.Now())
+	return s.DataStore.AuditEntries(filter)
+}
+
+func (s *InstrumentedStore) LoadAuditEntry(id int64) (*AuditEntry, error) {
+	defer observe("LoadAuditEntry", time.Now())
+	return s.DataStore.LoadAuditEntry(id)
+}
+
+func (s *InstrumentedStore) PruneAuditEntries(maxAge time.Duration, maxEntries int) (int, error) {
+	defer observe("PruneAuditEntries", time.Now())
+	return s.DataStore.PruneAuditEntries(maxAge, maxEntries)
+}
+
+func (s *InstrumentedStore) StoreProvisionRecord(record *ProvisionRecord) error {
+	defer observe("StoreProvisionRecord", time.Now())
+	return s.DataStore.StoreProvisionRecord(record)
+}
+
+func (s *InstrumentedStore) LoadProvisionRecord(name string) (*ProvisionRecord, error) {
+	defer observe("LoadProvisionRecord", time.Now())
+	return s.DataStore.LoadProvisionRecord(name)
+}
+
+func (s *InstrumentedStore) ProvisionRecords(ns *nodeset.NodeSet) (ProvisionRecordList, error) {
+	defer observe("ProvisionRecords", time.Now())
+	return s.DataStore.ProvisionRecords(ns)
+}
+
+func (s *InstrumentedStore) StoreLease(lease *Lease) error {
+	defer observe("StoreLease", time.Now())
+	return s.DataStore.StoreLease(lease)
+}
+
+func (s *InstrumentedStore) LoadLease(mac string) (*Lease, error) {
+	defer observe("LoadLease", time.Now())
+	return s.DataStore.LoadLease(mac)
+}
+
+func (s *InstrumentedStore) DeleteLease(mac string) error {
+	defer observe("DeleteLease", time.Now())
+	return s.DataStore.DeleteLease(mac)
+}
+
+func (s *InstrumentedStore) Leases() (LeaseList, error) {
+	defer observe("Leases", time.Now())
+	return s.DataStore.Leases()
+}
+
+func (s *InstrumentedStore) StoreUnregistered(host *UnregisteredHost) error {
+	defer observe("StoreUnregistered", time.Now())
+	return s.DataStore.StoreUnregistered(host)
+}
+
+func (s *InstrumentedStore) LoadUnregistered(mac string) (*UnregisteredHost, error) {
+	defer observe("LoadUnregistered", time.Now())
+	return s.DataStore.LoadUnregistered(mac)
+}
+
+func (s *InstrumentedStore) DeleteUnregistered(mac string) error {
+	defer observe("DeleteUnregistered", time.Now())
+	return s.DataStore.DeleteUnregistered(mac)
+}
+
+func (s *InstrumentedStore) UnregisteredHosts() (UnregisteredHostList, error) {
+	defer observe("UnregisteredHosts", time.Now())
+	return s.DataStore.UnregisteredHosts()
+}
@@ -0,0 +1,106 @@
+// Copyright 2019 Grendel Authors. All rights reserved.
+//
+// This file is part of Grendel.
+//
+// Grendel is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Grendel is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with Grendel. If not, see <https://www.gnu.org/licenses/>.
+
+package model
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/netip"
+	"sort"
+	"time"
+)
+
+type LeaseList []*Lease
+
+// Lease is an address from a dynamic DHCP pool assigned to a client which is
+// not in the data store. A client holds at most one lease and an address is
+// leased to at most one client. Expired leases are kept so returning clients
+// get the same address while it's free. A lease without a MAC holds an
+// address a client declined because it's in use by another machine.
+type Lease struct {
+	MAC      net.HardwareAddr `json:"mac"`
+	IP       netip.Addr       `json:"ip"`
+	Hostname string           `json:"hostname,omitempty"`
+	Started  time.Time        `json:"started"`
+	Expires  time.Time        `json:"expires"`
+}
+
+// Expired returns true if the lease is no longer valid at the given time
+func (l *Lease) Expired(now time.Time) bool {
+	return !now.Before(l.Expires)
+}
+
+// Declined returns true if the lease holds an address declined by a client
+func (l *Lease) Declined() bool {
+	return len(l.MAC) == 0
+}
+
+// key returns the key the lease is stored under, the MAC of the client or the
+// address for declined leases
+func (l *Lease) key() string {
+	if l.Declined() {
+		return "declined-" + l.IP.Unmap().String()
+	}
+
+	return l.MAC.String()
+}
+
+func (l *Lease) MarshalJSON() ([]byte, error) {
+	type Alias Lease
+	return json.Marshal(&struct {
+		MAC string `json:"mac"`
+		*Alias
+	}{
+		MAC:   l.MAC.String(),
+		Alias: (*Alias)(l),
+	})
+}
+
+func (l *Lease) UnmarshalJSON(data []byte) error {
+	type Alias Lease
+	aux := &struct {
+		MAC string `json:"mac"`
+		*Alias
+	}{
+		Alias: (*Alias)(l),
+	}
+	if err := json.Unmarshal(data, &aux); err != nil {
+		return err
+	}
+
+	if aux.MAC == "" {
+		l.MAC = nil
+		return nil
+	}
+
+	mac, err := net.ParseMAC(aux.MAC)
+	if err != nil {
+		return fmt.Errorf("Invalid MAC address %s: %w", aux.MAC, err)
+	}
+	l.MAC = mac
+
+	return nil
+}
+
+// sortLeases sorts the leases by address
+func sortLeases(leases LeaseList) {
+	sort.Slice(leases, func(i, j int) bool {
+		return leases[i].IP.Less(leases[j].IP)
+	})
+}
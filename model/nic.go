@@ -22,6 +22,7 @@ import (
 	"math/rand"
 	"net"
 	"net/netip"
+	"strings"
 	"time"
 
 	"github.com/spf13/viper"
@@ -33,16 +34,45 @@ type Subnet struct {
 	DNS          []net.IP
 	DomainSearch []string
 	MTU          uint16
+
+	// Pool is the dynamic range of addresses leased to clients which are not
+	// in the data store. The zero value disables dynamic leases.
+	Pool netipx.IPRange
 }
 
 type NetInterface struct {
-	MAC  net.HardwareAddr `json:"mac" validate:"required"`
+	MAC  net.HardwareAddr `json:"mac" validate:"required_without=CircuitID"`
 	Name string           `json:"ifname"`
 	IP   netip.Prefix     `json:"ip"`
 	FQDN string           `json:"fqdn"`
 	BMC  bool             `json:"bmc"`
 	VLAN string           `json:"vlan"`
 	MTU  uint16           `json:"mtu,omitempty"`
+
+	// Addrs are additional addresses of the interface, for example the IPv6
+	// address of a dual stack interface
+	Addrs []netip.Prefix `json:"addrs,omitempty"`
+
+	// Aliases are DNS names served as CNAME records pointing at the FQDN
+	Aliases []string `json:"aliases,omitempty"`
+
+	// CircuitID and RemoteID identify the interface by the switch port it is
+	// cabled to, as reported by a DHCP relay agent in option 82. When set the
+	// DHCP server matches requests relayed from that port regardless of the
+	// client MAC and records the MAC it learns on the interface.
+	CircuitID string `json:"circuit_id,omitempty"`
+	RemoteID  string `json:"remote_id,omitempty"`
+}
+
+// MatchRelay reports whether the interface is identified by the given relay
+// agent circuit id and remote id. The remote id is only compared if it is set
+// on the interface.
+func (n *NetInterface) MatchRelay(circuitID, remoteID string) bool {
+	if n.CircuitID == "" || n.CircuitID != circuitID {
+		return false
+	}
+
+	return n.RemoteID == "" || n.RemoteID == remoteID
 }
 
 func (n *NetInterface) MarshalJSON() ([]byte, error) {
@@ -61,8 +91,9 @@ func (n *NetInterface) MarshalJSON() ([]byte, error) {
 func (n *NetInterface) UnmarshalJSON(data []byte) error {
 	type Alias NetInterface
 	aux := &struct {
-		MAC string `json:"mac"`
-		IP  string `json:"ip"`
+		MAC   string   `json:"mac"`
+		IP    string   `json:"ip"`
+		Addrs []string `json:"addrs"`
 		*Alias
 	}{
 		Alias: (*Alias)(n),
@@ -80,16 +111,59 @@ func (n *NetInterface) UnmarshalJSON(data []byte) error {
 	}
 
 	if aux.IP != "" {
-		ip, err := netip.ParsePrefix(aux.IP)
+		ip, err := ParseIPPrefix(aux.IP)
 		if err != nil {
 			return fmt.Errorf("Invalid IPv4 address %s: %s", aux.IP, err)
 		}
 
 		n.IP = ip
 	}
+
+	n.Addrs = nil
+	for _, a := range aux.Addrs {
+		ip, err := ParseIPPrefix(a)
+		if err != nil {
+			return fmt.Errorf("Invalid IP address %s: %s", a, err)
+		}
+
+		n.Addrs = append(n.Addrs, ip)
+	}
+
 	return nil
 }
 
+// ParseIPPrefix parses an IP address with a network prefix. Bare IP addresses
+// stored by releases prior to 0.0.8 are upgraded using the prefix of the
+// matching subnet in dhcp.subnets, the dhcp.netmask setting or
+// DefaultPrefixBits.
+func ParseIPPrefix(s string) (netip.Prefix, error) {
+	if strings.Contains(s, "/") {
+		return netip.ParsePrefix(s)
+	}
+
+	addr, err := netip.ParseAddr(s)
+	if err != nil {
+		return netip.Prefix{}, err
+	}
+
+	for _, subnet := range Subnets {
+		if subnet.Gateway.Contains(addr) {
+			return netip.PrefixFrom(addr, subnet.Gateway.Bits()), nil
+		}
+	}
+
+	if !addr.Unmap().Is4() {
+		return netip.PrefixFrom(addr, DefaultPrefixBits6), nil
+	}
+
+	bits := DefaultPrefixBits
+	if viper.GetInt("dhcp.netmask") > 0 {
+		bits = viper.GetInt("dhcp.netmask")
+	}
+
+	return netip.PrefixFrom(addr, bits), nil
+}
+
 func (n *NetInterface) CIDR() string {
 	if !n.IP.IsValid() {
 		return ""
@@ -128,12 +202,67 @@ func (n *NetInterface) ToStdAddr() net.IP {
 	return net.IP(addr.AsSlice())
 }
 
+// Prefixes returns the primary IP address of the interface followed by any
+// additional addresses
+func (n *NetInterface) Prefixes() []netip.Prefix {
+	prefixes := make([]netip.Prefix, 0, len(n.Addrs)+1)
+	if n.IP.IsValid() {
+		prefixes = append(prefixes, n.IP)
+	}
+
+	for _, p := range n.Addrs {
+		if p.IsValid() {
+			prefixes = append(prefixes, p)
+		}
+	}
+
+	return prefixes
+}
+
+// HasAddr returns true if addr is one of the addresses of the interface
+func (n *NetInterface) HasAddr(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	for _, p := range n.Prefixes() {
+		if p.Addr().Unmap() == addr {
+			return true
+		}
+	}
+
+	return false
+}
+
+// IPv4 returns the first IPv4 address of the interface
+func (n *NetInterface) IPv4() netip.Prefix {
+	for _, p := range n.Prefixes() {
+		if p.Addr().Is4() {
+			return p
+		}
+
+		if p.Addr().Is4In6() {
+			return netip.PrefixFrom(p.Addr().Unmap(), p.Bits()-96)
+		}
+	}
+
+	return netip.Prefix{}
+}
+
+// IPv6 returns the first IPv6 address of the interface
+func (n *NetInterface) IPv6() netip.Prefix {
+	for _, p := range n.Prefixes() {
+		if p.Addr().Is6() && !p.Addr().Is4In6() {
+			return p
+		}
+	}
+
+	return netip.Prefix{}
+}
+
 func (n *NetInterface) Netmask() net.IPMask {
-	return net.CIDRMask(n.IP.Bits(), 32)
+	return net.CIDRMask(n.IP.Bits(), n.IP.Addr().BitLen())
 }
 
 func (n *NetInterface) NetmaskString() string {
-	return net.IP(net.CIDRMask(n.IP.Bits(), 32)).String()
+	return net.IP(n.Netmask()).String()
 }
 
 func (n *NetInterface) InterfaceMTU() uint16 {
@@ -0,0 +1,122 @@
+// Copyright 2019 Grendel Authors. All rights reserved.
+//
+// This file is part of Grendel.
+//
+// Grendel is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Grendel is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with Grendel. If not, see <https://www.gnu.org/licenses/>.
+
+package model
+
+import (
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+// ProvisionState is the step of the provisioning process a host last reached
+type ProvisionState string
+
+const (
+	ProvisionStatePending    ProvisionState = "pending"
+	ProvisionStateDHCP       ProvisionState = "dhcp"
+	ProvisionStateFirmware   ProvisionState = "firmware"
+	ProvisionStateIpxe       ProvisionState = "ipxe"
+	ProvisionStateKernel     ProvisionState = "kernel"
+	ProvisionStateInstalling ProvisionState = "installing"
+	ProvisionStateComplete   ProvisionState = "complete"
+	ProvisionStateFailed     ProvisionState = "failed"
+
+	// maxProvisionHistory is the number of transitions kept on a record
+	maxProvisionHistory = 32
+)
+
+func init() {
+	viper.SetDefault("provision.stuck_timeout", "30m")
+}
+
+type ProvisionRecordList []*ProvisionRecord
+
+// ProvisionRecord tracks the progress of a host through the provisioning
+// process. Started is the time the host was set to provision and Updated is
+// the last time the host was served by any subsystem. Stuck is set once the
+// host has been in the same state for longer than provision.stuck_timeout.
+type ProvisionRecord struct {
+	Name    string                 `json:"name"`
+	State   ProvisionState         `json:"state"`
+	Started time.Time              `json:"started"`
+	Updated time.Time              `json:"updated"`
+	LastIP  string                 `json:"last_ip,omitempty"`
+	Message string                 `json:"message,omitempty"`
+	Stuck   bool                   `json:"stuck"`
+	History []*ProvisionTransition `json:"history"`
+}
+
+// ProvisionTransition records a host entering a provisioning state
+type ProvisionTransition struct {
+	State   ProvisionState `json:"state"`
+	Time    time.Time      `json:"time"`
+	IP      string         `json:"ip,omitempty"`
+	Message string         `json:"message,omitempty"`
+}
+
+// NewProvisionRecord returns a record for a host that was just set to
+// provision
+func NewProvisionRecord(name string, t time.Time) *ProvisionRecord {
+	r := &ProvisionRecord{Name: name}
+	r.Transition(ProvisionStatePending, "", "", t)
+	return r
+}
+
+// Done returns true if provisioning completed or failed
+func (r *ProvisionRecord) Done() bool {
+	return r.State == ProvisionStateComplete || r.State == ProvisionStateFailed
+}
+
+// Transition moves the record to state. Moving to pending starts a new
+// provisioning run and clears the history. Repeated requests in the same
+// state only update the timestamp and client IP.
+func (r *ProvisionRecord) Transition(state ProvisionState, ip, msg string, t time.Time) {
+	if state == ProvisionStatePending {
+		r.Started = t
+		r.History = nil
+		r.LastIP = ""
+	}
+
+	r.Updated = t
+	r.Message = msg
+	r.Stuck = false
+	if ip != "" {
+		r.LastIP = ip
+	}
+
+	if state == r.State && len(r.History) > 0 {
+		return
+	}
+
+	r.State = state
+	r.History = append(r.History, &ProvisionTransition{State: state, Time: t, IP: ip, Message: msg})
+	if len(r.History) > maxProvisionHistory {
+		r.History = r.History[len(r.History)-maxProvisionHistory:]
+	}
+}
+
+// IsStuck returns true if the host has not completed provisioning and has
+// not been served by any subsystem for longer than timeout. A zero timeout
+// disables stuck detection.
+func (r *ProvisionRecord) IsStuck(timeout time.Duration, now time.Time) bool {
+	if timeout <= 0 || r.Done() {
+		return false
+	}
+
+	return now.Sub(r.Updated) > timeout
+}
@@ -0,0 +1,1445 @@
+// Copyright 2019 Grendel Authors. All rights reserved.
+//
+// This file is part of Grendel.
+//
+// Grendel is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Grendel is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with Grendel. If not, see <https://www.gnu.org/licenses/>.
+
+package model
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/netip"
+	"strings"
+	"time"
+
+	"github.com/segmentio/ksuid"
+	"github.com/sirupsen/logrus"
+	"github.com/ubccr/grendel/firmware"
+	"github.com/ubccr/grendel/nodeset"
+	"github.com/ubccr/grendel/util"
+	_ "modernc.org/sqlite"
+)
+
+// sqlMigrations are applied in order to bring the database schema up to date.
+// Never edit an existing migration, only append new ones.
+var sqlMigrations = []string{
+	`CREATE TABLE host (
+		id         TEXT PRIMARY KEY,
+		name       TEXT NOT NULL UNIQUE,
+		provision  INTEGER NOT NULL DEFAULT 0,
+		firmware   TEXT NOT NULL DEFAULT '',
+		boot_image TEXT NOT NULL DEFAULT ''
+	);
+
+	CREATE TABLE net_interface (
+		id        INTEGER PRIMARY KEY AUTOINCREMENT,
+		host_id   TEXT NOT NULL REFERENCES host(id) ON DELETE CASCADE,
+		position  INTEGER NOT NULL,
+		ifname    TEXT NOT NULL DEFAULT '',
+		mac       TEXT NOT NULL DEFAULT '',
+		ip        TEXT NOT NULL DEFAULT '',
+		addr      TEXT NOT NULL DEFAULT '',
+		fqdn      TEXT NOT NULL DEFAULT '',
+		fqdn_key  TEXT NOT NULL DEFAULT '',
+		bmc       INTEGER NOT NULL DEFAULT 0,
+		vlan      TEXT NOT NULL DEFAULT '',
+		mtu       INTEGER NOT NULL DEFAULT 0,
+		UNIQUE (host_id, position)
+	);
+
+	CREATE INDEX net_interface_mac ON net_interface(mac);
+	CREATE INDEX net_interface_addr ON net_interface(addr);
+	CREATE INDEX net_interface_fqdn_key ON net_interface(fqdn_key);
+
+	CREATE TABLE host_tag (
+		host_id TEXT NOT NULL REFERENCES host(id) ON DELETE CASCADE,
+		tag     TEXT NOT NULL,
+		PRIMARY KEY (host_id, tag)
+	);
+
+	CREATE INDEX host_tag_tag ON host_tag(tag);
+
+	CREATE TABLE boot_image (
+		id   TEXT NOT NULL,
+		name TEXT PRIMARY KEY,
+		data TEXT NOT NULL
+	);`,
+	`CREATE TABLE audit_entry (
+		id        INTEGER PRIMARY KEY AUTOINCREMENT,
+		timestamp INTEGER NOT NULL,
+		caller    TEXT NOT NULL,
+		operation TEXT NOT NULL,
+		data      TEXT NOT NULL
+	);
+
+	CREATE INDEX audit_entry_timestamp ON audit_entry(timestamp);
+
+	CREATE TABLE audit_change (
+		audit_id INTEGER NOT NULL REFERENCES audit_entry(id) ON DELETE CASCADE,
+		kind     TEXT NOT NULL,
+		name     TEXT NOT NULL,
+		PRIMARY KEY (audit_id, kind, name)
+	);
+
+	CREATE INDEX audit_change_name ON audit_change(name);`,
+	`CREATE TABLE provision_record (
+		host_id TEXT PRIMARY KEY REFERENCES host(id) ON DELETE CASCADE,
+		data    TEXT NOT NULL
+	);`,
+	`CREATE TABLE net_address (
+		interface_id INTEGER NOT NULL REFERENCES net_interface(id) ON DELETE CASCADE,
+		position     INTEGER NOT NULL,
+		ip           TEXT NOT NULL,
+		addr         TEXT NOT NULL,
+		PRIMARY KEY (interface_id, position)
+	);
+
+	CREATE INDEX net_address_addr ON net_address(addr);`,
+	`CREATE TABLE dns_alias (
+		host_id      TEXT NOT NULL REFERENCES host(id) ON DELETE CASCADE,
+		interface_id INTEGER REFERENCES net_interface(id) ON DELETE CASCADE,
+		position     INTEGER NOT NULL,
+		name         TEXT NOT NULL,
+		name_key     TEXT NOT NULL
+	);
+
+	CREATE INDEX dns_alias_name_key ON dns_alias(name_key);`,
+	`CREATE TABLE dhcp_lease (
+		mac     TEXT PRIMARY KEY,
+		addr    TEXT NOT NULL UNIQUE,
+		expires INTEGER NOT NULL,
+		data    TEXT NOT NULL
+	);`,
+	`CREATE TABLE unregistered_host (
+		mac  TEXT PRIMARY KEY,
+		data TEXT NOT NULL
+	);`,
+	`ALTER TABLE net_interface ADD COLUMN circuit_id TEXT NOT NULL DEFAULT '';
+	ALTER TABLE net_interface ADD COLUMN remote_id TEXT NOT NULL DEFAULT '';
+	CREATE INDEX net_interface_circuit_id ON net_interface(circuit_id);`,
+	`ALTER TABLE host ADD COLUMN uuid TEXT NOT NULL DEFAULT '';
+	ALTER TABLE host ADD COLUMN uuid_key TEXT NOT NULL DEFAULT '';
+	ALTER TABLE host ADD COLUMN serial TEXT NOT NULL DEFAULT '';
+	CREATE INDEX host_uuid_key ON host(uuid_key);`,
+}
+
+// querier is implemented by both *sql.DB and *sql.Tx
+type querier interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+// SQLStore implements a Grendel Datastore using SQLite
+type SQLStore struct {
+	db *sql.DB
+}
+
+// NewSQLStore returns a new SQLStore using the given database filename. For
+// memory only you can provide `:memory:`
+func NewSQLStore(filename string) (*SQLStore, error) {
+	dsn := "file:" + filename + "?_pragma=foreign_keys(1)&_pragma=busy_timeout(5000)"
+	if filename != ":memory:" {
+		dsn += "&_pragma=journal_mode(WAL)"
+	}
+
+	db, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		return nil, err
+	}
+
+	if filename == ":memory:" {
+		// Each connection to an in-memory database gets a new empty database
+		db.SetMaxOpenConns(1)
+	}
+
+	s := &SQLStore{db: db}
+
+	err = s.migrate()
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// migrate applies any outstanding schema migrations
+func (s *SQLStore) migrate() error {
+	_, err := s.db.Exec(`CREATE TABLE IF NOT EXISTS schema_migrations (version INTEGER PRIMARY KEY)`)
+	if err != nil {
+		return err
+	}
+
+	var version int
+	err = s.db.QueryRow(`SELECT COALESCE(MAX(version), 0) FROM schema_migrations`).Scan(&version)
+	if err != nil {
+		return err
+	}
+
+	for i := version; i < len(sqlMigrations); i++ {
+		err := s.update(func(tx *sql.Tx) error {
+			if _, err := tx.Exec(sqlMigrations[i]); err != nil {
+				return err
+			}
+
+			_, err := tx.Exec(`INSERT INTO schema_migrations (version) VALUES (?)`, i+1)
+			return err
+		})
+		if err != nil {
+			return fmt.Errorf("failed to apply schema migration %d: %w", i+1, err)
+		}
+
+		log.Infof("Applied database schema migration %d", i+1)
+	}
+
+	return nil
+}
+
+// update runs fn inside a transaction which is committed if fn returns nil
+func (s *SQLStore) update(fn func(tx *sql.Tx) error) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+
+	err = fn(tx)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// SchemaVersion returns the schema version of the records in the data store.
+// Records are stored in typed columns which are upgraded by the SQL schema
+// migrations when the database is opened so they are always current.
+func (s *SQLStore) SchemaVersion() (int, error) {
+	return SchemaVersion, nil
+}
+
+// Migrate is a no-op as SQL schema migrations are applied when the database
+// is opened
+func (s *SQLStore) Migrate() error {
+	return nil
+}
+
+// Close closes the SQLStore database
+func (s *SQLStore) Close() error {
+	return s.db.Close()
+}
+
+// nodeNames returns the node names in the given nodeset.NodeSet as a JSON
+// array suitable for use with json_each
+func nodeNames(ns *nodeset.NodeSet) string {
+	names := make([]string, 0, ns.Len())
+	it := ns.Iterator()
+	for it.Next() {
+		names = append(names, it.Value())
+	}
+
+	return jsonArray(names)
+}
+
+func jsonArray(values []string) string {
+	if values == nil {
+		values = []string{}
+	}
+
+	data, _ := json.Marshal(values)
+	return string(data)
+}
+
+// selectHosts returns the hosts whose id is returned by the filter query
+func selectHosts(q querier, filter string, args ...interface{}) (HostList, error) {
+	hosts := make(HostList, 0)
+	hostMap := make(map[string]*Host)
+
+	rows, err := q.Query(`SELECT id, name, provision, firmware, boot_image, uuid, serial FROM host WHERE id IN (`+filter+`) ORDER BY name`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id, fw string
+		h := &Host{Interfaces: make([]*NetInterface, 0)}
+		err := rows.Scan(&id, &h.Name, &h.Provision, &fw, &h.BootImage, &h.UUID, &h.Serial)
+		if err != nil {
+			return nil, err
+		}
+
+		h.ID, _ = ksuid.Parse(id)
+		h.Firmware, _ = firmware.Parse(fw)
+		hosts = append(hosts, h)
+		hostMap[id] = h
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(hosts) == 0 {
+		return hosts, nil
+	}
+
+	nicMap := make(map[int64]*NetInterface)
+	nicRows, err := q.Query(`SELECT id, host_id, ifname, mac, ip, fqdn, bmc, vlan, mtu, circuit_id, remote_id FROM net_interface WHERE host_id IN (`+filter+`) ORDER BY host_id, position`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer nicRows.Close()
+
+	for nicRows.Next() {
+		var nicID int64
+		var hostID, mac, ip string
+		nic := &NetInterface{}
+		err := nicRows.Scan(&nicID, &hostID, &nic.Name, &mac, &ip, &nic.FQDN, &nic.BMC, &nic.VLAN, &nic.MTU, &nic.CircuitID, &nic.RemoteID)
+		if err != nil {
+			return nil, err
+		}
+
+		nic.MAC, _ = net.ParseMAC(mac)
+		nic.IP, _ = netip.ParsePrefix(ip)
+		nicMap[nicID] = nic
+
+		if h, ok := hostMap[hostID]; ok {
+			h.Interfaces = append(h.Interfaces, nic)
+		}
+	}
+	if err := nicRows.Err(); err != nil {
+		return nil, err
+	}
+
+	addrRows, err := q.Query(`SELECT a.interface_id, a.ip FROM net_address a JOIN net_interface n ON n.id = a.interface_id WHERE n.host_id IN (`+filter+`) ORDER BY a.interface_id, a.position`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer addrRows.Close()
+
+	for addrRows.Next() {
+		var nicID int64
+		var ip string
+		if err := addrRows.Scan(&nicID, &ip); err != nil {
+			return nil, err
+		}
+
+		prefix, err := netip.ParsePrefix(ip)
+		if err != nil {
+			continue
+		}
+
+		if nic, ok := nicMap[nicID]; ok {
+			nic.Addrs = append(nic.Addrs, prefix)
+		}
+	}
+	if err := addrRows.Err(); err != nil {
+		return nil, err
+	}
+
+	aliasRows, err := q.Query(`SELECT host_id, interface_id, name FROM dns_alias WHERE host_id IN (`+filter+`) ORDER BY host_id, interface_id, position`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer aliasRows.Close()
+
+	for aliasRows.Next() {
+		var hostID, name string
+		var nicID sql.NullInt64
+		if err := aliasRows.Scan(&hostID, &nicID, &name); err != nil {
+			return nil, err
+		}
+
+		if !nicID.Valid {
+			if h, ok := hostMap[hostID]; ok {
+				h.Aliases = append(h.Aliases, name)
+			}
+			continue
+		}
+
+		if nic, ok := nicMap[nicID.Int64]; ok {
+			nic.Aliases = append(nic.Aliases, name)
+		}
+	}
+	if err := aliasRows.Err(); err != nil {
+		return nil, err
+	}
+
+	tagRows, err := q.Query(`SELECT host_id, tag FROM host_tag WHERE host_id IN (`+filter+`) ORDER BY host_id, tag`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer tagRows.Close()
+
+	for tagRows.Next() {
+		var hostID, tag string
+		err := tagRows.Scan(&hostID, &tag)
+		if err != nil {
+			return nil, err
+		}
+
+		if h, ok := hostMap[hostID]; ok {
+			h.Tags = append(h.Tags, tag)
+		}
+	}
+
+	return hosts, tagRows.Err()
+}
+
+// StoreHost stores a host in the data store. If the host exists it is overwritten
+func (s *SQLStore) StoreHost(host *Host) error {
+	hostList := HostList{host}
+	return s.StoreHosts(hostList)
+}
+
+// StoreHosts stores a list of host in the data store. If the host exists it is overwritten
+func (s *SQLStore) StoreHosts(hosts HostList) error {
+	for idx, host := range hosts {
+		if host.Name == "" {
+			return fmt.Errorf("host name required for host %d: %w", idx, ErrInvalidData)
+		}
+
+		// Keys are case-insensitive
+		host.Name = strings.ToLower(host.Name)
+	}
+
+	if err := checkUUIDs(hosts); err != nil {
+		return err
+	}
+
+	names := make(map[string]bool, len(hosts))
+	for _, host := range hosts {
+		names[host.Name] = true
+	}
+
+	return s.update(func(tx *sql.Tx) error {
+		for _, host := range hosts {
+			if host.UUID == "" {
+				continue
+			}
+
+			rows, err := tx.Query(`SELECT name FROM host WHERE uuid_key = ?`, strings.ToLower(host.UUID))
+			if err != nil {
+				return err
+			}
+
+			owner := ""
+			for rows.Next() {
+				var name string
+				if err := rows.Scan(&name); err != nil {
+					rows.Close()
+					return err
+				}
+				if !names[name] {
+					owner = name
+				}
+			}
+			rows.Close()
+			if err := rows.Err(); err != nil {
+				return err
+			}
+
+			if owner != "" {
+				return fmt.Errorf("uuid %s is already set on host %s:  %w", host.UUID, owner, ErrDuplicateEntry)
+			}
+		}
+
+		for _, host := range hosts {
+			var id string
+			err := tx.QueryRow(`SELECT id FROM host WHERE name = ?`, host.Name).Scan(&id)
+			switch {
+			case errors.Is(err, sql.ErrNoRows):
+				uuid, err := ksuid.NewRandom()
+				if err != nil {
+					return err
+				}
+
+				host.ID = uuid
+				_, err = tx.Exec(`INSERT INTO host (id, name, provision, firmware, boot_image, uuid, uuid_key, serial) VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+					host.ID.String(), host.Name, host.Provision, host.Firmware.String(), host.BootImage, host.UUID, strings.ToLower(host.UUID), host.Serial)
+				if err != nil {
+					return err
+				}
+			case err != nil:
+				return fmt.Errorf("Failed to check host with name %s for duplicates:  %w", host.Name, err)
+			default:
+				host.ID, err = ksuid.Parse(id)
+				if err != nil {
+					return err
+				}
+
+				_, err = tx.Exec(`UPDATE host SET provision = ?, firmware = ?, boot_image = ?, uuid = ?, uuid_key = ?, serial = ? WHERE id = ?`,
+					host.Provision, host.Firmware.String(), host.BootImage, host.UUID, strings.ToLower(host.UUID), host.Serial, id)
+				if err != nil {
+					return err
+				}
+
+				if _, err := tx.Exec(`DELETE FROM net_interface WHERE host_id = ?`, id); err != nil {
+					return err
+				}
+
+				if _, err := tx.Exec(`DELETE FROM host_tag WHERE host_id = ?`, id); err != nil {
+					return err
+				}
+
+				if _, err := tx.Exec(`DELETE FROM dns_alias WHERE host_id = ?`, id); err != nil {
+					return err
+				}
+			}
+
+			for pos, nic := range host.Interfaces {
+				mac := ""
+				if len(nic.MAC) > 0 {
+					mac = nic.MAC.String()
+				}
+
+				addr := ""
+				if nic.IP.IsValid() {
+					addr = nic.Addr().Unmap().String()
+				}
+
+				fqdnKey := ""
+				if nic.FQDN != "" {
+					fqdnKey = util.Normalize(nic.FQDN)
+				}
+
+				res, err := tx.Exec(`INSERT INTO net_interface (host_id, position, ifname, mac, ip, addr, fqdn, fqdn_key, bmc, vlan, mtu, circuit_id, remote_id) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+					host.ID.String(), pos, nic.Name, mac, nic.CIDR(), addr, nic.FQDN, fqdnKey, nic.BMC, nic.VLAN, nic.MTU, nic.CircuitID, nic.RemoteID)
+				if err != nil {
+					return err
+				}
+
+				if len(nic.Addrs) == 0 && len(nic.Aliases) == 0 {
+					continue
+				}
+
+				nicID, err := res.LastInsertId()
+				if err != nil {
+					return err
+				}
+
+				for apos, a := range nic.Aliases {
+					_, err := tx.Exec(`INSERT INTO dns_alias (host_id, interface_id, position, name, name_key) VALUES (?, ?, ?, ?, ?)`,
+						host.ID.String(), nicID, apos, a, util.Normalize(a))
+					if err != nil {
+						return err
+					}
+				}
+
+				for apos, p := range nic.Addrs {
+					if !p.IsValid() {
+						continue
+					}
+
+					_, err := tx.Exec(`INSERT INTO net_address (interface_id, position, ip, addr) VALUES (?, ?, ?, ?)`,
+						nicID, apos, p.String(), p.Addr().Unmap().String())
+					if err != nil {
+						return err
+					}
+				}
+			}
+
+			for apos, a := range host.Aliases {
+				_, err := tx.Exec(`INSERT INTO dns_alias (host_id, position, name, name_key) VALUES (?, ?, ?, ?)`,
+					host.ID.String(), apos, a, util.Normalize(a))
+				if err != nil {
+					return err
+				}
+			}
+
+			for _, tag := range host.Tags {
+				_, err := tx.Exec(`INSERT OR IGNORE INTO host_tag (host_id, tag) VALUES (?, ?)`, host.ID.String(), tag)
+				if err != nil {
+					return err
+				}
+			}
+		}
+
+		return nil
+	})
+}
+
+// DeleteHosts deletes all hosts in the given nodeset.NodeSet from the data store.
+func (s *SQLStore) DeleteHosts(ns *nodeset.NodeSet) error {
+	it := ns.Iterator()
+
+	return s.update(func(tx *sql.Tx) error {
+		for it.Next() {
+			res, err := tx.Exec(`DELETE FROM host WHERE name = ?`, it.Value())
+			if err != nil {
+				return err
+			}
+
+			n, err := res.RowsAffected()
+			if err != nil {
+				return err
+			}
+
+			if n == 0 {
+				return fmt.Errorf("host with name %s:  %w", it.Value(), ErrNotFound)
+			}
+		}
+
+		return nil
+	})
+}
+
+// LoadHostFromName returns the Host with the given name
+func (s *SQLStore) LoadHostFromName(name string) (*Host, error) {
+	hosts, err := selectHosts(s.db, `SELECT id FROM host WHERE name = ?`, name)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(hosts) == 0 {
+		return nil, fmt.Errorf("host with name %s:  %w", name, ErrNotFound)
+	}
+
+	return hosts[0], nil
+}
+
+// LoadHostFromID returns the Host with the given ID
+func (s *SQLStore) LoadHostFromID(id string) (*Host, error) {
+	hosts, err := selectHosts(s.db, `SELECT id FROM host WHERE id = ?`, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(hosts) == 0 {
+		return nil, fmt.Errorf("host with id %s:  %w", id, ErrNotFound)
+	}
+
+	return hosts[0], nil
+}
+
+// LoadHostFromMAC returns the Host that has a network interface with the give MAC address
+func (s *SQLStore) LoadHostFromMAC(mac string) (*Host, error) {
+	hwaddr, err := net.ParseMAC(mac)
+	if err != nil {
+		return nil, fmt.Errorf("no host found with mac address %s:  %w", mac, ErrNotFound)
+	}
+
+	hosts, err := selectHosts(s.db, `SELECT host_id FROM net_interface WHERE mac = ?`, hwaddr.String())
+	if err != nil {
+		return nil, err
+	}
+
+	// XXX What to about dups? We only fetch first one.
+	if len(hosts) == 0 {
+		return nil, fmt.Errorf("no host found with mac address %s:  %w", mac, ErrNotFound)
+	}
+
+	return hosts[0], nil
+}
+
+// LoadHostFromRelay returns the Host that has a network interface identified by
+// the given relay agent circuit id and remote id
+func (s *SQLStore) LoadHostFromRelay(circuitID, remoteID string) (*Host, error) {
+	if circuitID == "" {
+		return nil, fmt.Errorf("no host found with circuit id %s:  %w", circuitID, ErrNotFound)
+	}
+
+	hosts, err := selectHosts(s.db, `SELECT host_id FROM net_interface WHERE circuit_id = ? AND (remote_id = '' OR remote_id = ?)`, circuitID, remoteID)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(hosts) == 0 {
+		return nil, fmt.Errorf("no host found with circuit id %s:  %w", circuitID, ErrNotFound)
+	}
+
+	return hosts[0], nil
+}
+
+// LoadHostFromUUID returns the Host with the given system UUID
+func (s *SQLStore) LoadHostFromUUID(uuid string) (*Host, error) {
+	if uuid == "" || PlaceholderUUID(uuid) {
+		return nil, fmt.Errorf("no host found with uuid %s:  %w", uuid, ErrNotFound)
+	}
+
+	hosts, err := selectHosts(s.db, `SELECT id FROM host WHERE uuid_key = ?`, strings.ToLower(uuid))
+	if err != nil {
+		return nil, err
+	}
+
+	if len(hosts) == 0 {
+		return nil, fmt.Errorf("no host found with uuid %s:  %w", uuid, ErrNotFound)
+	}
+
+	if len(hosts) > 1 {
+		return nil, fmt.Errorf("%d hosts found with uuid %s:  %w", len(hosts), uuid, ErrDuplicateEntry)
+	}
+
+	return hosts[0], nil
+}
+
+// ResolveIPv4 returns the list of IPv4 addresses with the given FQDN
+func (s *SQLStore) ResolveIPv4(fqdn string) ([]net.IP, error) {
+	return s.resolve(fqdn, true)
+}
+
+// ResolveIPv6 returns the list of IPv6 addresses with the given FQDN
+func (s *SQLStore) ResolveIPv6(fqdn string) ([]net.IP, error) {
+	return s.resolve(fqdn, false)
+}
+
+// resolve returns the list of IPv4 or IPv6 addresses with the given FQDN
+func (s *SQLStore) resolve(fqdn string, v4 bool) ([]net.IP, error) {
+	fqdn = util.Normalize(fqdn)
+	ips := make([]net.IP, 0)
+
+	rows, err := s.db.Query(`SELECT ip FROM (
+		SELECT host_id, position, -1 AS seq, ip FROM net_interface WHERE fqdn_key = ? AND ip != ''
+		UNION ALL
+		SELECT n.host_id, n.position, a.position, a.ip FROM net_address a JOIN net_interface n ON n.id = a.interface_id WHERE n.fqdn_key = ?
+	) ORDER BY host_id, position, seq`, fqdn, fqdn)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var ip string
+		if err := rows.Scan(&ip); err != nil {
+			return nil, err
+		}
+
+		prefix, err := netip.ParsePrefix(ip)
+		if err == nil && prefix.Addr().Unmap().Is4() == v4 {
+			ips = append(ips, net.IP(prefix.Addr().Unmap().AsSlice()))
+		}
+	}
+
+	return ips, rows.Err()
+}
+
+// ResolveAlias returns the FQDN the given alias points to or an empty string
+// if there is no such alias
+func (s *SQLStore) ResolveAlias(name string) (string, error) {
+	name = util.Normalize(name)
+
+	hosts, err := selectHosts(s.db, `SELECT host_id FROM dns_alias WHERE name_key = ?`, name)
+	if err != nil {
+		return "", err
+	}
+
+	for _, host := range hosts {
+		if target := host.AliasTarget(name); target != "" {
+			return target, nil
+		}
+	}
+
+	return "", nil
+}
+
+// ReverseResolve returns the list of FQDNs for the given IP. Addresses are
+// compared exactly and the FQDN of every matching interface is returned.
+func (s *SQLStore) ReverseResolve(ip string) ([]string, error) {
+	fqdn := make([]string, 0)
+
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return fqdn, nil
+	}
+
+	key := addr.Unmap().String()
+	rows, err := s.db.Query(`SELECT fqdn FROM net_interface WHERE fqdn != '' AND (addr = ? OR id IN (SELECT interface_id FROM net_address WHERE addr = ?)) ORDER BY host_id, position`, key, key)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		fqdn = append(fqdn, name)
+	}
+
+	return fqdn, rows.Err()
+}
+
+// Hosts returns a list of all the hosts
+func (s *SQLStore) Hosts() (HostList, error) {
+	return selectHosts(s.db, `SELECT id FROM host`)
+}
+
+// FindHosts returns a list of all the hosts in the given NodeSet
+func (s *SQLStore) FindHosts(ns *nodeset.NodeSet) (HostList, error) {
+	return selectHosts(s.db, `SELECT id FROM host WHERE name IN (SELECT value FROM json_each(?))`, nodeNames(ns))
+}
+
+// FindTags returns a nodeset.NodeSet of all the hosts with the given tags
+func (s *SQLStore) FindTags(tags []string) (*nodeset.NodeSet, error) {
+	nodes := []string{}
+
+	rows, err := s.db.Query(`SELECT DISTINCT h.name FROM host h JOIN host_tag t ON t.host_id = h.id WHERE t.tag IN (SELECT value FROM json_each(?))`, jsonArray(tags))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		nodes = append(nodes, name)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(nodes) == 0 {
+		return nil, fmt.Errorf("no hosts found with tags %#v:  %w", tags, ErrNotFound)
+	}
+
+	return nodeset.NewNodeSet(strings.Join(nodes, ","))
+}
+
+// countHosts returns the number of hosts in the given NodeSet
+func countHosts(q querier, names string) (int, error) {
+	var count int
+	err := q.QueryRow(`SELECT COUNT(*) FROM host WHERE name IN (SELECT value FROM json_each(?))`, names).Scan(&count)
+	return count, err
+}
+
+// ProvisionHosts sets all hosts in the given NodeSet to provision (true) or unprovision (false)
+func (s *SQLStore) ProvisionHosts(ns *nodeset.NodeSet, provision bool) error {
+	res, err := s.db.Exec(`UPDATE host SET provision = ? WHERE name IN (SELECT value FROM json_each(?))`, provision, nodeNames(ns))
+	if err != nil {
+		return err
+	}
+
+	count, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if count == 0 {
+		return fmt.Errorf("no hosts found with nodeset %s:  %w", ns.String(), ErrNotFound)
+	}
+
+	return nil
+}
+
+// TagHosts adds tags to all hosts in the given NodeSet
+func (s *SQLStore) TagHosts(ns *nodeset.NodeSet, tags []string) error {
+	names := nodeNames(ns)
+
+	return s.update(func(tx *sql.Tx) error {
+		count, err := countHosts(tx, names)
+		if err != nil {
+			return err
+		}
+
+		if count == 0 {
+			return fmt.Errorf("no hosts found with nodeset %s:  %w", ns.String(), ErrNotFound)
+		}
+
+		_, err = tx.Exec(`INSERT OR IGNORE INTO host_tag (host_id, tag)
+			SELECT h.id, t.value FROM host h, json_each(?) t
+			WHERE h.name IN (SELECT value FROM json_each(?))`, jsonArray(tags), names)
+		return err
+	})
+}
+
+// UntagHosts removes tags from all hosts in the given NodeSet
+func (s *SQLStore) UntagHosts(ns *nodeset.NodeSet, tags []string) error {
+	names := nodeNames(ns)
+
+	return s.update(func(tx *sql.Tx) error {
+		count, err := countHosts(tx, names)
+		if err != nil {
+			return err
+		}
+
+		if count == 0 {
+			return fmt.Errorf("no hosts found with nodeset %s:  %w", ns.String(), ErrNotFound)
+		}
+
+		_, err = tx.Exec(`DELETE FROM host_tag
+			WHERE tag IN (SELECT value FROM json_each(?))
+			AND host_id IN (SELECT id FROM host WHERE name IN (SELECT value FROM json_each(?)))`, jsonArray(tags), names)
+		return err
+	})
+}
+
+// SetBootImage sets all hosts to use the BootImage with the given name
+func (s *SQLStore) SetBootImage(ns *nodeset.NodeSet, name string) error {
+	_, err := s.db.Exec(`UPDATE host SET boot_image = ? WHERE name IN (SELECT value FROM json_each(?))`, name, nodeNames(ns))
+	return err
+}
+
+// StoreBootImage stores a boot image in the data store. If the boot image exists it is overwritten
+func (s *SQLStore) StoreBootImage(image *BootImage) error {
+	imageList := BootImageList{image}
+	return s.StoreBootImages(imageList)
+}
+
+// StoreBootImages stores a list of boot images in the data store. If the boot image exists it is overwritten
+func (s *SQLStore) StoreBootImages(images BootImageList) error {
+	for idx, image := range images {
+		if image.Name == "" {
+			return fmt.Errorf("name required for boot image %d: %w", idx, ErrInvalidData)
+		}
+
+		// Keys are case-insensitive
+		image.Name = strings.ToLower(image.Name)
+
+		if image.ID.IsNil() {
+			uuid, err := ksuid.NewRandom()
+			if err != nil {
+				return err
+			}
+
+			image.ID = uuid
+		}
+	}
+
+	return s.update(func(tx *sql.Tx) error {
+		for _, image := range images {
+			val, err := json.Marshal(image)
+			if err != nil {
+				return err
+			}
+
+			_, err = tx.Exec(`INSERT INTO boot_image (id, name, data) VALUES (?, ?, ?)
+				ON CONFLICT(name) DO UPDATE SET id = excluded.id, data = excluded.data`,
+				image.ID.String(), image.Name, string(val))
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+// DeleteBootImages deletes boot images from the data store.
+func (s *SQLStore) DeleteBootImages(names []string) error {
+	return s.update(func(tx *sql.Tx) error {
+		for _, name := range names {
+			res, err := tx.Exec(`DELETE FROM boot_image WHERE name = ?`, name)
+			if err != nil {
+				return err
+			}
+
+			n, err := res.RowsAffected()
+			if err != nil {
+				return err
+			}
+
+			if n == 0 {
+				return fmt.Errorf("boot image with name %s:  %w", name, ErrNotFound)
+			}
+		}
+
+		return nil
+	})
+}
+
+// LoadBootImage returns a BootImage with the given name
+func (s *SQLStore) LoadBootImage(name string) (*BootImage, error) {
+	var val string
+	err := s.db.QueryRow(`SELECT data FROM boot_image WHERE name = ?`, name).Scan(&val)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("boot image with name %s:  %w", name, ErrNotFound)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var image BootImage
+	err = json.Unmarshal([]byte(val), &image)
+	if err != nil {
+		return nil, err
+	}
+
+	return &image, nil
+}
+
+// BootImages returns a list of all boot images
+func (s *SQLStore) BootImages() (BootImageList, error) {
+	images := make(BootImageList, 0)
+
+	rows, err := s.db.Query(`SELECT data FROM boot_image ORDER BY name`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var val string
+		if err := rows.Scan(&val); err != nil {
+			return nil, err
+		}
+
+		var i BootImage
+		err := json.Unmarshal([]byte(val), &i)
+		if err == nil {
+			images = append(images, &i)
+		} else {
+			log.WithFields(logrus.Fields{
+				"err": err,
+			}).Warn("Invalid boot image json stored in db")
+		}
+	}
+
+	return images, rows.Err()
+}
+
+// StoreAuditEntry stores an entry in the audit log and sets its ID
+func (s *SQLStore) StoreAuditEntry(entry *AuditEntry) error {
+	return s.update(func(tx *sql.Tx) error {
+		res, err := tx.Exec(`INSERT INTO audit_entry (timestamp, caller, operation, data) VALUES (?, ?, ?, '')`,
+			entry.Timestamp.UnixNano(), entry.Caller, entry.Operation)
+		if err != nil {
+			return err
+		}
+
+		entry.ID, err = res.LastInsertId()
+		if err != nil {
+			return err
+		}
+
+		data, err := json.Marshal(entry)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.Exec(`UPDATE audit_entry SET data = ? WHERE id = ?`, string(data), entry.ID)
+		if err != nil {
+			return err
+		}
+
+		for _, c := range entry.Changes {
+			_, err := tx.Exec(`INSERT OR IGNORE INTO audit_change (audit_id, kind, name) VALUES (?, ?, ?)`, entry.ID, c.Kind, c.Name)
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+// AuditEntries returns the audit log entries matching the filter, newest first
+func (s *SQLStore) AuditEntries(filter *AuditFilter) (AuditEntryList, error) {
+	if filter == nil {
+		filter = &AuditFilter{}
+	}
+
+	where := []string{"1 = 1"}
+	args := []interface{}{}
+
+	if filter.Operation != "" {
+		where = append(where, "operation = ?")
+		args = append(args, filter.Operation)
+	}
+
+	if filter.Caller != "" {
+		where = append(where, "caller = ?")
+		args = append(args, filter.Caller)
+	}
+
+	if !filter.Since.IsZero() {
+		where = append(where, "timestamp >= ?")
+		args = append(args, filter.Since.UnixNano())
+	}
+
+	if !filter.Until.IsZero() {
+		where = append(where, "timestamp <= ?")
+		args = append(args, filter.Until.UnixNano())
+	}
+
+	if filter.NodeSet != nil || filter.Kind != "" {
+		cond := "EXISTS (SELECT 1 FROM audit_change c WHERE c.audit_id = audit_entry.id"
+		if filter.Kind != "" {
+			cond += " AND c.kind = ?"
+			args = append(args, filter.Kind)
+		}
+		if filter.NodeSet != nil {
+			cond += " AND c.name IN (SELECT value FROM json_each(?))"
+			args = append(args, nodeNames(filter.NodeSet))
+		}
+		where = append(where, cond+")")
+	}
+
+	query := `SELECT id, data FROM audit_entry WHERE ` + strings.Join(where, " AND ") + ` ORDER BY id DESC`
+	if filter.Limit > 0 {
+		query += " LIMIT ?"
+		args = append(args, filter.Limit)
+	}
+
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	entries := make(AuditEntryList, 0)
+	for rows.Next() {
+		var id int64
+		var val string
+		if err := rows.Scan(&id, &val); err != nil {
+			return nil, err
+		}
+
+		var entry AuditEntry
+		err := json.Unmarshal([]byte(val), &entry)
+		if err != nil {
+			log.WithFields(logrus.Fields{
+				"err": err,
+				"id":  id,
+			}).Warn("Invalid audit entry json stored in db")
+			continue
+		}
+
+		entries = append(entries, &entry)
+	}
+
+	return entries, rows.Err()
+}
+
+// LoadAuditEntry returns the audit log entry with the given ID
+func (s *SQLStore) LoadAuditEntry(id int64) (*AuditEntry, error) {
+	var val string
+	err := s.db.QueryRow(`SELECT data FROM audit_entry WHERE id = ?`, id).Scan(&val)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("audit entry with id %d:  %w", id, ErrNotFound)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var entry AuditEntry
+	err = json.Unmarshal([]byte(val), &entry)
+	if err != nil {
+		return nil, err
+	}
+
+	return &entry, nil
+}
+
+// PruneAuditEntries deletes audit log entries older than maxAge and all but
+// the newest maxEntries entries. Their changes are deleted by cascade.
+func (s *SQLStore) PruneAuditEntries(maxAge time.Duration, maxEntries int) (int, error) {
+	where := []string{}
+	args := []interface{}{}
+
+	if maxAge > 0 {
+		where = append(where, "timestamp < ?")
+		args = append(args, time.Now().Add(-maxAge).UnixNano())
+	}
+
+	if maxEntries > 0 {
+		where = append(where, "id <= (SELECT MAX(id) FROM audit_entry) - ?")
+		args = append(args, maxEntries)
+	}
+
+	if len(where) == 0 {
+		return 0, nil
+	}
+
+	count := 0
+	err := s.update(func(tx *sql.Tx) error {
+		res, err := tx.Exec(`DELETE FROM audit_entry WHERE `+strings.Join(where, " OR "), args...)
+		if err != nil {
+			return err
+		}
+
+		n, err := res.RowsAffected()
+		count = int(n)
+		return err
+	})
+
+	return count, err
+}
+
+// StoreProvisionRecord stores the provisioning record of a host
+func (s *SQLStore) StoreProvisionRecord(record *ProvisionRecord) error {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+
+	res, err := s.db.Exec(`INSERT INTO provision_record (host_id, data)
+		SELECT id, ? FROM host WHERE name = ?
+		ON CONFLICT (host_id) DO UPDATE SET data = excluded.data`, string(data), record.Name)
+	if err != nil {
+		return err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if n == 0 {
+		return fmt.Errorf("host with name %s:  %w", record.Name, ErrNotFound)
+	}
+
+	return nil
+}
+
+// LoadProvisionRecord returns the provisioning record of the host with the given name
+func (s *SQLStore) LoadProvisionRecord(name string) (*ProvisionRecord, error) {
+	var val string
+	err := s.db.QueryRow(`SELECT p.data FROM provision_record p
+		JOIN host h ON h.id = p.host_id WHERE h.name = ?`, name).Scan(&val)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("provision record for host %s:  %w", name, ErrNotFound)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var record ProvisionRecord
+	err = json.Unmarshal([]byte(val), &record)
+	if err != nil {
+		return nil, err
+	}
+
+	return &record, nil
+}
+
+// ProvisionRecords returns the provisioning records of the hosts in the
+// given NodeSet, or of all hosts if ns is nil
+func (s *SQLStore) ProvisionRecords(ns *nodeset.NodeSet) (ProvisionRecordList, error) {
+	query := `SELECT p.data FROM provision_record p JOIN host h ON h.id = p.host_id`
+	args := []interface{}{}
+	if ns != nil {
+		query += ` WHERE h.name IN (SELECT value FROM json_each(?))`
+		args = append(args, nodeNames(ns))
+	}
+	query += ` ORDER BY h.name`
+
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	records := make(ProvisionRecordList, 0)
+	for rows.Next() {
+		var val string
+		if err := rows.Scan(&val); err != nil {
+			return nil, err
+		}
+
+		var record ProvisionRecord
+		if err := json.Unmarshal([]byte(val), &record); err != nil {
+			return nil, err
+		}
+
+		records = append(records, &record)
+	}
+
+	return records, rows.Err()
+}
+
+// StoreLease stores the dynamic DHCP lease of a client
+func (s *SQLStore) StoreLease(lease *Lease) error {
+	data, err := json.Marshal(lease)
+	if err != nil {
+		return err
+	}
+
+	mac := lease.key()
+	addr := lease.IP.Unmap().String()
+
+	return s.update(func(tx *sql.Tx) error {
+		var name string
+		err := tx.QueryRow(`SELECT h.name FROM net_interface n JOIN host h ON h.id = n.host_id
+			WHERE n.addr = ? OR n.id IN (SELECT interface_id FROM net_address WHERE addr = ?) LIMIT 1`, addr, addr).Scan(&name)
+		if err == nil {
+			return fmt.Errorf("address %s is assigned to host %s:  %w", addr, name, ErrDuplicateEntry)
+		}
+		if !errors.Is(err, sql.ErrNoRows) {
+			return err
+		}
+
+		var holder string
+		var expires int64
+		err = tx.QueryRow(`SELECT mac, expires FROM dhcp_lease WHERE addr = ? AND mac != ?`, addr, mac).Scan(&holder, &expires)
+		if err == nil {
+			if time.Now().UnixNano() < expires {
+				return fmt.Errorf("address %s is leased to %s:  %w", addr, holder, ErrDuplicateEntry)
+			}
+
+			if _, err := tx.Exec(`DELETE FROM dhcp_lease WHERE mac = ?`, holder); err != nil {
+				return err
+			}
+		} else if !errors.Is(err, sql.ErrNoRows) {
+			return err
+		}
+
+		_, err = tx.Exec(`INSERT INTO dhcp_lease (mac, addr, expires, data) VALUES (?, ?, ?, ?)
+			ON CONFLICT (mac) DO UPDATE SET addr = excluded.addr, expires = excluded.expires, data = excluded.data`,
+			mac, addr, lease.Expires.UnixNano(), string(data))
+		return err
+	})
+}
+
+// LoadLease returns the lease of the client with the given MAC address
+func (s *SQLStore) LoadLease(mac string) (*Lease, error) {
+	hwaddr, err := net.ParseMAC(mac)
+	if err != nil {
+		return nil, fmt.Errorf("lease for mac %s:  %w", mac, ErrNotFound)
+	}
+
+	var val string
+	err = s.db.QueryRow(`SELECT data FROM dhcp_lease WHERE mac = ?`, hwaddr.String()).Scan(&val)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("lease for mac %s:  %w", mac, ErrNotFound)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var lease Lease
+	if err := json.Unmarshal([]byte(val), &lease); err != nil {
+		return nil, err
+	}
+
+	return &lease, nil
+}
+
+// DeleteLease deletes the lease of the client with the given MAC address
+func (s *SQLStore) DeleteLease(mac string) error {
+	hwaddr, err := net.ParseMAC(mac)
+	if err != nil {
+		return fmt.Errorf("lease for mac %s:  %w", mac, ErrNotFound)
+	}
+
+	_, err = s.db.Exec(`DELETE FROM dhcp_lease WHERE mac = ?`, hwaddr.String())
+	return err
+}
+
+// Leases returns all dynamic DHCP leases
+func (s *SQLStore) Leases() (LeaseList, error) {
+	rows, err := s.db.Query(`SELECT data FROM dhcp_lease`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	leases := make(LeaseList, 0)
+	for rows.Next() {
+		var val string
+		if err := rows.Scan(&val); err != nil {
+			return nil, err
+		}
+
+		var lease Lease
+		if err := json.Unmarshal([]byte(val), &lease); err != nil {
+			return nil, err
+		}
+
+		leases = append(leases, &lease)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	sortLeases(leases)
+	return leases, nil
+}
+
+// StoreUnregistered stores an unknown PXE client seen by the DHCP server
+func (s *SQLStore) StoreUnregistered(host *UnregisteredHost) error {
+	data, err := json.Marshal(host)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.db.Exec(`INSERT INTO unregistered_host (mac, data) VALUES (?, ?)
+		ON CONFLICT (mac) DO UPDATE SET data = excluded.data`,
+		host.MAC.String(), string(data))
+	return err
+}
+
+// LoadUnregistered returns the unregistered host with the given MAC address
+func (s *SQLStore) LoadUnregistered(mac string) (*UnregisteredHost, error) {
+	hwaddr, err := net.ParseMAC(mac)
+	if err != nil {
+		return nil, fmt.Errorf("unregistered host with mac %s:  %w", mac, ErrNotFound)
+	}
+
+	var val string
+	err = s.db.QueryRow(`SELECT data FROM unregistered_host WHERE mac = ?`, hwaddr.String()).Scan(&val)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("unregistered host with mac %s:  %w", mac, ErrNotFound)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var host UnregisteredHost
+	if err := json.Unmarshal([]byte(val), &host); err != nil {
+		return nil, err
+	}
+
+	return &host, nil
+}
+
+// DeleteUnregistered deletes the unregistered host with the given MAC address
+func (s *SQLStore) DeleteUnregistered(mac string) error {
+	hwaddr, err := net.ParseMAC(mac)
+	if err != nil {
+		return fmt.Errorf("unregistered host with mac %s:  %w", mac, ErrNotFound)
+	}
+
+	_, err = s.db.Exec(`DELETE FROM unregistered_host WHERE mac = ?`, hwaddr.String())
+	return err
+}
+
+// UnregisteredHosts returns all unregistered hosts in the order they were
+// first seen
+func (s *SQLStore) UnregisteredHosts() (UnregisteredHostList, error) {
+	rows, err := s.db.Query(`SELECT data FROM unregistered_host`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	hosts := make(UnregisteredHostList, 0)
+	for rows.Next() {
+		var val string
+		if err := rows.Scan(&val); err != nil {
+			return nil, err
+		}
+
+		var host UnregisteredHost
+		if err := json.Unmarshal([]byte(val), &host); err != nil {
+			return nil, err
+		}
+
+		hosts = append(hosts, &host)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	sortUnregistered(hosts)
+	return hosts, nil
+}
@@ -0,0 +1,131 @@
+// Copyright 2019 Grendel Authors. All rights reserved.
+//
+// This file is part of Grendel.
+//
+// Grendel is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Grendel is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with Grendel. If not, see <https://www.gnu.org/licenses/>.
+
+package model_test
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/ubccr/grendel/internal/tests"
+	"github.com/ubccr/grendel/model"
+	"github.com/ubccr/grendel/nodeset"
+	_ "modernc.org/sqlite"
+)
+
+func TestSQLStoreReopen(t *testing.T) {
+	assert := assert.New(t)
+
+	dir, err := os.MkdirTemp("", "grendel-sqlite-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	file := filepath.Join(dir, "grendel.db")
+
+	store, err := model.NewDataStore("sqlite://" + file)
+	assert.NoError(err)
+
+	host := tests.HostFactory.MustCreate().(*model.Host)
+	host.Tags = []string{"k16"}
+	err = store.StoreHost(host)
+	assert.NoError(err)
+	assert.NoError(store.Close())
+
+	// Migrations are not applied twice when reopening an existing database
+	store, err = model.NewDataStore("sqlite://" + file)
+	if !assert.NoError(err) {
+		return
+	}
+	defer store.Close()
+
+	testHost, err := store.LoadHostFromMAC(host.Interfaces[0].MAC.String())
+	if assert.NoError(err) {
+		assert.Equal(host.ID, testHost.ID)
+		assert.Equal(host.Tags, testHost.Tags)
+		assert.Equal(len(host.Interfaces), len(testHost.Interfaces))
+	}
+
+	ns, err := nodeset.NewNodeSet(host.Name)
+	assert.NoError(err)
+	assert.NoError(store.DeleteHosts(ns))
+
+	// Interfaces and tags are removed with the host by foreign key cascade
+	db, err := sql.Open("sqlite", file)
+	if assert.NoError(err) {
+		defer db.Close()
+
+		for _, table := range []string{"net_interface", "net_address", "dns_alias", "host_tag"} {
+			var count int
+			err := db.QueryRow(fmt.Sprintf("SELECT COUNT(*) FROM %s", table)).Scan(&count)
+			if assert.NoError(err) {
+				assert.Equal(0, count, table)
+			}
+		}
+	}
+}
+
+func BenchmarkSQLStoreIndexedLookups(b *testing.B) {
+	for _, size := range []int{100, 1000, 10000} {
+		store, err := model.NewSQLStore(":memory:")
+		if err != nil {
+			b.Fatal(err)
+		}
+
+		hosts := make(model.HostList, size)
+		for i := 0; i < size; i++ {
+			host := tests.HostFactory.MustCreate().(*model.Host)
+			host.Name = fmt.Sprintf("tux-%05d", i)
+			hosts[i] = host
+		}
+
+		err = store.StoreHosts(hosts)
+		if err != nil {
+			b.Fatal(err)
+		}
+
+		b.Run(fmt.Sprintf("LoadHostFromMAC-%d", size), func(b *testing.B) {
+			for n := 0; n < b.N; n++ {
+				pick := hosts[n%size]
+				_, err := store.LoadHostFromMAC(pick.Interfaces[0].MAC.String())
+				if err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+
+		b.Run(fmt.Sprintf("ResolveIPv4-%d", size), func(b *testing.B) {
+			for n := 0; n < b.N; n++ {
+				pick := hosts[n%size]
+				ips, err := store.ResolveIPv4(pick.Interfaces[0].FQDN)
+				if err != nil {
+					b.Fatal(err)
+				}
+				if len(ips) == 0 {
+					b.Fatalf("IPs not found")
+				}
+			}
+		})
+
+		store.Close()
+	}
+}
@@ -19,6 +19,7 @@ package model
 
 import (
 	"encoding/json"
+	"strings"
 
 	"github.com/hako/branca"
 	"github.com/spf13/viper"
@@ -83,11 +84,19 @@ func ParseBootToken(token string) (*BootClaims, error) {
 	return &claims, nil
 }
 
+// FirmwareClaims are the claims encoded in a firmware token
+type FirmwareClaims struct {
+	Build firmware.Build
+	MAC   string
+}
+
 func NewFirmwareToken(mac string, fwtype firmware.Build) (string, error) {
 	b := branca.NewBranca(viper.GetString("provision.secret"))
 	b.SetTTL(viper.GetUint32("provision.token_ttl"))
 
-	token, err := b.EncodeToString(fwtype.String())
+	// The token is sent in the DHCP boot file name which is limited to 128
+	// bytes so we avoid the JSON encoding used for boot tokens
+	token, err := b.EncodeToString(fwtype.String() + " " + mac)
 	if err != nil {
 		return "", err
 	}
@@ -96,13 +105,26 @@ func NewFirmwareToken(mac string, fwtype firmware.Build) (string, error) {
 }
 
 func ParseFirmwareToken(token string) (firmware.Build, error) {
+	claims, err := ParseFirmwareClaims(token)
+	if err != nil {
+		return 0, err
+	}
+
+	return claims.Build, nil
+}
+
+// ParseFirmwareClaims returns the firmware build and MAC address of the host
+// encoded in a firmware token
+func ParseFirmwareClaims(token string) (*FirmwareClaims, error) {
 	b := branca.NewBranca(viper.GetString("provision.secret"))
 	b.SetTTL(viper.GetUint32("provision.token_ttl"))
 
 	message, err := b.DecodeToString(token)
 	if err != nil {
-		return 0, err
+		return nil, err
 	}
 
-	return firmware.NewFromString(message), nil
+	build, mac, _ := strings.Cut(message, " ")
+
+	return &FirmwareClaims{Build: firmware.NewFromString(build), MAC: mac}, nil
 }
@@ -41,6 +41,12 @@ func TestToken(t *testing.T) {
 		assert.Equal(build, firmware.SNPONLY)
 	}
 
+	fwclaims, err := model.ParseFirmwareClaims(token)
+	if assert.NoError(err) {
+		assert.Equal(fwclaims.Build, firmware.SNPONLY)
+		assert.Equal(fwclaims.MAC, host.Interfaces[0].MAC.String())
+	}
+
 	token, err = model.NewBootToken(host.ID.String(), host.Interfaces[0].MAC.String())
 	if assert.NoError(err) {
 		assert.Greater(len(token), 0)
@@ -52,3 +58,31 @@ func TestToken(t *testing.T) {
 		assert.Equal(claims.MAC, host.Interfaces[0].MAC.String())
 	}
 }
+
+func TestFirmwareTokenLength(t *testing.T) {
+	assert := assert.New(t)
+
+	// The longest custom build name still fits in the BOOTP file field
+	name := "undionly-mellanox-trust-ca.kpxe"
+	assert.Equal(firmware.MaxNameLength, len(name))
+
+	build, err := firmware.Register(name, []byte("undionly"))
+	if !assert.NoError(err) {
+		return
+	}
+
+	for i := 0; i < 100; i++ {
+		token, err := model.NewFirmwareToken("ff:ff:ff:ff:ff:ff", build)
+		if assert.NoError(err) {
+			assert.LessOrEqual(len(token), 127)
+		}
+	}
+
+	token, err := model.NewFirmwareToken("ff:ff:ff:ff:ff:ff", build)
+	if assert.NoError(err) {
+		claims, err := model.ParseFirmwareClaims(token)
+		if assert.NoError(err) {
+			assert.Equal(build, claims.Build)
+		}
+	}
+}
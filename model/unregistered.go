@@ -0,0 +1,122 @@
+// Copyright 2019 Grendel Authors. All rights reserved.
+//
+// This file is part of Grendel.
+//
+// Grendel is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Grendel is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with Grendel. If not, see <https://www.gnu.org/licenses/>.
+
+package model
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/netip"
+	"sort"
+	"time"
+)
+
+type UnregisteredHostList []*UnregisteredHost
+
+// UnregisteredHost is a client which sent a PXE discover but is not in the
+// data store. They are recorded by the DHCP server in auto-enroll mode and
+// promoted to hosts by assigning a name and address.
+type UnregisteredHost struct {
+	MAC         net.HardwareAddr `json:"mac"`
+	Arch        string           `json:"arch,omitempty"`
+	VendorClass string           `json:"vendor_class,omitempty"`
+	UserClass   string           `json:"user_class,omitempty"`
+	UUID        string           `json:"uuid,omitempty"`
+	Hostname    string           `json:"hostname,omitempty"`
+
+	// RelayAddr is the address of the relay agent which forwarded the
+	// discover. CircuitID and RemoteID are the relay agent information
+	// sub-options (option 82).
+	RelayAddr netip.Addr `json:"relay_addr"`
+	CircuitID string     `json:"circuit_id,omitempty"`
+	RemoteID  string     `json:"remote_id,omitempty"`
+
+	FirstSeen time.Time `json:"first_seen"`
+	LastSeen  time.Time `json:"last_seen"`
+	Count     int       `json:"count"`
+}
+
+func (u *UnregisteredHost) MarshalJSON() ([]byte, error) {
+	type Alias UnregisteredHost
+	return json.Marshal(&struct {
+		MAC string `json:"mac"`
+		*Alias
+	}{
+		MAC:   u.MAC.String(),
+		Alias: (*Alias)(u),
+	})
+}
+
+func (u *UnregisteredHost) UnmarshalJSON(data []byte) error {
+	type Alias UnregisteredHost
+	aux := &struct {
+		MAC string `json:"mac"`
+		*Alias
+	}{
+		Alias: (*Alias)(u),
+	}
+	if err := json.Unmarshal(data, &aux); err != nil {
+		return err
+	}
+
+	mac, err := net.ParseMAC(aux.MAC)
+	if err != nil {
+		return fmt.Errorf("Invalid MAC address %s: %w", aux.MAC, err)
+	}
+	u.MAC = mac
+
+	return nil
+}
+
+// sortUnregistered sorts the unregistered hosts by the time they were first
+// seen
+func sortUnregistered(hosts UnregisteredHostList) {
+	sort.SliceStable(hosts, func(i, j int) bool {
+		if hosts[i].FirstSeen.Equal(hosts[j].FirstSeen) {
+			return hosts[i].MAC.String() < hosts[j].MAC.String()
+		}
+		return hosts[i].FirstSeen.Before(hosts[j].FirstSeen)
+	})
+}
+
+// PromoteRequest assigns names from a nodeset and addresses from a subnet to
+// unregistered hosts
+type PromoteRequest struct {
+	// NodeSet are the host names assigned in order
+	NodeSet string `json:"nodeset"`
+
+	// Subnet is the network the addresses are assigned from. The address of
+	// each host is the network address plus the number the host name ends
+	// in, for example cpn-12 in 10.17.40.0/24 is given 10.17.40.12.
+	Subnet string `json:"subnet"`
+
+	// MACs are the unregistered hosts to promote in order. All unregistered
+	// hosts are promoted in the order they were first seen if empty.
+	MACs []string `json:"macs,omitempty"`
+
+	// Relay copies the relay agent circuit and remote id the hosts were
+	// enrolled through onto their interfaces, which identifies them by
+	// switch port. Hosts enrolled without a remote id are rejected.
+	Relay bool `json:"relay,omitempty"`
+
+	Domain    string   `json:"domain,omitempty"`
+	Provision bool     `json:"provision"`
+	Firmware  string   `json:"firmware,omitempty"`
+	BootImage string   `json:"boot_image,omitempty"`
+	Tags      []string `json:"tags,omitempty"`
+}
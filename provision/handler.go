@@ -32,12 +32,19 @@ import (
 	"github.com/labstack/echo/v4"
 	"github.com/sirupsen/logrus"
 	"github.com/spf13/viper"
+	"github.com/ubccr/grendel/artifact"
+	"github.com/ubccr/grendel/events"
+	"github.com/ubccr/grendel/logger"
 	"github.com/ubccr/grendel/model"
 )
 
 type Handler struct {
 	DB               model.DataStore
 	DefaultImageName string
+
+	// Artifacts is the store of uploaded boot image files. Files of an image
+	// which are artifacts are verified before they're sent.
+	Artifacts *artifact.Store
 }
 
 func NewHandler(db model.DataStore, defaultImageName string) (*Handler, error) {
@@ -67,6 +74,7 @@ func (h *Handler) LoadBootImageWithDefault(name string) (*model.BootImage, error
 
 func (h *Handler) SetupRoutes(e *echo.Echo) {
 	e.GET("/", h.Index).Name = "index"
+	e.GET("/firmware/:token/:name", h.Firmware)
 
 	boot := e.Group("/boot/:token/")
 	boot.Use(TokenRequired)
@@ -76,7 +84,8 @@ func (h *Handler) SetupRoutes(e *echo.Echo) {
 	boot.GET("file/kernel*", h.File)
 	boot.HEAD("file/liveimg", h.File)
 	boot.GET("file/liveimg", h.File)
-	boot.GET("file/rootfs", h.File)
+	boot.HEAD("file/rootfs*", h.File)
+	boot.GET("file/rootfs*", h.File)
 	boot.GET("file/initrd-*", h.File)
 	boot.GET("cloud-init/user-data", h.UserData)
 	boot.GET("cloud-init/meta-data", h.MetaData)
@@ -100,16 +109,16 @@ func (h *Handler) verifyClaims(c echo.Context) (*model.BootImage, *model.Host, *
 	host, err := h.DB.LoadHostFromID(claims.ID)
 	if err != nil {
 		log.WithFields(logrus.Fields{
-			"host_id": claims.ID,
-			"mac":     claims.MAC,
+			logger.FieldHostID: claims.ID,
+			logger.FieldMAC:    claims.MAC,
 		}).Error("failed to find host")
 		return nil, nil, nil, nil, echo.NewHTTPError(http.StatusBadRequest, "invalid host").SetInternal(err)
 	}
 
 	if !host.Provision {
 		log.WithFields(logrus.Fields{
-			"host_id": claims.ID,
-			"mac":     claims.MAC,
+			logger.FieldHostID: claims.ID,
+			logger.FieldMAC:    claims.MAC,
 		}).Error("host is not set to provision")
 		return nil, nil, nil, nil, echo.NewHTTPError(http.StatusBadRequest, "host not set to provision")
 	}
@@ -117,8 +126,8 @@ func (h *Handler) verifyClaims(c echo.Context) (*model.BootImage, *model.Host, *
 	mac, err := net.ParseMAC(claims.MAC)
 	if err != nil {
 		log.WithFields(logrus.Fields{
-			"host_id": claims.ID,
-			"mac":     claims.MAC,
+			logger.FieldHostID: claims.ID,
+			logger.FieldMAC:    claims.MAC,
 		}).Error("got invalid mac address")
 		return nil, nil, nil, nil, echo.NewHTTPError(http.StatusBadRequest, "invalid mac address").SetInternal(err)
 	}
@@ -126,8 +135,8 @@ func (h *Handler) verifyClaims(c echo.Context) (*model.BootImage, *model.Host, *
 	nic := host.Interface(mac)
 	if nic == nil {
 		log.WithFields(logrus.Fields{
-			"host_id": claims.ID,
-			"mac":     claims.MAC,
+			logger.FieldHostID: claims.ID,
+			logger.FieldMAC:    claims.MAC,
 		}).Error("got invalid boot interface for host")
 		return nil, nil, nil, nil, echo.NewHTTPError(http.StatusBadRequest, "invalid boot interface").SetInternal(err)
 	}
@@ -135,8 +144,8 @@ func (h *Handler) verifyClaims(c echo.Context) (*model.BootImage, *model.Host, *
 	bootImage, err := h.LoadBootImageWithDefault(host.BootImage)
 	if err != nil {
 		log.WithFields(logrus.Fields{
-			"host_id": claims.ID,
-			"mac":     claims.MAC,
+			logger.FieldHostID: claims.ID,
+			logger.FieldMAC:    claims.MAC,
 		}).Error("failed to find boot image for host")
 		return nil, nil, nil, nil, echo.NewHTTPError(http.StatusBadRequest, "invalid boot image").SetInternal(err)
 	}
@@ -155,16 +164,38 @@ func (h *Handler) verifyClaims(c echo.Context) (*model.BootImage, *model.Host, *
 		"adminSSHPubKeys": viper.GetStringSlice("admin_ssh_pubkeys"),
 	}
 
+	if bootImage.RootFS != "" {
+		data["rootfs"] = endpoints.RootFSURL()
+	}
+
 	return bootImage, host, nic, data, nil
 }
 
+// publishEvent publishes a provision request from a host on the event bus
+func (h *Handler) publishEvent(c echo.Context, etype string, host *model.Host, nic *model.NetInterface, msg string) {
+	events.Publish(&events.Event{
+		Service: "provision",
+		Type:    etype,
+		Host:    host.Name,
+		MAC:     nic.MAC.String(),
+		IP:      c.RealIP(),
+		Tags:    host.Tags,
+		Message: msg,
+	})
+}
+
 func (h *Handler) Ipxe(c echo.Context) error {
-	bootImage, host, _, data, err := h.verifyClaims(c)
+	bootImage, host, nic, data, err := h.verifyClaims(c)
 	if err != nil {
 		return err
 	}
 
+	if !bootImage.Ready() {
+		return imageNotReady(bootImage)
+	}
+
 	log.Infof("Sending iPXE script to boot host %s with image %s", host.Name, bootImage.Name)
+	h.publishEvent(c, events.TypeProvisionIpxe, host, nic, fmt.Sprintf("Sent iPXE script for image %s", bootImage.Name))
 
 	commandLine := bootImage.CommandLine
 
@@ -182,29 +213,51 @@ func (h *Handler) Ipxe(c echo.Context) error {
 		commandLine = buf.String()
 	}
 
+	if rootfs, ok := data["rootfs"]; ok && !hasKernelArg(commandLine, "root") {
+		commandLine = strings.TrimSpace(commandLine + " root=live:" + rootfs.(string))
+	}
+
 	data["commandLine"] = commandLine
 
 	return c.Render(http.StatusOK, "ipxe.tmpl", data)
 }
 
 func (h *Handler) File(c echo.Context) error {
-	bootImage, host, _, _, err := h.verifyClaims(c)
+	bootImage, host, nic, _, err := h.verifyClaims(c)
 	if err != nil {
 		return err
 	}
 
+	if !bootImage.Ready() {
+		return imageNotReady(bootImage)
+	}
+
 	_, fileType := path.Split(c.Request().URL.Path)
 
 	log.Infof("Got request for file %q from host %s %s", fileType, host.Name, c.RealIP())
+	if c.Request().Method != http.MethodHead {
+		h.publishEvent(c, events.TypeProvisionFile, host, nic, fmt.Sprintf("Sent file %s", fileType))
+	}
 
 	switch {
 	case fileType == "kernel":
-		return c.File(bootImage.KernelPath)
+		return h.sendFile(c, bootImage, bootImage.KernelPath)
 	case fileType == "kernel.sig":
 		return c.File(bootImage.KernelPath + ".sig")
 
 	case fileType == "liveimg":
-		return c.File(bootImage.LiveImage)
+		return h.sendFile(c, bootImage, bootImage.LiveImage)
+
+	case strings.HasPrefix(fileType, "rootfs"):
+		if bootImage.RootFS == "" {
+			return echo.NewHTTPError(http.StatusNotFound, "no rootfs for image")
+		}
+		switch fileType {
+		case "rootfs":
+			return h.sendFile(c, bootImage, bootImage.RootFS)
+		case "rootfs.sig", "rootfs.sha256":
+			return c.File(bootImage.RootFS + strings.TrimPrefix(fileType, "rootfs"))
+		}
 
 	case strings.HasPrefix(fileType, "initrd-"):
 		initrdBaseName := strings.TrimSuffix(fileType, ".sig")
@@ -214,21 +267,84 @@ func (h *Handler) File(c echo.Context) error {
 		}
 		initrd := bootImage.InitrdPaths[i]
 		if strings.HasSuffix(fileType, ".sig") {
-			initrd += ".sig"
+			return c.File(initrd + ".sig")
 		}
-		return c.File(initrd)
+		return h.sendFile(c, bootImage, initrd)
 	}
 
 	return echo.NewHTTPError(http.StatusNotFound, "")
 }
 
+// imageNotReady returns the error sent for images with sources which have not
+// been fetched yet
+func imageNotReady(bootImage *model.BootImage) error {
+	return echo.NewHTTPError(http.StatusServiceUnavailable, fmt.Sprintf("boot image %s is not ready", bootImage.Name)).
+		SetInternal(fmt.Errorf("image status %q %s", bootImage.Status, bootImage.StatusMessage))
+}
+
+// sendFile sends a file of the boot image. Files uploaded to the artifact
+// store are verified against the checksum recorded on the image.
+func (h *Handler) sendFile(c echo.Context, bootImage *model.BootImage, path string) error {
+	if h.Artifacts != nil {
+		if a := h.Artifacts.Find(bootImage, path); a != nil {
+			if err := h.Artifacts.Verify(a); err != nil {
+				return echo.NewHTTPError(http.StatusInternalServerError, "artifact failed verification").SetInternal(err)
+			}
+		}
+	}
+
+	return c.File(path)
+}
+
+// hasKernelArg returns true if the kernel command line sets the given argument
+func hasKernelArg(commandLine, name string) bool {
+	for _, arg := range strings.Fields(commandLine) {
+		if arg == name || strings.HasPrefix(arg, name+"=") {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Firmware sends the iPXE firmware build encoded in a firmware token to UEFI
+// HTTP boot clients
+func (h *Handler) Firmware(c echo.Context) error {
+	claims, err := model.ParseFirmwareClaims(c.Param("token"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid token").SetInternal(err)
+	}
+
+	bs := claims.Build.ToBytes()
+	if bs == nil || c.Param("name") != claims.Build.String() {
+		return echo.NewHTTPError(http.StatusNotFound, "firmware not found")
+	}
+
+	log.Infof("Sending firmware %s to %s %s", claims.Build, claims.MAC, c.RealIP())
+
+	host, err := h.DB.LoadHostFromMAC(claims.MAC)
+	if err == nil {
+		events.Publish(&events.Event{
+			Service: "provision",
+			Type:    events.TypeProvisionFirmware,
+			Host:    host.Name,
+			MAC:     claims.MAC,
+			IP:      c.RealIP(),
+			Tags:    host.Tags,
+			Message: fmt.Sprintf("Sent firmware %s", claims.Build),
+		})
+	}
+
+	return h.serveBlob(c, claims.Build.String(), bs)
+}
+
 func (h *Handler) serveBlob(c echo.Context, name string, data []byte) error {
 	http.ServeContent(c.Response(), c.Request(), name, time.Time{}, bytes.NewReader(data))
 	return nil
 }
 
 func (h *Handler) Kickstart(c echo.Context) error {
-	bootImage, _, _, data, err := h.verifyClaims(c)
+	bootImage, host, nic, data, err := h.verifyClaims(c)
 	if err != nil {
 		return err
 	}
@@ -238,11 +354,13 @@ func (h *Handler) Kickstart(c echo.Context) error {
 		tmplName = bootImage.ProvisionTemplate
 	}
 
+	h.publishEvent(c, events.TypeProvisionKickstart, host, nic, fmt.Sprintf("Sent kickstart %s", tmplName))
+
 	return c.Render(http.StatusOK, tmplName, data)
 }
 
 func (h *Handler) Complete(c echo.Context) error {
-	_, host, _, _, err := h.verifyClaims(c)
+	_, host, nic, _, err := h.verifyClaims(c)
 	if err != nil {
 		return err
 	}
@@ -251,15 +369,17 @@ func (h *Handler) Complete(c echo.Context) error {
 
 	host.Provision = false
 
-	err = h.DB.StoreHost(host)
+	err = model.NewAuditedStore(h.DB, "provision", "").StoreHost(host)
 	if err != nil {
 		log.WithFields(logrus.Fields{
-			"id":   host.ID,
-			"name": host.Name,
+			logger.FieldHostID: host.ID,
+			logger.FieldHost:   host.Name,
 		}).Error("failed to unprovision host")
 		return echo.NewHTTPError(http.StatusInternalServerError, "failed to unprovision host").SetInternal(err)
 	}
 
+	h.publishEvent(c, events.TypeProvisionComplete, host, nic, "Provisioning complete")
+
 	resp := map[string]interface{}{
 		"status": "ok",
 	}
@@ -21,11 +21,17 @@ import (
 	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/labstack/echo/v4"
 	"github.com/stretchr/testify/assert"
 	"github.com/tidwall/gjson"
+	"github.com/ubccr/grendel/artifact"
+	"github.com/ubccr/grendel/firmware"
 	"github.com/ubccr/grendel/internal/tests"
 	"github.com/ubccr/grendel/model"
 )
@@ -280,7 +286,7 @@ func TestUnprovision(t *testing.T) {
 	c.SetParamNames("token")
 	c.SetParamValues(token)
 
-	if assert.NoError(TokenRequired(h.Unprovision)(c)) {
+	if assert.NoError(TokenRequired(h.Complete)(c)) {
 		assert.Equal(http.StatusOK, rec.Code)
 		assert.Equal("ok", gjson.Get(rec.Body.String(), "status").String())
 	}
@@ -356,3 +362,222 @@ func TestMetaData(t *testing.T) {
 		assert.Contains(rec.Body.String(), host.ID.String())
 	}
 }
+
+func TestFirmware(t *testing.T) {
+	assert := assert.New(t)
+
+	h := &Handler{DB: newTestDB(t)}
+
+	host := tests.HostFactory.MustCreate().(*model.Host)
+	err := h.DB.StoreHost(host)
+	assert.NoError(err)
+
+	token, err := model.NewFirmwareToken(host.Interfaces[0].MAC.String(), firmware.EFI64)
+	assert.NoError(err)
+
+	for _, test := range []struct {
+		token string
+		name  string
+		code  int
+	}{
+		{token, firmware.EFI64.String(), http.StatusOK},
+		{token, firmware.EFIARM64.String(), http.StatusNotFound},
+		{"bad token", firmware.EFI64.String(), http.StatusBadRequest},
+	} {
+		e := newTestEcho(t)
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+		c.SetPath("/firmware/:token/:name")
+		c.SetParamNames("token", "name")
+		c.SetParamValues(test.token, test.name)
+
+		err := h.Firmware(c)
+		if test.code == http.StatusOK {
+			if assert.NoError(err) {
+				assert.Equal(http.StatusOK, rec.Code)
+				assert.Equal(len(firmware.EFI64.ToBytes()), rec.Body.Len())
+			}
+			continue
+		}
+
+		if assert.Error(err) {
+			he, ok := err.(*echo.HTTPError)
+			if assert.True(ok) {
+				assert.Equal(test.code, he.Code)
+			}
+		}
+	}
+}
+
+func TestRootFS(t *testing.T) {
+	assert := assert.New(t)
+
+	h := &Handler{DB: newTestDB(t)}
+
+	rootfs := filepath.Join(t.TempDir(), "rootfs.squashfs")
+	err := os.WriteFile(rootfs, []byte("0123456789"), 0644)
+	assert.NoError(err)
+	err = os.WriteFile(rootfs+".sha256", []byte("checksum"), 0644)
+	assert.NoError(err)
+
+	image := tests.BootImageFactory.MustCreate().(*model.BootImage)
+	image.RootFS = rootfs
+	image.CommandLine = "rd.neednet=1 ip=dhcp"
+	err = h.DB.StoreBootImage(image)
+	assert.NoError(err)
+
+	host := tests.HostFactory.MustCreate().(*model.Host)
+	host.BootImage = image.Name
+	host.Provision = true
+	err = h.DB.StoreHost(host)
+	assert.NoError(err)
+
+	token, err := model.NewBootToken(host.ID.String(), host.Interfaces[0].MAC.String())
+	assert.NoError(err)
+
+	e := newTestEcho(t)
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	c.SetPath("/boot/:token/ipxe")
+	c.SetParamNames("token")
+	c.SetParamValues(token)
+
+	if assert.NoError(TokenRequired(h.Ipxe)(c)) {
+		assert.Equal(http.StatusOK, rec.Code)
+		assert.Contains(rec.Body.String(), "rd.neednet=1 ip=dhcp root=live:http")
+		assert.Contains(rec.Body.String(), "/file/rootfs")
+	}
+
+	for _, test := range []struct {
+		file string
+		rng  string
+		code int
+		body string
+	}{
+		{"rootfs", "", http.StatusOK, "0123456789"},
+		{"rootfs", "bytes=2-5", http.StatusPartialContent, "2345"},
+		{"rootfs.sha256", "", http.StatusOK, "checksum"},
+		{"rootfs.sig", "", http.StatusNotFound, ""},
+	} {
+		e := newTestEcho(t)
+		req := httptest.NewRequest(http.MethodGet, "/boot/"+token+"/file/"+test.file, nil)
+		if test.rng != "" {
+			req.Header.Set("Range", test.rng)
+		}
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+		c.SetPath("/boot/:token/file/rootfs*")
+		c.SetParamNames("token")
+		c.SetParamValues(token)
+
+		err := TokenRequired(h.File)(c)
+		if test.code == http.StatusNotFound {
+			assert.Errorf(err, "no error for %s", test.file)
+			continue
+		}
+
+		if assert.NoErrorf(err, "error for %s", test.file) {
+			assert.Equal(test.code, rec.Code)
+			assert.Equal(test.body, rec.Body.String())
+		}
+	}
+}
+
+func TestArtifactVerify(t *testing.T) {
+	assert := assert.New(t)
+
+	store, err := artifact.NewStore(t.TempDir())
+	if !assert.NoError(err) {
+		return
+	}
+
+	h := &Handler{DB: newTestDB(t), Artifacts: store}
+
+	image := tests.BootImageFactory.MustCreate().(*model.BootImage)
+	_, err = store.Add(strings.NewReader("kernel"), "", func(a *model.Artifact, path string) error {
+		a.Kind = model.ArtifactKernel
+		image.KernelPath = path
+		image.Artifacts = []*model.Artifact{a}
+		return h.DB.StoreBootImage(image)
+	})
+	assert.NoError(err)
+
+	host := tests.HostFactory.MustCreate().(*model.Host)
+	host.BootImage = image.Name
+	host.Provision = true
+	err = h.DB.StoreHost(host)
+	assert.NoError(err)
+
+	token, err := model.NewBootToken(host.ID.String(), host.Interfaces[0].MAC.String())
+	assert.NoError(err)
+
+	getKernel := func() (*httptest.ResponseRecorder, error) {
+		e := newTestEcho(t)
+		req := httptest.NewRequest(http.MethodGet, "/boot/"+token+"/file/kernel", nil)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+		c.SetPath("/boot/:token/file/kernel*")
+		c.SetParamNames("token")
+		c.SetParamValues(token)
+
+		return rec, TokenRequired(h.File)(c)
+	}
+
+	rec, err := getKernel()
+	if assert.NoError(err) {
+		assert.Equal("kernel", rec.Body.String())
+	}
+
+	err = os.WriteFile(image.KernelPath, []byte("KERNEL"), 0644)
+	assert.NoError(err)
+	later := time.Now().Add(time.Hour)
+	err = os.Chtimes(image.KernelPath, later, later)
+	assert.NoError(err)
+
+	_, err = getKernel()
+	if assert.Error(err) {
+		he, ok := err.(*echo.HTTPError)
+		if assert.True(ok) {
+			assert.Equal(http.StatusInternalServerError, he.Code)
+		}
+	}
+}
+
+func TestImageNotReady(t *testing.T) {
+	assert := assert.New(t)
+
+	h := &Handler{DB: newTestDB(t)}
+
+	image := tests.BootImageFactory.MustCreate().(*model.BootImage)
+	image.Sources = []*model.Source{{Kind: model.ArtifactKernel, URL: "oci://ghcr.io/ubccr/kernel:6.1"}}
+	image.ResetStatus()
+	err := h.DB.StoreBootImage(image)
+	assert.NoError(err)
+
+	host := tests.HostFactory.MustCreate().(*model.Host)
+	host.BootImage = image.Name
+	host.Provision = true
+	err = h.DB.StoreHost(host)
+	assert.NoError(err)
+
+	token, err := model.NewBootToken(host.ID.String(), host.Interfaces[0].MAC.String())
+	assert.NoError(err)
+
+	e := newTestEcho(t)
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	c.SetPath("/boot/:token/ipxe")
+	c.SetParamNames("token")
+	c.SetParamValues(token)
+
+	err = TokenRequired(h.Ipxe)(c)
+	if assert.Error(err) {
+		he, ok := err.(*echo.HTTPError)
+		if assert.True(ok) {
+			assert.Equal(http.StatusServiceUnavailable, he.Code)
+		}
+	}
+}
@@ -0,0 +1,158 @@
+// Copyright 2019 Grendel Authors. All rights reserved.
+//
+// This file is part of Grendel.
+//
+// Grendel is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Grendel is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with Grendel. If not, see <https://www.gnu.org/licenses/>.
+
+package provision
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/spf13/viper"
+	"github.com/ubccr/grendel/metrics"
+	"github.com/ubccr/grendel/model"
+)
+
+var (
+	requestsTotal = metrics.Factory.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metrics.Namespace,
+		Subsystem: "provision",
+		Name:      "requests_total",
+		Help:      "Provision HTTP requests by endpoint and status code",
+	}, []string{"endpoint", "code"})
+
+	requestDuration = metrics.Factory.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: metrics.Namespace,
+		Subsystem: "provision",
+		Name:      "request_duration_seconds",
+		Help:      "Provision HTTP request latency by endpoint",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"endpoint"})
+)
+
+// Metrics records the count and latency of provision requests. Endpoints are
+// labeled by route so the boot token is not included.
+func Metrics(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		start := time.Now()
+		err := next(c)
+
+		code := c.Response().Status
+		if err != nil {
+			code = http.StatusInternalServerError
+			if he, ok := err.(*echo.HTTPError); ok {
+				code = he.Code
+			}
+		}
+
+		endpoint := strings.TrimPrefix(c.Path(), "/boot/:token")
+		if endpoint == "" {
+			endpoint = "unknown"
+		}
+
+		requestsTotal.WithLabelValues(endpoint, strconv.Itoa(code)).Inc()
+		requestDuration.WithLabelValues(endpoint).Observe(time.Since(start).Seconds())
+
+		return err
+	}
+}
+
+// StateCollector exports the number of hosts set to provision and the number
+// of hosts in each provisioning state
+type StateCollector struct {
+	DB model.DataStore
+
+	hosts  *prometheus.Desc
+	states *prometheus.Desc
+	stuck  *prometheus.Desc
+}
+
+func NewStateCollector(db model.DataStore) *StateCollector {
+	return &StateCollector{
+		DB: db,
+		hosts: prometheus.NewDesc(
+			prometheus.BuildFQName(metrics.Namespace, "", "hosts"),
+			"Number of hosts by provision flag",
+			[]string{"provision"}, nil),
+		states: prometheus.NewDesc(
+			prometheus.BuildFQName(metrics.Namespace, "provision", "state_hosts"),
+			"Number of hosts in each provisioning state",
+			[]string{"state"}, nil),
+		stuck: prometheus.NewDesc(
+			prometheus.BuildFQName(metrics.Namespace, "provision", "stuck_hosts"),
+			"Number of hosts stuck in a provisioning state longer than provision.stuck_timeout",
+			nil, nil),
+	}
+}
+
+func (sc *StateCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- sc.hosts
+	ch <- sc.states
+	ch <- sc.stuck
+}
+
+func (sc *StateCollector) Collect(ch chan<- prometheus.Metric) {
+	hosts, err := sc.DB.Hosts()
+	if err != nil {
+		log.Errorf("Failed to collect host metrics: %v", err)
+		return
+	}
+
+	provision := 0
+	for _, host := range hosts {
+		if host.Provision {
+			provision++
+		}
+	}
+	ch <- prometheus.MustNewConstMetric(sc.hosts, prometheus.GaugeValue, float64(provision), "true")
+	ch <- prometheus.MustNewConstMetric(sc.hosts, prometheus.GaugeValue, float64(len(hosts)-provision), "false")
+
+	records, err := sc.DB.ProvisionRecords(nil)
+	if err != nil {
+		log.Errorf("Failed to collect provision metrics: %v", err)
+		return
+	}
+
+	counts := map[model.ProvisionState]int{
+		model.ProvisionStatePending:    0,
+		model.ProvisionStateDHCP:       0,
+		model.ProvisionStateFirmware:   0,
+		model.ProvisionStateIpxe:       0,
+		model.ProvisionStateKernel:     0,
+		model.ProvisionStateInstalling: 0,
+		model.ProvisionStateComplete:   0,
+		model.ProvisionStateFailed:     0,
+	}
+
+	timeout := viper.GetDuration("provision.stuck_timeout")
+	now := time.Now()
+	stuck := 0
+	for _, record := range records {
+		counts[record.State]++
+		if record.Stuck || record.IsStuck(timeout, now) {
+			stuck++
+		}
+	}
+
+	for state, n := range counts {
+		ch <- prometheus.MustNewConstMetric(sc.states, prometheus.GaugeValue, float64(n), string(state))
+	}
+	ch <- prometheus.MustNewConstMetric(sc.stuck, prometheus.GaugeValue, float64(stuck))
+}
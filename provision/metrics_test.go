@@ -0,0 +1,66 @@
+// Copyright 2019 Grendel Authors. All rights reserved.
+//
+// This file is part of Grendel.
+//
+// Grendel is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Grendel is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with Grendel. If not, see <https://www.gnu.org/licenses/>.
+
+package provision
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/ubccr/grendel/internal/tests"
+	"github.com/ubccr/grendel/model"
+)
+
+func TestMetrics(t *testing.T) {
+	assert := assert.New(t)
+
+	db := newTestDB(t)
+	h := &Handler{DB: db}
+	e := newTestEcho(t)
+	h.SetupRoutes(e)
+
+	host := tests.HostFactory.MustCreate().(*model.Host)
+	host.Provision = true
+	assert.NoError(db.StoreHost(host))
+
+	before := testutil.ToFloat64(requestsTotal.WithLabelValues("/ipxe", "400"))
+
+	req := httptest.NewRequest(http.MethodGet, "/boot/invalid-token/ipxe", nil)
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	assert.Equal(http.StatusBadRequest, rec.Code)
+	assert.Equal(before+1, testutil.ToFloat64(requestsTotal.WithLabelValues("/ipxe", "400")))
+
+	assert.NoError(db.StoreProvisionRecord(model.NewProvisionRecord(host.Name, time.Now().Add(-time.Hour))))
+
+	expected := `
+# HELP grendel_provision_stuck_hosts Number of hosts stuck in a provisioning state longer than provision.stuck_timeout
+# TYPE grendel_provision_stuck_hosts gauge
+grendel_provision_stuck_hosts 1
+# HELP grendel_hosts Number of hosts by provision flag
+# TYPE grendel_hosts gauge
+grendel_hosts{provision="false"} 0
+grendel_hosts{provision="true"} 1
+`
+	err := testutil.CollectAndCompare(NewStateCollector(db), strings.NewReader(expected), "grendel_hosts", "grendel_provision_stuck_hosts")
+	assert.NoError(err)
+}
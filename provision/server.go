@@ -29,6 +29,7 @@ import (
 	"github.com/labstack/echo/v4"
 	"github.com/labstack/echo/v4/middleware"
 	"github.com/sirupsen/logrus"
+	"github.com/ubccr/grendel/artifact"
 	"github.com/ubccr/grendel/logger"
 	"github.com/ubccr/grendel/model"
 	"github.com/ubccr/grendel/util"
@@ -48,6 +49,7 @@ type Server struct {
 	KeyFile       string
 	CertFile      string
 	RepoDir       string
+	Artifacts     *artifact.Store
 	DB            model.DataStore
 	httpServer    *http.Server
 }
@@ -76,13 +78,13 @@ func NewServer(db model.DataStore, address string) (*Server, error) {
 	s.Port = port
 
 	ip := net.ParseIP(shost)
-	if ip == nil || ip.To4() == nil {
-		return nil, fmt.Errorf("Invalid IPv4 address: %s", shost)
+	if ip == nil {
+		return nil, fmt.Errorf("Invalid IP address: %s", shost)
 	}
 
 	s.ListenAddress = ip
 
-	if !ip.To4().Equal(net.IPv4zero) {
+	if !ip.IsUnspecified() {
 		s.ServerAddress = ip
 		return s, nil
 	}
@@ -102,6 +104,7 @@ func newEcho() (*echo.Echo, error) {
 	e.HTTPErrorHandler = HTTPErrorHandler
 	e.HideBanner = true
 	e.Use(middleware.Recover())
+	e.Use(Metrics)
 	e.Logger = EchoLogger()
 
 	renderer, err := NewTemplateRenderer()
@@ -119,22 +122,22 @@ func HTTPErrorHandler(err error, c echo.Context) {
 	if he, ok := err.(*echo.HTTPError); ok {
 		if he.Code == http.StatusNotFound {
 			log.WithFields(logrus.Fields{
-				"path": path,
-				"ip":   c.RealIP(),
+				"path":         path,
+				logger.FieldIP: c.RealIP(),
 			}).Warn("Requested path not found")
 		} else {
 			log.WithFields(logrus.Fields{
-				"code": he.Code,
-				"err":  he.Internal,
-				"path": path,
-				"ip":   c.RealIP(),
+				"code":         he.Code,
+				"err":          he.Internal,
+				"path":         path,
+				logger.FieldIP: c.RealIP(),
 			}).Error(he.Message)
 		}
 	} else {
 		log.WithFields(logrus.Fields{
-			"err":  err,
-			"path": path,
-			"ip":   c.RealIP(),
+			"err":          err,
+			"path":         path,
+			logger.FieldIP: c.RealIP(),
 		}).Error("HTTP Error")
 	}
 
@@ -159,10 +162,11 @@ func (s *Server) Serve(defaultImageName string) error {
 		return err
 	}
 
+	h.Artifacts = s.Artifacts
 	h.SetupRoutes(e)
 
 	httpServer := &http.Server{
-		Addr:         fmt.Sprintf("%s:%d", s.ListenAddress, s.Port),
+		Addr:         net.JoinHostPort(s.ListenAddress.String(), strconv.Itoa(s.Port)),
 		ReadTimeout:  15 * time.Minute,
 		WriteTimeout: 15 * time.Minute,
 		IdleTimeout:  120 * time.Second,
@@ -198,13 +202,13 @@ func (s *Server) Serve(defaultImageName string) error {
 		}
 
 		s.Scheme = "https"
-		httpServer.Addr = fmt.Sprintf("%s:%d", s.ListenAddress, s.Port)
+		httpServer.Addr = net.JoinHostPort(s.ListenAddress.String(), strconv.Itoa(s.Port))
 	} else {
 		s.Scheme = "http"
 	}
 
 	s.httpServer = httpServer
-	log.Infof("Listening on %s://%s:%d", s.Scheme, s.ListenAddress, s.Port)
+	log.Infof("Listening on %s://%s", s.Scheme, httpServer.Addr)
 	if err := e.StartServer(httpServer); err != nil && err != http.ErrServerClosed {
 		return err
 	}
@@ -0,0 +1,188 @@
+// Copyright 2019 Grendel Authors. All rights reserved.
+//
+// This file is part of Grendel.
+//
+// Grendel is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Grendel is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with Grendel. If not, see <https://www.gnu.org/licenses/>.
+
+package provision
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/ubccr/grendel/events"
+	"github.com/ubccr/grendel/logger"
+	"github.com/ubccr/grendel/model"
+)
+
+// stateForEvent maps events published by the grendel services to the
+// provisioning state the host reached
+var stateForEvent = map[string]model.ProvisionState{
+	events.TypeDHCPOffer:          model.ProvisionStateDHCP,
+	events.TypeDHCPAck:            model.ProvisionStateDHCP,
+	events.TypeDHCPNak:            model.ProvisionStateFailed,
+	events.TypePXEAck:             model.ProvisionStateDHCP,
+	events.TypeTFTPFirmware:       model.ProvisionStateFirmware,
+	events.TypeProvisionFirmware:  model.ProvisionStateFirmware,
+	events.TypeProvisionIpxe:      model.ProvisionStateIpxe,
+	events.TypeTFTPKernel:         model.ProvisionStateKernel,
+	events.TypeTFTPInitrd:         model.ProvisionStateKernel,
+	events.TypeProvisionFile:      model.ProvisionStateKernel,
+	events.TypeProvisionKickstart: model.ProvisionStateInstalling,
+	events.TypeProvisionComplete:  model.ProvisionStateComplete,
+}
+
+// Tracker keeps the provisioning record of each host up to date from the
+// events published by the DHCP, PXE, TFTP and provision services
+type Tracker struct {
+	DB model.DataStore
+
+	// Timeout after which a host which has not moved on from a state is
+	// reported as stuck. Zero disables stuck detection.
+	Timeout time.Duration
+}
+
+func NewTracker(db model.DataStore, timeout time.Duration) *Tracker {
+	return &Tracker{DB: db, Timeout: timeout}
+}
+
+// Run updates provisioning records until ctx is cancelled. The tracker
+// subscribes without dropping events so a boot storm delays state transitions
+// instead of losing them and reporting hosts as stuck.
+func (t *Tracker) Run(ctx context.Context) {
+	sub := events.SubscribeLossless(nil)
+	defer sub.Close()
+
+	interval := time.Minute
+	if t.Timeout > 0 && t.Timeout/2 < interval {
+		interval = t.Timeout / 2
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := t.CheckStuck(time.Now()); err != nil {
+				log.Errorf("Failed to check for stuck hosts: %v", err)
+			}
+		case e := <-sub.C:
+			if err := t.Handle(e); err != nil {
+				log.WithFields(logrus.Fields{
+					"err":            err,
+					logger.FieldHost: e.Host,
+					"event":          e.Type,
+				}).Error("Failed to update provision record")
+			}
+		}
+	}
+}
+
+// Handle updates the provisioning record of the host the event was published
+// for
+func (t *Tracker) Handle(e *events.Event) error {
+	if e.Host == "" {
+		return nil
+	}
+
+	state, ok := stateForEvent[e.Type]
+	if !ok && e.Type != events.TypeHostChanged {
+		return nil
+	}
+
+	host, err := t.DB.LoadHostFromName(e.Host)
+	if errors.Is(err, model.ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	record, err := t.DB.LoadProvisionRecord(host.Name)
+	if err != nil && !errors.Is(err, model.ErrNotFound) {
+		return err
+	}
+
+	switch {
+	case e.Type == events.TypeHostChanged:
+		// Start a new run when a host is set to provision
+		if !host.Provision || (record != nil && !record.Done()) {
+			return nil
+		}
+		return t.DB.StoreProvisionRecord(model.NewProvisionRecord(host.Name, e.Time))
+	case state == model.ProvisionStateComplete:
+		// Complete unsets the provision flag before the event is published
+	case !host.Provision:
+		// Hosts not set to provision are served on every normal boot
+		return nil
+	}
+
+	if record == nil {
+		record = model.NewProvisionRecord(host.Name, e.Time)
+	}
+
+	// Files fetched by the installer don't move the host back to kernel
+	if state == model.ProvisionStateKernel && record.State == model.ProvisionStateInstalling {
+		state = model.ProvisionStateInstalling
+	}
+
+	record.Transition(state, e.IP, e.Message, e.Time)
+
+	return t.DB.StoreProvisionRecord(record)
+}
+
+// CheckStuck marks hosts which have been in the same state for longer than
+// the timeout as stuck. Each host is reported once per state.
+func (t *Tracker) CheckStuck(now time.Time) error {
+	if t.Timeout <= 0 {
+		return nil
+	}
+
+	records, err := t.DB.ProvisionRecords(nil)
+	if err != nil {
+		return err
+	}
+
+	for _, record := range records {
+		if record.Stuck || !record.IsStuck(t.Timeout, now) {
+			continue
+		}
+
+		log.WithFields(logrus.Fields{
+			logger.FieldHost: record.Name,
+			"state":          record.State,
+			"updated":        record.Updated,
+		}).Warn("Host is stuck provisioning")
+
+		record.Stuck = true
+		err := t.DB.StoreProvisionRecord(record)
+		if err != nil {
+			return err
+		}
+
+		events.Publish(&events.Event{
+			Service: "provision",
+			Type:    events.TypeProvisionStuck,
+			Host:    record.Name,
+			IP:      record.LastIP,
+			Message: "Stuck in state " + string(record.State) + " since " + record.Updated.Format(time.RFC3339),
+		})
+	}
+
+	return nil
+}
@@ -0,0 +1,103 @@
+// Copyright 2019 Grendel Authors. All rights reserved.
+//
+// This file is part of Grendel.
+//
+// Grendel is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Grendel is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with Grendel. If not, see <https://www.gnu.org/licenses/>.
+
+package provision
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/ubccr/grendel/events"
+	"github.com/ubccr/grendel/internal/tests"
+	"github.com/ubccr/grendel/model"
+)
+
+func TestTracker(t *testing.T) {
+	assert := assert.New(t)
+
+	db := newTestDB(t)
+	tracker := NewTracker(db, 10*time.Minute)
+
+	host := tests.HostFactory.MustCreate().(*model.Host)
+	host.Provision = true
+	err := db.StoreHost(host)
+	assert.NoError(err)
+
+	start := time.Now()
+	steps := []struct {
+		etype string
+		state model.ProvisionState
+	}{
+		{events.TypeHostChanged, model.ProvisionStatePending},
+		{events.TypeDHCPOffer, model.ProvisionStateDHCP},
+		{events.TypeDHCPAck, model.ProvisionStateDHCP},
+		{events.TypeTFTPFirmware, model.ProvisionStateFirmware},
+		{events.TypeProvisionIpxe, model.ProvisionStateIpxe},
+		{events.TypeProvisionFile, model.ProvisionStateKernel},
+		{events.TypeProvisionKickstart, model.ProvisionStateInstalling},
+		{events.TypeProvisionFile, model.ProvisionStateInstalling},
+	}
+
+	for i, step := range steps {
+		err := tracker.Handle(&events.Event{
+			Time: start.Add(time.Duration(i) * time.Second),
+			Type: step.etype,
+			Host: host.Name,
+			IP:   "10.0.0.1",
+		})
+		assert.NoError(err)
+
+		record, err := db.LoadProvisionRecord(host.Name)
+		if assert.NoError(err) {
+			assert.Equal(step.state, record.State, step.etype)
+		}
+	}
+
+	record, err := db.LoadProvisionRecord(host.Name)
+	if assert.NoError(err) {
+		assert.Len(record.History, 6)
+		assert.Equal("10.0.0.1", record.LastIP)
+		assert.True(start.Equal(record.Started))
+	}
+
+	err = tracker.CheckStuck(start.Add(time.Hour))
+	assert.NoError(err)
+
+	record, err = db.LoadProvisionRecord(host.Name)
+	if assert.NoError(err) {
+		assert.True(record.Stuck)
+	}
+
+	host.Provision = false
+	err = db.StoreHost(host)
+	assert.NoError(err)
+
+	err = tracker.Handle(&events.Event{Time: start.Add(time.Hour), Type: events.TypeProvisionComplete, Host: host.Name})
+	assert.NoError(err)
+
+	// Hosts not set to provision are ignored after completion
+	err = tracker.Handle(&events.Event{Time: start.Add(2 * time.Hour), Type: events.TypeDHCPAck, Host: host.Name})
+	assert.NoError(err)
+
+	record, err = db.LoadProvisionRecord(host.Name)
+	if assert.NoError(err) {
+		assert.Equal(model.ProvisionStateComplete, record.State)
+		assert.False(record.Stuck)
+		assert.False(record.IsStuck(time.Minute, start.Add(3*time.Hour)))
+	}
+}
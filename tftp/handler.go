@@ -27,22 +27,52 @@ import (
 	"strings"
 
 	"github.com/pin/tftp/v3"
+	"github.com/ubccr/grendel/events"
 	"github.com/ubccr/grendel/model"
 )
 
-func (s *Server) sendFile(fileName string, rf io.ReaderFrom) error {
+// publishEvent publishes a completed transfer on the event bus. Kernel and
+// initrd requests don't carry a token so the host is only known for firmware
+// transfers.
+func (s *Server) publishEvent(etype, mac string, rf io.ReaderFrom, msg string) {
+	e := &events.Event{
+		Service: "tftp",
+		Type:    etype,
+		MAC:     mac,
+		Message: msg,
+	}
+
+	if ot, ok := rf.(tftp.OutgoingTransfer); ok {
+		addr := ot.RemoteAddr()
+		e.IP = addr.IP.String()
+	}
+
+	if mac != "" {
+		host, err := s.DB.LoadHostFromMAC(mac)
+		if err == nil {
+			e.Host = host.Name
+			e.Tags = host.Tags
+		}
+	}
+
+	events.Publish(e)
+}
+
+func (s *Server) sendFile(fileName, etype string, rf io.ReaderFrom) error {
 	file, err := os.Open(fileName)
 	if err != nil {
 		log.Errorf("Failed to open %s: %s", fileName, err)
 		return err
 	}
 	n, err := rf.ReadFrom(file)
+	observeTransfer(strings.TrimPrefix(etype, "tftp."), "", n, err)
 	if err != nil {
 		log.Errorf("Failed to send %s via tftp: %s", fileName, err)
 		return err
 	}
 
 	log.Infof("Sent %s via tftp: %d bytes sent", fileName, n)
+	s.publishEvent(etype, "", rf, fmt.Sprintf("Sent %s: %d bytes", fileName, n))
 	return nil
 }
 
@@ -56,25 +86,27 @@ func (s *Server) imageFileHandler(filePath string, rf io.ReaderFrom) error {
 
 	switch {
 	case fileType == "kernel":
-		return s.sendFile(bootImage.KernelPath, rf)
+		return s.sendFile(bootImage.KernelPath, events.TypeTFTPKernel, rf)
 	case strings.HasPrefix(fileType, "initrd-"):
 		i, err := strconv.Atoi(fileType[7:])
 		if err != nil || i < 0 || i >= len(bootImage.InitrdPaths) {
 			return fmt.Errorf("no initrd with ID %q", i)
 		}
 		initrd := bootImage.InitrdPaths[i]
-		return s.sendFile(initrd, rf)
+		return s.sendFile(initrd, events.TypeTFTPInitrd, rf)
 	}
 
 	return fmt.Errorf("File not found: %s", filePath)
 }
 
 func (s *Server) ReadHandler(token string, rf io.ReaderFrom) error {
-	fwtype, err := model.ParseFirmwareToken(token)
+	claims, err := model.ParseFirmwareClaims(token)
 	if err != nil {
 		return s.imageFileHandler(token, rf)
 	}
 
+	fwtype := claims.Build
+
 	log.Infof("Got read request for firmware type: %d", fwtype)
 
 	bs := fwtype.ToBytes()
@@ -87,10 +119,18 @@ func (s *Server) ReadHandler(token string, rf io.ReaderFrom) error {
 	n, err := rf.ReadFrom(bytes.NewBuffer(bs))
 	if err != nil && !strings.Contains(err.Error(), "User aborted") {
 		log.Errorf("Failed to send firmware via tftp: %s", err)
+		observeTransfer("firmware", fwtype.String(), n, err)
 		return err
 	}
 
 	log.Infof("Sent firmware %d via tftp: %d bytes sent", fwtype, n)
 
+	// Clients abort the first request after receiving the transfer size so
+	// only publish completed transfers
+	if err == nil {
+		observeTransfer("firmware", fwtype.String(), n, nil)
+		s.publishEvent(events.TypeTFTPFirmware, claims.MAC, rf, fmt.Sprintf("Sent firmware %s: %d bytes", fwtype, n))
+	}
+
 	return nil
 }
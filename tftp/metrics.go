@@ -0,0 +1,50 @@
+// Copyright 2019 Grendel Authors. All rights reserved.
+//
+// This file is part of Grendel.
+//
+// Grendel is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Grendel is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with Grendel. If not, see <https://www.gnu.org/licenses/>.
+
+package tftp
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/ubccr/grendel/metrics"
+)
+
+var (
+	transfersTotal = metrics.Factory.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metrics.Namespace,
+		Subsystem: "tftp",
+		Name:      "transfers_total",
+		Help:      "TFTP transfers by file type (firmware, kernel or initrd), firmware build and result",
+	}, []string{"file", "build", "result"})
+
+	bytesTotal = metrics.Factory.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metrics.Namespace,
+		Subsystem: "tftp",
+		Name:      "bytes_total",
+		Help:      "Bytes sent via TFTP by file type (firmware, kernel or initrd) and firmware build",
+	}, []string{"file", "build"})
+)
+
+// observeTransfer counts a TFTP transfer and the bytes sent
+func observeTransfer(file, build string, n int64, err error) {
+	result := "ok"
+	if err != nil {
+		result = "error"
+	}
+
+	transfersTotal.WithLabelValues(file, build, result).Inc()
+	bytesTotal.WithLabelValues(file, build).Add(float64(n))
+}
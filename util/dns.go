@@ -5,7 +5,9 @@
 package util
 
 import (
+	"fmt"
 	"net"
+	"net/netip"
 	"strings"
 
 	"github.com/miekg/dns"
@@ -39,6 +41,46 @@ func ExtractAddressFromReverse(reverseName string) string {
 	return f(strings.Split(search, "."))
 }
 
+// ReverseZone returns the in-addr.arpa or ip6.arpa zone name of the prefix.
+// The prefix length must fall on an octet boundary for IPv4 and a nibble
+// boundary for IPv6.
+//
+// 10.1.0.0/16 becomes 1.10.in-addr.arpa.
+func ReverseZone(prefix netip.Prefix) (string, error) {
+	prefix = prefix.Masked()
+	addr := prefix.Addr()
+
+	var labels []string
+	switch {
+	case addr.Is4():
+		if prefix.Bits()%8 != 0 {
+			return "", fmt.Errorf("reverse zone %s must have a prefix length divisible by 8", prefix)
+		}
+
+		b := addr.As4()
+		for i := prefix.Bits()/8 - 1; i >= 0; i-- {
+			labels = append(labels, fmt.Sprintf("%d", b[i]))
+		}
+		labels = append(labels, IP4arpa[1:])
+	default:
+		if prefix.Bits()%4 != 0 {
+			return "", fmt.Errorf("reverse zone %s must have a prefix length divisible by 4", prefix)
+		}
+
+		b := addr.As16()
+		for i := prefix.Bits()/4 - 1; i >= 0; i-- {
+			nibble := b[i/2] >> 4
+			if i%2 == 1 {
+				nibble = b[i/2] & 0x0f
+			}
+			labels = append(labels, fmt.Sprintf("%x", nibble))
+		}
+		labels = append(labels, IP6arpa[1:])
+	}
+
+	return strings.Join(labels, "."), nil
+}
+
 // IsReverse returns 0 is name is not in a reverse zone. Anything > 0 indicates
 // name is in a reverse zone. The returned integer will be 1 for in-addr.arpa. (IPv4)
 // and 2 for ip6.arpa. (IPv6).
@@ -116,7 +116,7 @@ func GetInterfaceFromIP(ip net.IP) (string, net.IPMask, error) {
 				mask = v.Mask
 			}
 
-			if i.To4() != nil && i.To4().Equal(ip) {
+			if i != nil && i.Equal(ip) {
 				return intf.Name, mask, nil
 			}
 		}
@@ -125,3 +125,75 @@ func GetInterfaceFromIP(ip net.IP) (string, net.IPMask, error) {
 
 	return "", nil, fmt.Errorf("Interface not found with ip: %s", ip)
 }
+
+// getExternalIPv6Addrs returns the global unicast IPv6 addresses in addrs
+func getExternalIPv6Addrs(addrs []net.Addr) []net.IP {
+	ips := make([]net.IP, 0)
+	for _, addr := range addrs {
+		var ip net.IP
+		switch v := addr.(type) {
+		case *net.IPAddr:
+			ip = v.IP
+		case *net.IPNet:
+			ip = v.IP
+		}
+
+		if ip == nil || ip.To4() != nil || !ip.IsGlobalUnicast() {
+			continue
+		}
+
+		ips = append(ips, ip)
+	}
+
+	return ips
+}
+
+// GetFirstExternalIPv6FromInterfaces returns the first global unicast IPv6
+// address of the non loopback interfaces
+func GetFirstExternalIPv6FromInterfaces() (net.IP, error) {
+	intfs, err := interfaces.GetNonLoopbackInterfaces()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, intf := range intfs {
+		addrs, err := intf.Addrs()
+		if err != nil {
+			return nil, err
+		}
+
+		ips := getExternalIPv6Addrs(addrs)
+		if len(ips) > 0 {
+			return ips[0], nil
+		}
+	}
+
+	return nil, errors.New("Failed to find server IPv6 address from configured interfaces")
+}
+
+// GetInterfaceIPv6Map returns the first global unicast IPv6 address of each
+// non loopback interface keyed by interface index
+func GetInterfaceIPv6Map() (map[int]net.IP, error) {
+	intfs, err := interfaces.GetNonLoopbackInterfaces()
+	if err != nil {
+		return nil, err
+	}
+
+	intfIps := make(map[int]net.IP, 0)
+
+	for _, intf := range intfs {
+		addrs, err := intf.Addrs()
+		if err != nil {
+			return nil, err
+		}
+
+		ips := getExternalIPv6Addrs(addrs)
+		if len(ips) == 0 {
+			continue
+		}
+
+		intfIps[intf.Index] = ips[0]
+	}
+
+	return intfIps, nil
+}